@@ -16,25 +16,178 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"slices"
+	"strings"
+	"unicode"
 )
 
+// entrypointSeparator separates the own arguments of secret-init from the entrypoint, e.g. secret-init -- myapp arg
+const entrypointSeparator = "--"
+
+// versionCommands print the build info instead of running an entrypoint
+var versionCommands = []string{"version", "--version"}
+
 // ExtractEntrypoint extracts entrypoint data in the form of binary path and its arguments from the
 // os.Args. Note that the path to the binary will be returned as the first element.
+// Everything after a -- separator is the entrypoint, the flags before it are left to secret-init (see splitArgs).
+// A single entrypoint argument after the separator is split shell-style, e.g. -- "myapp --name 'my app'".
 func ExtractEntrypoint(args []string) (string, []string, error) {
 	if len(args) <= 1 {
 		return "", nil, fmt.Errorf("no args provided")
 	}
 
-	binaryPath, err := exec.LookPath(args[1])
+	_, entrypoint, err := splitArgs(args)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(entrypoint) == 0 {
+		return "", nil, fmt.Errorf("no entrypoint provided")
+	}
+
+	binary := entrypoint[0]
+	binaryPath, err := exec.LookPath(binary)
+	if err != nil {
+		// LookPath rejects existing files without execute permission,
+		// report those explicitly instead of a misleading "not found"
+		if fileInfo, statErr := os.Stat(binary); statErr == nil && !fileInfo.IsDir() {
+			return "", nil, fmt.Errorf("binary %s is not executable", binary)
+		}
+
+		// Binaries without a path are looked up in $PATH, which may not be what the image author expects
+		if !strings.Contains(binary, "/") {
+			return "", nil, fmt.Errorf("binary %s not found in $PATH (%s)", binary, os.Getenv("PATH"))
+		}
+
+		return "", nil, fmt.Errorf("binary %s not found", binary)
+	}
+
+	if err := checkExecutable(binaryPath); err != nil {
+		return "", nil, fmt.Errorf("binary %s is not executable", binary)
+	}
+
+	return binaryPath, entrypoint[1:], nil // returns the arguments for the binary
+}
+
+// splitArgs splits the os.Args into the flags of secret-init and the entrypoint.
+// Only flags may precede the separator and only --print is honoured, settings are read from the environment,
+// a -- after the binary belongs to the arguments of the entrypoint.
+// Without a separator, the arguments are either all flags (e.g. --print) or all the entrypoint.
+func splitArgs(args []string) ([]string, []string, error) {
+	if len(args) <= 1 {
+		return nil, nil, nil
+	}
+
+	args = args[1:]
+	separator := slices.IndexFunc(args, func(arg string) bool {
+		return !strings.HasPrefix(arg, "-") || arg == entrypointSeparator
+	})
+
+	switch {
+	case separator < 0:
+		return args, nil, nil
+	case args[separator] == entrypointSeparator:
+		if separator == len(args)-1 {
+			return nil, nil, fmt.Errorf("no entrypoint provided after %s", entrypointSeparator)
+		}
+
+		flags, entrypoint := args[:separator], args[separator+1:]
+
+		// Paths containing whitespace are left alone
+		_, statErr := os.Stat(entrypoint[0])
+		if len(entrypoint) == 1 && strings.ContainsAny(entrypoint[0], " \t") && statErr != nil {
+			var err error
+			entrypoint, err = splitShellWords(entrypoint[0])
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to split entrypoint: %w", err)
+			}
+		}
+
+		return flags, entrypoint, nil
+	case separator == 0:
+		return nil, args, nil
+	default:
+		return nil, nil, fmt.Errorf("missing %s separator between the arguments of secret-init and the entrypoint", entrypointSeparator)
+	}
+}
+
+// splitShellWords splits a command line the way a shell would, without any expansion.
+// Words are separated by unquoted whitespace, single quotes keep everything literally,
+// double quotes keep whitespace and backslashes escape the next character.
+func splitShellWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			// Within double quotes, only quotes and backslashes are escaped
+			if quote == '"' && r != '"' && r != '\\' {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty command line")
+	}
+
+	return words, nil
+}
+
+// checkExecutable makes sure the path is a regular file with at least one execute bit set.
+func checkExecutable(path string) error {
+	fileInfo, err := os.Stat(path)
 	if err != nil {
-		return "", nil, fmt.Errorf("binary %s not found", args[1])
+		return err
 	}
 
-	var binaryArgs []string
-	if len(args) >= 2 {
-		binaryArgs = args[2:] // returns the arguments for the binary
+	if fileInfo.IsDir() || fileInfo.Mode().Perm()&0o111 == 0 {
+		return os.ErrPermission
 	}
 
-	return binaryPath, binaryArgs, nil
+	return nil
 }
@@ -16,7 +16,9 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -29,6 +31,15 @@ func TestExtractEntrypoint(t *testing.T) {
 		t.Fatalf("failed to find path of env binary: %v", err)
 	}
 
+	scriptDir := t.TempDir()
+	nonExecutableScript := filepath.Join(scriptDir, "non-executable.sh")
+	err = os.WriteFile(nonExecutableScript, []byte("#!/bin/sh\necho test\n"), 0o644)
+	assert.Nil(t, err, "Failed to create non-executable script")
+
+	executableScript := filepath.Join(scriptDir, "executable.sh")
+	err = os.WriteFile(executableScript, []byte("#!/bin/sh\necho test\n"), 0o755)
+	assert.Nil(t, err, "Failed to create executable script")
+
 	tests := []struct {
 		name               string
 		args               []string
@@ -48,6 +59,51 @@ func TestExtractEntrypoint(t *testing.T) {
 			expectedBinaryPath: envPath,
 			expectedBinaryArgs: []string{"|", "grep", "secrets"},
 		},
+		{
+			name:               "Valid case with executable script",
+			args:               []string{"secret-init", executableScript, "arg"},
+			expectedBinaryPath: executableScript,
+			expectedBinaryArgs: []string{"arg"},
+		},
+		{
+			name:               "Valid case with separator",
+			args:               []string{"secret-init", "--", "env", "arg"},
+			expectedBinaryPath: envPath,
+			expectedBinaryArgs: []string{"arg"},
+		},
+		{
+			name:               "Valid case with flags before separator",
+			args:               []string{"secret-init", "--daemon", "--", "env", "--", "-i"},
+			expectedBinaryPath: envPath,
+			expectedBinaryArgs: []string{"--", "-i"},
+		},
+		{
+			name:               "Valid case with quoted entrypoint after separator",
+			args:               []string{"secret-init", "--", `env -u 'MY VAR' "a b"`},
+			expectedBinaryPath: envPath,
+			expectedBinaryArgs: []string{"-u", "MY VAR", "a b"},
+		},
+		{
+			name:               "Valid case with separator in the entrypoint arguments",
+			args:               []string{"secret-init", "env", "--", "-i"},
+			expectedBinaryPath: envPath,
+			expectedBinaryArgs: []string{"--", "-i"},
+		},
+		{
+			name: "Invalid case - flags without separator",
+			args: []string{"secret-init", "--daemon", "env"},
+			err:  fmt.Errorf("missing -- separator between the arguments of secret-init and the entrypoint"),
+		},
+		{
+			name: "Invalid case - no entrypoint after separator",
+			args: []string{"secret-init", "--daemon", "--"},
+			err:  fmt.Errorf("no entrypoint provided after --"),
+		},
+		{
+			name: "Invalid case - flags without entrypoint",
+			args: []string{"secret-init", "--daemon"},
+			err:  fmt.Errorf("no entrypoint provided"),
+		},
 		{
 			name: "Invalid case - no arguments",
 			args: []string{"secret-init"},
@@ -56,7 +112,17 @@ func TestExtractEntrypoint(t *testing.T) {
 		{
 			name: "Invalid case - binary not found",
 			args: []string{"secret-init", "nonexistentBinary"},
-			err:  fmt.Errorf("binary nonexistentBinary not found"),
+			err:  fmt.Errorf("binary nonexistentBinary not found in $PATH (%s)", os.Getenv("PATH")),
+		},
+		{
+			name: "Invalid case - binary path not found",
+			args: []string{"secret-init", "/nonexistent/binary"},
+			err:  fmt.Errorf("binary /nonexistent/binary not found"),
+		},
+		{
+			name: "Invalid case - script without execute permission",
+			args: []string{"secret-init", nonExecutableScript},
+			err:  fmt.Errorf("binary %s is not executable", nonExecutableScript),
 		},
 	}
 
@@ -64,12 +130,115 @@ func TestExtractEntrypoint(t *testing.T) {
 		ttp := tt
 		t.Run(ttp.name, func(t *testing.T) {
 			binaryPath, binaryArgs, err := ExtractEntrypoint(ttp.args)
-			if err != nil {
-				assert.EqualError(t, ttp.err, err.Error(), "Unexpected error message")
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
 			} else {
+				assert.NoError(t, err, "Unexpected error")
 				assert.Equal(t, ttp.expectedBinaryPath, binaryPath, "Unexpected binary path")
 				assert.Equal(t, ttp.expectedBinaryArgs, binaryArgs, "Unexpected binary args")
 			}
 		})
 	}
 }
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		wantFlags      []string
+		wantEntrypoint []string
+		err            error
+	}{
+		{
+			name:           "Entrypoint only",
+			args:           []string{"secret-init", "myapp", "--", "-i"},
+			wantEntrypoint: []string{"myapp", "--", "-i"},
+		},
+		{
+			name:           "Flags and entrypoint",
+			args:           []string{"secret-init", "--daemon", "--log-level=debug", "--", "myapp", "arg1"},
+			wantFlags:      []string{"--daemon", "--log-level=debug"},
+			wantEntrypoint: []string{"myapp", "arg1"},
+		},
+		{
+			name:           "Quoted entrypoint",
+			args:           []string{"secret-init", "--print", "--", "myapp --name 'my app'"},
+			wantFlags:      []string{"--print"},
+			wantEntrypoint: []string{"myapp", "--name", "my app"},
+		},
+		{
+			name:      "Flags only",
+			args:      []string{"secret-init", "--print"},
+			wantFlags: []string{"--print"},
+		},
+		{
+			name: "Flags without separator",
+			args: []string{"secret-init", "--daemon", "myapp"},
+			err:  fmt.Errorf("missing -- separator between the arguments of secret-init and the entrypoint"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			flags, entrypoint, err := splitArgs(ttp.args)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantFlags, flags, "Unexpected flags")
+			assert.Equal(t, ttp.wantEntrypoint, entrypoint, "Unexpected entrypoint")
+		})
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantWords []string
+		err       error
+	}{
+		{
+			name:      "Plain words",
+			line:      "  myapp  --port 8080 ",
+			wantWords: []string{"myapp", "--port", "8080"},
+		},
+		{
+			name:      "Quoted words",
+			line:      `myapp --name 'my app' "it's" ""`,
+			wantWords: []string{"myapp", "--name", "my app", "it's", ""},
+		},
+		{
+			name:      "Escaped characters",
+			line:      `myapp my\ app "C:\dir" "say \"hi\""`,
+			wantWords: []string{"myapp", "my app", `C:\dir`, `say "hi"`},
+		},
+		{
+			name: "Unterminated quote",
+			line: `myapp 'my app`,
+			err:  fmt.Errorf("unterminated ' quote"),
+		},
+		{
+			name: "Trailing backslash",
+			line: `myapp \`,
+			err:  fmt.Errorf("trailing backslash"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			words, err := splitShellWords(ttp.line)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantWords, words, "Unexpected words")
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const (
+	auditMessage = "secret injected"
+
+	// Long enough to detect tampering, short enough to not be mistaken for the value itself
+	auditHashLength = 16
+)
+
+// logAudit records each injected secret along with the provider and reference it came from.
+// Values are never recorded, only a truncated SHA-256 of them.
+// Secrets without a reference of their own (e.g. from VAULT_FROM_PATH or expanded JSON)
+// are recorded without provider and reference.
+func logAudit(logger *slog.Logger, secretReferences map[string][]string, secrets []provider.Secret) {
+	if logger == nil {
+		return
+	}
+
+	type origin struct {
+		provider  string
+		reference string
+	}
+
+	origins := make(map[string]origin)
+	for providerName, paths := range secretReferences {
+		for _, path := range paths {
+			key, reference, _ := strings.Cut(path, "=")
+			origins[key] = origin{provider: providerName, reference: reference}
+		}
+	}
+
+	for _, secret := range secrets {
+		hash := sha256.Sum256([]byte(secret.Value))
+
+		logger.Info(auditMessage,
+			slog.String("key", secret.Key),
+			slog.String("provider", origins[secret.Key].provider),
+			slog.String("reference", origins[secret.Key].reference),
+			slog.String("sha256", hex.EncodeToString(hash[:])[:auditHashLength]),
+		)
+	}
+}
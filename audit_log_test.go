@@ -0,0 +1,73 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+
+	logAudit(slog.New(newEventHandler(&buf)), map[string][]string{
+		"vault": {"DB_PASSWORD=vault:secret/data/db#password"},
+		"file":  {"DB_USER=file:secret/db/user"},
+	}, []provider.Secret{
+		{Key: "DB_PASSWORD", Value: "s3cr3t"},
+		{Key: "DB_USER", Value: "root"},
+		{Key: "FROM_PATH_SECRET", Value: "p@ss"},
+	})
+
+	for _, value := range []string{"s3cr3t", "root", "p@ss"} {
+		assert.NotContains(t, buf.String(), value, "Secret value must not be logged")
+	}
+
+	entries := make(map[string]map[string]any)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry map[string]any
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &entry), "Entry is not valid JSON")
+		assert.Equal(t, auditMessage, entry["msg"])
+		assert.Contains(t, entry, "time")
+		entries[entry["key"].(string)] = entry
+	}
+	assert.Len(t, entries, 3, "Unexpected number of entries")
+
+	// sha256("s3cr3t") = 4e738ca5563c06cfd0018299933d58db1dd8bf97f6973dc99bf6cdc64b5550bd
+	assert.Equal(t, "vault", entries["DB_PASSWORD"]["provider"])
+	assert.Equal(t, "vault:secret/data/db#password", entries["DB_PASSWORD"]["reference"])
+	assert.Equal(t, "4e738ca5563c06cf", entries["DB_PASSWORD"]["sha256"])
+
+	assert.Equal(t, "file", entries["DB_USER"]["provider"])
+	assert.Equal(t, "file:secret/db/user", entries["DB_USER"]["reference"])
+	assert.Len(t, entries["DB_USER"]["sha256"], 16)
+
+	assert.Equal(t, "", entries["FROM_PATH_SECRET"]["provider"])
+	assert.Equal(t, "", entries["FROM_PATH_SECRET"]["reference"])
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	assert.NotPanics(t, func() {
+		logAudit(nil, nil, []provider.Secret{{Key: "DB_PASSWORD", Value: "s3cr3t"}})
+	})
+}
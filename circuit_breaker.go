@@ -0,0 +1,133 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// circuitBreaker skips providers failing repeatedly, so they don't fail every reload.
+// After threshold consecutive failures the breaker of a provider opens: the provider is skipped
+// for the cooldown and the secrets it loaded before are kept. Once the cooldown is over
+// a single load is let through, a success closes the breaker and a failure opens it again.
+// The breaker of a provider never opens before its first successful load, there are no secrets to keep then.
+// It is only created when SECRET_INIT_CB_THRESHOLD is set, a nil *circuitBreaker never opens.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	states map[string]*breakerState
+}
+
+// breakerState tracks a provider, a zero openedAt means the breaker is closed.
+type breakerState struct {
+	failures int
+	openedAt time.Time
+	// secrets are the ones loaded by the last successful load, kept while the breaker is open
+	secrets []provider.Secret
+	// loaded is set once the provider loaded successfully
+	loaded bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, now func() time.Time) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       now,
+		states:    make(map[string]*breakerState),
+	}
+}
+
+// allow reports whether the provider may be loaded, i.e. its breaker is closed or its cooldown is over.
+func (b *circuitBreaker) allow(providerName string) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.states[providerName]
+	if !ok || state.openedAt.IsZero() {
+		return true
+	}
+
+	return b.now().Sub(state.openedAt) >= b.cooldown
+}
+
+// succeeded closes the breaker of the provider and keeps its secrets for the time it may be open.
+func (b *circuitBreaker) succeeded(providerName string, secrets []provider.Secret) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.states[providerName] = &breakerState{secrets: slices.Clone(secrets), loaded: true}
+}
+
+// failed records a failure of the provider, it reports whether the breaker is open after it.
+// Failures of a provider which never loaded successfully keep failing the load, whatever their number.
+func (b *circuitBreaker) failed(providerName string) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.states[providerName]
+	if !ok {
+		state = &breakerState{}
+		b.states[providerName] = state
+	}
+
+	state.failures++
+	if state.failures < b.threshold || !state.loaded {
+		return false
+	}
+
+	state.openedAt = b.now()
+
+	return true
+}
+
+// keptSecrets returns the secrets the provider loaded before its breaker opened.
+func (b *circuitBreaker) keptSecrets(providerName string) []provider.Secret {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.states[providerName]
+	if !ok {
+		return nil
+	}
+
+	return slices.Clone(state.secrets)
+}
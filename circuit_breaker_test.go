@@ -0,0 +1,167 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := newCircuitBreaker(2, time.Minute, func() time.Time { return now })
+	secrets := []provider.Secret{{Key: "DB_PASSWORD", Value: "s3cr3t"}}
+
+	breaker.succeeded("flaky", secrets)
+	assert.False(t, breaker.failed("flaky"), "Breaker must stay closed below the threshold")
+	assert.True(t, breaker.allow("flaky"), "Closed breaker must allow loads")
+
+	assert.True(t, breaker.failed("flaky"), "Breaker must open at the threshold")
+	assert.False(t, breaker.allow("flaky"), "Open breaker must skip loads")
+	assert.Equal(t, secrets, breaker.keptSecrets("flaky"), "Unexpected kept secrets")
+
+	// A single load is let through after the cooldown, failing it opens the breaker again
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.allow("flaky"), "Breaker must allow a load after the cooldown")
+	assert.True(t, breaker.failed("flaky"), "Breaker must open again on a failure after the cooldown")
+	assert.False(t, breaker.allow("flaky"), "Reopened breaker must skip loads")
+
+	now = now.Add(time.Minute)
+	breaker.succeeded("flaky", nil)
+	assert.True(t, breaker.allow("flaky"), "Breaker must close on a success")
+	assert.False(t, breaker.failed("flaky"), "Closed breaker must count the failures again")
+
+	assert.True(t, breaker.allow("other"), "Breakers must be kept per provider")
+
+	// Without a successful load there is nothing to keep, the breaker stays closed
+	assert.False(t, breaker.failed("other"), "Breaker must not open before a successful load")
+	assert.False(t, breaker.failed("other"), "Breaker must not open before a successful load")
+	assert.True(t, breaker.allow("other"), "Breaker must allow loads before a successful load")
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker := newCircuitBreaker(0, time.Minute, time.Now)
+
+	assert.Nil(t, breaker, "Breaker must be disabled without a threshold")
+	assert.False(t, breaker.failed("flaky"), "Disabled breaker must never open")
+	assert.True(t, breaker.allow("flaky"), "Disabled breaker must allow loads")
+}
+
+// flakyProvider serves its references as values unless it is set to fail
+type flakyProvider struct {
+	err   error
+	loads int
+}
+
+func (p *flakyProvider) GetProviderName() string {
+	return "flaky"
+}
+
+func (p *flakyProvider) LoadSecrets(_ context.Context, paths []string) ([]provider.Secret, error) {
+	p.loads++
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	var secrets []provider.Secret
+	for _, path := range paths {
+		key, reference, _ := strings.Cut(path, "=")
+		secrets = append(secrets, provider.Secret{Key: key, Value: strings.TrimPrefix(reference, "flaky:")})
+	}
+
+	return secrets, nil
+}
+
+func TestEnvStore_LoadProviderSecretsCircuitBreaker(t *testing.T) {
+	flakyProvider := &flakyProvider{}
+	envStore := NewEnvStore(&common.Config{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	envStore.RegisterFactory(provider.Factory{
+		ProviderType: "flaky",
+		Validator:    func(envValue string) bool { return strings.HasPrefix(envValue, "flaky:") },
+		Create: func(_ context.Context, _ *common.Config) (provider.Provider, error) {
+			return flakyProvider, nil
+		},
+	})
+
+	now := time.Now()
+	envStore.breaker.now = func() time.Time { return now }
+
+	paths := map[string][]string{"flaky": {"DB_PASSWORD=flaky:s3cr3t"}}
+	wantSecrets := []provider.Secret{{Key: "DB_PASSWORD", Value: "s3cr3t"}}
+
+	secrets, err := envStore.LoadProviderSecrets(context.Background(), paths)
+	require.NoError(t, err)
+	assert.Equal(t, wantSecrets, secrets, "Unexpected secrets")
+
+	// Failures below the threshold fail the load as usual
+	flakyProvider.err = errors.New("service unavailable")
+	_, err = envStore.LoadProviderSecrets(context.Background(), paths)
+	assert.EqualError(t, err, "failed to load secrets for provider flaky: service unavailable", "Unexpected error message")
+
+	// The failure opening the breaker keeps the secrets loaded before
+	secrets, err = envStore.LoadProviderSecrets(context.Background(), paths)
+	require.NoError(t, err)
+	assert.Equal(t, wantSecrets, secrets, "Unexpected secrets")
+	assert.Equal(t, 3, flakyProvider.loads, "Unexpected number of loads")
+
+	// The provider is skipped while the breaker is open
+	secrets, err = envStore.LoadProviderSecrets(context.Background(), paths)
+	require.NoError(t, err)
+	assert.Equal(t, wantSecrets, secrets, "Unexpected secrets")
+	assert.Equal(t, 3, flakyProvider.loads, "Provider must be skipped while the breaker is open")
+
+	// A success after the cooldown closes the breaker
+	now = now.Add(time.Minute)
+	flakyProvider.err = nil
+	paths = map[string][]string{"flaky": {"DB_PASSWORD=flaky:r0tated"}}
+	secrets, err = envStore.LoadProviderSecrets(context.Background(), paths)
+	require.NoError(t, err)
+	assert.Equal(t, []provider.Secret{{Key: "DB_PASSWORD", Value: "r0tated"}}, secrets, "Unexpected secrets")
+	assert.Equal(t, 4, flakyProvider.loads, "Unexpected number of loads")
+
+	flakyProvider.err = errors.New("service unavailable")
+	_, err = envStore.LoadProviderSecrets(context.Background(), paths)
+	assert.EqualError(t, err, "failed to load secrets for provider flaky: service unavailable", "Closed breaker must count the failures again")
+}
+
+func TestEnvStore_LoadProviderSecretsCircuitBreakerFirstLoad(t *testing.T) {
+	flakyProvider := &flakyProvider{err: errors.New("service unavailable")}
+	envStore := NewEnvStore(&common.Config{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	envStore.RegisterFactory(provider.Factory{
+		ProviderType: "flaky",
+		Validator:    func(envValue string) bool { return strings.HasPrefix(envValue, "flaky:") },
+		Create: func(_ context.Context, _ *common.Config) (provider.Provider, error) {
+			return flakyProvider, nil
+		},
+	})
+
+	paths := map[string][]string{"flaky": {"DB_PASSWORD=flaky:s3cr3t"}}
+
+	// The failures of the initial load are never swallowed, there are no secrets to keep
+	for range 2 {
+		_, err := envStore.LoadProviderSecrets(context.Background(), paths)
+		assert.EqualError(t, err, "failed to load secrets for provider flaky: service unavailable", "Unexpected error message")
+	}
+	assert.Equal(t, 2, flakyProvider.loads, "Provider must not be skipped before a successful load")
+}
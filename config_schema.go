@@ -0,0 +1,126 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+)
+
+// configSchemaCommand is a hidden subcommand, it is not meant to be used as an entrypoint
+const configSchemaCommand = "config-schema"
+
+type configSchema struct {
+	Schema     string                    `json:"$schema"`
+	Title      string                    `json:"title"`
+	Type       string                    `json:"type"`
+	Properties map[string]schemaProperty `json:"properties"`
+}
+
+type schemaProperty struct {
+	Type    string `json:"type"`
+	Format  string `json:"format,omitempty"`
+	Default string `json:"default,omitempty"`
+	// Provider is the provider the variable configures, empty for application settings
+	Provider string `json:"x-provider,omitempty"`
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	signalType   = reflect.TypeOf(syscall.Signal(0))
+)
+
+// newConfigSchema builds a JSON schema of the recognized environment variables.
+// Application settings are reflected from the env tags of common.Config,
+// provider settings are taken from the provider factories.
+func newConfigSchema() (*configSchema, error) {
+	schema := &configSchema{
+		Schema:     "https://json-schema.org/draft/2020-12/schema",
+		Title:      "secret-init configuration",
+		Type:       "object",
+		Properties: make(map[string]schemaProperty),
+	}
+
+	configType := reflect.TypeOf(common.Config{})
+	for i := range configType.NumField() {
+		field := configType.Field(i)
+
+		env, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+
+		property, err := newSchemaProperty(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe %s: %w", env, err)
+		}
+		property.Default = field.Tag.Get("default")
+
+		schema.Properties[env] = property
+	}
+
+	// Provider settings are passed to the provider SDKs as is, so they are described as plain strings
+	for _, factory := range factories {
+		for _, env := range factory.EnvVars {
+			schema.Properties[env] = schemaProperty{
+				Type:     "string",
+				Provider: factory.ProviderType,
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+func newSchemaProperty(fieldType reflect.Type) (schemaProperty, error) {
+	switch {
+	case fieldType == durationType:
+		return schemaProperty{Type: "string", Format: "duration"}, nil
+	case fieldType == signalType:
+		return schemaProperty{Type: "string", Format: "signal"}, nil
+	case fieldType.Kind() == reflect.Slice && (fieldType.Elem().Kind() == reflect.String || fieldType.Elem().Kind() == reflect.Int):
+		// Lists are set as comma-separated strings
+		return schemaProperty{Type: "string", Format: "list"}, nil
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		return schemaProperty{Type: "string"}, nil
+	case reflect.Bool:
+		return schemaProperty{Type: "boolean"}, nil
+	case reflect.Int:
+		return schemaProperty{Type: "integer"}, nil
+	default:
+		return schemaProperty{}, fmt.Errorf("unsupported type %s", fieldType)
+	}
+}
+
+func printConfigSchema(w io.Writer) error {
+	schema, err := newConfigSchema()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(schema)
+}
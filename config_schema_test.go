@@ -0,0 +1,125 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+)
+
+func TestPrintConfigSchema(t *testing.T) {
+	var buf bytes.Buffer
+	err := printConfigSchema(&buf)
+	require.NoError(t, err)
+
+	var schema configSchema
+	err = json.Unmarshal(buf.Bytes(), &schema)
+	require.NoError(t, err, "Schema is not valid JSON")
+
+	tests := []struct {
+		env          string
+		wantProperty schemaProperty
+	}{
+		{
+			env:          common.LogLevelEnv,
+			wantProperty: schemaProperty{Type: "string"},
+		},
+		{
+			env:          common.DaemonEnv,
+			wantProperty: schemaProperty{Type: "boolean"},
+		},
+		{
+			env:          common.RetryMaxAttemptsEnv,
+			wantProperty: schemaProperty{Type: "integer", Default: "1"},
+		},
+		{
+			env:          common.StopTimeoutEnv,
+			wantProperty: schemaProperty{Type: "string", Format: "duration", Default: "10s"},
+		},
+		{
+			env:          common.StopSignalEnv,
+			wantProperty: schemaProperty{Type: "string", Format: "signal", Default: "SIGTERM"},
+		},
+		{
+			env:          common.IgnoreEnvEnv,
+			wantProperty: schemaProperty{Type: "string", Format: "list"},
+		},
+		{
+			env:          "VAULT_ADDR",
+			wantProperty: schemaProperty{Type: "string", Provider: "vault"},
+		},
+		{
+			env:          "BAO_TOKEN_FILE",
+			wantProperty: schemaProperty{Type: "string", Provider: "bao"},
+		},
+		{
+			env:          "AWS_REGION",
+			wantProperty: schemaProperty{Type: "string", Provider: "aws"},
+		},
+		{
+			env:          "FILE_MOUNT_PATH",
+			wantProperty: schemaProperty{Type: "string", Provider: "file"},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.env, func(t *testing.T) {
+			property, ok := schema.Properties[ttp.env]
+			assert.True(t, ok, "Env var not found in schema")
+			assert.Equal(t, ttp.wantProperty, property, "Unexpected property")
+		})
+	}
+}
+
+// The schema defaults are declared in tags, make sure they don't drift from the loaded ones.
+func TestConfigSchemaDefaults(t *testing.T) {
+	os.Clearenv()
+
+	config, err := common.LoadConfig()
+	require.NoError(t, err)
+
+	configValue := reflect.ValueOf(*config)
+	for i := range configValue.NumField() {
+		field := configValue.Type().Field(i)
+
+		defaultValue, ok := field.Tag.Lookup("default")
+		if !ok {
+			assert.True(t, configValue.Field(i).IsZero(), "%s has an undeclared default", field.Name)
+			continue
+		}
+
+		switch value := configValue.Field(i).Interface().(type) {
+		case syscall.Signal:
+			assert.Equal(t, defaultValue, unix.SignalName(value), "Unexpected default for %s", field.Name)
+		case fmt.Stringer:
+			assert.Equal(t, defaultValue, value.String(), "Unexpected default for %s", field.Name)
+		default:
+			assert.Equal(t, defaultValue, cast.ToString(value), "Unexpected default for %s", field.Name)
+		}
+	}
+}
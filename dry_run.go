@@ -0,0 +1,69 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+type dryRunReference struct {
+	Provider  string `json:"provider"`
+	Key       string `json:"key"`
+	Reference string `json:"reference"`
+}
+
+// printSecretReferences writes a line per detected secret reference, sorted by provider and key.
+// Only references are known at this point, so no secret value can end up in the output.
+func printSecretReferences(w io.Writer, secretReferences map[string][]string, jsonOutput bool) error {
+	var references []dryRunReference
+	for providerName, paths := range secretReferences {
+		for _, path := range paths {
+			key, reference, _ := strings.Cut(path, "=")
+			references = append(references, dryRunReference{
+				Provider:  providerName,
+				Key:       key,
+				Reference: reference,
+			})
+		}
+	}
+
+	slices.SortFunc(references, func(a, b dryRunReference) int {
+		if c := strings.Compare(a.Provider, b.Provider); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, reference := range references {
+		var err error
+		if jsonOutput {
+			err = encoder.Encode(reference)
+		} else {
+			_, err = fmt.Fprintf(w, "provider=%s key=%s reference=%q\n", reference.Provider, reference.Key, reference.Reference)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to print secret reference: %w", err)
+		}
+	}
+
+	return nil
+}
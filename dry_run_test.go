@@ -0,0 +1,68 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintSecretReferences(t *testing.T) {
+	secretReferences := map[string][]string{
+		"vault": {
+			"MYSQL_PASSWORD=vault:secret/data/test/mysql#MYSQL_PASSWORD",
+			"AWS_SECRET_ACCESS_KEY=vault:secret/data/test/aws#AWS_SECRET_ACCESS_KEY",
+		},
+		"file": {
+			"AWS_SECRET_ACCESS_KEY_ID=file:secret/data/test/aws",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		jsonOutput bool
+		wantOutput string
+	}{
+		{
+			name: "Text output",
+			wantOutput: `provider=file key=AWS_SECRET_ACCESS_KEY_ID reference="file:secret/data/test/aws"
+provider=vault key=AWS_SECRET_ACCESS_KEY reference="vault:secret/data/test/aws#AWS_SECRET_ACCESS_KEY"
+provider=vault key=MYSQL_PASSWORD reference="vault:secret/data/test/mysql#MYSQL_PASSWORD"
+`,
+		},
+		{
+			name:       "JSON output",
+			jsonOutput: true,
+			wantOutput: `{"provider":"file","key":"AWS_SECRET_ACCESS_KEY_ID","reference":"file:secret/data/test/aws"}
+{"provider":"vault","key":"AWS_SECRET_ACCESS_KEY","reference":"vault:secret/data/test/aws#AWS_SECRET_ACCESS_KEY"}
+{"provider":"vault","key":"MYSQL_PASSWORD","reference":"vault:secret/data/test/mysql#MYSQL_PASSWORD"}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := printSecretReferences(&buf, secretReferences, ttp.jsonOutput)
+			assert.Nil(t, err, "Unexpected error")
+
+			assert.Equal(t, ttp.wantOutput, buf.String(), "Unexpected output")
+		})
+	}
+}
@@ -18,50 +18,123 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 
+	"github.com/bank-vaults/secret-init/pkg/cache"
 	"github.com/bank-vaults/secret-init/pkg/common"
 	"github.com/bank-vaults/secret-init/pkg/provider"
 	"github.com/bank-vaults/secret-init/pkg/provider/aws"
 	"github.com/bank-vaults/secret-init/pkg/provider/azure"
 	"github.com/bank-vaults/secret-init/pkg/provider/bao"
+	"github.com/bank-vaults/secret-init/pkg/provider/conjur"
+	"github.com/bank-vaults/secret-init/pkg/provider/doppler"
 	"github.com/bank-vaults/secret-init/pkg/provider/file"
 	"github.com/bank-vaults/secret-init/pkg/provider/gcp"
+	"github.com/bank-vaults/secret-init/pkg/provider/gcs"
+	"github.com/bank-vaults/secret-init/pkg/provider/k8s"
+	"github.com/bank-vaults/secret-init/pkg/provider/sops"
 	"github.com/bank-vaults/secret-init/pkg/provider/vault"
+	"github.com/bank-vaults/secret-init/pkg/transform"
+	"github.com/bank-vaults/secret-init/pkg/utils"
 )
 
+const (
+	// composeProviderType is a meta-provider that renders a single value from
+	// sub-references resolved by the other providers
+	composeProviderType      = "compose"
+	composeReferenceSelector = "compose:"
+
+	// binaryOption marks a reference whose secret must only be delivered as a file, e.g. a DER keystore
+	binaryOption = "binary"
+	// fileOption delivers a secret as a file at the given path, e.g. vault:pki/issue/app#certificate?file=/etc/tls/tls.crt
+	fileOption = "file"
+)
+
+// Sub-references are trailing "|name=reference" segments of a compose reference
+var composeSubReferenceRegex = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.+)$`)
+
+// A sub-reference like "compose:DB_HOST" refers to the value composed for another env var
+var composeKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Placeholders substituted in references, e.g. vault:secret/data/{env}/app#key, other braces are left alone
+var placeholderRegex = regexp.MustCompile(`\{(env|hostname)\}`)
+
 var factories = []provider.Factory{
 	{
 		ProviderType: file.ProviderType,
 		Validator:    file.Valid,
 		Create:       file.NewProvider,
+		EnvVars:      file.EnvVars,
 	},
 	{
 		ProviderType: vault.ProviderType,
 		Validator:    vault.Valid,
 		Create:       vault.NewProvider,
+		EnvVars:      vault.EnvVars,
 	},
 	{
 		ProviderType: bao.ProviderType,
 		Validator:    bao.Valid,
 		Create:       bao.NewProvider,
+		EnvVars:      bao.EnvVars,
 	},
 	{
 		ProviderType: aws.ProviderType,
 		Validator:    aws.Valid,
 		Create:       aws.NewProvider,
+		EnvVars:      aws.EnvVars,
 	},
 	{
 		ProviderType: gcp.ProviderType,
 		Validator:    gcp.Valid,
 		Create:       gcp.NewProvider,
+		EnvVars:      gcp.EnvVars,
+	},
+	{
+		ProviderType: gcs.ProviderType,
+		Validator:    gcs.Valid,
+		Create:       gcs.NewProvider,
+		EnvVars:      gcs.EnvVars,
 	},
 	{
 		ProviderType: azure.ProviderType,
 		Validator:    azure.Valid,
 		Create:       azure.NewProvider,
+		EnvVars:      azure.EnvVars,
+	},
+	{
+		ProviderType: k8s.ProviderType,
+		Validator:    k8s.Valid,
+		Create:       k8s.NewProvider,
+		EnvVars:      k8s.EnvVars,
+	},
+	{
+		ProviderType: conjur.ProviderType,
+		Validator:    conjur.Valid,
+		Create:       conjur.NewProvider,
+		EnvVars:      conjur.EnvVars,
+	},
+	{
+		ProviderType: doppler.ProviderType,
+		Validator:    doppler.Valid,
+		Create:       doppler.NewProvider,
+		EnvVars:      doppler.EnvVars,
+	},
+	{
+		ProviderType: sops.ProviderType,
+		Validator:    sops.Valid,
+		Create:       sops.NewProvider,
+		EnvVars:      sops.EnvVars,
 	},
 }
 
@@ -70,6 +143,25 @@ var factories = []provider.Factory{
 type EnvStore struct {
 	data      map[string]string
 	appConfig *common.Config
+	// eventLogger receives an event per resolved reference, it is disabled when nil
+	eventLogger *slog.Logger
+	// auditLogger receives an entry per injected secret, it is disabled when nil
+	auditLogger *slog.Logger
+	// cache keeps resolved secrets across loads (and processes when shared), it is disabled when nil
+	cache cache.Store
+	// metrics records the fetches of the providers, it is disabled when nil
+	metrics *metrics
+	// placeholders holds the values substituted in references, unresolvable placeholders are missing
+	placeholders map[string]string
+	// factories are the providers references are routed to, including the ones registered at runtime
+	factories []provider.Factory
+	// providers are created once per type and reused by later loads (e.g. reloads) until the store is closed
+	providersMu sync.Mutex
+	providers   map[string]provider.Provider
+	// breaker skips the providers failing repeatedly, it is disabled when nil
+	breaker *circuitBreaker
+	// loadedCounts holds the number of secrets each provider loaded in the last load none of them failed
+	loadedCounts map[string]int
 }
 
 func NewEnvStore(appConfig *common.Config) *EnvStore {
@@ -81,17 +173,101 @@ func NewEnvStore(appConfig *common.Config) *EnvStore {
 		environ[name] = value
 	}
 
-	return &EnvStore{
-		data:      environ,
-		appConfig: appConfig,
+	// A typed nil would not disable the cache
+	var secretCache cache.Store
+	if appConfig.CacheTTL > 0 {
+		secretCache = cache.New(appConfig.CacheTTL)
+	}
+
+	envStore := &EnvStore{
+		data:         make(map[string]string, len(environ)),
+		appConfig:    appConfig,
+		cache:        secretCache,
+		placeholders: referencePlaceholders(appConfig),
+		breaker:      newCircuitBreaker(appConfig.BreakerThreshold, appConfig.BreakerCooldown, time.Now),
+		factories:    slices.Clone(factories),
+	}
+	envStore.AddReferences(environ)
+
+	return envStore
+}
+
+// AddReferences merges references coming from outside of the environment (e.g. stdin) into the store.
+// They take precedence over environment variables of the same name.
+func (s *EnvStore) AddReferences(references map[string]string) {
+	for key, reference := range references {
+		if s.isSecretReference(reference) {
+			reference = substitutePlaceholders(reference, s.placeholders)
+		}
+
+		s.data[key] = reference
+	}
+}
+
+// RegisterFactory routes references to a provider only available at runtime, e.g. one fed through stdin.
+func (s *EnvStore) RegisterFactory(factory provider.Factory) {
+	s.factories = append(s.factories, factory)
+}
+
+// referencePlaceholders returns the values of the placeholders that can be resolved.
+func referencePlaceholders(appConfig *common.Config) map[string]string {
+	placeholders := make(map[string]string)
+	if appConfig.Environment != "" {
+		placeholders["env"] = appConfig.Environment
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		placeholders["hostname"] = hostname
+	}
+
+	return placeholders
+}
+
+// substitutePlaceholders replaces the resolvable placeholders of a reference,
+// the others are left in place to be reported by checkPlaceholders.
+func substitutePlaceholders(reference string, placeholders map[string]string) string {
+	return placeholderRegex.ReplaceAllStringFunc(reference, func(placeholder string) string {
+		if value, ok := placeholders[strings.Trim(placeholder, "{}")]; ok {
+			return value
+		}
+
+		return placeholder
+	})
+}
+
+// isSecretReference reports whether the value is routed to a provider of the store, plain values are never substituted.
+func (s *EnvStore) isSecretReference(value string) bool {
+	if strings.HasPrefix(value, composeReferenceSelector) {
+		return true
 	}
+
+	return slices.ContainsFunc(s.factories, func(factory provider.Factory) bool {
+		return factory.Validator(value)
+	})
 }
 
-// GetSecretReferences returns a map of secret key=value pairs for each provider
+// GetSecretReferences returns a map of secret key=value pairs for each provider.
+// With SECRET_INIT_ENV_PREFIX set, only env vars starting with the prefix (case-insensitive) are considered,
+// their keys are kept as is, e.g. APP__DB__PASSWORD stays APP__DB__PASSWORD with the prefix APP__.
+// Env vars are further filtered by SECRET_INIT_ONLY_ENV and SECRET_INIT_IGNORE_ENV, see isScanned.
 func (s *EnvStore) GetSecretReferences() map[string][]string {
 	secretReferences := make(map[string][]string)
 	for envKey, envPath := range s.data {
-		for _, factory := range factories {
+		if !hasPrefixFold(envKey, s.appConfig.EnvPrefix) || !s.isScanned(envKey) {
+			continue
+		}
+
+		if strings.HasPrefix(envPath, composeReferenceSelector) {
+			secretReferences[composeProviderType] = append(secretReferences[composeProviderType], fmt.Sprintf("%s=%s", envKey, envPath))
+			continue
+		}
+
+		if s.isTemplateValue(envPath) {
+			secretReferences[templateProviderType] = append(secretReferences[templateProviderType], fmt.Sprintf("%s=%s", envKey, envPath))
+			continue
+		}
+
+		for _, factory := range s.factories {
 			if factory.Validator(envPath) {
 				secretReferences[factory.ProviderType] = append(secretReferences[factory.ProviderType], fmt.Sprintf("%s=%s", envKey, envPath))
 			}
@@ -102,54 +278,210 @@ func (s *EnvStore) GetSecretReferences() map[string][]string {
 	return secretReferences
 }
 
+// isScanned reports whether the env var may hold a reference. With an allowlist set it has to be listed,
+// an empty allowlist scans nothing, and the ignore list removes env vars from the allowlist as well.
+func (s *EnvStore) isScanned(envKey string) bool {
+	if s.appConfig.OnlyEnv != nil && !slices.Contains(s.appConfig.OnlyEnv, envKey) {
+		return false
+	}
+
+	return !slices.Contains(s.appConfig.IgnoreEnv, envKey)
+}
+
+func hasPrefixFold(s string, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
 // LoadProviderSecrets creates a new provider for each detected provider using a specified config.
 // It then asynchronously loads secrets using each provider and it's corresponding paths.
 // The secrets from each provider are then placed into a single slice.
 func (s *EnvStore) LoadProviderSecrets(ctx context.Context, providerPaths map[string][]string) ([]provider.Secret, error) {
-	var providerSecrets []provider.Secret
+	defer func(start time.Time) {
+		s.metrics.observeLoad(time.Since(start))
+	}(time.Now())
+
+	err := checkPlaceholders(providerPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	composedReferences, providerPaths, err := s.expandComposedReferences(providerPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	templateReferences, providerPaths, err := s.expandTemplateReferences(providerPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkPinnedProvider(providerPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultValues, providerPaths := splitDefaultValues(providerPaths, s.appConfig.DefaultDelimiter)
+
+	decodings, providerPaths, err := splitDecodings(providerPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	binaryKeys, providerPaths, err := splitBinaryReferences(providerPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	filePaths, providerPaths, err := splitFileReferences(providerPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, providerPaths := dedupeReferences(providerPaths)
+
+	err = checkReferenceLimit(providerPaths, s.appConfig.MaxReferencesPerProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache hits count for their provider as well
+	loadedCounts := make(map[string]int, len(providerPaths))
+	requestedPaths := providerPaths
+	providerSecrets, providerPaths := s.loadCachedSecrets(providerPaths)
+	for providerName, paths := range requestedPaths {
+		loadedCounts[providerName] = len(paths) - len(providerPaths[providerName])
+	}
+
+	// Stores writing the secrets out as a whole do it once per load, not once per secret
+	defer s.flushCache()
+
+	loadCtx, stopTimeout := withLoadTimeout(ctx, s.appConfig.Timeout)
+	defer stopTimeout()
+
+	// With fail fast, the first provider error cancels the loading of the other providers.
+	// The context is cancelled once the load is done, the goroutines of the providers outliving it
+	// (e.g. file watchers) run with ctx instead, see provider.Lifetime.
+	loadCtx, failFast := context.WithCancelCause(provider.WithLifetime(loadCtx, ctx))
+	defer failFast(nil)
+
 	// Workaround for openBao
 	// Remove once openBao uses BAO_ADDR in their client, instead of VAULT_ADDR
 	if _, ok := providerPaths[vault.ProviderType]; ok {
-		vaultSecrets, err := s.workaroundForBao(ctx, providerPaths[vault.ProviderType])
+		vaultSecrets, err := s.workaroundForBao(loadCtx, providerPaths[vault.ProviderType])
 		if err != nil {
+			if errors.Is(context.Cause(loadCtx), errLoadTimeout) {
+				return nil, newLoadTimeoutError(s.appConfig.Timeout, []string{vault.ProviderType})
+			}
+
 			return nil, err
 		}
 
 		providerSecrets = append(providerSecrets, vaultSecrets...)
+		loadedCounts[vault.ProviderType] += len(vaultSecrets)
 		delete(providerPaths, vault.ProviderType)
 	}
 
 	// At most, we will have one error per provider
-	errCh := make(chan error, len(factories))
+	errCh := make(chan error, len(s.factories))
+	reportErr := func(err error) {
+		errCh <- err
+		if s.appConfig.FailFast {
+			failFast(err)
+		}
+	}
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
+	pendingProviders := make(map[string]bool, len(providerPaths))
 	for providerName, paths := range providerPaths {
+		pendingProviders[providerName] = true
 		wg.Add(1)
 		go func(providerName string, paths []string, errCh chan<- error) {
 			defer wg.Done()
+			// Providers finishing after the timeout are reported as timed out, whatever their result
+			defer func() {
+				mu.Lock()
+				if loadCtx.Err() == nil {
+					delete(pendingProviders, providerName)
+				}
+				mu.Unlock()
+			}()
+
+			keepSecrets := func(secrets []provider.Secret) {
+				mu.Lock()
+				providerSecrets = append(providerSecrets, secrets...)
+				loadedCounts[providerName] += len(secrets)
+				mu.Unlock()
+			}
 
-			for _, factory := range factories {
+			for _, factory := range s.factories {
 				if factory.ProviderType == providerName {
-					provider, err := factory.Create(ctx, s.appConfig)
+					if secrets, ok := s.breakerOpen(providerName); ok {
+						keepSecrets(secrets)
+						return
+					}
+
+					provider, err := s.providerFor(loadCtx, factory)
 					if err != nil {
-						errCh <- fmt.Errorf("failed to create provider %s: %w", providerName, err)
+						err = &providerError{providerName: providerName, err: fmt.Errorf("failed to create provider %s: %w", providerName, err)}
+						if secrets, ok := s.breakerFallback(providerName, err); ok {
+							keepSecrets(secrets)
+							return
+						}
+
+						reportErr(err)
 						return
 					}
 
-					secrets, err := provider.LoadSecrets(ctx, paths)
+					start := time.Now()
+					secrets, err := provider.LoadSecrets(loadCtx, paths)
+					logEvents(s.eventLogger, providerName, paths, secrets, time.Since(start), err)
+					s.metrics.observeFetch(providerName, time.Since(start), err)
 					if err != nil {
-						errCh <- fmt.Errorf("failed to load secrets for provider %s: %w", providerName, err)
+						err = &providerError{providerName: providerName, err: fmt.Errorf("failed to load secrets for provider %s: %w", providerName, err)}
+						if secrets, ok := s.breakerFallback(providerName, err); ok {
+							keepSecrets(secrets)
+							return
+						}
+
+						reportErr(err)
 						return
 					}
 
-					mu.Lock()
-					providerSecrets = append(providerSecrets, secrets...)
-					mu.Unlock()
+					s.dropRevoked(providerName, provider)
+
+					slog.Info("secrets loaded", slog.String("provider", provider.GetProviderName()), slog.Int("count", len(secrets)))
+					s.metrics.observeSecrets(providerName, secrets)
+					s.cacheSecrets(paths, secrets)
+					s.breaker.succeeded(providerName, secrets)
+
+					keepSecrets(secrets)
 				}
 			}
 		}(providerName, paths, errCh)
 	}
-	wg.Wait()
+
+	loaded := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(loaded)
+	}()
+
+	select {
+	case <-loaded:
+	case <-loadCtx.Done():
+		mu.Lock()
+		pending := slices.Sorted(maps.Keys(pendingProviders))
+		mu.Unlock()
+
+		// Providers ignoring the cancellation are not waited for
+		if errors.Is(context.Cause(loadCtx), errLoadTimeout) && len(pending) > 0 {
+			return nil, newLoadTimeoutError(s.appConfig.Timeout, pending)
+		}
+
+		// Cancelled providers are waited for, so none of them is left running after the load
+		<-loaded
+	}
 	close(errCh)
 
 	var errs error
@@ -159,27 +491,794 @@ func (s *EnvStore) LoadProviderSecrets(ctx context.Context, providerPaths map[st
 		}
 	}
 	if errs != nil {
+		// The other errors are most likely caused by the cancellation, only the first one is relevant
+		if s.appConfig.FailFast {
+			return nil, context.Cause(loadCtx)
+		}
+
 		return nil, errs
 	}
 
+	s.loadedCounts = loadedCounts
+	providerSecrets = fanOutAliases(providerSecrets, aliases)
+
+	providerSecrets, err = applyDecodings(providerSecrets, decodings)
+	if err != nil {
+		return nil, err
+	}
+
+	providerSecrets = applyDefaultValues(providerSecrets, defaultValues)
+
+	for i, secret := range providerSecrets {
+		providerSecrets[i].Binary = binaryKeys[secret.Key]
+		providerSecrets[i].FilePath = filePaths[secret.Key]
+	}
+
+	providerSecrets, err = renderTemplateSecrets(providerSecrets, templateReferences)
+	if err != nil {
+		return nil, err
+	}
+
+	return renderComposedSecrets(providerSecrets, composedReferences)
+}
+
+// providerFor returns the provider of the factory, creating it on first use.
+// Providers are created without holding the lock, so a slow one doesn't hold up the others.
+func (s *EnvStore) providerFor(ctx context.Context, factory provider.Factory) (provider.Provider, error) {
+	s.providersMu.Lock()
+	p, ok := s.providers[factory.ProviderType]
+	s.providersMu.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	p, err := factory.Create(ctx, s.appConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	s.providersMu.Lock()
+	defer s.providersMu.Unlock()
+
+	// A concurrent load created the same provider in the meantime, the first one is kept
+	if existing, ok := s.providers[factory.ProviderType]; ok {
+		if closer, ok := p.(provider.Closer); ok {
+			_ = closer.Close()
+		}
+
+		return existing, nil
+	}
+
+	if s.providers == nil {
+		s.providers = make(map[string]provider.Provider)
+	}
+	s.providers[factory.ProviderType] = p
+
+	return p, nil
+}
+
+// dropRevoked stops reusing a provider which revoked its token, see provider.Revoker.
+// It is not closed, revoking the token released its resources already (or will once the process exits).
+func (s *EnvStore) dropRevoked(providerName string, p provider.Provider) {
+	revoker, ok := p.(provider.Revoker)
+	if !ok || !revoker.Revoked() {
+		return
+	}
+
+	s.providersMu.Lock()
+	defer s.providersMu.Unlock()
+
+	if s.providers[providerName] == p {
+		delete(s.providers, providerName)
+	}
+}
+
+// Close releases the resources of the providers created by the store, each of them is closed once.
+// Providers are created again by a later load.
+func (s *EnvStore) Close() error {
+	s.providersMu.Lock()
+	defer s.providersMu.Unlock()
+
+	var errs error
+	for _, providerName := range slices.Sorted(maps.Keys(s.providers)) {
+		if closer, ok := s.providers[providerName].(provider.Closer); ok {
+			err := closer.Close()
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("failed to close provider %s: %w", providerName, err))
+			}
+		}
+	}
+	s.providers = nil
+
+	return errs
+}
+
+var errLoadTimeout = errors.New("loading secrets timed out")
+
+// withLoadTimeout returns a context cancelled once the timeout passes, a zero timeout disables it.
+// Unlike with context.WithTimeout, the context is not cancelled after the load,
+// since providers keep watching for changes with it (e.g. file watchers in daemon mode).
+func withLoadTimeout(ctx context.Context, timeout time.Duration) (context.Context, func() bool) {
+	if timeout <= 0 {
+		return ctx, func() bool { return false }
+	}
+
+	loadCtx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(timeout, func() {
+		cancel(errLoadTimeout)
+	})
+
+	return loadCtx, timer.Stop
+}
+
+// newLoadTimeoutError names the providers which were still loading when the timeout passed.
+func newLoadTimeoutError(timeout time.Duration, pendingProviders []string) error {
+	var errs error
+	for _, providerName := range pendingProviders {
+		errs = errors.Join(errs, &providerError{
+			providerName: providerName,
+			err:          fmt.Errorf("provider %s did not load its secrets within %s set by %s", providerName, timeout, common.TimeoutEnv),
+		})
+	}
+
+	return errs
+}
+
+// dedupeReferences keeps a single path for the env vars referencing the same secret,
+// so it is fetched once per load. It returns the keys of the dropped paths by the key of the kept one.
+// References with options are kept as is, since options like ?expand=true name the loaded secrets after the key.
+// Write references (>>vault:) are kept as well, each of them has to be executed.
+func dedupeReferences(providerPaths map[string][]string) (map[string][]string, map[string][]string) {
+	aliases := make(map[string][]string)
+	dedupedPaths := make(map[string][]string, len(providerPaths))
+	for providerName, paths := range providerPaths {
+		keysByReference := make(map[string]string, len(paths))
+		dedupedPaths[providerName] = make([]string, 0, len(paths))
+		for _, path := range paths {
+			key, reference, _ := strings.Cut(path, "=")
+			if strings.Contains(reference, "?") || strings.HasPrefix(reference, ">>") {
+				dedupedPaths[providerName] = append(dedupedPaths[providerName], path)
+				continue
+			}
+
+			if firstKey, ok := keysByReference[reference]; ok {
+				aliases[firstKey] = append(aliases[firstKey], key)
+				continue
+			}
+
+			keysByReference[reference] = key
+			dedupedPaths[providerName] = append(dedupedPaths[providerName], path)
+		}
+	}
+
+	return aliases, dedupedPaths
+}
+
+// fanOutAliases copies the secrets loaded for deduplicated references to the keys of the other env vars referencing them.
+func fanOutAliases(providerSecrets []provider.Secret, aliases map[string][]string) []provider.Secret {
+	if len(aliases) == 0 {
+		return providerSecrets
+	}
+
+	for _, secret := range providerSecrets {
+		for _, key := range aliases[secret.Key] {
+			alias := secret
+			alias.Key = key
+			providerSecrets = append(providerSecrets, alias)
+		}
+	}
+
+	return providerSecrets
+}
+
+// checkReferenceLimit fails if any provider got more references than allowed,
+// before a single request reaches the backends. A limit of zero disables the check.
+func checkReferenceLimit(providerPaths map[string][]string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	var errs error
+	for _, providerName := range slices.Sorted(maps.Keys(providerPaths)) {
+		if count := len(providerPaths[providerName]); count > limit {
+			errs = errors.Join(errs, &providerError{
+				providerName: providerName,
+				err:          fmt.Errorf("provider %s has %d references, exceeding the limit of %d set by %s", providerName, count, limit, common.MaxReferencesPerProviderEnv),
+			})
+		}
+	}
+
+	return errs
+}
+
+// checkPinnedProvider rejects the references of every provider other than the pinned one,
+// so a locked-down deployment can't resolve secrets from a backend it was not meant to use.
+func (s *EnvStore) checkPinnedProvider(providerPaths map[string][]string) error {
+	pinned := s.appConfig.Provider
+	if pinned == "" {
+		return nil
+	}
+
+	if !slices.ContainsFunc(s.factories, func(factory provider.Factory) bool { return factory.ProviderType == pinned }) {
+		return fmt.Errorf("unknown provider %s set by %s", pinned, common.ProviderEnv)
+	}
+
+	var errs error
+	for _, providerName := range slices.Sorted(maps.Keys(providerPaths)) {
+		if providerName == pinned {
+			continue
+		}
+
+		for _, path := range providerPaths[providerName] {
+			envKey, _, _ := strings.Cut(path, "=")
+			errs = errors.Join(errs, fmt.Errorf("invalid reference for %s: provider %s is not allowed, %s pins the provider to %s", envKey, providerName, common.ProviderEnv, pinned))
+		}
+	}
+
+	return errs
+}
+
+// checkPlaceholders rejects the references left with placeholders that could not be resolved,
+// e.g. {env} without SECRET_INIT_ENVIRONMENT, instead of loading a secret from the wrong path.
+func checkPlaceholders(providerPaths map[string][]string) error {
+	var errs error
+	for _, providerName := range slices.Sorted(maps.Keys(providerPaths)) {
+		for _, path := range providerPaths[providerName] {
+			placeholder := placeholderRegex.FindString(path)
+			if placeholder == "" {
+				continue
+			}
+
+			err := fmt.Errorf("unresolved placeholder %s", placeholder)
+			if placeholder == "{env}" {
+				err = fmt.Errorf("%w, %s is not set", err, common.EnvironmentEnv)
+			}
+
+			key, _, _ := strings.Cut(path, "=")
+			errs = errors.Join(errs, &providerError{
+				providerName: providerName,
+				err:          fmt.Errorf("invalid reference for %s: %w", key, err),
+			})
+		}
+	}
+
+	return errs
+}
+
+// splitDefaultValues strips the default values from the references, so providers only see the references themselves.
+// A default value follows the first delimiter of a reference, e.g. DB_PORT=vault:secret/data/db#port|5432.
+// Templated and inline references (containing "${") are left as is, since templates may contain pipes.
+// An empty delimiter disables default values.
+func splitDefaultValues(providerPaths map[string][]string, delimiter string) (map[string]string, map[string][]string) {
+	if delimiter == "" {
+		return nil, providerPaths
+	}
+
+	defaultValues := make(map[string]string)
+	strippedPaths := make(map[string][]string, len(providerPaths))
+	for providerName, paths := range providerPaths {
+		strippedPaths[providerName] = make([]string, 0, len(paths))
+		for _, path := range paths {
+			key, reference, _ := strings.Cut(path, "=")
+			if strings.Contains(reference, "${") {
+				strippedPaths[providerName] = append(strippedPaths[providerName], path)
+				continue
+			}
+
+			if reference, defaultValue, ok := strings.Cut(reference, delimiter); ok {
+				defaultValues[key] = defaultValue
+				path = fmt.Sprintf("%s=%s", key, reference)
+			}
+
+			strippedPaths[providerName] = append(strippedPaths[providerName], path)
+		}
+	}
+
+	return defaultValues, strippedPaths
+}
+
+// applyDefaultValues adds the default value of each reference the providers skipped,
+// e.g. missing secrets ignored with VAULT_IGNORE_MISSING_SECRETS or SECRET_INIT_IGNORE_MISSING_SECRETS.
+// Providers failing on missing secrets fail the load before defaults are considered.
+func applyDefaultValues(providerSecrets []provider.Secret, defaultValues map[string]string) []provider.Secret {
+	if len(defaultValues) == 0 {
+		return providerSecrets
+	}
+
+	loadedKeys := make(map[string]bool, len(providerSecrets))
+	for _, secret := range providerSecrets {
+		loadedKeys[secret.Key] = true
+	}
+
+	for key, defaultValue := range defaultValues {
+		if !loadedKeys[key] {
+			slog.Info("secret is missing, using its default value", slog.String("key", key))
+
+			providerSecrets = append(providerSecrets, provider.Secret{
+				Key:   key,
+				Value: defaultValue,
+			})
+		}
+	}
+
+	return providerSecrets
+}
+
+// splitDecodings strips the decode options from the references, e.g. vault:secret/data/tls#key?decode=base64,
+// so providers only see the references themselves. Templated references are left as is, like for default values.
+func splitDecodings(providerPaths map[string][]string) (map[string]string, map[string][]string, error) {
+	decodings := make(map[string]string)
+	strippedPaths := make(map[string][]string, len(providerPaths))
+	for providerName, paths := range providerPaths {
+		strippedPaths[providerName] = make([]string, 0, len(paths))
+		for _, path := range paths {
+			key, reference, _ := strings.Cut(path, "=")
+			if strings.Contains(reference, "${") {
+				strippedPaths[providerName] = append(strippedPaths[providerName], path)
+				continue
+			}
+
+			reference, encoding, err := transform.SplitDecode(reference)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid reference for %s: %w", key, err)
+			}
+
+			if encoding != "" {
+				decodings[key] = encoding
+				path = fmt.Sprintf("%s=%s", key, reference)
+			}
+
+			strippedPaths[providerName] = append(strippedPaths[providerName], path)
+		}
+	}
+
+	return decodings, strippedPaths, nil
+}
+
+// splitBinaryReferences strips the binary option from the references, e.g. vault:secret/data/tls#keystore?binary=true,
+// returning the keys of the references marked as binary.
+func splitBinaryReferences(providerPaths map[string][]string) (map[string]bool, map[string][]string, error) {
+	binaryKeys := make(map[string]bool)
+	strippedPaths := make(map[string][]string, len(providerPaths))
+	for providerName, paths := range providerPaths {
+		strippedPaths[providerName] = make([]string, 0, len(paths))
+		for _, path := range paths {
+			key, reference, _ := strings.Cut(path, "=")
+			if strings.Contains(reference, "${") {
+				strippedPaths[providerName] = append(strippedPaths[providerName], path)
+				continue
+			}
+
+			reference, value, ok, err := utils.CutOption(reference, binaryOption)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid reference for %s: %w", key, err)
+			}
+
+			if ok {
+				binary, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid reference for %s: invalid %s option %q", key, binaryOption, value)
+				}
+
+				binaryKeys[key] = binary
+				path = fmt.Sprintf("%s=%s", key, reference)
+			}
+
+			strippedPaths[providerName] = append(strippedPaths[providerName], path)
+		}
+	}
+
+	return binaryKeys, strippedPaths, nil
+}
+
+// splitFileReferences strips the file option from the references, e.g. vault:secret/data/tls#crt?file=/etc/tls/tls.crt,
+// and returns the target path of the file per env var.
+func splitFileReferences(providerPaths map[string][]string) (map[string]string, map[string][]string, error) {
+	filePaths := make(map[string]string)
+	strippedPaths := make(map[string][]string, len(providerPaths))
+	for providerName, paths := range providerPaths {
+		strippedPaths[providerName] = make([]string, 0, len(paths))
+		for _, path := range paths {
+			key, reference, _ := strings.Cut(path, "=")
+			if strings.Contains(reference, "${") {
+				strippedPaths[providerName] = append(strippedPaths[providerName], path)
+				continue
+			}
+
+			reference, value, ok, err := utils.CutOption(reference, fileOption)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid reference for %s: %w", key, err)
+			}
+
+			if ok {
+				if !filepath.IsAbs(value) {
+					return nil, nil, fmt.Errorf("invalid reference for %s: %s option must be an absolute path, got %q", key, fileOption, value)
+				}
+
+				filePaths[key] = filepath.Clean(value)
+				path = fmt.Sprintf("%s=%s", key, reference)
+			}
+
+			strippedPaths[providerName] = append(strippedPaths[providerName], path)
+		}
+	}
+
+	return filePaths, strippedPaths, nil
+}
+
+// applyDecodings decodes the secrets loaded from references with a decode option.
+func applyDecodings(providerSecrets []provider.Secret, decodings map[string]string) ([]provider.Secret, error) {
+	for i, secret := range providerSecrets {
+		encoding, ok := decodings[secret.Key]
+		if !ok {
+			continue
+		}
+
+		value, err := transform.Decode(secret.Value, encoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode secret %s: %w", secret.Key, err)
+		}
+
+		providerSecrets[i].Value = value
+	}
+
 	return providerSecrets, nil
 }
 
+// providerError records which provider a loading error belongs to.
+type providerError struct {
+	providerName string
+	err          error
+}
+
+func (e *providerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *providerError) Unwrap() error {
+	return e.err
+}
+
+// composedReference is a value rendered from a template using the secrets of its sub-references.
+type composedReference struct {
+	key      string
+	template *template.Template
+	// subKeys maps template fields to the keys their sub-references are loaded under
+	subKeys map[string]string
+	// dependencies maps template fields to the keys of other composed references
+	dependencies map[string]string
+}
+
+// expandComposedReferences parses the compose references and hands their sub-references
+// over to the providers they belong to, so they are loaded along with all other references.
+// A compose reference looks like: compose:{{.u}}:{{.p}}@db|u=vault:secret/data/db#user|p=file:db/pass
+func (s *EnvStore) expandComposedReferences(providerPaths map[string][]string) ([]composedReference, map[string][]string, error) {
+	composePaths, ok := providerPaths[composeProviderType]
+	if !ok {
+		return nil, providerPaths, nil
+	}
+
+	expandedPaths := make(map[string][]string, len(providerPaths))
+	for providerName, paths := range providerPaths {
+		if providerName != composeProviderType {
+			expandedPaths[providerName] = append([]string(nil), paths...)
+		}
+	}
+
+	composedReferences := make([]composedReference, 0, len(composePaths))
+	for _, path := range composePaths {
+		key, reference, _ := strings.Cut(path, "=")
+		segments := strings.Split(strings.TrimPrefix(reference, composeReferenceSelector), "|")
+
+		// Templates may contain pipes themselves, so sub-references are collected from the end
+		subKeys := make(map[string]string)
+		dependencies := make(map[string]string)
+		for len(segments) > 1 {
+			match := composeSubReferenceRegex.FindStringSubmatch(segments[len(segments)-1])
+			if match == nil {
+				break
+			}
+
+			name, subReference := match[1], match[2]
+			if composedKey, ok := strings.CutPrefix(subReference, composeReferenceSelector); ok {
+				if !composeKeyRegex.MatchString(composedKey) {
+					return nil, nil, fmt.Errorf("failed to compose %s: sub-reference %s is not a valid env var name", key, name)
+				}
+
+				dependencies[name] = composedKey
+				segments = segments[:len(segments)-1]
+
+				continue
+			}
+
+			providerName, ok := s.referenceProvider(subReference)
+			if !ok {
+				return nil, nil, fmt.Errorf("failed to compose %s: sub-reference %s is not supported", key, name)
+			}
+
+			// Env var names can't contain dots, so the sub-reference keys can't clash with real ones
+			subKey := key + "." + name
+			subKeys[name] = subKey
+			expandedPaths[providerName] = append(expandedPaths[providerName], fmt.Sprintf("%s=%s", subKey, subReference))
+			segments = segments[:len(segments)-1]
+		}
+
+		if len(subKeys) == 0 && len(dependencies) == 0 {
+			return nil, nil, fmt.Errorf("failed to compose %s: no sub-references found", key)
+		}
+
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(strings.Join(segments, "|"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compose %s: %w", key, err)
+		}
+
+		composedReferences = append(composedReferences, composedReference{
+			key:          key,
+			template:     tmpl,
+			subKeys:      subKeys,
+			dependencies: dependencies,
+		})
+	}
+
+	composedReferences, err := sortComposedReferences(composedReferences)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return composedReferences, expandedPaths, nil
+}
+
+// sortComposedReferences orders the composed references so that each one comes after
+// the composed references it depends on, e.g. with A depending on B and B on C, C is rendered first.
+func sortComposedReferences(composedReferences []composedReference) ([]composedReference, error) {
+	byKey := make(map[string]composedReference, len(composedReferences))
+	for _, composed := range composedReferences {
+		byKey[composed.key] = composed
+	}
+
+	// Keys are visited in order, so the result (and the reported cycle) doesn't depend on the order of the env vars
+	keys := slices.Sorted(maps.Keys(byKey))
+
+	sorted := make([]composedReference, 0, len(composedReferences))
+	done := make(map[string]bool, len(composedReferences))
+	var path []string
+
+	var visit func(key string) error
+	visit = func(key string) error {
+		if done[key] {
+			return nil
+		}
+
+		if i := slices.Index(path, key); i >= 0 {
+			return fmt.Errorf("failed to compose %s: dependency cycle %s", key, strings.Join(append(path[i:], key), " -> "))
+		}
+
+		composed := byKey[key]
+		path = append(path, key)
+
+		for _, name := range slices.Sorted(maps.Keys(composed.dependencies)) {
+			dependency := composed.dependencies[name]
+			if _, ok := byKey[dependency]; !ok {
+				return fmt.Errorf("failed to compose %s: sub-reference %s refers to %s, which is not composed", key, name, dependency)
+			}
+
+			err := visit(dependency)
+			if err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		done[key] = true
+		sorted = append(sorted, composed)
+
+		return nil
+	}
+
+	for _, key := range keys {
+		err := visit(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// renderComposedSecrets replaces the secrets of sub-references with the values composed from them.
+// The composed references are expected in dependency order, so composed values are available to their dependents.
+func renderComposedSecrets(providerSecrets []provider.Secret, composedReferences []composedReference) ([]provider.Secret, error) {
+	if len(composedReferences) == 0 {
+		return providerSecrets, nil
+	}
+
+	secretValues := make(map[string]string, len(providerSecrets))
+	for _, secret := range providerSecrets {
+		secretValues[secret.Key] = secret.Value
+	}
+
+	subKeys := make(map[string]bool)
+	var composedSecrets []provider.Secret
+	for _, composed := range composedReferences {
+		data := make(map[string]string, len(composed.subKeys))
+		for name, subKey := range composed.subKeys {
+			value, ok := secretValues[subKey]
+			if !ok {
+				return nil, fmt.Errorf("failed to compose %s: sub-reference %s was not loaded", composed.key, name)
+			}
+
+			data[name] = value
+			subKeys[subKey] = true
+		}
+
+		for name, dependency := range composed.dependencies {
+			data[name] = secretValues[dependency]
+		}
+
+		var value strings.Builder
+		err := composed.template.Execute(&value, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compose %s: %w", composed.key, err)
+		}
+		secretValues[composed.key] = value.String()
+
+		composedSecrets = append(composedSecrets, provider.Secret{
+			Key:      composed.key,
+			Value:    value.String(),
+			Provider: composeProviderType,
+		})
+	}
+
+	secrets := make([]provider.Secret, 0, len(providerSecrets))
+	for _, secret := range providerSecrets {
+		if !subKeys[secret.Key] {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	return append(secrets, composedSecrets...), nil
+}
+
+// referenceProvider returns the type of the provider of the store the reference belongs to.
+func (s *EnvStore) referenceProvider(reference string) (string, bool) {
+	for _, factory := range s.factories {
+		if factory.Validator(reference) {
+			return factory.ProviderType, true
+		}
+	}
+
+	return "", false
+}
+
+// loadCachedSecrets returns the fresh secrets from the cache,
+// along with the references that still need to be loaded from their providers.
+// Providers with all of their references cached are skipped entirely.
+func (s *EnvStore) loadCachedSecrets(providerPaths map[string][]string) ([]provider.Secret, map[string][]string) {
+	if s.cache == nil {
+		return nil, providerPaths
+	}
+
+	var cachedSecrets []provider.Secret
+	uncachedPaths := make(map[string][]string, len(providerPaths))
+	for providerName, paths := range providerPaths {
+		var uncached []string
+		for _, path := range paths {
+			if secret, ok := s.cache.Get(path); ok {
+				logCachedEvent(s.eventLogger, providerName, path, secret)
+				cachedSecrets = append(cachedSecrets, secret)
+				continue
+			}
+
+			uncached = append(uncached, path)
+		}
+
+		// Keep providers without references, they may load secrets on their own (e.g. VAULT_FROM_PATH)
+		if len(uncached) > 0 || len(paths) == 0 {
+			uncachedPaths[providerName] = uncached
+		}
+	}
+
+	return cachedSecrets, uncachedPaths
+}
+
+// ClearCache drops the cached secrets, so the next load reaches out to the providers.
+func (s *EnvStore) ClearCache() {
+	if s.cache != nil {
+		s.cache.Clear()
+	}
+}
+
+// flushCache writes out the secrets cached by a load, see cache.Flusher.
+func (s *EnvStore) flushCache() {
+	if flusher, ok := s.cache.(cache.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// cacheSecrets stores the loaded secrets under the reference they were loaded from.
+func (s *EnvStore) cacheSecrets(paths []string, secrets []provider.Secret) {
+	if s.cache == nil {
+		return
+	}
+
+	pathsByKey := make(map[string]string, len(paths))
+	for _, path := range paths {
+		key, _, _ := strings.Cut(path, "=")
+		pathsByKey[key] = path
+	}
+
+	for _, secret := range secrets {
+		if path, ok := pathsByKey[secret.Key]; ok {
+			s.cache.Set(path, secret)
+		}
+	}
+}
+
+// breakerOpen returns the secrets loaded by a provider before, if it is skipped by its open breaker.
+func (s *EnvStore) breakerOpen(providerName string) ([]provider.Secret, bool) {
+	if s.breaker.allow(providerName) {
+		return nil, false
+	}
+
+	secrets := s.breaker.keptSecrets(providerName)
+	slog.Warn("circuit breaker open, keeping the previously loaded secrets", slog.String("provider", providerName), slog.Int("count", len(secrets)))
+
+	return secrets, true
+}
+
+// breakerFallback records the failure of a provider. Once its breaker is open, the failure is only logged
+// and the secrets loaded by the provider before are returned to be kept instead.
+func (s *EnvStore) breakerFallback(providerName string, err error) ([]provider.Secret, bool) {
+	if !s.breaker.failed(providerName) {
+		return nil, false
+	}
+
+	secrets := s.breaker.keptSecrets(providerName)
+	slog.Warn(fmt.Errorf("circuit breaker opened, keeping the previously loaded secrets: %w", err).Error(), slog.String("provider", providerName), slog.Int("count", len(secrets)))
+
+	return secrets, true
+}
+
 // Workaround for openBao, essentially loading secretes from Vault first.
 func (s *EnvStore) workaroundForBao(ctx context.Context, vaultPaths []string) ([]provider.Secret, error) {
 	var providerSecrets []provider.Secret
 	for _, factory := range factories {
 		if factory.ProviderType == vault.ProviderType {
-			provider, err := factory.Create(ctx, s.appConfig)
+			if secrets, ok := s.breakerOpen(factory.ProviderType); ok {
+				return secrets, nil
+			}
+
+			provider, err := s.providerFor(ctx, factory)
 			if err != nil {
-				return nil, fmt.Errorf("failed to create provider %s: %w", factory.ProviderType, err)
+				err = &providerError{providerName: factory.ProviderType, err: fmt.Errorf("failed to create provider %s: %w", factory.ProviderType, err)}
+				if secrets, ok := s.breakerFallback(factory.ProviderType, err); ok {
+					return secrets, nil
+				}
+
+				return nil, err
 			}
 
+			start := time.Now()
 			secrets, err := provider.LoadSecrets(ctx, vaultPaths)
+			logEvents(s.eventLogger, factory.ProviderType, vaultPaths, secrets, time.Since(start), err)
+			s.metrics.observeFetch(factory.ProviderType, time.Since(start), err)
 			if err != nil {
-				return nil, fmt.Errorf("failed to load secrets for provider %s: %w", factory.ProviderType, err)
+				err = &providerError{providerName: factory.ProviderType, err: fmt.Errorf("failed to load secrets for provider %s: %w", factory.ProviderType, err)}
+				if secrets, ok := s.breakerFallback(factory.ProviderType, err); ok {
+					return secrets, nil
+				}
+
+				return nil, err
 			}
 
+			// With VAULT_REVOKE_TOKEN the token is revoked after the load (or once the process exits), so the next load creates vault again
+			s.dropRevoked(factory.ProviderType, provider)
+
+			slog.Info("secrets loaded", slog.String("provider", provider.GetProviderName()), slog.Int("count", len(secrets)))
+			s.metrics.observeSecrets(factory.ProviderType, secrets)
+			s.cacheSecrets(vaultPaths, secrets)
+			s.breaker.succeeded(factory.ProviderType, secrets)
+
 			providerSecrets = append(providerSecrets, secrets...)
 			break
 		}
@@ -188,16 +1287,44 @@ func (s *EnvStore) workaroundForBao(ctx context.Context, vaultPaths []string) ([
 	return providerSecrets, nil
 }
 
-// ConvertProviderSecrets converts the loaded secrets to environment variables
+// LoadedCounts returns the number of secrets each provider loaded in the last load none of them failed,
+// including the ones served from the cache.
+func (s *EnvStore) LoadedCounts() map[string]int {
+	return maps.Clone(s.loadedCounts)
+}
+
+// ConvertProviderSecrets converts the loaded secrets to environment variables.
+// Binary secrets are left out, they are only delivered as files.
+// Secrets delivered as files at a target path are set to that path instead of their value.
 func (s *EnvStore) ConvertProviderSecrets(providerSecrets []provider.Secret) []string {
 	var secretsEnv []string
 	for _, secret := range providerSecrets {
-		secretsEnv = append(secretsEnv, fmt.Sprintf("%s=%s", secret.Key, secret.Value))
+		slog.Debug("secret loaded", slog.String("key", secret.Key), slog.String("provider", secret.Provider), slog.String("reference", secret.Reference))
+
+		value, ok := envValue(secret)
+		if !ok {
+			continue
+		}
+
+		secretsEnv = append(secretsEnv, fmt.Sprintf("%s=%s", secret.Key, value))
 	}
 
 	return secretsEnv
 }
 
+// envValue returns the value a secret is set to as an env var, binary secrets are never set.
+func envValue(secret provider.Secret) (string, bool) {
+	if secret.Binary {
+		return "", false
+	}
+
+	if secret.FilePath != "" {
+		return secret.FilePath, true
+	}
+
+	return secret.Value, true
+}
+
 // Handle the edge case where *_FROM_PATH is defined but no direct env-var references are present
 // in this case the provider should be created with an empty list of secret references
 // leaving the secret injection to the provider
@@ -17,13 +17,31 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/bank-vaults/secret-init/pkg/common"
 	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/provider/aws"
+	"github.com/bank-vaults/secret-init/pkg/provider/azure"
+	"github.com/bank-vaults/secret-init/pkg/provider/bao"
+	"github.com/bank-vaults/secret-init/pkg/provider/conjur"
+	"github.com/bank-vaults/secret-init/pkg/provider/doppler"
+	"github.com/bank-vaults/secret-init/pkg/provider/file"
+	"github.com/bank-vaults/secret-init/pkg/provider/gcp"
+	"github.com/bank-vaults/secret-init/pkg/provider/gcs"
+	"github.com/bank-vaults/secret-init/pkg/provider/k8s"
+	"github.com/bank-vaults/secret-init/pkg/provider/sops"
+	"github.com/bank-vaults/secret-init/pkg/provider/stdin"
+	"github.com/bank-vaults/secret-init/pkg/provider/vault"
 )
 
 func TestEnvStore_GetSecretReferences(t *testing.T) {
@@ -128,6 +146,50 @@ func TestEnvStore_GetSecretReferences(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "k8s provider",
+			envs: map[string]string{
+				"K8S_SECRET1": "k8s:default/my-secret#password",
+			},
+			wantPaths: map[string][]string{
+				"k8s": {
+					"K8S_SECRET1=k8s:default/my-secret#password",
+				},
+			},
+		},
+		{
+			name: "conjur provider",
+			envs: map[string]string{
+				"CONJUR_SECRET1": "conjur:prod/db/password",
+			},
+			wantPaths: map[string][]string{
+				"conjur": {
+					"CONJUR_SECRET1=conjur:prod/db/password",
+				},
+			},
+		},
+		{
+			name: "doppler provider",
+			envs: map[string]string{
+				"DOPPLER_SECRET1": "doppler:prd/DB_PASSWORD",
+			},
+			wantPaths: map[string][]string{
+				"doppler": {
+					"DOPPLER_SECRET1=doppler:prd/DB_PASSWORD",
+				},
+			},
+		},
+		{
+			name: "compose provider",
+			envs: map[string]string{
+				"DB_URL": "compose:{{.u}}:{{.p}}@db|u=vault:secret/data/db#user|p=file:secret/db/pass",
+			},
+			wantPaths: map[string][]string{
+				"compose": {
+					"DB_URL=compose:{{.u}}:{{.p}}@db|u=vault:secret/data/db#user|p=file:secret/db/pass",
+				},
+			},
+		},
 		{
 			name: "multi provider",
 			envs: map[string]string{
@@ -193,6 +255,189 @@ func TestEnvStore_GetSecretReferences(t *testing.T) {
 	}
 }
 
+func TestEnvStore_GetSecretReferencesEnvPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		envPrefix string
+		wantPaths map[string][]string
+	}{
+		{
+			name:      "Only prefixed keys are scanned",
+			envPrefix: "APP__",
+			wantPaths: map[string][]string{
+				"vault": {"APP__DB__PASSWORD=vault:secret/data/db#password"},
+				"file":  {"app__db__user=file:secret/db/user"},
+			},
+		},
+		{
+			name:      "All keys are scanned without prefix",
+			envPrefix: "",
+			wantPaths: map[string][]string{
+				"vault": {"APP__DB__PASSWORD=vault:secret/data/db#password", "OTHER_PASSWORD=vault:secret/data/other#password"},
+				"file":  {"app__db__user=file:secret/db/user"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			os.Setenv("APP__DB__PASSWORD", "vault:secret/data/db#password")
+			os.Setenv("app__db__user", "file:secret/db/user")
+			os.Setenv("OTHER_PASSWORD", "vault:secret/data/other#password")
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			paths := NewEnvStore(&common.Config{EnvPrefix: ttp.envPrefix}).GetSecretReferences()
+
+			assert.Len(t, paths, len(ttp.wantPaths), "Unexpected providers")
+			for key, expectedSlice := range ttp.wantPaths {
+				assert.ElementsMatch(t, expectedSlice, paths[key], "Slices for key %s do not match", key)
+			}
+		})
+	}
+}
+
+func TestEnvStore_GetSecretReferencesIgnoreEnv(t *testing.T) {
+	secretFile := newSecretFile(t, "s3cr3t")
+	defer os.Remove(secretFile)
+
+	os.Setenv("DB_PASSWORD", "file:"+secretFile)
+	os.Setenv("UPLOAD_TARGET", "file:"+secretFile)
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	envStore := NewEnvStore(&common.Config{IgnoreEnv: []string{"UPLOAD_TARGET"}})
+	paths := envStore.GetSecretReferences()
+	assert.Equal(t, map[string][]string{"file": {"DB_PASSWORD=file:" + secretFile}}, paths, "Ignored env vars should not be scanned")
+
+	providerSecrets, err := envStore.LoadProviderSecrets(context.Background(), paths)
+	require.NoError(t, err)
+
+	secretsEnv := envStore.ConvertProviderSecrets(providerSecrets)
+	assert.Equal(t, []string{"DB_PASSWORD=s3cr3t"}, secretsEnv, "Only the scanned env var should be resolved")
+}
+
+func TestEnvStore_GetSecretReferencesOnlyEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		onlyEnv   []string
+		ignoreEnv []string
+		wantPaths map[string][]string
+	}{
+		{
+			name:    "Only allowed keys are scanned",
+			onlyEnv: []string{"DB_PASSWORD", "API_TOKEN"},
+			wantPaths: map[string][]string{
+				"vault": {"DB_PASSWORD=vault:secret/data/db#password"},
+				"file":  {"API_TOKEN=file:secret/api/token"},
+			},
+		},
+		{
+			name:      "Ignored keys are removed from the allowlist",
+			onlyEnv:   []string{"DB_PASSWORD", "API_TOKEN"},
+			ignoreEnv: []string{"API_TOKEN", "OTHER_PASSWORD"},
+			wantPaths: map[string][]string{
+				"vault": {"DB_PASSWORD=vault:secret/data/db#password"},
+			},
+		},
+		{
+			name:      "Empty allowlist scans nothing",
+			onlyEnv:   []string{},
+			wantPaths: map[string][]string{},
+		},
+		{
+			name: "All keys are scanned without allowlist",
+			wantPaths: map[string][]string{
+				"vault": {"DB_PASSWORD=vault:secret/data/db#password", "OTHER_PASSWORD=vault:secret/data/other#password"},
+				"file":  {"API_TOKEN=file:secret/api/token"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			os.Setenv("DB_PASSWORD", "vault:secret/data/db#password")
+			os.Setenv("API_TOKEN", "file:secret/api/token")
+			os.Setenv("OTHER_PASSWORD", "vault:secret/data/other#password")
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			paths := NewEnvStore(&common.Config{OnlyEnv: ttp.onlyEnv, IgnoreEnv: ttp.ignoreEnv}).GetSecretReferences()
+
+			assert.Len(t, paths, len(ttp.wantPaths), "Unexpected providers")
+			for key, expectedSlice := range ttp.wantPaths {
+				assert.ElementsMatch(t, expectedSlice, paths[key], "Slices for key %s do not match", key)
+			}
+		})
+	}
+}
+
+func TestEnvStore_GetSecretReferencesPlaceholders(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		environment string
+		wantPaths   map[string][]string
+	}{
+		{
+			name:        "Placeholders are substituted across providers",
+			environment: "staging",
+			wantPaths: map[string][]string{
+				"vault": {"DB_PASSWORD=vault:secret/data/staging/app#password"},
+				"aws":   {"API_KEY=arn:aws:secretsmanager:eu-west-1:123456789012:secret:staging/api-key"},
+				"gcp":   {"TOKEN=gcp:secretmanager:projects/app/secrets/staging-token"},
+				"file":  {"NODE_KEY=file:/secrets/" + hostname + "/staging/key"},
+			},
+		},
+		{
+			name: "Unresolved placeholders are kept",
+			wantPaths: map[string][]string{
+				"vault": {"DB_PASSWORD=vault:secret/data/{env}/app#password"},
+				"aws":   {"API_KEY=arn:aws:secretsmanager:eu-west-1:123456789012:secret:{env}/api-key"},
+				"gcp":   {"TOKEN=gcp:secretmanager:projects/app/secrets/{env}-token"},
+				"file":  {"NODE_KEY=file:/secrets/" + hostname + "/{env}/key"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			os.Setenv("DB_PASSWORD", "vault:secret/data/{env}/app#password")
+			os.Setenv("API_KEY", "arn:aws:secretsmanager:eu-west-1:123456789012:secret:{env}/api-key")
+			os.Setenv("TOKEN", "gcp:secretmanager:projects/app/secrets/{env}-token")
+			os.Setenv("NODE_KEY", "file:/secrets/{hostname}/{env}/key")
+			os.Setenv("GREETING", "hello {env}")
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			envStore := NewEnvStore(&common.Config{Environment: ttp.environment})
+			paths := envStore.GetSecretReferences()
+
+			assert.Len(t, paths, len(ttp.wantPaths), "Unexpected providers")
+			for key, expectedSlice := range ttp.wantPaths {
+				assert.ElementsMatch(t, expectedSlice, paths[key], "Slices for key %s do not match", key)
+			}
+			assert.Equal(t, "hello {env}", envStore.data["GREETING"], "Plain values should not be substituted")
+		})
+	}
+}
+
+func TestEnvStore_LoadProviderSecretsUnresolvedPlaceholder(t *testing.T) {
+	_, err := NewEnvStore(&common.Config{}).LoadProviderSecrets(context.Background(), map[string][]string{
+		"vault": {"DB_PASSWORD=vault:secret/data/{env}/app#password"},
+	})
+	assert.EqualError(t, err, "invalid reference for DB_PASSWORD: unresolved placeholder {env}, SECRET_INIT_ENVIRONMENT is not set", "Unexpected error message")
+}
+
 func TestEnvStore_LoadProviderSecrets(t *testing.T) {
 	secretFile := newSecretFile(t, "secretId")
 	defer os.Remove(secretFile)
@@ -212,8 +457,10 @@ func TestEnvStore_LoadProviderSecrets(t *testing.T) {
 			},
 			wantProviderSecrets: []provider.Secret{
 				{
-					Key:   "AWS_SECRET_ACCESS_KEY_ID",
-					Value: "secretId",
+					Key:       "AWS_SECRET_ACCESS_KEY_ID",
+					Value:     "secretId",
+					Provider:  file.ProviderType,
+					Reference: "file:" + secretFile,
 				},
 			},
 		},
@@ -244,6 +491,773 @@ func TestEnvStore_LoadProviderSecrets(t *testing.T) {
 	}
 }
 
+func TestEnvStore_LoadProviderSecretsRegisteredFactory(t *testing.T) {
+	os.Clearenv()
+
+	stdinProvider, err := stdin.NewProvider(strings.NewReader(`{"DB_PASSWORD":"s3cr3t"}`))
+	require.NoError(t, err)
+
+	envStore := NewEnvStore(&common.Config{})
+	envStore.RegisterFactory(stdinProvider.Factory())
+	envStore.AddReferences(stdinProvider.References())
+
+	paths := envStore.GetSecretReferences()
+	assert.Equal(t, map[string][]string{"stdin": {"DB_PASSWORD=stdin:DB_PASSWORD"}}, paths, "Unexpected references")
+
+	secrets, err := envStore.LoadProviderSecrets(context.Background(), paths)
+	require.NoError(t, err)
+	assert.Equal(t, []provider.Secret{{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: stdin.ProviderType, Reference: "stdin:DB_PASSWORD"}}, secrets, "Unexpected secrets")
+}
+
+func TestEnvStore_RegisteredFactoryReferences(t *testing.T) {
+	os.Clearenv()
+
+	stdinProvider, err := stdin.NewProvider(strings.NewReader(`{"prod_DB_PASSWORD":"s3cr3t"}`))
+	require.NoError(t, err)
+
+	envStore := NewEnvStore(&common.Config{Environment: "prod"})
+	envStore.RegisterFactory(stdinProvider.Factory())
+	envStore.AddReferences(map[string]string{
+		"DB_PASSWORD": "stdin:{env}_DB_PASSWORD",
+		"DB_URL":      "compose:root:{{.p}}@db|p=stdin:{env}_DB_PASSWORD",
+		"DB_DSN":      "root:${stdin:prod_DB_PASSWORD}@db",
+	})
+
+	paths := envStore.GetSecretReferences()
+	assert.Equal(t, map[string][]string{
+		"stdin":              {"DB_PASSWORD=stdin:prod_DB_PASSWORD"},
+		"compose":            {"DB_URL=compose:root:{{.p}}@db|p=stdin:prod_DB_PASSWORD"},
+		templateProviderType: {"DB_DSN=root:${stdin:prod_DB_PASSWORD}@db"},
+	}, paths, "Unexpected references")
+
+	secrets, err := envStore.LoadProviderSecrets(context.Background(), paths)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []provider.Secret{
+		{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: stdin.ProviderType, Reference: "stdin:prod_DB_PASSWORD"},
+		{Key: "DB_URL", Value: "root:s3cr3t@db", Provider: "compose"},
+		{Key: "DB_DSN", Value: "root:s3cr3t@db", Provider: templateProviderType},
+	}, secrets, "Unexpected secrets")
+}
+
+// closingProvider serves its references as values and counts how often it is closed
+type closingProvider struct {
+	closed int
+}
+
+func (p *closingProvider) GetProviderName() string {
+	return "closing"
+}
+
+func (p *closingProvider) LoadSecrets(_ context.Context, paths []string) ([]provider.Secret, error) {
+	var secrets []provider.Secret
+	for _, path := range paths {
+		key, reference, _ := strings.Cut(path, "=")
+		secrets = append(secrets, provider.Secret{Key: key, Value: strings.TrimPrefix(reference, "closing:")})
+	}
+
+	return secrets, nil
+}
+
+func (p *closingProvider) Close() error {
+	p.closed++
+
+	return nil
+}
+
+func TestEnvStore_Close(t *testing.T) {
+	created := 0
+	closingProvider := &closingProvider{}
+
+	envStore := NewEnvStore(&common.Config{})
+	envStore.RegisterFactory(provider.Factory{
+		ProviderType: "closing",
+		Validator:    func(envValue string) bool { return strings.HasPrefix(envValue, "closing:") },
+		Create: func(_ context.Context, _ *common.Config) (provider.Provider, error) {
+			created++
+
+			return closingProvider, nil
+		},
+	})
+
+	// Reloads reuse the provider created by the first load
+	paths := map[string][]string{"closing": {"DB_PASSWORD=closing:s3cr3t"}}
+	for range 2 {
+		secrets, err := envStore.LoadProviderSecrets(context.Background(), paths)
+		require.NoError(t, err)
+		assert.Equal(t, []provider.Secret{{Key: "DB_PASSWORD", Value: "s3cr3t"}}, secrets, "Unexpected secrets")
+		assert.Zero(t, closingProvider.closed, "Provider must not be closed by a load")
+	}
+	assert.Equal(t, 1, created, "Provider must be created once")
+
+	require.NoError(t, envStore.Close())
+	require.NoError(t, envStore.Close())
+	assert.Equal(t, 1, closingProvider.closed, "Provider must be closed once")
+}
+
+// revokingProvider revokes its token on every load, like vault and bao with their REVOKE_TOKEN setting
+type revokingProvider struct {
+	closingProvider
+}
+
+func (p *revokingProvider) Revoked() bool {
+	return true
+}
+
+func TestEnvStore_LoadProviderSecretsRevoked(t *testing.T) {
+	created := 0
+
+	envStore := NewEnvStore(&common.Config{})
+	envStore.RegisterFactory(provider.Factory{
+		ProviderType: "closing",
+		Validator:    func(envValue string) bool { return strings.HasPrefix(envValue, "closing:") },
+		Create: func(_ context.Context, _ *common.Config) (provider.Provider, error) {
+			created++
+
+			return &revokingProvider{}, nil
+		},
+	})
+
+	// A provider which revoked its token is created again by the next load
+	paths := map[string][]string{"closing": {"DB_PASSWORD=closing:s3cr3t"}}
+	for range 2 {
+		_, err := envStore.LoadProviderSecrets(context.Background(), paths)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, created, "Provider must be created for every load")
+
+	require.NoError(t, envStore.Close())
+}
+
+func TestEnvStore_LoadProviderSecretsCached(t *testing.T) {
+	secretFile := newSecretFile(t, "secretId")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	providerPaths := func() map[string][]string {
+		return map[string][]string{
+			"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile},
+		}
+	}
+
+	envStore := NewEnvStore(&common.Config{CacheTTL: time.Hour})
+
+	providerSecrets, err := envStore.LoadProviderSecrets(context.Background(), providerPaths())
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile}}, providerSecrets)
+
+	// The provider would fail now, so the secret must be served from the cache
+	err = os.Remove(secretFile)
+	assert.Nil(t, err, "Failed to remove secret file")
+
+	providerSecrets, err = envStore.LoadProviderSecrets(context.Background(), providerPaths())
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile}}, providerSecrets)
+}
+
+func TestEnvStore_LoadProviderSecretsComposed(t *testing.T) {
+	userFile := newSecretFile(t, "root")
+	passwordFile := newSecretFile(t, "p@ss")
+
+	tests := []struct {
+		name                string
+		providerPaths       map[string][]string
+		wantProviderSecrets []provider.Secret
+		err                 error
+	}{
+		{
+			name: "Compose secret successfully",
+			providerPaths: map[string][]string{
+				"compose": {
+					"DB_URL=compose:postgres://{{.u}}:{{.p | urlquery}}@db:5432|u=file:" + userFile + "|p=file:" + passwordFile,
+				},
+				"file": {
+					"DB_USER=file:" + userFile,
+				},
+			},
+			wantProviderSecrets: []provider.Secret{
+				{Key: "DB_USER", Value: "root", Provider: file.ProviderType, Reference: "file:" + userFile},
+				{Key: "DB_URL", Value: "postgres://root:p%40ss@db:5432", Provider: composeProviderType},
+			},
+		},
+		{
+			name: "Compose chained secrets in dependency order",
+			providerPaths: map[string][]string{
+				"compose": {
+					"DB_URL=compose:{{.auth}}@db:5432|auth=compose:DB_AUTH",
+					"DB_AUTH=compose:{{.u}}:{{.p}}|u=compose:DB_USER|p=file:" + passwordFile,
+					"DB_USER=compose:{{.u}}|u=file:" + userFile,
+				},
+			},
+			wantProviderSecrets: []provider.Secret{
+				{Key: "DB_USER", Value: "root", Provider: composeProviderType},
+				{Key: "DB_AUTH", Value: "root:p@ss", Provider: composeProviderType},
+				{Key: "DB_URL", Value: "root:p@ss@db:5432", Provider: composeProviderType},
+			},
+		},
+		{
+			name: "Fail on dependency cycle",
+			providerPaths: map[string][]string{
+				"compose": {
+					"DB_URL=compose:{{.auth}}|auth=compose:DB_AUTH",
+					"DB_AUTH=compose:{{.url}}|url=compose:DB_URL",
+				},
+			},
+			err: fmt.Errorf("failed to compose DB_AUTH: dependency cycle DB_AUTH -> DB_URL -> DB_AUTH"),
+		},
+		{
+			name: "Fail on dependency that is not composed",
+			providerPaths: map[string][]string{
+				"compose": {"DB_URL=compose:{{.auth}}|auth=compose:DB_AUTH"},
+			},
+			err: fmt.Errorf("failed to compose DB_URL: sub-reference auth refers to DB_AUTH, which is not composed"),
+		},
+		{
+			name: "Fail on missing sub-references",
+			providerPaths: map[string][]string{
+				"compose": {"DB_URL=compose:{{.u}}"},
+			},
+			err: fmt.Errorf("failed to compose DB_URL: no sub-references found"),
+		},
+		{
+			name: "Fail on unsupported sub-reference",
+			providerPaths: map[string][]string{
+				"compose": {"DB_URL=compose:{{.u}}|u=unknown:user"},
+			},
+			err: fmt.Errorf("failed to compose DB_URL: sub-reference u is not supported"),
+		},
+		{
+			name: "Fail on unknown template field",
+			providerPaths: map[string][]string{
+				"compose": {"DB_URL=compose:{{.u}}:{{.p}}|u=file:" + userFile},
+			},
+			err: fmt.Errorf(`failed to compose DB_URL: template: DB_URL:1:9: executing "DB_URL" at <.p>: map has no entry for key "p"`),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			providerSecrets, err := NewEnvStore(&common.Config{}).LoadProviderSecrets(context.Background(), ttp.providerPaths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.ElementsMatch(t, ttp.wantProviderSecrets, providerSecrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestExpandComposedReferences(t *testing.T) {
+	providerPaths := map[string][]string{
+		"compose": {"DB_URL=compose:{{.u}}:{{.p}}@db|u=vault:secret/data/db#user|p=file:secret/db/pass"},
+	}
+
+	envStore := NewEnvStore(&common.Config{})
+	composedReferences, expandedPaths, err := envStore.expandComposedReferences(providerPaths)
+	assert.Nil(t, err, "Unexpected error")
+
+	assert.Equal(t, map[string][]string{
+		"vault": {"DB_URL.u=vault:secret/data/db#user"},
+		"file":  {"DB_URL.p=file:secret/db/pass"},
+	}, expandedPaths, "Unexpected provider paths")
+	assert.Len(t, composedReferences, 1)
+	assert.Equal(t, map[string]string{"u": "DB_URL.u", "p": "DB_URL.p"}, composedReferences[0].subKeys)
+}
+
+func TestSortComposedReferences(t *testing.T) {
+	composedReferences := []composedReference{
+		{key: "A", dependencies: map[string]string{"b": "B"}},
+		{key: "B", dependencies: map[string]string{"c": "C"}},
+		{key: "C"},
+	}
+
+	sorted, err := sortComposedReferences(composedReferences)
+	assert.Nil(t, err, "Unexpected error")
+
+	keys := make([]string, 0, len(sorted))
+	for _, composed := range sorted {
+		keys = append(keys, composed.key)
+	}
+	assert.Equal(t, []string{"C", "B", "A"}, keys, "Unexpected order")
+
+	composedReferences[2].dependencies = map[string]string{"a": "A"}
+	_, err = sortComposedReferences(composedReferences)
+	assert.EqualError(t, err, "failed to compose A: dependency cycle A -> B -> C -> A", "Unexpected error message")
+}
+
+func TestEnvStore_LoadProviderSecretsReferenceLimit(t *testing.T) {
+	userFile := newSecretFile(t, "root")
+	passwordFile := newSecretFile(t, "p@ss")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	providerPaths := func() map[string][]string {
+		return map[string][]string{
+			"file": {
+				"DB_USER=file:" + userFile,
+				"DB_PASSWORD=file:" + passwordFile,
+			},
+		}
+	}
+
+	tests := []struct {
+		name                string
+		limit               int
+		wantProviderSecrets []provider.Secret
+		err                 error
+	}{
+		{
+			name:  "Unlimited references",
+			limit: 0,
+			wantProviderSecrets: []provider.Secret{
+				{Key: "DB_USER", Value: "root", Provider: file.ProviderType, Reference: "file:" + userFile},
+				{Key: "DB_PASSWORD", Value: "p@ss", Provider: file.ProviderType, Reference: "file:" + passwordFile},
+			},
+		},
+		{
+			name:  "References under the limit",
+			limit: 2,
+			wantProviderSecrets: []provider.Secret{
+				{Key: "DB_USER", Value: "root", Provider: file.ProviderType, Reference: "file:" + userFile},
+				{Key: "DB_PASSWORD", Value: "p@ss", Provider: file.ProviderType, Reference: "file:" + passwordFile},
+			},
+		},
+		{
+			name:  "References over the limit",
+			limit: 1,
+			err:   fmt.Errorf("provider file has 2 references, exceeding the limit of 1 set by SECRET_INIT_MAX_REFERENCES_PER_PROVIDER"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			envStore := NewEnvStore(&common.Config{MaxReferencesPerProvider: ttp.limit})
+
+			providerSecrets, err := envStore.LoadProviderSecrets(context.Background(), providerPaths())
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.Nil(t, err, "Unexpected error")
+				assert.ElementsMatch(t, ttp.wantProviderSecrets, providerSecrets, "Unexpected secrets")
+			}
+		})
+	}
+}
+
+func TestSplitDefaultValues(t *testing.T) {
+	tests := []struct {
+		name              string
+		delimiter         string
+		providerPaths     map[string][]string
+		wantDefaultValues map[string]string
+		wantPaths         map[string][]string
+	}{
+		{
+			name:      "Default values are stripped",
+			delimiter: "|",
+			providerPaths: map[string][]string{
+				"vault": {"DB_PORT=vault:secret/data/db#port|5432", "DB_HOST=vault:secret/data/db#host"},
+				"file":  {"DB_USER=file:secret/db/user|"},
+			},
+			wantDefaultValues: map[string]string{
+				"DB_PORT": "5432",
+				"DB_USER": "",
+			},
+			wantPaths: map[string][]string{
+				"vault": {"DB_PORT=vault:secret/data/db#port", "DB_HOST=vault:secret/data/db#host"},
+				"file":  {"DB_USER=file:secret/db/user"},
+			},
+		},
+		{
+			name:      "Custom delimiter",
+			delimiter: "||",
+			providerPaths: map[string][]string{
+				"vault": {"DB_OPTS=vault:secret/data/db#opts||a|b"},
+			},
+			wantDefaultValues: map[string]string{
+				"DB_OPTS": "a|b",
+			},
+			wantPaths: map[string][]string{
+				"vault": {"DB_OPTS=vault:secret/data/db#opts"},
+			},
+		},
+		{
+			name:      "Templated references are left as is",
+			delimiter: "|",
+			providerPaths: map[string][]string{
+				"vault": {
+					"DB_URL=scheme://${vault:secret/data/db#user}:${vault:secret/data/db#${.password | urlquery}}@db",
+					"DB_PASSWORD=vault:secret/data/db#${.password | urlquery}",
+				},
+			},
+			wantDefaultValues: map[string]string{},
+			wantPaths: map[string][]string{
+				"vault": {
+					"DB_URL=scheme://${vault:secret/data/db#user}:${vault:secret/data/db#${.password | urlquery}}@db",
+					"DB_PASSWORD=vault:secret/data/db#${.password | urlquery}",
+				},
+			},
+		},
+		{
+			name:      "Default values disabled",
+			delimiter: "",
+			providerPaths: map[string][]string{
+				"vault": {"DB_PORT=vault:secret/data/db#port|5432"},
+			},
+			wantPaths: map[string][]string{
+				"vault": {"DB_PORT=vault:secret/data/db#port|5432"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			defaultValues, paths := splitDefaultValues(ttp.providerPaths, ttp.delimiter)
+
+			assert.Equal(t, ttp.wantDefaultValues, defaultValues, "Unexpected default values")
+			assert.Equal(t, ttp.wantPaths, paths, "Unexpected provider paths")
+		})
+	}
+}
+
+func TestEnvStore_LoadProviderSecretsDefaultValues(t *testing.T) {
+	secretFile := newSecretFile(t, "secretId")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	providerSecrets, err := NewEnvStore(&common.Config{DefaultDelimiter: "|"}).LoadProviderSecrets(context.Background(), map[string][]string{
+		"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile + "|fallback"},
+	})
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile}}, providerSecrets, "Loaded secret must win over its default value")
+
+	// Skipped references resolve to their default value, empty ones included
+	providerSecrets = applyDefaultValues(providerSecrets, map[string]string{
+		"AWS_SECRET_ACCESS_KEY_ID": "fallback",
+		"DB_PORT":                  "5432",
+		"DB_USER":                  "",
+	})
+	assert.ElementsMatch(t, []provider.Secret{
+		{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile},
+		{Key: "DB_PORT", Value: "5432"},
+		{Key: "DB_USER", Value: ""},
+	}, providerSecrets, "Unexpected secrets")
+}
+
+func TestEnvStore_LoadProviderSecretsDecoded(t *testing.T) {
+	secretFile := newSecretFile(t, "c2VjcmV0SWQ=")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	tests := []struct {
+		name                string
+		providerPaths       map[string][]string
+		wantProviderSecrets []provider.Secret
+		err                 error
+	}{
+		{
+			name: "Decode secret",
+			providerPaths: map[string][]string{
+				"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile + "?decode=base64"},
+			},
+			wantProviderSecrets: []provider.Secret{
+				{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile},
+			},
+		},
+		{
+			name: "Fail on invalid input",
+			providerPaths: map[string][]string{
+				"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile + "?decode=hex"},
+			},
+			err: fmt.Errorf("failed to decode secret AWS_SECRET_ACCESS_KEY_ID: invalid hex value: encoding/hex: invalid byte: U+0056 'V'"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			providerSecrets, err := NewEnvStore(&common.Config{}).LoadProviderSecrets(context.Background(), ttp.providerPaths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantProviderSecrets, providerSecrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestEnvStore_LoadProviderSecretsBinary(t *testing.T) {
+	keystoreFile := newSecretFile(t, "AAECAw==")
+	secretFile := newSecretFile(t, "secretId")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	envStore := NewEnvStore(&common.Config{})
+	providerSecrets, err := envStore.LoadProviderSecrets(context.Background(), map[string][]string{
+		"file": {
+			"KEYSTORE=file:" + keystoreFile + "?decode=base64&binary=true",
+			"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile,
+		},
+	})
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{
+		{Key: "KEYSTORE", Value: "\x00\x01\x02\x03", Binary: true, Provider: file.ProviderType, Reference: "file:" + keystoreFile},
+		{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile},
+	}, providerSecrets, "Unexpected secrets")
+
+	assert.Equal(t, []string{"AWS_SECRET_ACCESS_KEY_ID=secretId"}, envStore.ConvertProviderSecrets(providerSecrets), "Binary secrets must not be set as env vars")
+
+	_, err = envStore.LoadProviderSecrets(context.Background(), map[string][]string{
+		"file": {"KEYSTORE=file:" + keystoreFile + "?binary=maybe"},
+	})
+	assert.EqualError(t, err, `invalid reference for KEYSTORE: invalid binary option "maybe"`, "Unexpected error message")
+}
+
+func TestEnvStore_LoadProviderSecretsFileTarget(t *testing.T) {
+	secretFile := newSecretFile(t, "secretId")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	envStore := NewEnvStore(&common.Config{})
+	providerSecrets, err := envStore.LoadProviderSecrets(context.Background(), map[string][]string{
+		"file": {
+			"TLS_CRT=file:" + secretFile + "?file=/etc/tls/tls.crt",
+			"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile,
+		},
+	})
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{
+		{Key: "TLS_CRT", Value: "secretId", FilePath: "/etc/tls/tls.crt", Provider: file.ProviderType, Reference: "file:" + secretFile},
+		{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile},
+	}, providerSecrets, "Unexpected secrets")
+
+	assert.Equal(t, []string{"TLS_CRT=/etc/tls/tls.crt", "AWS_SECRET_ACCESS_KEY_ID=secretId"}, envStore.ConvertProviderSecrets(providerSecrets), "Secrets delivered as files must be set to their path")
+
+	_, err = envStore.LoadProviderSecrets(context.Background(), map[string][]string{
+		"file": {"TLS_CRT=file:" + secretFile + "?file=tls.crt"},
+	})
+	assert.EqualError(t, err, `invalid reference for TLS_CRT: file option must be an absolute path, got "tls.crt"`, "Unexpected error message")
+}
+
+func TestEnvStore_LoadProviderSecretsPinned(t *testing.T) {
+	secretFile := newSecretFile(t, "secretId")
+
+	tests := []struct {
+		name        string
+		provider    string
+		env         map[string]string
+		wantSecrets []provider.Secret
+		err         error
+	}{
+		{
+			name:     "Load references of the pinned provider",
+			provider: file.ProviderType,
+			env: map[string]string{
+				"AWS_SECRET_ACCESS_KEY_ID": "file:" + secretFile,
+			},
+			wantSecrets: []provider.Secret{
+				{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile},
+			},
+		},
+		{
+			name:     "Reject references of other providers",
+			provider: vault.ProviderType,
+			env: map[string]string{
+				"AWS_SECRET_ACCESS_KEY_ID": "file:" + secretFile,
+				"DB_PASSWORD":              "vault:secret/data/db#password",
+			},
+			err: fmt.Errorf("invalid reference for AWS_SECRET_ACCESS_KEY_ID: provider file is not allowed, SECRET_INIT_PROVIDER pins the provider to vault"),
+		},
+		{
+			name:     "Reject unknown pinned provider",
+			provider: "keepass",
+			env: map[string]string{
+				"AWS_SECRET_ACCESS_KEY_ID": "file:" + secretFile,
+			},
+			err: fmt.Errorf("unknown provider keepass set by SECRET_INIT_PROVIDER"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			for envKey, envVal := range ttp.env {
+				t.Setenv(envKey, envVal)
+			}
+
+			envStore := NewEnvStore(&common.Config{Provider: ttp.provider})
+			providerSecrets, err := envStore.LoadProviderSecrets(context.Background(), envStore.GetSecretReferences())
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, providerSecrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestEnvStore_LoadProviderSecretsDeduplicated(t *testing.T) {
+	// Fake Conjur appliance counting the fetches of each variable
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variable, ok := strings.CutPrefix(r.URL.Path, "/secrets/myorg/variable/")
+		if !ok {
+			_, _ = w.Write([]byte("dG9rZW4="))
+			return
+		}
+
+		mu.Lock()
+		fetches[variable]++
+		mu.Unlock()
+
+		_, _ = w.Write([]byte(variable + "-value"))
+	}))
+	defer server.Close()
+
+	t.Setenv(conjur.ApplianceURLEnv, server.URL)
+	t.Setenv(conjur.AccountEnv, "myorg")
+	t.Setenv(conjur.AuthnLoginEnv, "host/secret-init")
+	t.Setenv(conjur.AuthnAPIKeyEnv, "api-key")
+
+	providerSecrets, err := NewEnvStore(&common.Config{}).LoadProviderSecrets(context.Background(), map[string][]string{
+		"conjur": {
+			"DB_PASSWORD=conjur:db/password",
+			"MIGRATIONS_DB_PASSWORD=conjur:db/password",
+			"API_KEY=conjur:api/key",
+		},
+	})
+	assert.Nil(t, err, "Unexpected error")
+	assert.ElementsMatch(t, []provider.Secret{
+		{Key: "DB_PASSWORD", Value: "db/password-value", Provider: conjur.ProviderType, Reference: "conjur:db/password"},
+		{Key: "MIGRATIONS_DB_PASSWORD", Value: "db/password-value", Provider: conjur.ProviderType, Reference: "conjur:db/password"},
+		{Key: "API_KEY", Value: "api/key-value", Provider: conjur.ProviderType, Reference: "conjur:api/key"},
+	}, providerSecrets, "Unexpected secrets")
+	assert.Equal(t, map[string]int{"db/password": 1, "api/key": 1}, fetches, "Shared references must be fetched once")
+}
+
+func TestEnvStore_LoadProviderSecretsTimeout(t *testing.T) {
+	secretFile := newSecretFile(t, "secretId")
+
+	// Fake Doppler API hanging until the request is cancelled
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	t.Setenv(doppler.TokenEnv, "dp.st.prd.token")
+	t.Setenv(doppler.APIHostEnv, server.URL)
+
+	tests := []struct {
+		name                string
+		providerPaths       map[string][]string
+		wantProviderSecrets []provider.Secret
+		err                 error
+	}{
+		{
+			name: "Load secrets within the timeout",
+			providerPaths: map[string][]string{
+				"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile},
+			},
+			wantProviderSecrets: []provider.Secret{
+				{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile},
+			},
+		},
+		{
+			name: "Fail on a hanging provider",
+			providerPaths: map[string][]string{
+				"file":    {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile},
+				"doppler": {"DB_PASSWORD=doppler:prd/DB_PASSWORD"},
+			},
+			err: fmt.Errorf("provider doppler did not load its secrets within 100ms set by SECRET_INIT_TIMEOUT"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			start := time.Now()
+			providerSecrets, err := NewEnvStore(&common.Config{Timeout: 100 * time.Millisecond}).LoadProviderSecrets(context.Background(), ttp.providerPaths)
+			assert.Less(t, time.Since(start), time.Second, "Loading did not stop at the timeout")
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantProviderSecrets, providerSecrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestEnvStore_LoadProviderSecretsFailFast(t *testing.T) {
+	// Fake Doppler API hanging until the request is cancelled, for a while at most
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv(doppler.TokenEnv, "dp.st.prd.token")
+	t.Setenv(doppler.APIHostEnv, server.URL)
+
+	providerPaths := func() map[string][]string {
+		return map[string][]string{
+			"file":    {"AWS_SECRET_ACCESS_KEY_ID=file:/not/existing"},
+			"doppler": {"DB_PASSWORD=doppler:prd/DB_PASSWORD"},
+		}
+	}
+
+	// The slow provider is cancelled and waited for, only the first error is returned
+	start := time.Now()
+	_, err := NewEnvStore(&common.Config{FailFast: true}).LoadProviderSecrets(context.Background(), providerPaths())
+	assert.Less(t, time.Since(start), 5*time.Second, "Loading did not stop at the first error")
+	assert.EqualError(t, err, "failed to load secrets for provider file: failed to get secret from file: failed to read file: open not/existing: no such file or directory", "Unexpected error message")
+
+	// By default, all providers are waited for and their errors are joined
+	_, err = NewEnvStore(&common.Config{Timeout: 200 * time.Millisecond}).LoadProviderSecrets(context.Background(), providerPaths())
+	assert.EqualError(t, err, "provider doppler did not load its secrets within 200ms set by SECRET_INIT_TIMEOUT", "Unexpected error message")
+}
+
+func TestEnvStore_LoadProviderSecretsWatchAfterLoad(t *testing.T) {
+	secretFile := newSecretFile(t, "secretId")
+
+	restarts := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(provider.WithRestart(context.Background(), func() {
+		restarts <- struct{}{}
+	}))
+	defer cancel()
+
+	// The file is watched in daemon mode, with fail fast the load is cancelled once it is done
+	envStore := NewEnvStore(&common.Config{Daemon: true, FailFast: true})
+	_, err := envStore.LoadProviderSecrets(ctx, map[string][]string{"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile}})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(secretFile, []byte("newSecretId"), 0o600))
+
+	select {
+	case <-restarts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Restart was not requested after the file changed")
+	}
+}
+
 func TestEnvStore_ConvertProviderSecrets(t *testing.T) {
 	secretFile := newSecretFile(t, "secretId")
 	defer os.Remove(secretFile)
@@ -281,6 +1295,32 @@ func TestEnvStore_ConvertProviderSecrets(t *testing.T) {
 	}
 }
 
+// Creating most providers needs a live backend, so the provider types are checked against the factories instead.
+func TestFactories_GetProviderName(t *testing.T) {
+	providers := map[string]provider.Provider{
+		file.ProviderType:    &file.Provider{},
+		vault.ProviderType:   &vault.Provider{},
+		bao.ProviderType:     &bao.Provider{},
+		aws.ProviderType:     &aws.Provider{},
+		gcp.ProviderType:     &gcp.Provider{},
+		gcs.ProviderType:     &gcs.Provider{},
+		azure.ProviderType:   &azure.Provider{},
+		k8s.ProviderType:     &k8s.Provider{},
+		conjur.ProviderType:  &conjur.Provider{},
+		doppler.ProviderType: &doppler.Provider{},
+		sops.ProviderType:    &sops.Provider{},
+	}
+
+	for _, factory := range factories {
+		t.Run(factory.ProviderType, func(t *testing.T) {
+			p, ok := providers[factory.ProviderType]
+			if assert.True(t, ok, "Provider is missing from the test") {
+				assert.Equal(t, factory.ProviderType, p.GetProviderName(), "Unexpected provider name")
+			}
+		})
+	}
+}
+
 func newSecretFile(t *testing.T, content string) string {
 	dir := t.TempDir() + "/test/secrets"
 	err := os.MkdirAll(dir, 0o755)
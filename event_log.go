@@ -0,0 +1,110 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const eventMessage = "secret reference resolved"
+
+// newEventLogger creates a logger that emits one JSON line per resolved secret reference.
+// It is meant for log pipelines rather than humans, so it is kept separate from the application logger.
+// The sink is either "stdout", "stderr" or a path to a file the events get appended to.
+// The file is left open for the lifetime of the process, as events are written unbuffered.
+func newEventLogger(sink string) (*slog.Logger, error) {
+	var writer io.Writer
+	switch sink {
+	case "stdout":
+		writer = os.Stdout
+	case "stderr":
+		writer = os.Stderr
+	default:
+		file, err := os.OpenFile(sink, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open event log %s: %w", sink, err)
+		}
+
+		writer = file
+	}
+
+	return slog.New(newEventHandler(writer)), nil
+}
+
+// newEventHandler is a JSON handler variant without levels, since every event carries the same weight.
+func newEventHandler(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.LevelKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	})
+}
+
+// logEvents emits an event for each reference loaded by a provider.
+// Only the size of the values is recorded, the values themselves never are.
+// The references of a provider are loaded at once, so the duration is the one of the whole provider load.
+func logEvents(logger *slog.Logger, providerName string, paths []string, secrets []provider.Secret, duration time.Duration, loadErr error) {
+	if logger == nil {
+		return
+	}
+
+	sizes := make(map[string]int, len(secrets))
+	for _, secret := range secrets {
+		sizes[secret.Key] = len(secret.Value)
+	}
+
+	for _, path := range paths {
+		key, _, _ := strings.Cut(path, "=")
+		size, ok := sizes[key]
+
+		logger.Info(eventMessage,
+			slog.String("key", key),
+			slog.String("provider", providerName),
+			slog.Int64("provider_duration_ms", duration.Milliseconds()),
+			slog.Int("bytes", size),
+			slog.Bool("success", loadErr == nil && ok),
+			slog.Bool("cached", false),
+		)
+	}
+}
+
+// logCachedEvent emits the event of a reference served from the cache.
+// No provider was contacted for it, so the event has no duration.
+func logCachedEvent(logger *slog.Logger, providerName string, path string, secret provider.Secret) {
+	if logger == nil {
+		return
+	}
+
+	key, _, _ := strings.Cut(path, "=")
+
+	logger.Info(eventMessage,
+		slog.String("key", key),
+		slog.String("provider", providerName),
+		slog.Int("bytes", len(secret.Value)),
+		slog.Bool("success", true),
+		slog.Bool("cached", true),
+	)
+}
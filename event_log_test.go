@@ -0,0 +1,110 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+)
+
+func TestEventLog(t *testing.T) {
+	secretFile := newSecretFile(t, "secretId")
+	defer os.Remove(secretFile)
+	// A different file, references to the same secret are only fetched (and logged) once
+	otherSecretFile := newSecretFile(t, "s3cr3tId")
+	defer os.Remove(otherSecretFile)
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	var buf bytes.Buffer
+	envStore := NewEnvStore(&common.Config{})
+	envStore.eventLogger = slog.New(newEventHandler(&buf))
+
+	_, err := envStore.LoadProviderSecrets(context.Background(), map[string][]string{
+		"file": {
+			"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile,
+			"AWS_SECRET_ACCESS_KEY=file:" + otherSecretFile,
+		},
+	})
+	assert.Nil(t, err, "Unexpected error")
+
+	assert.NotContains(t, buf.String(), "secretId", "Secret value must not be logged")
+
+	var events []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event map[string]any
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &event), "Event is not valid JSON")
+		events = append(events, event)
+	}
+	assert.Len(t, events, 2, "Unexpected number of events")
+
+	var keys []any
+	for _, event := range events {
+		keys = append(keys, event["key"])
+		assert.Equal(t, eventMessage, event["msg"])
+		assert.Equal(t, "file", event["provider"])
+		assert.Equal(t, float64(len("secretId")), event["bytes"])
+		assert.Equal(t, true, event["success"])
+		assert.Contains(t, event, "provider_duration_ms")
+		assert.Equal(t, false, event["cached"])
+		assert.NotContains(t, event, "level")
+	}
+	assert.ElementsMatch(t, []any{"AWS_SECRET_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}, keys)
+}
+
+func TestEventLogCached(t *testing.T) {
+	secretFile := newSecretFile(t, "secretId")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	var buf bytes.Buffer
+	envStore := NewEnvStore(&common.Config{CacheTTL: time.Hour})
+	envStore.eventLogger = slog.New(newEventHandler(&buf))
+
+	providerPaths := func() map[string][]string {
+		return map[string][]string{"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile}}
+	}
+
+	_, err := envStore.LoadProviderSecrets(context.Background(), providerPaths())
+	assert.Nil(t, err, "Unexpected error")
+	buf.Reset()
+
+	// The second load is served from the cache, it is logged all the same
+	_, err = envStore.LoadProviderSecrets(context.Background(), providerPaths())
+	assert.Nil(t, err, "Unexpected error")
+
+	var event map[string]any
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &event), "Event is not valid JSON")
+	assert.Equal(t, eventMessage, event["msg"])
+	assert.Equal(t, "AWS_SECRET_ACCESS_KEY_ID", event["key"])
+	assert.Equal(t, "file", event["provider"])
+	assert.Equal(t, float64(len("secretId")), event["bytes"])
+	assert.Equal(t, true, event["success"])
+	assert.Equal(t, true, event["cached"])
+	assert.NotContains(t, event, "provider_duration_ms")
+}
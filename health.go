@@ -0,0 +1,100 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const healthShutdownTimeout = 5 * time.Second
+
+// health reports whether the secrets are resolved and the entrypoint process is running.
+// It is only created when SECRET_INIT_HEALTH_ADDR is set, a nil *health reports nothing.
+type health struct {
+	ready atomic.Bool
+}
+
+func newHealth() *health {
+	return &health{}
+}
+
+// setReady records a lifecycle change of the entrypoint process,
+// it is ready once started with resolved secrets and no longer ready once it has exited.
+func (h *health) setReady(ready bool) {
+	if h == nil {
+		return
+	}
+
+	h.ready.Store(ready)
+}
+
+func (h *health) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if !h.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+// serveHealth serves the readiness of the entrypoint process on /healthz in the background.
+func serveHealth(addr string, h *health) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", h)
+
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(fmt.Errorf("failed to serve health: %w", err).Error())
+		}
+	}()
+
+	slog.Info("serving health", slog.String("addr", server.Addr))
+
+	return server, nil
+}
+
+// shutdownHealth stops serving the readiness once the entrypoint process has exited.
+func shutdownHealth(server *http.Server) {
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to shut down health server: %w", err).Error())
+	}
+}
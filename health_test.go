@@ -0,0 +1,61 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeHealth(t *testing.T) {
+	h := newHealth()
+
+	server, err := serveHealth("127.0.0.1:0", h)
+	require.NoError(t, err)
+
+	status := func() int {
+		resp, err := http.Get("http://" + server.Addr + "/healthz")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, status(), "Must not be ready before the process has started")
+
+	h.setReady(true)
+	assert.Equal(t, http.StatusOK, status(), "Must be ready once the process has started")
+
+	// e.g. while restarting with reloaded secrets
+	h.setReady(false)
+	assert.Equal(t, http.StatusServiceUnavailable, status(), "Must not be ready once the process has exited")
+
+	shutdownHealth(server)
+
+	_, err = http.Get("http://" + server.Addr + "/healthz")
+	assert.Error(t, err, "Health must not be served after shutdown")
+}
+
+func TestHealthDisabled(t *testing.T) {
+	var h *health
+
+	assert.NotPanics(t, func() {
+		h.setReady(true)
+		shutdownHealth(nil)
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPreExecHook(t *testing.T) {
+	dir := t.TempDir()
+	sentinel := filepath.Join(dir, "sentinel")
+	script := filepath.Join(dir, "hook.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$MYSQL_PASSWORD\" > \"$SENTINEL\"\necho hook ran\n"), 0o700))
+
+	tests := []struct {
+		name       string
+		command    string
+		wantOutput string
+		err        error
+	}{
+		{
+			name:       "Hook gets the environment of the process",
+			command:    script,
+			wantOutput: "hook ran\n",
+		},
+		{
+			name:    "Failing hook",
+			command: "exit 3",
+			err:     fmt.Errorf("failed to run pre-exec hook: exit status 3"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := runPreExecHook(ttp.command, []string{"MYSQL_PASSWORD=s3cr3t", "SENTINEL=" + sentinel}, &stdout, &stdout)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantOutput, stdout.String(), "Unexpected output")
+
+			content, err := os.ReadFile(sentinel)
+			require.NoError(t, err)
+			assert.Equal(t, "s3cr3t\n", string(content), "Unexpected sentinel content")
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// writeKeystore writes a PKCS#12 keystore of the certificate, private key and CA certificates
+// held by the loaded secrets, for applications expecting a keystore instead of PEM values (e.g. Java).
+func writeKeystore(config *common.Config, providerSecrets []provider.Secret) error {
+	if config.KeystorePassword == "" {
+		return fmt.Errorf("%s must be set", common.KeystorePasswordEnv)
+	}
+
+	secrets := make(map[string]string, len(providerSecrets))
+	for _, secret := range providerSecrets {
+		secrets[secret.Key] = secret.Value
+	}
+
+	certPEM, ok := secrets[config.KeystoreCert]
+	if !ok {
+		return fmt.Errorf("certificate secret %q set by %s not found", config.KeystoreCert, common.KeystoreCertEnv)
+	}
+
+	keyPEM, ok := secrets[config.KeystoreKey]
+	if !ok {
+		return fmt.Errorf("private key secret %q set by %s not found", config.KeystoreKey, common.KeystoreKeyEnv)
+	}
+
+	// The CA certificates are optional
+	var caPEM string
+	if config.KeystoreCA != "" {
+		caPEM, ok = secrets[config.KeystoreCA]
+		if !ok {
+			return fmt.Errorf("CA secret %q set by %s not found", config.KeystoreCA, common.KeystoreCAEnv)
+		}
+	}
+
+	keystore, err := buildKeystore([]byte(certPEM), []byte(keyPEM), []byte(caPEM), config.KeystorePassword)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(config.KeystorePath, keystore)
+}
+
+// buildKeystore encodes a PKCS#12 keystore, the certificates following the first one
+// in the certificate PEM (the intermediates of a chain) are stored along with the CA certificates.
+func buildKeystore(certPEM []byte, keyPEM []byte, caPEM []byte, password string) ([]byte, error) {
+	// Also verifies that the private key matches the certificate
+	keyPair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate and private key: %w", err)
+	}
+
+	certs := make([]*x509.Certificate, 0, len(keyPair.Certificate))
+	for _, der := range keyPair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	caCerts := certs[1:]
+	for block, rest := pem.Decode(caPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		caCert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+
+		caCerts = append(caCerts, caCert)
+	}
+
+	keystore, err := pkcs12.Modern.Encode(keyPair.PrivateKey, certs[0], caCerts, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode keystore: %w", err)
+	}
+
+	return keystore, nil
+}
@@ -0,0 +1,154 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestWriteKeystore(t *testing.T) {
+	caCert, caKey, caPEM, _ := newTestCertificate(t, "test-ca", nil, nil)
+	cert, _, certPEM, keyPEM := newTestCertificate(t, "secret-init", caCert, caKey)
+	_, _, otherCertPEM, _ := newTestCertificate(t, "other", caCert, caKey)
+
+	tests := []struct {
+		name            string
+		config          *common.Config
+		providerSecrets []provider.Secret
+		wantCACerts     int
+		err             error
+	}{
+		{
+			name:   "Keystore with CA certificate",
+			config: &common.Config{KeystorePassword: "changeit", KeystoreCert: "TLS_CERT", KeystoreKey: "TLS_KEY", KeystoreCA: "TLS_CA"},
+			providerSecrets: []provider.Secret{
+				{Key: "TLS_CERT", Value: certPEM},
+				{Key: "TLS_KEY", Value: keyPEM},
+				{Key: "TLS_CA", Value: caPEM},
+			},
+			wantCACerts: 1,
+		},
+		{
+			name:   "Keystore with certificate chain",
+			config: &common.Config{KeystorePassword: "changeit", KeystoreCert: "TLS_CERT", KeystoreKey: "TLS_KEY"},
+			providerSecrets: []provider.Secret{
+				{Key: "TLS_CERT", Value: certPEM + caPEM},
+				{Key: "TLS_KEY", Value: keyPEM},
+			},
+			wantCACerts: 1,
+		},
+		{
+			name:   "Missing password",
+			config: &common.Config{KeystoreCert: "TLS_CERT", KeystoreKey: "TLS_KEY"},
+			err:    fmt.Errorf("SECRET_INIT_KEYSTORE_PASSWORD must be set"),
+		},
+		{
+			name:   "Missing private key secret",
+			config: &common.Config{KeystorePassword: "changeit", KeystoreCert: "TLS_CERT", KeystoreKey: "TLS_KEY"},
+			providerSecrets: []provider.Secret{
+				{Key: "TLS_CERT", Value: certPEM},
+			},
+			err: fmt.Errorf("private key secret \"TLS_KEY\" set by SECRET_INIT_KEYSTORE_KEY not found"),
+		},
+		{
+			name:   "Private key not matching the certificate",
+			config: &common.Config{KeystorePassword: "changeit", KeystoreCert: "TLS_CERT", KeystoreKey: "TLS_KEY"},
+			providerSecrets: []provider.Secret{
+				{Key: "TLS_CERT", Value: otherCertPEM},
+				{Key: "TLS_KEY", Value: keyPEM},
+			},
+			err: fmt.Errorf("failed to parse certificate and private key: tls: private key does not match public key"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			ttp.config.KeystorePath = filepath.Join(t.TempDir(), "keystore.p12")
+
+			err := writeKeystore(ttp.config, ttp.providerSecrets)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+
+			content, err := os.ReadFile(ttp.config.KeystorePath)
+			require.NoError(t, err)
+
+			privateKey, keystoreCert, caCerts, err := pkcs12.DecodeChain(content, ttp.config.KeystorePassword)
+			require.NoError(t, err, "Failed to read keystore back")
+
+			assert.Equal(t, cert.Raw, keystoreCert.Raw, "Unexpected certificate")
+			assert.IsType(t, &ecdsa.PrivateKey{}, privateKey, "Unexpected private key")
+			assert.Len(t, caCerts, ttp.wantCACerts, "Unexpected number of CA certificates")
+		})
+	}
+}
+
+// newTestCertificate creates a certificate signed by the given CA, or a self-signed CA certificate without one.
+func newTestCertificate(t *testing.T, commonName string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	parent, parentKey := template, key
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		parent, parentKey = ca, caKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	return cert, key, string(certPEM), string(keyPEM)
+}
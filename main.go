@@ -15,26 +15,64 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"net"
+	"net/http"
 	"os"
 	"os/exec"
-	"os/signal"
 	"slices"
+	"strings"
 	"time"
 
 	slogmulti "github.com/samber/slog-multi"
 	slogsyslog "github.com/samber/slog-syslog"
 
+	"github.com/bank-vaults/secret-init/pkg/buildinfo"
+	"github.com/bank-vaults/secret-init/pkg/cache"
 	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/mask"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	stdinprovider "github.com/bank-vaults/secret-init/pkg/provider/stdin"
+	"github.com/bank-vaults/secret-init/pkg/validate"
 )
 
-var Version = "dev"
-
 func main() {
+	if len(os.Args) == 2 && slices.Contains(versionCommands, os.Args[1]) {
+		fmt.Fprint(os.Stdout, buildinfo.Get().String())
+		os.Exit(0)
+	}
+
+	if len(os.Args) == 2 && os.Args[1] == configSchemaCommand {
+		err := printConfigSchema(os.Stdout)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to print config schema: %w", err).Error())
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	if len(os.Args) == 2 && os.Args[1] == selftestCommand {
+		err := runSelftest(os.Stdout, factories)
+		if err != nil {
+			slog.Error(fmt.Errorf("selftest failed: %w", err).Error())
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	// The flags before the entrypoint are left to secret-init
+	flags, _, err := splitArgs(os.Args)
+	if err != nil {
+		slog.Error(fmt.Errorf("failed to parse arguments: %w", err).Error())
+		os.Exit(1)
+	}
+
 	// Load application config
 	config, err := common.LoadConfig()
 	if err != nil {
@@ -42,8 +80,92 @@ func main() {
 		os.Exit(1)
 	}
 
+	if slices.Contains(flags, printFlag) {
+		config.Print = true
+	}
+
+	// Stdout is reserved for the export lines in print mode
+	if config.Print {
+		config.LogStderrOnly = true
+	}
+
 	initLogger(config)
 
+	// Settings are configured through the environment, no other flag is known
+	for _, flag := range flags {
+		if flag != printFlag {
+			slog.Warn(fmt.Sprintf("ignoring unknown flag %s, settings are configured through SECRET_INIT_* env vars", flag))
+		}
+	}
+
+	// Fetch all provider secrets and assemble env variables using envstore
+	envStore := NewEnvStore(config)
+
+	// Stdin is read up front, the entrypoint process gets an empty stdin instead of a half-consumed one
+	var stdin io.Reader = os.Stdin
+	if config.ReferencesStdinLines {
+		references, err := readReferenceLines(os.Stdin)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to read references from stdin: %w", err).Error())
+			os.Exit(1)
+		}
+
+		envStore.AddReferences(references)
+		stdin = nil
+	}
+
+	// Secrets piped to stdin are served by the stdin provider, referenced under their own keys
+	if config.StdinSecrets {
+		stdinProvider, err := stdinprovider.NewProvider(os.Stdin)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to read secrets from stdin: %w", err).Error())
+			os.Exit(1)
+		}
+
+		envStore.RegisterFactory(stdinProvider.Factory())
+		envStore.AddReferences(stdinProvider.References())
+		stdin = nil
+	}
+
+	// Dry run only lists the detected references, neither providers nor the entrypoint are touched
+	if config.DryRun {
+		err = printSecretReferences(os.Stdout, envStore.GetSecretReferences(), config.JSONLog)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	if config.EventLog != "" {
+		envStore.eventLogger, err = newEventLogger(config.EventLog)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to create event log: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	if config.AuditLog != "" {
+		envStore.auditLogger, err = newEventLogger(config.AuditLog)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to create audit log: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	// Print mode hands the secrets over to the calling shell instead of an entrypoint process
+	if config.Print {
+		err = printSecrets(context.Background(), os.Stdout, config, envStore)
+		closeProviders(envStore)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
 	// Get entrypoint data from arguments
 	binaryPath, binaryArgs, err := ExtractEntrypoint(os.Args)
 	if err != nil {
@@ -51,83 +173,431 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Fetch all provider secrets and assemble env variables using envstore
-	envStore := NewEnvStore(config)
+	// The shared cache replaces the in-memory one, so it only takes effect with caching enabled
+	if config.CacheTTL > 0 && config.CacheRedisURL != "" {
+		envStore.cache, err = newRedisCache(config)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to create redis cache: %w", err).Error())
+			os.Exit(1)
+		}
+	}
 
-	providerSecrets, err := envStore.LoadProviderSecrets(context.Background(), envStore.GetSecretReferences())
+	// So does the file cache, which keeps the secrets across restarts of the container
+	if config.CacheTTL > 0 && config.CachePath != "" {
+		envStore.cache, err = newFileCache(config)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to create file cache: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	// Metrics are served before any secret is loaded, so the first load is recorded as well
+	var metricsServer *http.Server
+	if config.MetricsAddr != "" {
+		envStore.metrics = newMetrics()
+		metricsServer, err = serveMetrics(config.MetricsAddr, envStore.metrics)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to serve metrics: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	// Readiness is served before any secret is loaded, so probes see the process as not ready yet
+	var readiness *health
+	var healthServer *http.Server
+	if config.HealthAddr != "" {
+		readiness = newHealth()
+		healthServer, err = serveHealth(config.HealthAddr, readiness)
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to serve health: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	// Providers may request a restart of the process when their secrets change (e.g. watched files),
+	// restart requests are coalesced while one is pending
+	restarts := make(chan struct{}, 1)
+	requestRestart := func() {
+		select {
+		case restarts <- struct{}{}:
+		default:
+		}
+	}
+	ctx, cancel := context.WithCancel(provider.WithRestart(context.Background(), requestRestart))
+
+	// Providers defer cleanups (e.g. revoking a passed through token) until the process has exited
+	cleanups := &exitCleanups{}
+	ctx = provider.WithCleanup(ctx, cleanups.register)
+
+	// Providers signal the process (e.g. on lease renewals) through the signaler, whichever process is running
+	signaler := &processSignaler{}
+	ctx = provider.WithSignal(ctx, signaler.signal)
+
+	// SIGHUP reloads the secrets the same way as a restart requested by a provider
+	var reload func()
+	if config.ReloadOnSighup {
+		reload = requestRestart
+	}
+
+	cmdEnv, secretValues, err := loadCommandEnv(ctx, config, envStore)
 	if err != nil {
-		slog.Error(fmt.Errorf("failed to extract secrets: %w", err).Error())
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 
-	secretsEnv := envStore.ConvertProviderSecrets(providerSecrets)
-
 	if config.Delay > 0 {
 		slog.Info(fmt.Sprintf("sleeping for %s...", config.Delay))
 		time.Sleep(config.Delay)
 	}
 
-	slog.Info("spawning process for provided entrypoint command")
-
-	cmd := exec.Command(binaryPath, binaryArgs...)
-	cmd.Env = append(os.Environ(), secretsEnv...)
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
+	if config.Daemon {
+		slog.Info("running in daemon mode")
+	}
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs)
+	// Only the secrets of providers without a change detection of their own are reloaded periodically
+	periodicReload := config.Daemon && config.ReloadInterval > 0 && !config.Disable && reloadReferences(envStore) != nil
+	if config.ReloadInterval > 0 && !periodicReload {
+		slog.Warn("periodic reload is disabled, it requires daemon mode and references of providers other than vault, bao and file")
+	}
 
-	err = cmd.Start()
+	// The files stay open, so every restart of the process inherits them as well
+	extraFiles, err := inheritedFiles(config.ExtraFiles)
 	if err != nil {
-		slog.Error(fmt.Errorf("failed to start process: %w", err).Error())
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 
-	if config.Daemon {
-		// in daemon mode, pass signals to the actual process
-		slog.Info("running in daemon mode")
+	for {
+		slog.Info("spawning process for provided entrypoint command")
 
+		cmd := newCommand(config, binaryPath, binaryArgs, cmdEnv, stdin, extraFiles)
+
+		// The output is copied through a pipe then, the copying is done once the process has been waited for
+		var stdoutMask, stderrMask *mask.Writer
+		if config.MaskOutput {
+			stdoutMask = mask.NewWriter(os.Stdout, secretValues)
+			stderrMask = mask.NewWriter(os.Stderr, secretValues)
+			cmd.Stdout = stdoutMask
+			cmd.Stderr = stderrMask
+		}
+
+		if config.PreExec != "" {
+			err = runPreExecHook(config.PreExec, cmdEnv, cmd.Stdout, cmd.Stderr)
+			if err != nil {
+				flushMasks(stdoutMask, stderrMask)
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		relay := catchSignals()
+
+		err = cmd.Start()
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to start process: %w", err).Error())
+			os.Exit(1)
+		}
+
+		signaler.set(cmd)
+		readiness.setReady(true)
+
+		var reloader *periodicReloader
+		if periodicReload {
+			reloader = newPeriodicReloader(config.ReloadInterval, func(ctx context.Context) (map[string]string, error) {
+				return reloadValues(ctx, envStore)
+			}, requestRestart)
+			reloader.start(ctx)
+		}
+
+		if config.Daemon {
+			// in daemon mode, pass signals to the actual process
+			relay.forward(cmd, config.StopSignal, config.StopTimeout, reload)
+		}
+
+		exited := make(chan error, 1)
 		go func() {
-			for sig := range sigs {
-				slog.Info("received signal", slog.String("signal", sig.String()))
+			exited <- cmd.Wait()
+		}()
 
-				// We don't want to signal a non-running process.
-				if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
-					break
+		restart := false
+	wait:
+		for {
+			select {
+			case err = <-exited:
+				break wait
+			case <-restarts:
+				slog.Info("restarting process with reloaded secrets")
+
+				// The process is only stopped once the new environment is ready, it keeps running with its secrets otherwise.
+				// Its cleanups are kept apart, so they don't revoke the tokens of the new load.
+				processCleanups := cleanups.detach()
+
+				// The periodic reloader is stopped first, so the secrets are never loaded concurrently
+				reloader.stop()
+
+				envStore.ClearCache()
+				newEnv, newValues, loadErr := loadCommandEnv(ctx, config, envStore)
+				if loadErr != nil {
+					slog.Error(fmt.Errorf("failed to reload secrets, keeping the running process: %w", loadErr).Error())
+
+					// Nothing uses the tokens of the failed load
+					cleanups.run()
+					cleanups.register(processCleanups.run)
+
+					// It keeps comparing against the secrets of the running process
+					if reloader != nil {
+						reloader.start(ctx)
+					}
+
+					continue
 				}
 
-				err := cmd.Process.Signal(sig)
-				if err != nil {
-					slog.Warn(
-						fmt.Errorf("failed to signal process: %w", err).Error(),
-						slog.String("signal", sig.String()),
-					)
-				}
+				readiness.setReady(false)
+
+				// The old process has to be gone before the new one is started, otherwise both would run at once
+				// (e.g. competing for the same port) with different secrets
+				stopProcess(cmd, exited, config.StopSignal, config.StopTimeout)
+				flushMasks(stdoutMask, stderrMask)
+				relay.stop()
+				processCleanups.run()
+
+				cmdEnv, secretValues = newEnv, newValues
+				restart = true
+				break wait
 			}
-		}()
+		}
+
+		if restart {
+			continue
+		}
+
+		readiness.setReady(false)
+		flushMasks(stdoutMask, stderrMask)
+
+		// Signals are no longer forwarded before anything else is torn down
+		relay.stop()
+		reloader.stop()
+		cleanups.run()
+
+		// Stops the watchers of the providers
+		cancel()
+		closeProviders(envStore)
+		shutdownMetrics(metricsServer)
+		shutdownHealth(healthServer)
+
+		if err != nil {
+			slog.Error(fmt.Errorf("failed to exec process: %w", err).Error())
+
+			// Exit with the original exit code if possible
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitCode(exitErr.ProcessState))
+			}
+
+			os.Exit(-1)
+		}
+
+		os.Exit(exitCode(cmd.ProcessState))
 	}
+}
+
+// entrypointEnviron returns the environment passed to the entrypoint process,
+// without the variables set from the config file, those only configure secret-init.
+func entrypointEnviron(config *common.Config) []string {
+	return slices.DeleteFunc(os.Environ(), func(env string) bool {
+		key, _, _ := strings.Cut(env, "=")
+		return slices.Contains(config.FileEnv, key)
+	})
+}
+
+// flushMasks writes the output held back by the masks, once the process has exited.
+func flushMasks(masks ...*mask.Writer) {
+	for _, m := range masks {
+		if m != nil {
+			_ = m.Flush()
+		}
+	}
+}
 
-	err = cmd.Wait()
+// closeProviders releases the clients of the providers, failing to do so doesn't affect the exit code.
+func closeProviders(envStore *EnvStore) {
+	err := envStore.Close()
+	if err != nil {
+		slog.Warn(err.Error())
+	}
+}
 
-	close(sigs)
+// loadCommandEnv loads the secrets and assembles the environment of the entrypoint process.
+// The loaded values are returned as well, so they can be masked in the output of the process.
+func loadCommandEnv(ctx context.Context, config *common.Config, envStore *EnvStore) ([]string, []string, error) {
+	// No provider is created, the references reach the entrypoint process as they are
+	if resolutionDisabled(config) {
+		return entrypointEnviron(config), nil, nil
+	}
 
+	providerSecrets, secretsEnv, err := loadSecrets(ctx, config, envStore)
 	if err != nil {
-		slog.Error(fmt.Errorf("failed to exec process: %w", err).Error())
+		return nil, nil, err
+	}
+
+	// Values delivered as files only are included, the process may print them just as well
+	secretValues := make([]string, 0, len(providerSecrets))
+	for _, secret := range providerSecrets {
+		secretValues = append(secretValues, secret.Value)
+	}
 
-		// Exit with the original exit code if possible
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			os.Exit(exitErr.ExitCode())
+	if config.KeystorePath != "" {
+		err = writeKeystore(config, providerSecrets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to write keystore: %w", err)
 		}
 
-		os.Exit(-1)
+		slog.Info("keystore written to file", slog.String("path", config.KeystorePath))
 	}
 
-	os.Exit(cmd.ProcessState.ExitCode())
+	// Secrets are either rendered into a file or injected as env variables, never both
+	if config.RenderPath != "" {
+		err = renderSecrets(config.RenderPath, providerSecrets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to render secrets: %w", err)
+		}
+
+		slog.Info("secrets rendered to file", slog.String("path", config.RenderPath))
+
+		return entrypointEnviron(config), secretValues, nil
+	}
+
+	return append(entrypointEnviron(config), secretsEnv...), secretValues, nil
+}
+
+// resolutionDisabled tells whether secret resolution is turned off, warning loudly if so.
+func resolutionDisabled(config *common.Config) bool {
+	if !config.Disable {
+		return false
+	}
+
+	slog.Warn(fmt.Sprintf("SECRET RESOLUTION IS DISABLED by %s, references are passed through unresolved", common.DisableEnv))
+
+	return true
+}
+
+// loadSecrets loads the secrets, validates the resulting environment and writes the secrets delivered as files.
+// The env variables of the secrets are returned along with the secrets, whether they are run or printed.
+func loadSecrets(ctx context.Context, config *common.Config, envStore *EnvStore) ([]provider.Secret, []string, error) {
+	secretReferences := envStore.GetSecretReferences()
+	start := time.Now()
+	providerSecrets, err := envStore.LoadProviderSecrets(ctx, secretReferences)
+	loadDuration := time.Since(start)
+
+	// The result is written on failure as well, so the failed references can be told apart
+	if config.ResultFile != "" {
+		resultErr := writeLoadResult(config.ResultFile, newLoadResult(secretReferences, providerSecrets, err))
+		if resultErr != nil {
+			slog.Warn(fmt.Errorf("failed to write result file: %w", resultErr).Error())
+		}
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to extract secrets: %w", err)
+	}
+
+	if config.SummaryFile != "" {
+		summaryErr := writeLoadSummary(config.SummaryFile, newLoadSummary(envStore.LoadedCounts(), loadDuration))
+		if summaryErr != nil {
+			slog.Warn(fmt.Errorf("failed to write summary file: %w", summaryErr).Error())
+		}
+	}
+
+	secretsEnv := envStore.ConvertProviderSecrets(providerSecrets)
+
+	// The process is never started with an environment known to be broken
+	err = validateEnviron(config, append(entrypointEnviron(config), secretsEnv...))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to validate secrets: %w", err)
+	}
+
+	logAudit(envStore.auditLogger, secretReferences, providerSecrets)
+
+	err = writeSecretFiles(config.FilesDir, providerSecrets)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to write secret files: %w", err)
+	}
+
+	return providerSecrets, secretsEnv, nil
+}
+
+// validateEnviron checks the environment of the entrypoint process against the required env vars
+// and the rules of the validation schema.
+func validateEnviron(config *common.Config, environ []string) error {
+	var schema validate.Schema
+	if config.ValidationSchema != "" {
+		var err error
+		schema, err = validate.LoadSchema(config.ValidationSchema)
+		if err != nil {
+			return err
+		}
+	}
+
+	return schema.Require(config.Require...).Validate(environ)
+}
+
+// newRedisCache creates the cache shared through Redis, encrypted with the configured key.
+func newRedisCache(config *common.Config) (cache.Store, error) {
+	key, err := readCacheKey(config, "shared cache")
+	if err != nil {
+		return nil, err
+	}
+
+	redisCache, err := cache.NewRedis(config.CacheRedisURL, key, config.CacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return redisCache, nil
+}
+
+// newFileCache creates the cache kept in a local file, encrypted with the configured key.
+func newFileCache(config *common.Config) (cache.Store, error) {
+	key, err := readCacheKey(config, "file cache")
+	if err != nil {
+		return nil, err
+	}
+
+	fileCache, err := cache.NewFile(config.CachePath, key, config.CacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileCache, nil
+}
+
+// readCacheKey returns the key material of SECRET_INIT_CACHE_KEY, or the one read from SECRET_INIT_CACHE_KEY_FILE.
+func readCacheKey(config *common.Config, cacheName string) ([]byte, error) {
+	if config.CacheKey != "" {
+		return []byte(config.CacheKey), nil
+	}
+
+	if config.CacheKeyFile == "" {
+		return nil, fmt.Errorf("%s or %s must be set to encrypt the %s", common.CacheKeyEnv, common.CacheKeyFileEnv, cacheName)
+	}
+
+	key, err := os.ReadFile(config.CacheKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache key: %w", err)
+	}
+
+	return key, nil
 }
 
 func initLogger(config *common.Config) {
+	logger := newLogger(config, os.Stdout, os.Stderr)
+
+	// Set the default logger to the configured logger,
+	// enabling direct usage of the slog package for logging.
+	slog.SetDefault(logger)
+}
+
+func newLogger(config *common.Config, stdout io.Writer, stderr io.Writer) *slog.Logger {
 	var level slog.Level
 
 	err := level.UnmarshalText([]byte(config.LogLevel))
@@ -135,54 +605,55 @@ func initLogger(config *common.Config) {
 		level = slog.LevelInfo
 	}
 
+	// Quiet mode only lets warnings and errors through
+	syslogLevel := slog.LevelInfo
+	if config.Quiet {
+		level = max(level, slog.LevelWarn)
+		syslogLevel = level
+	}
+
 	levelFilter := func(levels ...slog.Level) func(ctx context.Context, r slog.Record) bool {
 		return func(_ context.Context, r slog.Record) bool {
 			return slices.Contains(levels, r.Level)
 		}
 	}
 
-	router := slogmulti.Router()
+	newHandler := func(w io.Writer) slog.Handler {
+		if config.JSONLog {
+			return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+		}
 
-	if config.JSONLog {
-		// Send logs with level higher than warning to stderr
-		router = router.Add(
-			slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}),
-			levelFilter(slog.LevelWarn, slog.LevelError),
-		)
-
-		// Send info and debug logs to stdout
-		router = router.Add(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}),
-			levelFilter(slog.LevelDebug, slog.LevelInfo),
-		)
-	} else {
-		// Send logs with level higher than warning to stderr
-		router = router.Add(
-			slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}),
-			levelFilter(slog.LevelWarn, slog.LevelError),
-		)
-
-		// Send info and debug logs to stdout
-		router = router.Add(
-			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}),
-			levelFilter(slog.LevelDebug, slog.LevelInfo),
-		)
+		return slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})
 	}
 
-	if config.LogServer != "" {
-		writer, err := net.Dial("udp", config.LogServer)
+	// Keep stdout clean for the entrypoint process if requested
+	infoWriter := stdout
+	if config.LogStderrOnly {
+		infoWriter = stderr
+	}
 
-		// We silently ignore syslog connection errors for the lack of a better solution
-		if err == nil {
-			router = router.Add(slogsyslog.Option{Level: slog.LevelInfo, Writer: writer}.NewSyslogHandler())
-		}
+	router := slogmulti.Router()
+
+	// Send logs with level higher than warning to stderr
+	router = router.Add(
+		newHandler(stderr),
+		levelFilter(slog.LevelWarn, slog.LevelError),
+	)
+
+	// Send info and debug logs to stdout
+	router = router.Add(
+		newHandler(infoWriter),
+		levelFilter(slog.LevelDebug, slog.LevelInfo),
+	)
+
+	// Connection errors aren't reported, the writer reconnects and buffers the messages meanwhile
+	if config.LogServer != "" {
+		writer := newSyslogWriter(cmp.Or(config.LogServerProto, "udp"), config.LogServer)
+		router = router.Add(slogsyslog.Option{Level: syslogLevel, Writer: writer}.NewSyslogHandler())
 	}
 
 	// TODO: add level filter handler
 	logger := slog.New(router.Handler())
-	logger = logger.With(slog.String("app", "secret-init"))
 
-	// Set the default logger to the configured logger,
-	// enabling direct usage of the slog package for logging.
-	slog.SetDefault(logger)
+	return logger.With(slog.String("app", "secret-init"))
 }
@@ -0,0 +1,227 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/provider/file"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     *common.Config
+		wantStdout []string
+		wantStderr []string
+	}{
+		{
+			name:       "Default routing",
+			config:     &common.Config{},
+			wantStdout: []string{"info message"},
+			wantStderr: []string{"warn message", "error message"},
+		},
+		{
+			name:       "Quiet mode",
+			config:     &common.Config{Quiet: true, LogLevel: "debug"},
+			wantStderr: []string{"warn message", "error message"},
+		},
+		{
+			name:       "Stderr only",
+			config:     &common.Config{LogStderrOnly: true},
+			wantStderr: []string{"info message", "warn message", "error message"},
+		},
+		{
+			name:       "Quiet mode with stderr only",
+			config:     &common.Config{Quiet: true, LogStderrOnly: true},
+			wantStderr: []string{"warn message", "error message"},
+		},
+	}
+
+	allMessages := []string{"debug message", "info message", "warn message", "error message"}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			logger := newLogger(ttp.config, &stdout, &stderr)
+
+			logger.Debug("debug message")
+			logger.Info("info message")
+			logger.Warn("warn message")
+			logger.Error("error message")
+
+			for _, message := range allMessages {
+				assert.Equal(t, slices.Contains(ttp.wantStdout, message), bytes.Contains(stdout.Bytes(), []byte(message)), "Unexpected stdout routing for %q", message)
+				assert.Equal(t, slices.Contains(ttp.wantStderr, message), bytes.Contains(stderr.Bytes(), []byte(message)), "Unexpected stderr routing for %q", message)
+			}
+		})
+	}
+}
+
+func TestLoadCommandEnvDisabled(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("DB_PASSWORD", "vault:secret/data/app#password")
+	t.Setenv("API_KEY", "file:/nonexistent")
+	t.Setenv("COUNTED", "counting:value")
+
+	created := 0
+	envStore := NewEnvStore(&common.Config{Disable: true})
+	envStore.RegisterFactory(provider.Factory{
+		ProviderType: "counting",
+		Validator:    func(envValue string) bool { return strings.HasPrefix(envValue, "counting:") },
+		Create: func(_ context.Context, _ *common.Config) (provider.Provider, error) {
+			created++
+
+			return nil, errors.New("unexpected provider creation")
+		},
+	})
+
+	cmdEnv, _, err := loadCommandEnv(context.Background(), &common.Config{Disable: true}, envStore)
+	require.NoError(t, err)
+
+	assert.Contains(t, cmdEnv, "DB_PASSWORD=vault:secret/data/app#password")
+	assert.Contains(t, cmdEnv, "API_KEY=file:/nonexistent")
+	assert.Contains(t, cmdEnv, "COUNTED=counting:value")
+	assert.Zero(t, created, "No provider must be created")
+}
+
+func TestLoadCommandEnvSummary(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("DB_USER", "file:"+newSecretFile(t, "root"))
+	t.Setenv("DB_PASSWORD", "file:"+newSecretFile(t, "p@ss"))
+	t.Setenv("API_KEY", "closing:s3cr3t")
+
+	config := &common.Config{SummaryFile: filepath.Join(t.TempDir(), "summary.json")}
+	envStore := NewEnvStore(config)
+	envStore.RegisterFactory(provider.Factory{
+		ProviderType: "closing",
+		Validator:    func(envValue string) bool { return strings.HasPrefix(envValue, "closing:") },
+		Create: func(_ context.Context, _ *common.Config) (provider.Provider, error) {
+			return &closingProvider{}, nil
+		},
+	})
+
+	_, _, err := loadCommandEnv(context.Background(), config, envStore)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(config.SummaryFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "p@ss", "Secret value leaked into the summary file")
+	assert.NotContains(t, string(content), "DB_PASSWORD", "Secret key leaked into the summary file")
+
+	var summary loadSummary
+	err = json.Unmarshal(content, &summary)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{file.ProviderType: 2, "closing": 1}, summary.Providers, "Unexpected provider counts")
+	assert.Equal(t, 3, summary.Total, "Unexpected total")
+	assert.GreaterOrEqual(t, summary.DurationMs, int64(0), "Unexpected duration")
+}
+
+func TestLoadCommandEnvValidation(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("DB_PASSWORD", "file:"+newSecretFile(t, "p@ss"))
+	t.Setenv("API_KEY", "file:"+newSecretFile(t, ""))
+
+	config := &common.Config{Require: []string{"DB_PASSWORD", "API_KEY", "LOG_LEVEL"}}
+	envStore := NewEnvStore(config)
+
+	_, _, err := loadCommandEnv(context.Background(), config, envStore)
+	assert.EqualError(t, err, "failed to validate secrets: env var API_KEY is empty\nrequired env var LOG_LEVEL is missing", "Unexpected error message")
+}
+
+func TestEntrypointEnviron(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("VAULT_ADDR", "https://vault:8200")
+	t.Setenv("APP_ENV", "production")
+
+	environ := entrypointEnviron(&common.Config{FileEnv: []string{"VAULT_ADDR"}})
+	assert.Equal(t, []string{"APP_ENV=production"}, environ, "Settings of the config file must not reach the entrypoint process")
+}
+
+func TestNewRedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	keyFile := filepath.Join(t.TempDir(), "cache-key")
+	err := os.WriteFile(keyFile, []byte("cluster-key"), 0o600)
+	require.NoError(t, err)
+
+	_, err = newRedisCache(&common.Config{CacheRedisURL: "redis://" + server.Addr(), CacheTTL: time.Minute})
+	assert.EqualError(t, err, "SECRET_INIT_CACHE_KEY or SECRET_INIT_CACHE_KEY_FILE must be set to encrypt the shared cache", "Unexpected error message")
+
+	config := &common.Config{CacheRedisURL: "redis://" + server.Addr(), CacheKeyFile: keyFile, CacheTTL: time.Minute}
+	secretFile := newSecretFile(t, "secretId")
+	paths := map[string][]string{"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile}}
+
+	loadSecrets := func() ([]provider.Secret, error) {
+		envStore := NewEnvStore(config)
+		envStore.cache, err = newRedisCache(config)
+		require.NoError(t, err)
+
+		return envStore.LoadProviderSecrets(context.Background(), paths)
+	}
+
+	// The first process loads the secret from the provider, the second one from the shared cache
+	_, err = loadSecrets()
+	require.NoError(t, err)
+	os.Remove(secretFile)
+
+	providerSecrets, err := loadSecrets()
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile}}, providerSecrets, "Unexpected secrets")
+}
+
+func TestNewFileCache(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := newFileCache(&common.Config{CachePath: filepath.Join(dir, "secrets.cache"), CacheTTL: time.Minute})
+	assert.EqualError(t, err, "SECRET_INIT_CACHE_KEY or SECRET_INIT_CACHE_KEY_FILE must be set to encrypt the file cache", "Unexpected error message")
+
+	config := &common.Config{CachePath: filepath.Join(dir, "secrets.cache"), CacheKey: "cache-key", CacheTTL: time.Minute}
+	secretFile := newSecretFile(t, "secretId")
+	paths := map[string][]string{"file": {"AWS_SECRET_ACCESS_KEY_ID=file:" + secretFile}}
+
+	loadSecrets := func() ([]provider.Secret, error) {
+		envStore := NewEnvStore(config)
+		envStore.cache, err = newFileCache(config)
+		require.NoError(t, err)
+
+		return envStore.LoadProviderSecrets(context.Background(), paths)
+	}
+
+	// The first run loads the secret from the provider, the restarted one from the file cache
+	_, err = loadSecrets()
+	require.NoError(t, err)
+	os.Remove(secretFile)
+
+	providerSecrets, err := loadSecrets()
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId", Provider: file.ProviderType, Reference: "file:" + secretFile}}, providerSecrets, "Unexpected secrets")
+}
@@ -0,0 +1,243 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const metricsShutdownTimeout = 5 * time.Second
+
+// metrics records how secrets are fetched from the providers.
+// It is only created when SECRET_INIT_METRICS_ADDR is set, a nil *metrics records nothing.
+type metrics struct {
+	registry *prometheus.Registry
+
+	fetches       *prometheus.CounterVec
+	fetchErrors   *prometheus.CounterVec
+	fetchDuration *prometheus.HistogramVec
+	loadDuration  prometheus.Histogram
+	secrets       *secretCollector
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		fetches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "secret_init_provider_fetches_total",
+			Help: "Number of secret fetches per provider.",
+		}, []string{"provider"}),
+		fetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "secret_init_provider_fetch_errors_total",
+			Help: "Number of failed secret fetches per provider.",
+		}, []string{"provider"}),
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "secret_init_provider_fetch_duration_seconds",
+			Help:    "Duration of secret fetches per provider.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"provider"}),
+		loadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "secret_init_load_duration_seconds",
+			Help:    "Duration of loading the secrets of all providers.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		secrets: newSecretCollector(time.Now),
+	}
+
+	m.registry.MustRegister(m.fetches, m.fetchErrors, m.fetchDuration, m.loadDuration, m.secrets)
+
+	return m
+}
+
+// observeFetch records a single LoadSecrets call of a provider.
+func (m *metrics) observeFetch(providerName string, duration time.Duration, fetchErr error) {
+	if m == nil {
+		return
+	}
+
+	m.fetches.WithLabelValues(providerName).Inc()
+	m.fetchDuration.WithLabelValues(providerName).Observe(duration.Seconds())
+	if fetchErr != nil {
+		m.fetchErrors.WithLabelValues(providerName).Inc()
+	}
+}
+
+// observeLoad records a LoadProviderSecrets call, spanning all providers.
+func (m *metrics) observeLoad(duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.loadDuration.Observe(duration.Seconds())
+}
+
+// observeSecrets records the lifetime of the secrets loaded by a provider, as far as the provider knows it.
+func (m *metrics) observeSecrets(providerName string, secrets []provider.Secret) {
+	if m == nil {
+		return
+	}
+
+	m.secrets.observe(providerName, secrets)
+}
+
+// secretLifetime is the known lifetime of a loaded secret, zero times are unknown.
+type secretLifetime struct {
+	provider  string
+	expiresAt time.Time
+	createdAt time.Time
+}
+
+// secretCollector reports the remaining TTL and the age of the loaded secrets.
+// The values are calculated on scrape, so they don't go stale between loads.
+// Secrets are labeled by the hash of their key, since even the key may tell too much.
+type secretCollector struct {
+	now     func() time.Time
+	ttlDesc *prometheus.Desc
+	ageDesc *prometheus.Desc
+
+	mu        sync.Mutex
+	lifetimes map[string]secretLifetime
+}
+
+func newSecretCollector(now func() time.Time) *secretCollector {
+	return &secretCollector{
+		now: now,
+		ttlDesc: prometheus.NewDesc(
+			"secret_init_secret_ttl_seconds",
+			"Remaining lifetime of a loaded secret, for secrets with a known TTL (e.g. Vault leases).",
+			[]string{"provider", "key_hash"}, nil,
+		),
+		ageDesc: prometheus.NewDesc(
+			"secret_init_secret_age_seconds",
+			"Age of the loaded version of a secret, for secrets with a known creation time (e.g. Azure or AWS).",
+			[]string{"provider", "key_hash"}, nil,
+		),
+		lifetimes: make(map[string]secretLifetime),
+	}
+}
+
+func (c *secretCollector) observe(providerName string, secrets []provider.Secret) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, secret := range secrets {
+		keyHash := hashKey(secret.Key)
+
+		// A reloaded secret may have lost its lifetime, e.g. a dynamic secret turned static
+		if secret.TTL <= 0 && secret.CreatedAt.IsZero() {
+			delete(c.lifetimes, keyHash)
+			continue
+		}
+
+		lifetime := secretLifetime{
+			provider:  providerName,
+			createdAt: secret.CreatedAt,
+		}
+		if secret.TTL > 0 {
+			lifetime.expiresAt = now.Add(secret.TTL)
+		}
+
+		c.lifetimes[keyHash] = lifetime
+	}
+}
+
+func (c *secretCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.ttlDesc
+	ch <- c.ageDesc
+}
+
+func (c *secretCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for keyHash, lifetime := range c.lifetimes {
+		if !lifetime.expiresAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.ttlDesc, prometheus.GaugeValue, lifetime.expiresAt.Sub(now).Seconds(), lifetime.provider, keyHash)
+		}
+
+		if !lifetime.createdAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.ageDesc, prometheus.GaugeValue, now.Sub(lifetime.createdAt).Seconds(), lifetime.provider, keyHash)
+		}
+	}
+}
+
+// hashKey shortens the SHA-256 hash of an env var name, which is enough to tell the secrets apart.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// serveMetrics starts serving the metrics on /metrics in the background.
+// The address is bound before returning, so a port already in use fails the startup.
+func serveMetrics(addr string, m *metrics) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(fmt.Errorf("failed to serve metrics: %w", err).Error())
+		}
+	}()
+
+	slog.Info("serving metrics", slog.String("addr", server.Addr))
+
+	return server, nil
+}
+
+// shutdownMetrics lets in-flight scrapes finish before the process exits.
+func shutdownMetrics(server *http.Server) {
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to shut down metrics server: %w", err).Error())
+	}
+}
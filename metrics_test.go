@@ -0,0 +1,99 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestServeMetrics(t *testing.T) {
+	m := newMetrics()
+	m.observeFetch("vault", 50*time.Millisecond, nil)
+	m.observeFetch("vault", 20*time.Millisecond, errors.New("permission denied"))
+	m.observeFetch("file", time.Millisecond, nil)
+	m.observeLoad(100 * time.Millisecond)
+
+	server, err := serveMetrics("127.0.0.1:0", m)
+	require.NoError(t, err)
+
+	resp, err := http.Get("http://" + server.Addr + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), `secret_init_provider_fetches_total{provider="vault"} 2`)
+	assert.Contains(t, string(body), `secret_init_provider_fetches_total{provider="file"} 1`)
+	assert.Contains(t, string(body), `secret_init_provider_fetch_errors_total{provider="vault"} 1`)
+	assert.Contains(t, string(body), `secret_init_provider_fetch_duration_seconds_count{provider="vault"} 2`)
+	assert.Contains(t, string(body), `secret_init_load_duration_seconds_count 1`)
+
+	shutdownMetrics(server)
+
+	_, err = http.Get("http://" + server.Addr + "/metrics")
+	assert.Error(t, err, "Metrics must not be served after shutdown")
+}
+
+func TestServeMetricsSecretLifetimes(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	m := newMetrics()
+	m.secrets.now = func() time.Time { return now }
+	m.observeSecrets("vault", []provider.Secret{
+		{Key: "DB_PASSWORD", Value: "s3cr3t", TTL: time.Hour},
+		{Key: "API_KEY", Value: "k3y"},
+	})
+	m.observeSecrets("azure", []provider.Secret{
+		{Key: "TLS_KEY", Value: "k3y", CreatedAt: now.Add(-48 * time.Hour)},
+	})
+
+	// The values are calculated on scrape
+	now = now.Add(10 * time.Minute)
+
+	server, err := serveMetrics("127.0.0.1:0", m)
+	require.NoError(t, err)
+	defer shutdownMetrics(server)
+
+	resp, err := http.Get("http://" + server.Addr + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), `secret_init_secret_ttl_seconds{key_hash="`+hashKey("DB_PASSWORD")+`",provider="vault"} 3000`)
+	assert.Contains(t, string(body), `secret_init_secret_age_seconds{key_hash="`+hashKey("TLS_KEY")+`",provider="azure"} 173400`)
+	assert.NotContains(t, string(body), hashKey("API_KEY"), "Secrets with an unknown lifetime must not be reported")
+	assert.NotContains(t, string(body), "DB_PASSWORD", "Keys must not be exposed")
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	var m *metrics
+
+	assert.NotPanics(t, func() {
+		m.observeFetch("vault", time.Second, nil)
+		m.observeLoad(time.Second)
+		m.observeSecrets("vault", []provider.Secret{{Key: "DB_PASSWORD", TTL: time.Hour}})
+		shutdownMetrics(nil)
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildinfo
+
+import (
+	"cmp"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// These variables are meant to be overridden at build time via ldflags, e.g.
+// -X github.com/bank-vaults/secret-init/pkg/buildinfo.Version=v1.0.0
+var (
+	Version = ""
+	Commit  = ""
+)
+
+// Info describes the build of the running binary.
+type Info struct {
+	Version   string
+	Commit    string
+	GoVersion string
+}
+
+// Get returns the build info, the values set via ldflags take precedence over the ones embedded by the go tool.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && buildInfo.Main.Version != "(devel)" {
+			info.Version = buildInfo.Main.Version
+		}
+
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" && info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// String formats the build info for humans, unknown values are reported as such.
+func (i Info) String() string {
+	return fmt.Sprintf("version: %s\ncommit: %s\ngo: %s\n", cmp.Or(i.Version, "dev"), cmp.Or(i.Commit, "unknown"), cmp.Or(i.GoVersion, "unknown"))
+}
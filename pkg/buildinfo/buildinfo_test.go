@@ -0,0 +1,61 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildinfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfo_String(t *testing.T) {
+	tests := []struct {
+		name string
+		info Info
+		want string
+	}{
+		{
+			name: "Complete build info",
+			info: Info{Version: "v1.2.3", Commit: "0123abcd", GoVersion: "go1.23.4"},
+			want: "version: v1.2.3\ncommit: 0123abcd\ngo: go1.23.4\n",
+		},
+		{
+			name: "Development build",
+			info: Info{GoVersion: "go1.23.4"},
+			want: "version: dev\ncommit: unknown\ngo: go1.23.4\n",
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			assert.Equal(t, ttp.want, ttp.info.String(), "Unexpected build info")
+		})
+	}
+}
+
+func TestGet_Overridden(t *testing.T) {
+	version, commit := Version, Commit
+	t.Cleanup(func() {
+		Version, Commit = version, commit
+	})
+
+	Version, Commit = "v1.2.3", "0123abcd"
+
+	info := Get()
+	assert.Equal(t, "v1.2.3", info.Version, "Unexpected version")
+	assert.Equal(t, "0123abcd", info.Commit, "Unexpected commit")
+	assert.NotEmpty(t, info.GoVersion, "Missing go version")
+}
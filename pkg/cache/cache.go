@@ -0,0 +1,102 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// Store holds resolved secrets under the reference they were loaded from.
+// A store is best effort, secrets missing from it are loaded from the providers.
+type Store interface {
+	Get(key string) (provider.Secret, bool)
+	Set(key string, secret provider.Secret)
+	// Clear drops the secrets, e.g. when they are known to have changed
+	Clear()
+}
+
+// Flusher is implemented by stores writing the secrets out as a whole (e.g. to a file),
+// the secrets set during a load are only written once the load is done.
+type Flusher interface {
+	Flush()
+}
+
+// Cache holds resolved secrets in memory until they expire.
+// Each entry expires after the TTL reported by its provider (e.g. a Vault lease),
+// or after the default TTL when the provider doesn't know it.
+type Cache struct {
+	mu         sync.Mutex
+	defaultTTL time.Duration
+	entries    map[string]entry
+	now        func() time.Time
+}
+
+type entry struct {
+	secret    provider.Secret
+	expiresAt time.Time
+}
+
+func New(defaultTTL time.Duration) *Cache {
+	return &Cache{
+		defaultTTL: defaultTTL,
+		entries:    make(map[string]entry),
+		now:        time.Now,
+	}
+}
+
+// Get returns the secret stored under key, if it is still fresh.
+func (c *Cache) Get(key string) (provider.Secret, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return provider.Secret{}, false
+	}
+
+	if !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		return provider.Secret{}, false
+	}
+
+	return e.secret, true
+}
+
+// Set stores the secret under key, honoring its TTL when known.
+func (c *Cache) Set(key string, secret provider.Secret) {
+	ttl := c.defaultTTL
+	if secret.TTL > 0 {
+		ttl = secret.TTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = entry{
+		secret:    secret,
+		expiresAt: c.now().Add(ttl),
+	}
+}
+
+// Clear removes all entries, e.g. when the secrets are known to have changed.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
@@ -0,0 +1,50 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	cache := New(time.Hour)
+	cache.now = func() time.Time { return now }
+
+	// A Vault secret with a short lease and a secret without a known TTL
+	cache.Set("DB_PASSWORD=vault:database/creds/app#password", provider.Secret{Key: "DB_PASSWORD", Value: "s3cr3t", TTL: 30 * time.Second})
+	cache.Set("API_KEY=file:/secrets/api-key", provider.Secret{Key: "API_KEY", Value: "k3y"})
+
+	now = now.Add(29 * time.Second)
+	_, ok := cache.Get("DB_PASSWORD=vault:database/creds/app#password")
+	assert.True(t, ok, "Secret should be cached before its lease expires")
+
+	now = now.Add(time.Second)
+	_, ok = cache.Get("DB_PASSWORD=vault:database/creds/app#password")
+	assert.False(t, ok, "Secret should expire at its lease boundary")
+
+	secret, ok := cache.Get("API_KEY=file:/secrets/api-key")
+	assert.True(t, ok, "Secret without TTL should use the default TTL")
+	assert.Equal(t, "k3y", secret.Value)
+
+	now = now.Add(time.Hour)
+	_, ok = cache.Get("API_KEY=file:/secrets/api-key")
+	assert.False(t, ok, "Secret without TTL should expire after the default TTL")
+}
@@ -0,0 +1,213 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// FileCache keeps resolved secrets in a local file across restarts (e.g. of jobs hitting rate limits),
+// so secrets still fresh are served without reaching out to the providers.
+// The file is encrypted as a whole, so neither the secrets nor their references are exposed,
+// and a tampered file (or one encrypted with another key) is detected and discarded.
+type FileCache struct {
+	mu         sync.Mutex
+	path       string
+	aead       cipher.AEAD
+	defaultTTL time.Duration
+	entries    map[string]fileEntry
+	now        func() time.Time
+	// dirty is set by Set, a load served from the cache alone doesn't rewrite the file
+	dirty bool
+}
+
+type fileEntry struct {
+	Secret    provider.Secret `json:"secret"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// NewFile creates a cache in the file at path, loading the entries stored by earlier processes.
+// The encryption key is derived from the key material.
+func NewFile(path string, key []byte, defaultTTL time.Duration) (*FileCache, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &FileCache{
+		path:       path,
+		aead:       aead,
+		defaultTTL: defaultTTL,
+		entries:    make(map[string]fileEntry),
+		now:        time.Now,
+	}
+	c.load()
+
+	return c, nil
+}
+
+// load reads the entries from the file, an unreadable file only costs cache misses.
+func (c *FileCache) load() {
+	ciphertext, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to read file cache: %w", err).Error(), slog.String("path", c.path))
+		return
+	}
+
+	plaintext, err := unseal(c.aead, ciphertext)
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to decrypt file cache, discarding it: %w", err).Error(), slog.String("path", c.path))
+		return
+	}
+
+	var entries map[string]fileEntry
+	err = json.Unmarshal(plaintext, &entries)
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to decode file cache, discarding it: %w", err).Error(), slog.String("path", c.path))
+		return
+	}
+
+	for key, e := range entries {
+		c.entries[key] = e
+	}
+}
+
+// Get returns the secret stored under key, if it is still fresh.
+func (c *FileCache) Get(key string) (provider.Secret, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return provider.Secret{}, false
+	}
+
+	now := c.now()
+	if !now.Before(e.ExpiresAt) {
+		delete(c.entries, key)
+		return provider.Secret{}, false
+	}
+
+	// The TTL reported by the backend has been running since the secret was stored
+	secret := e.Secret
+	if secret.TTL > 0 {
+		secret.TTL = e.ExpiresAt.Sub(now)
+	}
+
+	return secret, true
+}
+
+// Set stores the secret under key, honoring its TTL when known. The file is only written by Flush.
+func (c *FileCache) Set(key string, secret provider.Secret) {
+	ttl := c.defaultTTL
+	if secret.TTL > 0 {
+		ttl = secret.TTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = fileEntry{
+		Secret:    secret,
+		ExpiresAt: c.now().Add(ttl),
+	}
+	c.dirty = true
+}
+
+// Flush writes the entries to the file, once for all the secrets set by a load.
+func (c *FileCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return
+	}
+
+	err := c.save()
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to write file cache: %w", err).Error(), slog.String("path", c.path))
+		return
+	}
+	c.dirty = false
+}
+
+// Clear removes all entries along with the file.
+func (c *FileCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.dirty = false
+
+	err := os.Remove(c.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn(fmt.Errorf("failed to remove file cache: %w", err).Error(), slog.String("path", c.path))
+	}
+}
+
+// save writes the entries still fresh to a temporary file readable only by the current user,
+// which then replaces the file, so other processes never read a partial file.
+func (c *FileCache) save() error {
+	now := c.now()
+	for key, e := range c.entries {
+		if !now.Before(e.ExpiresAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	plaintext, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := seal(c.aead, plaintext)
+	if err != nil {
+		return err
+	}
+
+	// CreateTemp creates the file with 0600 permissions
+	file, err := os.CreateTemp(filepath.Dir(c.path), ".secret-init-cache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(ciphertext)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), c.path)
+}
@@ -0,0 +1,126 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestFileCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.cache")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newCache := func(key string) *FileCache {
+		cache, err := NewFile(path, []byte(key), time.Hour)
+		require.NoError(t, err)
+		cache.now = func() time.Time { return now }
+
+		return cache
+	}
+
+	first := newCache("cache-key")
+	_, ok := first.Get("DB_PASSWORD=vault:database/creds/app#password")
+	assert.False(t, ok, "Secret should not be cached before it is loaded")
+
+	first.Set("DB_PASSWORD=vault:database/creds/app#password", provider.Secret{Key: "DB_PASSWORD", Value: "s3cr3t", TTL: 30 * time.Second})
+	first.Set("API_KEY=file:/secrets/api-key", provider.Secret{Key: "API_KEY", Value: "k3y"})
+
+	// The file is written once for all the secrets of a load
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "File should not be written before flushing")
+	first.Flush()
+
+	// Neither the secrets nor their references are stored in plain text
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "s3cr3t", "Secret stored in plain text")
+	assert.NotContains(t, string(content), "DB_PASSWORD", "Reference stored in plain text")
+
+	fileInfo, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fileInfo.Mode().Perm(), "Unexpected file permissions")
+
+	// A restarted process reads the secrets still fresh from the file
+	now = now.Add(10 * time.Second)
+	second := newCache("cache-key")
+	secret, ok := second.Get("DB_PASSWORD=vault:database/creds/app#password")
+	assert.True(t, ok, "Secret should be read from the file within its TTL")
+	assert.Equal(t, provider.Secret{Key: "DB_PASSWORD", Value: "s3cr3t", TTL: 20 * time.Second}, secret, "Unexpected secret")
+
+	secret, ok = second.Get("API_KEY=file:/secrets/api-key")
+	assert.True(t, ok, "Secret without TTL should use the default TTL")
+	assert.Equal(t, provider.Secret{Key: "API_KEY", Value: "k3y"}, secret, "Unexpected secret")
+
+	// Entries expire across restarts as well
+	now = now.Add(20 * time.Second)
+	_, ok = newCache("cache-key").Get("DB_PASSWORD=vault:database/creds/app#password")
+	assert.False(t, ok, "Secret should expire at its lease boundary")
+
+	now = now.Add(time.Hour)
+	_, ok = newCache("cache-key").Get("API_KEY=file:/secrets/api-key")
+	assert.False(t, ok, "Secret should expire after the default TTL")
+
+	// Another key can't decrypt the secrets
+	now = now.Add(-time.Hour)
+	_, ok = newCache("other-key").Get("API_KEY=file:/secrets/api-key")
+	assert.False(t, ok, "Secret should not be decrypted with another key")
+
+	second.Clear()
+	second.Flush()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "File should be removed on clear")
+	_, ok = newCache("cache-key").Get("API_KEY=file:/secrets/api-key")
+	assert.False(t, ok, "Secret should be cleared")
+}
+
+func TestFileCacheTampered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.cache")
+
+	cache, err := NewFile(path, []byte("cache-key"), time.Hour)
+	require.NoError(t, err)
+	cache.Set("API_KEY=file:/secrets/api-key", provider.Secret{Key: "API_KEY", Value: "k3y"})
+	cache.Flush()
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	content[len(content)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+
+	cache, err = NewFile(path, []byte("cache-key"), time.Hour)
+	require.NoError(t, err)
+	_, ok := cache.Get("API_KEY=file:/secrets/api-key")
+	assert.False(t, ok, "Tampered file should be discarded")
+
+	// The discarded file is replaced by the next write
+	cache.Set("API_KEY=file:/secrets/api-key", provider.Secret{Key: "API_KEY", Value: "k3y"})
+	cache.Flush()
+	cache, err = NewFile(path, []byte("cache-key"), time.Hour)
+	require.NoError(t, err)
+	_, ok = cache.Get("API_KEY=file:/secrets/api-key")
+	assert.True(t, ok, "Secret should be read from the rewritten file")
+}
+
+func TestNewFile(t *testing.T) {
+	_, err := NewFile(filepath.Join(t.TempDir(), "secrets.cache"), nil, time.Hour)
+	assert.EqualError(t, err, "encryption key is empty", "Unexpected error message")
+}
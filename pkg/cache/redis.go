@@ -0,0 +1,203 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const (
+	redisKeyPrefix = "secret-init:"
+
+	// Upper bound for a single Redis command, an unreachable Redis only costs a cache miss
+	redisTimeout = 2 * time.Second
+)
+
+// RedisCache shares resolved secrets between processes (e.g. pods starting at the same time) through Redis,
+// so only the first one reaches out to the providers.
+// The secrets are encrypted with a key shared by the processes, Redis never sees them in plain text.
+// Keys are hashed, so the references are not exposed either.
+type RedisCache struct {
+	client     redis.UniversalClient
+	aead       cipher.AEAD
+	defaultTTL time.Duration
+
+	// keys tracks the entries this process has seen, only those are removed by Clear
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+// NewRedis creates a cache on the Redis at url (e.g. redis://redis:6379/0).
+// The encryption key is derived from the shared key material.
+func NewRedis(url string, key []byte, defaultTTL time.Duration) (*RedisCache, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis url: %w", err)
+	}
+
+	return newRedisCache(redis.NewClient(options), key, defaultTTL)
+}
+
+func newRedisCache(client redis.UniversalClient, key []byte, defaultTTL time.Duration) (*RedisCache, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisCache{
+		client:     client,
+		aead:       aead,
+		defaultTTL: defaultTTL,
+		keys:       make(map[string]bool),
+	}, nil
+}
+
+// Get returns the secret stored under key, if another process or this one has stored it.
+func (c *RedisCache) Get(key string) (provider.Secret, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	redisKey := c.redisKey(key)
+	c.track(redisKey)
+
+	var get *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, redisKey)
+		ttl = pipe.PTTL(ctx, redisKey)
+
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.Warn(fmt.Errorf("failed to read secret from redis cache: %w", err).Error())
+		}
+
+		return provider.Secret{}, false
+	}
+
+	ciphertext, _ := get.Bytes()
+	secret, err := c.decrypt(ciphertext)
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to decrypt secret from redis cache: %w", err).Error())
+		return provider.Secret{}, false
+	}
+
+	// The TTL reported by the backend has been running since the secret was stored
+	if secret.TTL > 0 && ttl.Val() > 0 {
+		secret.TTL = ttl.Val()
+	}
+
+	return secret, true
+}
+
+// Set stores the secret under key, expiring after its TTL when known.
+func (c *RedisCache) Set(key string, secret provider.Secret) {
+	ttl := c.defaultTTL
+	if secret.TTL > 0 {
+		ttl = secret.TTL
+	}
+
+	ciphertext, err := c.encrypt(secret)
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to encrypt secret for redis cache: %w", err).Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	redisKey := c.redisKey(key)
+	c.track(redisKey)
+
+	err = c.client.Set(ctx, redisKey, ciphertext, ttl).Err()
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to write secret to redis cache: %w", err).Error())
+	}
+}
+
+// Clear removes the entries this process has seen, the entries of other references are left to expire.
+func (c *RedisCache) Clear() {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.keys))
+	for key := range c.keys {
+		keys = append(keys, key)
+	}
+	clear(c.keys)
+	c.mu.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	err := c.client.Del(ctx, keys...).Err()
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to clear redis cache: %w", err).Error())
+	}
+}
+
+func (c *RedisCache) redisKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return redisKeyPrefix + hex.EncodeToString(hash[:])
+}
+
+func (c *RedisCache) track(redisKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.keys[redisKey] = true
+}
+
+// encrypt seals the secret encoded as JSON.
+func (c *RedisCache) encrypt(secret provider.Secret) ([]byte, error) {
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return seal(c.aead, plaintext)
+}
+
+func (c *RedisCache) decrypt(ciphertext []byte) (provider.Secret, error) {
+	plaintext, err := unseal(c.aead, ciphertext)
+	if err != nil {
+		return provider.Secret{}, err
+	}
+
+	var secret provider.Secret
+	err = json.Unmarshal(plaintext, &secret)
+	if err != nil {
+		return provider.Secret{}, err
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,93 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestRedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	newCache := func(key string) *RedisCache {
+		cache, err := newRedisCache(redis.NewClient(&redis.Options{Addr: server.Addr()}), []byte(key), time.Hour)
+		require.NoError(t, err)
+
+		return cache
+	}
+
+	// Two processes sharing the cluster key, e.g. pods of the same deployment
+	first := newCache("cluster-key")
+	second := newCache("cluster-key")
+
+	_, ok := second.Get("DB_PASSWORD=vault:database/creds/app#password")
+	assert.False(t, ok, "Secret should not be cached before it is loaded")
+
+	first.Set("DB_PASSWORD=vault:database/creds/app#password", provider.Secret{Key: "DB_PASSWORD", Value: "s3cr3t", TTL: 30 * time.Second})
+	first.Set("API_KEY=file:/secrets/api-key", provider.Secret{Key: "API_KEY", Value: "k3y"})
+
+	// Write-through: only encrypted values under hashed keys reach Redis
+	keys := server.Keys()
+	assert.Len(t, keys, 2, "Unexpected number of entries")
+	for _, key := range keys {
+		assert.NotContains(t, key, "DB_PASSWORD", "Reference exposed in key")
+		value, err := server.Get(key)
+		require.NoError(t, err)
+		assert.NotContains(t, value, "s3cr3t", "Secret stored in plain text")
+		assert.NotContains(t, value, "k3y", "Secret stored in plain text")
+	}
+
+	// Read-through: the other process reads what the first one loaded
+	server.FastForward(10 * time.Second)
+	secret, ok := second.Get("DB_PASSWORD=vault:database/creds/app#password")
+	assert.True(t, ok, "Secret should be shared through redis")
+	assert.Equal(t, provider.Secret{Key: "DB_PASSWORD", Value: "s3cr3t", TTL: 20 * time.Second}, secret, "Unexpected secret")
+
+	secret, ok = second.Get("API_KEY=file:/secrets/api-key")
+	assert.True(t, ok, "Secret without TTL should use the default TTL")
+	assert.Equal(t, provider.Secret{Key: "API_KEY", Value: "k3y"}, secret, "Unexpected secret")
+
+	server.FastForward(20 * time.Second)
+	_, ok = second.Get("DB_PASSWORD=vault:database/creds/app#password")
+	assert.False(t, ok, "Secret should expire at its lease boundary")
+
+	// Another cluster key can't decrypt the secrets
+	_, ok = newCache("other-key").Get("API_KEY=file:/secrets/api-key")
+	assert.False(t, ok, "Secret should not be decrypted with another key")
+
+	// Clear only removes the entries of the clearing process
+	first.Set("OTHER_KEY=file:/secrets/other-key", provider.Secret{Key: "OTHER_KEY", Value: "0th3r"})
+	second.Clear()
+	_, ok = first.Get("API_KEY=file:/secrets/api-key")
+	assert.False(t, ok, "Secret should be cleared")
+	_, ok = first.Get("OTHER_KEY=file:/secrets/other-key")
+	assert.True(t, ok, "Secret of another process should be kept")
+}
+
+func TestNewRedis(t *testing.T) {
+	_, err := NewRedis("redis://localhost:6379/0", nil, time.Hour)
+	assert.EqualError(t, err, "encryption key is empty", "Unexpected error message")
+
+	_, err = NewRedis("localhost:6379", []byte("cluster-key"), time.Hour)
+	assert.EqualError(t, err, "failed to parse redis url: redis: invalid URL scheme: localhost", "Unexpected error message")
+}
@@ -15,34 +15,452 @@
 package common
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cast"
+	"golang.org/x/sys/unix"
 )
 
 const (
-	LogLevelEnv  = "SECRET_INIT_LOG_LEVEL"
-	JSONLogEnv   = "SECRET_INIT_JSON_LOG"
-	LogServerEnv = "SECRET_INIT_LOG_SERVER"
-	DaemonEnv    = "SECRET_INIT_DAEMON"
-	DelayEnv     = "SECRET_INIT_DELAY"
+	LogLevelEnv      = "SECRET_INIT_LOG_LEVEL"
+	JSONLogEnv       = "SECRET_INIT_JSON_LOG"
+	LogServerEnv     = "SECRET_INIT_LOG_SERVER"
+	QuietEnv         = "SECRET_INIT_QUIET"
+	LogStderrOnlyEnv = "SECRET_INIT_LOG_STDERR_ONLY"
+	EventLogEnv      = "SECRET_INIT_EVENT_LOG"
+	AuditLogEnv      = "SECRET_INIT_AUDIT_LOG"
+	EnvPrefixEnv     = "SECRET_INIT_ENV_PREFIX"
+	DaemonEnv        = "SECRET_INIT_DAEMON"
+	DelayEnv         = "SECRET_INIT_DELAY"
+	RenderPathEnv    = "SECRET_INIT_RENDER_PATH"
+	CacheTTLEnv      = "SECRET_INIT_CACHE_TTL"
+	DryRunEnv        = "SECRET_INIT_DRY_RUN"
+	DisableEnv       = "SECRET_INIT_DISABLE"
+	PrintEnv         = "SECRET_INIT_PRINT"
+	MaskOutputEnv    = "SECRET_INIT_MASK_OUTPUT"
+	ResultFileEnv    = "SECRET_INIT_RESULT_FILE"
+	SummaryFileEnv   = "SECRET_INIT_SUMMARY_FILE"
+	MetricsAddrEnv   = "SECRET_INIT_METRICS_ADDR"
+	HealthAddrEnv    = "SECRET_INIT_HEALTH_ADDR"
+	StopSignalEnv    = "SECRET_INIT_STOP_SIGNAL"
+	StopTimeoutEnv   = "SECRET_INIT_STOP_TIMEOUT"
+	TimeoutEnv       = "SECRET_INIT_TIMEOUT"
+
+	// LogServerProtoEnv is the protocol of the log server, udp (default) or tcp
+	LogServerProtoEnv = "SECRET_INIT_LOG_SERVER_PROTO"
+
+	// ReferencesStdinLinesEnv reads additional KEY=reference lines from stdin
+	ReferencesStdinLinesEnv = "SECRET_INIT_REFERENCES_STDIN_LINES"
+	// StdinSecretsEnv reads already resolved secrets from stdin as a JSON object
+	StdinSecretsEnv = "SECRET_INIT_STDIN_SECRETS"
+
+	// DefaultDelimiterEnv separates a reference from its default value, e.g. vault:secret/data/db#port|5432
+	DefaultDelimiterEnv = "SECRET_INIT_DEFAULT_DELIMITER"
+
+	RenewalKillTimeoutEnv = "SECRET_INIT_RENEWAL_KILL_TIMEOUT"
+
+	// RenewSignalEnv is sent to the process in daemon mode whenever a lease of its secrets is renewed, e.g. SIGUSR1
+	RenewSignalEnv = "SECRET_INIT_RENEW_SIGNAL"
+
+	// CacheRedisURLEnv shares the cache between processes through Redis, encrypted with the key in CacheKeyEnv
+	// or the one read from CacheKeyFileEnv
+	CacheRedisURLEnv = "SECRET_INIT_CACHE_REDIS_URL"
+	CacheKeyEnv      = "SECRET_INIT_CACHE_KEY"
+	CacheKeyFileEnv  = "SECRET_INIT_CACHE_KEY_FILE"
+	// CachePathEnv keeps the cache in a local file across restarts instead, encrypted with the same key
+	CachePathEnv = "SECRET_INIT_CACHE_PATH"
+
+	// KeystorePathEnv enables writing a PKCS#12 keystore of the secrets named by the other keystore settings
+	KeystorePathEnv     = "SECRET_INIT_KEYSTORE_PATH"
+	KeystorePasswordEnv = "SECRET_INIT_KEYSTORE_PASSWORD"
+	KeystoreCertEnv     = "SECRET_INIT_KEYSTORE_CERT"
+	KeystoreKeyEnv      = "SECRET_INIT_KEYSTORE_KEY"
+	KeystoreCAEnv       = "SECRET_INIT_KEYSTORE_CA"
+
+	// MaxReferencesPerProviderEnv guards the backends against a runaway environment, zero means unlimited
+	MaxReferencesPerProviderEnv = "SECRET_INIT_MAX_REFERENCES_PER_PROVIDER"
+
+	// MaxSecretBytesEnv guards against oversized objects read from object storage (GCS or S3), zero means unlimited
+	MaxSecretBytesEnv = "SECRET_INIT_MAX_SECRET_BYTES"
+
+	// IgnoreEnvEnv lists env vars (comma-separated) never scanned for references, e.g. URLs looking like one
+	IgnoreEnvEnv = "SECRET_INIT_IGNORE_ENV"
+	// OnlyEnvEnv lists the only env vars (comma-separated) scanned for references, set but empty nothing is scanned
+	OnlyEnvEnv = "SECRET_INIT_ONLY_ENV"
+
+	// RequireEnv lists env vars (comma-separated) that have to be set and non-empty once the secrets are resolved
+	RequireEnv = "SECRET_INIT_REQUIRE"
+	// ValidationSchemaEnv is a JSON file of rules the env vars have to satisfy once the secrets are resolved
+	ValidationSchemaEnv = "SECRET_INIT_VALIDATION_SCHEMA"
+
+	// EnvironmentEnv is substituted for the {env} placeholder of references, e.g. vault:secret/data/{env}/app#key
+	EnvironmentEnv = "SECRET_INIT_ENVIRONMENT"
+
+	// ConfigFileEnv points to a JSON config file, its settings apply unless the environment sets them
+	ConfigFileEnv = "SECRET_INIT_CONFIG_FILE"
+
+	// ProviderEnv pins the provider, references of any other provider are rejected then
+	ProviderEnv = "SECRET_INIT_PROVIDER"
+
+	// FailFastEnv stops loading the secrets of all providers on the first provider error
+	FailFastEnv = "SECRET_INIT_FAIL_FAST"
+
+	// PreExecEnv is a shell command run with the environment of the entrypoint process before it is started
+	PreExecEnv = "SECRET_INIT_PRE_EXEC"
+
+	// FilesDirEnv is where secrets marked binary (?binary=true) are written as files, named after their keys
+	FilesDirEnv = "SECRET_INIT_FILES_DIR"
+
+	// ReloadOnSighupEnv makes daemon mode reload the secrets on SIGHUP instead of forwarding it
+	ReloadOnSighupEnv = "SECRET_INIT_RELOAD_ON_SIGHUP"
+	// ReloadIntervalEnv makes daemon mode reload the secrets periodically, restarting the process once they changed
+	ReloadIntervalEnv = "SECRET_INIT_RELOAD_INTERVAL"
+
+	// RequireAuthEnv makes the providers fail early when no credentials are configured
+	RequireAuthEnv = "SECRET_INIT_REQUIRE_AUTH"
+
+	// CABundleEnv is a PEM file of CA certificates trusted by the REST-based providers on top of the system ones
+	CABundleEnv = "SECRET_INIT_CA_BUNDLE"
+
+	// IgnoreMissingSecretsEnv skips the secrets a provider reports as missing instead of failing the load
+	IgnoreMissingSecretsEnv = "SECRET_INIT_IGNORE_MISSING_SECRETS"
+
+	RetryMaxAttemptsEnv = "SECRET_INIT_RETRY_MAX_ATTEMPTS"
+	RetryBaseDelayEnv   = "SECRET_INIT_RETRY_BASE_DELAY"
+
+	// BreakerThresholdEnv opens the circuit breaker of a provider after that many consecutive failures,
+	// it is skipped for BreakerCooldownEnv then, keeping the secrets it loaded before
+	BreakerThresholdEnv = "SECRET_INIT_CB_THRESHOLD"
+	BreakerCooldownEnv  = "SECRET_INIT_CB_COOLDOWN"
+
+	// ExtraFilesEnv lists file descriptors of secret-init passed on to the process, they become 3, 4, ... there
+	ExtraFilesEnv = "SECRET_INIT_EXTRA_FILES"
+	// CloseStdinEnv gives the process an empty stdin instead of the one of secret-init
+	CloseStdinEnv = "SECRET_INIT_CLOSE_STDIN"
+
+	defaultRetryMaxAttempts = 1
+	defaultRetryBaseDelay   = time.Second
+
+	defaultBreakerCooldown = time.Minute
+
+	defaultStopSignal  = syscall.SIGTERM
+	defaultStopTimeout = 10 * time.Second
+
+	defaultDefaultDelimiter = "|"
 )
 
+// Config is the application config, loaded from SECRET_INIT_* environment variables.
+// The env and default tags describe each setting for the config schema.
 type Config struct {
-	LogLevel  string        `json:"log_level"`
-	JSONLog   bool          `json:"json_log"`
-	LogServer string        `json:"log_server"`
-	Daemon    bool          `json:"daemon"`
-	Delay     time.Duration `json:"delay"`
+	ConfigFile string `json:"config_file" env:"SECRET_INIT_CONFIG_FILE"`
+	// FileEnv lists the environment variables set from the config file, they are not passed to the entrypoint process
+	FileEnv []string `json:"-"`
+
+	LogLevel      string `json:"log_level" env:"SECRET_INIT_LOG_LEVEL"`
+	JSONLog       bool   `json:"json_log" env:"SECRET_INIT_JSON_LOG"`
+	LogServer     string `json:"log_server" env:"SECRET_INIT_LOG_SERVER"`
+	Quiet         bool   `json:"quiet" env:"SECRET_INIT_QUIET"`
+	LogStderrOnly bool   `json:"log_stderr_only" env:"SECRET_INIT_LOG_STDERR_ONLY"`
+	EventLog      string `json:"event_log" env:"SECRET_INIT_EVENT_LOG"`
+	AuditLog      string `json:"audit_log" env:"SECRET_INIT_AUDIT_LOG"`
+	// LogServerProto is empty for udp
+	LogServerProto string `json:"log_server_proto" env:"SECRET_INIT_LOG_SERVER_PROTO"`
+	// EnvPrefix limits the env vars scanned for references, the keys are not renamed
+	EnvPrefix   string        `json:"env_prefix" env:"SECRET_INIT_ENV_PREFIX"`
+	Daemon      bool          `json:"daemon" env:"SECRET_INIT_DAEMON"`
+	Delay       time.Duration `json:"delay" env:"SECRET_INIT_DELAY"`
+	RenderPath  string        `json:"render_path" env:"SECRET_INIT_RENDER_PATH"`
+	CacheTTL    time.Duration `json:"cache_ttl" env:"SECRET_INIT_CACHE_TTL"`
+	DryRun      bool          `json:"dry_run" env:"SECRET_INIT_DRY_RUN"`
+	Disable     bool          `json:"disable" env:"SECRET_INIT_DISABLE"`
+	Print       bool          `json:"print" env:"SECRET_INIT_PRINT"`
+	ResultFile  string        `json:"result_file" env:"SECRET_INIT_RESULT_FILE"`
+	SummaryFile string        `json:"summary_file" env:"SECRET_INIT_SUMMARY_FILE"`
+	MetricsAddr string        `json:"metrics_addr" env:"SECRET_INIT_METRICS_ADDR"`
+	HealthAddr  string        `json:"health_addr" env:"SECRET_INIT_HEALTH_ADDR"`
+	// Timeout bounds the loading of the secrets, zero means no timeout
+	Timeout time.Duration `json:"timeout" env:"SECRET_INIT_TIMEOUT"`
+	// ReferencesStdinLines consumes stdin, the entrypoint process gets an empty stdin then
+	ReferencesStdinLines bool `json:"references_stdin_lines" env:"SECRET_INIT_REFERENCES_STDIN_LINES"`
+	// StdinSecrets consumes stdin as well, so it can't be combined with ReferencesStdinLines
+	StdinSecrets bool `json:"stdin_secrets" env:"SECRET_INIT_STDIN_SECRETS"`
+	// DefaultDelimiter separates references from their default values, an empty delimiter disables defaults
+	DefaultDelimiter string `json:"default_delimiter" env:"SECRET_INIT_DEFAULT_DELIMITER" default:"|"`
+
+	// KeystoreCert, KeystoreKey and KeystoreCA name the secrets (by their env var) holding the PEM encoded entries
+	KeystorePath     string `json:"keystore_path" env:"SECRET_INIT_KEYSTORE_PATH"`
+	KeystorePassword string `json:"keystore_password" env:"SECRET_INIT_KEYSTORE_PASSWORD"`
+	KeystoreCert     string `json:"keystore_cert" env:"SECRET_INIT_KEYSTORE_CERT"`
+	KeystoreKey      string `json:"keystore_key" env:"SECRET_INIT_KEYSTORE_KEY"`
+	KeystoreCA       string `json:"keystore_ca" env:"SECRET_INIT_KEYSTORE_CA"`
+
+	// CacheRedisURL moves the cache to Redis, the secrets are encrypted with CacheKey or the key in CacheKeyFile
+	CacheRedisURL string `json:"cache_redis_url" env:"SECRET_INIT_CACHE_REDIS_URL"`
+	CacheKey      string `json:"cache_key" env:"SECRET_INIT_CACHE_KEY"`
+	CacheKeyFile  string `json:"cache_key_file" env:"SECRET_INIT_CACHE_KEY_FILE"`
+	// CachePath moves the cache to a local file instead, it can't be combined with CacheRedisURL
+	CachePath string `json:"cache_path" env:"SECRET_INIT_CACHE_PATH"`
+
+	StopSignal  syscall.Signal `json:"stop_signal" env:"SECRET_INIT_STOP_SIGNAL" default:"SIGTERM"`
+	StopTimeout time.Duration  `json:"stop_timeout" env:"SECRET_INIT_STOP_TIMEOUT" default:"10s"`
+	// RenewalKillTimeout is how long the process gets to stop after its secrets expire
+	RenewalKillTimeout time.Duration `json:"renewal_kill_timeout" env:"SECRET_INIT_RENEWAL_KILL_TIMEOUT" default:"10s"`
+	// RenewSignal tells the process to reconnect with its renewed credentials, zero sends no signal
+	RenewSignal syscall.Signal `json:"renew_signal" env:"SECRET_INIT_RENEW_SIGNAL"`
+
+	// IgnoreEnv holds the keys of the env vars passed to the process as they are, even if they look like references
+	IgnoreEnv []string `json:"ignore_env" env:"SECRET_INIT_IGNORE_ENV"`
+	// OnlyEnv is nil if all env vars are scanned, an empty allowlist scans none
+	OnlyEnv []string `json:"only_env" env:"SECRET_INIT_ONLY_ENV"`
+
+	// Require and ValidationSchema are checked before the process is started, a violation aborts the start
+	Require          []string `json:"require" env:"SECRET_INIT_REQUIRE"`
+	ValidationSchema string   `json:"validation_schema" env:"SECRET_INIT_VALIDATION_SCHEMA"`
+
+	// Environment selects the environment specific variant of the references through their {env} placeholder
+	Environment string `json:"environment" env:"SECRET_INIT_ENVIRONMENT"`
+
+	// Provider restricts the references to a single provider type, e.g. vault, empty allows all providers
+	Provider string `json:"provider" env:"SECRET_INIT_PROVIDER"`
+
+	// FailFast cancels the other providers on the first provider error, instead of joining all errors
+	FailFast bool `json:"fail_fast" env:"SECRET_INIT_FAIL_FAST"`
+
+	// MaskOutput replaces the secret values in the output of the entrypoint process with ***
+	MaskOutput bool `json:"mask_output" env:"SECRET_INIT_MASK_OUTPUT"`
+
+	// PreExec runs before every start of the entrypoint process, a failing command aborts the start
+	PreExec string `json:"pre_exec" env:"SECRET_INIT_PRE_EXEC"`
+
+	// FilesDir receives the binary secrets, which are never set as env vars
+	FilesDir string `json:"files_dir" env:"SECRET_INIT_FILES_DIR"`
+
+	// ReloadOnSighup restarts the process with reloaded secrets on SIGHUP, the signal isn't forwarded then
+	ReloadOnSighup bool `json:"reload_on_sighup" env:"SECRET_INIT_RELOAD_ON_SIGHUP"`
+	// ReloadInterval is jittered, so replicas started at once don't reload at once, zero disables periodic reloads
+	ReloadInterval time.Duration `json:"reload_interval" env:"SECRET_INIT_RELOAD_INTERVAL"`
+
+	MaxReferencesPerProvider int `json:"max_references_per_provider" env:"SECRET_INIT_MAX_REFERENCES_PER_PROVIDER"`
+	MaxSecretBytes           int `json:"max_secret_bytes" env:"SECRET_INIT_MAX_SECRET_BYTES"`
+	// RequireAuth rejects providers without credentials instead of letting them read anonymously
+	RequireAuth bool `json:"require_auth" env:"SECRET_INIT_REQUIRE_AUTH"`
+	// CABundle is trusted by the providers building their own HTTP clients (e.g. conjur, doppler)
+	CABundle string `json:"ca_bundle" env:"SECRET_INIT_CA_BUNDLE"`
+	// IgnoreMissingSecrets leaves the env vars of missing secrets unset, vault and bao use their own setting
+	IgnoreMissingSecrets bool `json:"ignore_missing_secrets" env:"SECRET_INIT_IGNORE_MISSING_SECRETS"`
+
+	RetryMaxAttempts int           `json:"retry_max_attempts" env:"SECRET_INIT_RETRY_MAX_ATTEMPTS" default:"1"`
+	RetryBaseDelay   time.Duration `json:"retry_base_delay" env:"SECRET_INIT_RETRY_BASE_DELAY" default:"1s"`
+
+	// BreakerThreshold is the number of consecutive failures opening the breaker of a provider, zero disables it
+	BreakerThreshold int           `json:"cb_threshold" env:"SECRET_INIT_CB_THRESHOLD"`
+	BreakerCooldown  time.Duration `json:"cb_cooldown" env:"SECRET_INIT_CB_COOLDOWN" default:"1m0s"`
+
+	// ExtraFiles are inherited by the process in this order, starting from file descriptor 3
+	ExtraFiles []int `json:"extra_files" env:"SECRET_INIT_EXTRA_FILES"`
+	CloseStdin bool  `json:"close_stdin" env:"SECRET_INIT_CLOSE_STDIN"`
 }
 
 func LoadConfig() (*Config, error) {
+	// The file is applied first, so everything below reads its settings from the environment as usual
+	var fileEnv []string
+	configFile := os.Getenv(ConfigFileEnv)
+	if configFile != "" {
+		var err error
+		fileEnv, err = applyConfigFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logServerProto := os.Getenv(LogServerProtoEnv)
+	if logServerProto != "" && logServerProto != "udp" && logServerProto != "tcp" {
+		return nil, fmt.Errorf("invalid log server protocol: %s", logServerProto)
+	}
+
+	// 0, 1 and 2 are the standard streams of the process already, they are not passed on as extra files
+	var extraFiles []int
+	for _, value := range splitList(os.Getenv(ExtraFilesEnv)) {
+		fd, err := cast.ToIntE(value)
+		if err != nil || fd < 3 {
+			return nil, fmt.Errorf("invalid extra file descriptor: %s", value)
+		}
+
+		extraFiles = append(extraFiles, fd)
+	}
+
+	// Retries are disabled by default, a single attempt is made
+	retryMaxAttempts := defaultRetryMaxAttempts
+	if value, ok := os.LookupEnv(RetryMaxAttemptsEnv); ok {
+		retryMaxAttempts = cast.ToInt(value)
+	}
+
+	retryBaseDelay := defaultRetryBaseDelay
+	if value, ok := os.LookupEnv(RetryBaseDelayEnv); ok {
+		retryBaseDelay = cast.ToDuration(value)
+	}
+
+	breakerCooldown := defaultBreakerCooldown
+	if value, ok := os.LookupEnv(BreakerCooldownEnv); ok {
+		breakerCooldown = cast.ToDuration(value)
+	}
+
+	stopSignal := defaultStopSignal
+	if value, ok := os.LookupEnv(StopSignalEnv); ok {
+		var err error
+		stopSignal, err = parseSignal("stop", value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stopTimeout := defaultStopTimeout
+	if value, ok := os.LookupEnv(StopTimeoutEnv); ok {
+		stopTimeout = cast.ToDuration(value)
+	}
+
+	defaultDelimiter := defaultDefaultDelimiter
+	if value, ok := os.LookupEnv(DefaultDelimiterEnv); ok {
+		defaultDelimiter = value
+	}
+
+	var renewSignal syscall.Signal
+	if value, ok := os.LookupEnv(RenewSignalEnv); ok {
+		var err error
+		renewSignal, err = parseSignal("renew", value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var onlyEnv []string
+	if value, ok := os.LookupEnv(OnlyEnvEnv); ok {
+		onlyEnv = splitList(value)
+		if onlyEnv == nil {
+			onlyEnv = []string{}
+		}
+	}
+
+	// Both read stdin until EOF, nothing would be left for the second one
+	if cast.ToBool(os.Getenv(ReferencesStdinLinesEnv)) && cast.ToBool(os.Getenv(StdinSecretsEnv)) {
+		return nil, fmt.Errorf("%s and %s can't be combined", ReferencesStdinLinesEnv, StdinSecretsEnv)
+	}
+
+	// The cache is kept in a single store
+	if os.Getenv(CacheRedisURLEnv) != "" && os.Getenv(CachePathEnv) != "" {
+		return nil, fmt.Errorf("%s and %s can't be combined", CacheRedisURLEnv, CachePathEnv)
+	}
+
+	// The cache is encrypted with a single key
+	if os.Getenv(CacheKeyEnv) != "" && os.Getenv(CacheKeyFileEnv) != "" {
+		return nil, fmt.Errorf("%s and %s can't be combined", CacheKeyEnv, CacheKeyFileEnv)
+	}
+
+	// Falls back to the stop timeout, so both can be tuned at once
+	renewalKillTimeout := stopTimeout
+	if value, ok := os.LookupEnv(RenewalKillTimeoutEnv); ok {
+		renewalKillTimeout = cast.ToDuration(value)
+	}
+
 	return &Config{
-		LogLevel:  os.Getenv(LogLevelEnv),
-		JSONLog:   cast.ToBool(os.Getenv(JSONLogEnv)),
-		LogServer: os.Getenv(LogServerEnv),
-		Daemon:    cast.ToBool(os.Getenv(DaemonEnv)),
-		Delay:     cast.ToDuration(os.Getenv(DelayEnv)),
+		ConfigFile:               configFile,
+		FileEnv:                  fileEnv,
+		LogLevel:                 os.Getenv(LogLevelEnv),
+		JSONLog:                  cast.ToBool(os.Getenv(JSONLogEnv)),
+		LogServer:                os.Getenv(LogServerEnv),
+		LogServerProto:           logServerProto,
+		Quiet:                    cast.ToBool(os.Getenv(QuietEnv)),
+		LogStderrOnly:            cast.ToBool(os.Getenv(LogStderrOnlyEnv)),
+		EventLog:                 os.Getenv(EventLogEnv),
+		AuditLog:                 os.Getenv(AuditLogEnv),
+		EnvPrefix:                os.Getenv(EnvPrefixEnv),
+		Daemon:                   cast.ToBool(os.Getenv(DaemonEnv)),
+		Delay:                    cast.ToDuration(os.Getenv(DelayEnv)),
+		RenderPath:               os.Getenv(RenderPathEnv),
+		CacheTTL:                 cast.ToDuration(os.Getenv(CacheTTLEnv)),
+		CacheRedisURL:            os.Getenv(CacheRedisURLEnv),
+		CacheKey:                 os.Getenv(CacheKeyEnv),
+		CacheKeyFile:             os.Getenv(CacheKeyFileEnv),
+		CachePath:                os.Getenv(CachePathEnv),
+		DryRun:                   cast.ToBool(os.Getenv(DryRunEnv)),
+		Disable:                  cast.ToBool(os.Getenv(DisableEnv)),
+		Print:                    cast.ToBool(os.Getenv(PrintEnv)),
+		MaskOutput:               cast.ToBool(os.Getenv(MaskOutputEnv)),
+		ResultFile:               os.Getenv(ResultFileEnv),
+		SummaryFile:              os.Getenv(SummaryFileEnv),
+		MetricsAddr:              os.Getenv(MetricsAddrEnv),
+		HealthAddr:               os.Getenv(HealthAddrEnv),
+		Timeout:                  cast.ToDuration(os.Getenv(TimeoutEnv)),
+		ReferencesStdinLines:     cast.ToBool(os.Getenv(ReferencesStdinLinesEnv)),
+		StdinSecrets:             cast.ToBool(os.Getenv(StdinSecretsEnv)),
+		DefaultDelimiter:         defaultDelimiter,
+		KeystorePath:             os.Getenv(KeystorePathEnv),
+		KeystorePassword:         os.Getenv(KeystorePasswordEnv),
+		KeystoreCert:             os.Getenv(KeystoreCertEnv),
+		KeystoreKey:              os.Getenv(KeystoreKeyEnv),
+		KeystoreCA:               os.Getenv(KeystoreCAEnv),
+		StopSignal:               stopSignal,
+		StopTimeout:              stopTimeout,
+		RenewalKillTimeout:       renewalKillTimeout,
+		RenewSignal:              renewSignal,
+		ReloadOnSighup:           cast.ToBool(os.Getenv(ReloadOnSighupEnv)),
+		ReloadInterval:           cast.ToDuration(os.Getenv(ReloadIntervalEnv)),
+		PreExec:                  os.Getenv(PreExecEnv),
+		FilesDir:                 os.Getenv(FilesDirEnv),
+		IgnoreEnv:                splitList(os.Getenv(IgnoreEnvEnv)),
+		OnlyEnv:                  onlyEnv,
+		Require:                  splitList(os.Getenv(RequireEnv)),
+		ValidationSchema:         os.Getenv(ValidationSchemaEnv),
+		Environment:              os.Getenv(EnvironmentEnv),
+		Provider:                 os.Getenv(ProviderEnv),
+		FailFast:                 cast.ToBool(os.Getenv(FailFastEnv)),
+		MaxReferencesPerProvider: cast.ToInt(os.Getenv(MaxReferencesPerProviderEnv)),
+		MaxSecretBytes:           cast.ToInt(os.Getenv(MaxSecretBytesEnv)),
+		RequireAuth:              cast.ToBool(os.Getenv(RequireAuthEnv)),
+		CABundle:                 os.Getenv(CABundleEnv),
+		IgnoreMissingSecrets:     cast.ToBool(os.Getenv(IgnoreMissingSecretsEnv)),
+		RetryMaxAttempts:         retryMaxAttempts,
+		RetryBaseDelay:           retryBaseDelay,
+		BreakerThreshold:         cast.ToInt(os.Getenv(BreakerThresholdEnv)),
+		BreakerCooldown:          breakerCooldown,
+		ExtraFiles:               extraFiles,
+		CloseStdin:               cast.ToBool(os.Getenv(CloseStdinEnv)),
 	}, nil
 }
+
+// splitList splits a comma-separated list, the entries are trimmed and empty ones are dropped.
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
+}
+
+// parseSignal accepts signal names with or without the SIG prefix (e.g. SIGTERM, TERM)
+// and plain signal numbers (e.g. 15).
+func parseSignal(kind string, value string) (syscall.Signal, error) {
+	if number, err := cast.ToIntE(value); err == nil && number > 0 {
+		return syscall.Signal(number), nil
+	}
+
+	name := strings.ToUpper(value)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	signal := unix.SignalNum(name)
+	if signal == 0 {
+		return 0, fmt.Errorf("invalid %s signal: %s", kind, value)
+	}
+
+	return signal, nil
+}
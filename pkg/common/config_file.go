@@ -0,0 +1,139 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// providersSection holds the settings of the providers in the config file, by provider type
+const providersSection = "providers"
+
+// applyConfigFile reads a JSON config file and sets the environment variables of its settings,
+// unless they are set already, so the environment always takes precedence over the file.
+// Top-level keys are the JSON names of the Config fields (e.g. "log_level"), the providers section holds
+// the environment variables of each provider, since providers (and their SDKs) read their settings from there:
+//
+//	{"daemon": true, "providers": {"vault": {"VAULT_ADDR": "https://vault:8200"}}}
+//
+// The variables set from the file are returned, so they can be kept from the entrypoint process.
+func applyConfigFile(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var settings map[string]json.RawMessage
+	err = json.Unmarshal(content, &settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	fileEnv := make(map[string]string)
+	envByName := configEnvVars()
+	for name, value := range settings {
+		if name == providersSection {
+			continue
+		}
+
+		env, ok := envByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q in config file %s", name, path)
+		}
+
+		fileEnv[env], err = settingValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid setting %q in config file %s: %w", name, path, err)
+		}
+	}
+
+	if section, ok := settings[providersSection]; ok {
+		var providers map[string]map[string]json.RawMessage
+		err = json.Unmarshal(section, &providers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s of config file %s: %w", providersSection, path, err)
+		}
+
+		for providerName, providerSettings := range providers {
+			for env, value := range providerSettings {
+				fileEnv[env], err = settingValue(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid setting %q of provider %s in config file %s: %w", env, providerName, path, err)
+				}
+			}
+		}
+	}
+
+	var applied []string
+	for env, value := range fileEnv {
+		if _, ok := os.LookupEnv(env); ok {
+			continue
+		}
+
+		err = os.Setenv(env, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set %s from config file %s: %w", env, path, err)
+		}
+		applied = append(applied, env)
+	}
+	slices.Sort(applied)
+
+	return applied, nil
+}
+
+// configEnvVars maps the JSON names of the Config fields to their environment variables.
+func configEnvVars() map[string]string {
+	envByName := make(map[string]string)
+
+	configType := reflect.TypeOf(Config{})
+	for i := range configType.NumField() {
+		field := configType.Field(i)
+
+		env, ok := field.Tag.Lookup("env")
+		if !ok || env == ConfigFileEnv {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		envByName[name] = env
+	}
+
+	return envByName
+}
+
+// settingValue returns a JSON string as is and other scalars (e.g. true or 5) as written in the file.
+func settingValue(value json.RawMessage) (string, error) {
+	value = bytes.TrimSpace(value)
+
+	switch {
+	case len(value) > 0 && value[0] == '"':
+		var s string
+		err := json.Unmarshal(value, &s)
+
+		return s, err
+	case len(value) > 0 && (value[0] == '{' || value[0] == '['):
+		return "", fmt.Errorf("expected a string, number or boolean")
+	case bytes.Equal(value, []byte("null")):
+		return "", nil
+	default:
+		return string(value), nil
+	}
+}
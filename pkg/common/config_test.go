@@ -15,8 +15,13 @@
 package common
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,6 +31,7 @@ func TestConfig(t *testing.T) {
 		name       string
 		env        map[string]string
 		wantConfig *Config
+		err        error
 	}{
 		{
 			name: "Valid configuration",
@@ -36,12 +42,520 @@ func TestConfig(t *testing.T) {
 				DaemonEnv:    "true",
 			},
 			wantConfig: &Config{
-				LogLevel:  "debug",
-				JSONLog:   true,
-				LogServer: "",
-				Daemon:    true,
+				LogLevel:           "debug",
+				JSONLog:            true,
+				LogServer:          "",
+				Daemon:             true,
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
 			},
 		},
+		{
+			name: "Valid retry configuration",
+			env: map[string]string{
+				RetryMaxAttemptsEnv: "5",
+				RetryBaseDelayEnv:   "200ms",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   5,
+				RetryBaseDelay:     200 * time.Millisecond,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid circuit breaker configuration",
+			env: map[string]string{
+				BreakerThresholdEnv: "3",
+				BreakerCooldownEnv:  "30s",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerThreshold:   3,
+				BreakerCooldown:    30 * time.Second,
+			},
+		},
+		{
+			name: "Valid stop configuration",
+			env: map[string]string{
+				StopSignalEnv:  "quit",
+				StopTimeoutEnv: "30s",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGQUIT,
+				StopTimeout:        30 * time.Second,
+				RenewalKillTimeout: 30 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid renewal kill timeout",
+			env: map[string]string{
+				RenewalKillTimeoutEnv: "1m",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: time.Minute,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid renew signal",
+			env: map[string]string{
+				RenewSignalEnv: "usr1",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RenewSignal:        syscall.SIGUSR1,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid default delimiter",
+			env: map[string]string{
+				DefaultDelimiterEnv: "||",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "||",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid max references per provider",
+			env: map[string]string{
+				MaxReferencesPerProviderEnv: "100",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:         "|",
+				StopSignal:               syscall.SIGTERM,
+				StopTimeout:              10 * time.Second,
+				RenewalKillTimeout:       10 * time.Second,
+				MaxReferencesPerProvider: 100,
+				RetryMaxAttempts:         1,
+				RetryBaseDelay:           time.Second,
+				BreakerCooldown:          time.Minute,
+			},
+		},
+		{
+			name: "Valid require auth",
+			env: map[string]string{
+				RequireAuthEnv: "true",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RequireAuth:        true,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid ignore missing secrets",
+			env: map[string]string{
+				IgnoreMissingSecretsEnv: "true",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:     "|",
+				StopSignal:           syscall.SIGTERM,
+				StopTimeout:          10 * time.Second,
+				RenewalKillTimeout:   10 * time.Second,
+				IgnoreMissingSecrets: true,
+				RetryMaxAttempts:     1,
+				RetryBaseDelay:       time.Second,
+				BreakerCooldown:      time.Minute,
+			},
+		},
+		{
+			name: "Valid pre-exec hook",
+			env: map[string]string{
+				PreExecEnv: "mkdir -p /tmp/app",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				PreExec:            "mkdir -p /tmp/app",
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid ignored env vars",
+			env: map[string]string{
+				IgnoreEnvEnv: " HOMEPAGE_URL, ,FILE_PATTERN,",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				IgnoreEnv:          []string{"HOMEPAGE_URL", "FILE_PATTERN"},
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid validation configuration",
+			env: map[string]string{
+				RequireEnv:          "DB_PASSWORD, API_KEY",
+				ValidationSchemaEnv: "/etc/secret-init/schema.json",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				Require:            []string{"DB_PASSWORD", "API_KEY"},
+				ValidationSchema:   "/etc/secret-init/schema.json",
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid allowed env vars",
+			env: map[string]string{
+				OnlyEnvEnv: "DB_PASSWORD, API_TOKEN",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				OnlyEnv:            []string{"DB_PASSWORD", "API_TOKEN"},
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid empty allowlist",
+			env: map[string]string{
+				OnlyEnvEnv: " ,",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				OnlyEnv:            []string{},
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid reload on SIGHUP",
+			env: map[string]string{
+				DaemonEnv:         "true",
+				ReloadOnSighupEnv: "true",
+			},
+			wantConfig: &Config{
+				Daemon:             true,
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				ReloadOnSighup:     true,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid environment",
+			env: map[string]string{
+				EnvironmentEnv: "staging",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				Environment:        "staging",
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid fail fast",
+			env: map[string]string{
+				FailFastEnv: "true",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				FailFast:           true,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid pinned provider",
+			env: map[string]string{
+				ProviderEnv: "vault",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				Provider:           "vault",
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid print",
+			env: map[string]string{
+				PrintEnv: "true",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				Print:              true,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid mask output",
+			env: map[string]string{
+				MaskOutputEnv: "true",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				MaskOutput:         true,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid disable",
+			env: map[string]string{
+				DisableEnv: "true",
+			},
+			wantConfig: &Config{
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				Disable:            true,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid log server configuration",
+			env: map[string]string{
+				LogServerEnv:      "syslog:514",
+				LogServerProtoEnv: "tcp",
+			},
+			wantConfig: &Config{
+				LogServer:          "syslog:514",
+				LogServerProto:     "tcp",
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid CA bundle configuration",
+			env: map[string]string{
+				CABundleEnv: "/etc/ssl/internal-ca.pem",
+			},
+			wantConfig: &Config{
+				CABundle:           "/etc/ssl/internal-ca.pem",
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid inherited file descriptors configuration",
+			env: map[string]string{
+				ExtraFilesEnv: "3, 5",
+				CloseStdinEnv: "true",
+			},
+			wantConfig: &Config{
+				ExtraFiles:         []int{3, 5},
+				CloseStdin:         true,
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid periodic reload configuration",
+			env: map[string]string{
+				DaemonEnv:         "true",
+				ReloadIntervalEnv: "15m",
+			},
+			wantConfig: &Config{
+				Daemon:             true,
+				ReloadInterval:     15 * time.Minute,
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Valid file cache configuration",
+			env: map[string]string{
+				CacheTTLEnv:     "1h",
+				CachePathEnv:    "/var/cache/secret-init/secrets.cache",
+				CacheKeyFileEnv: "/etc/secret-init/cache-key",
+			},
+			wantConfig: &Config{
+				CacheTTL:           time.Hour,
+				CachePath:          "/var/cache/secret-init/secrets.cache",
+				CacheKeyFile:       "/etc/secret-init/cache-key",
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Invalid cache configuration - redis and file cache combined",
+			env: map[string]string{
+				CacheRedisURLEnv: "redis://redis:6379/0",
+				CachePathEnv:     "/var/cache/secret-init/secrets.cache",
+			},
+			err: fmt.Errorf("SECRET_INIT_CACHE_REDIS_URL and SECRET_INIT_CACHE_PATH can't be combined"),
+		},
+		{
+			name: "Valid file cache configuration - key set directly",
+			env: map[string]string{
+				CacheTTLEnv:  "1h",
+				CachePathEnv: "/var/cache/secret-init/secrets.cache",
+				CacheKeyEnv:  "c2VjcmV0LWluaXQ=",
+			},
+			wantConfig: &Config{
+				CacheTTL:           time.Hour,
+				CachePath:          "/var/cache/secret-init/secrets.cache",
+				CacheKey:           "c2VjcmV0LWluaXQ=",
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Invalid cache configuration - key and key file combined",
+			env: map[string]string{
+				CacheKeyEnv:     "c2VjcmV0LWluaXQ=",
+				CacheKeyFileEnv: "/etc/secret-init/cache-key",
+			},
+			err: fmt.Errorf("SECRET_INIT_CACHE_KEY and SECRET_INIT_CACHE_KEY_FILE can't be combined"),
+		},
+		{
+			name: "Invalid extra file descriptor",
+			env: map[string]string{
+				ExtraFilesEnv: "3,2",
+			},
+			err: fmt.Errorf("invalid extra file descriptor: 2"),
+		},
+		{
+			name: "Invalid log server protocol",
+			env: map[string]string{
+				LogServerProtoEnv: "unix",
+			},
+			err: fmt.Errorf("invalid log server protocol: unix"),
+		},
+		{
+			name: "Invalid stop signal",
+			env: map[string]string{
+				StopSignalEnv: "SIGNOPE",
+			},
+			err: fmt.Errorf("invalid stop signal: SIGNOPE"),
+		},
+		{
+			name: "Invalid renew signal",
+			env: map[string]string{
+				RenewSignalEnv: "SIGNOPE",
+			},
+			err: fmt.Errorf("invalid renew signal: SIGNOPE"),
+		},
+		{
+			name: "Both stdin options",
+			env: map[string]string{
+				ReferencesStdinLinesEnv: "true",
+				StdinSecretsEnv:         "true",
+			},
+			err: fmt.Errorf("SECRET_INIT_REFERENCES_STDIN_LINES and SECRET_INIT_STDIN_SECRETS can't be combined"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -53,9 +567,144 @@ func TestConfig(t *testing.T) {
 			defer os.Clearenv()
 
 			config, err := LoadConfig()
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
 			assert.Nil(t, err, "Unexpected error")
 
 			assert.Equal(t, ttp.wantConfig, config, "Unexpected config")
 		})
 	}
 }
+
+func TestConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		env         map[string]string
+		wantConfig  *Config
+		wantFileEnv map[string]string
+		err         error
+	}{
+		{
+			name: "File only",
+			file: `{"log_level": "debug", "daemon": true, "stop_timeout": "30s", "max_secret_bytes": 1024}`,
+			wantConfig: &Config{
+				FileEnv:            []string{DaemonEnv, LogLevelEnv, MaxSecretBytesEnv, StopTimeoutEnv},
+				LogLevel:           "debug",
+				Daemon:             true,
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        30 * time.Second,
+				RenewalKillTimeout: 30 * time.Second,
+				MaxSecretBytes:     1024,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Env only",
+			file: `{}`,
+			env: map[string]string{
+				LogLevelEnv: "warn",
+			},
+			wantConfig: &Config{
+				LogLevel:           "warn",
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Env takes precedence over the file",
+			file: `{"log_level": "debug", "json_log": true}`,
+			env: map[string]string{
+				LogLevelEnv: "warn",
+			},
+			wantConfig: &Config{
+				FileEnv:            []string{JSONLogEnv},
+				LogLevel:           "warn",
+				JSONLog:            true,
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+		},
+		{
+			name: "Provider settings",
+			file: `{"providers": {"vault": {"VAULT_ADDR": "https://vault:8200", "VAULT_ROLE": "app"}, "file": {"FILE_TRIM_SPACE": true}}}`,
+			env: map[string]string{
+				"VAULT_ROLE": "other",
+			},
+			wantConfig: &Config{
+				FileEnv:            []string{"FILE_TRIM_SPACE", "VAULT_ADDR"},
+				DefaultDelimiter:   "|",
+				StopSignal:         syscall.SIGTERM,
+				StopTimeout:        10 * time.Second,
+				RenewalKillTimeout: 10 * time.Second,
+				RetryMaxAttempts:   1,
+				RetryBaseDelay:     time.Second,
+				BreakerCooldown:    time.Minute,
+			},
+			wantFileEnv: map[string]string{
+				"VAULT_ADDR":      "https://vault:8200",
+				"VAULT_ROLE":      "other",
+				"FILE_TRIM_SPACE": "true",
+			},
+		},
+		{
+			name: "Unknown setting",
+			file: `{"log_levl": "debug"}`,
+			err:  fmt.Errorf(`unknown setting "log_levl" in config file {file}`),
+		},
+		{
+			name: "Invalid setting",
+			file: `{"log_level": ["debug"]}`,
+			err:  fmt.Errorf(`invalid setting "log_level" in config file {file}: expected a string, number or boolean`),
+		},
+		{
+			name: "Invalid JSON",
+			file: `log_level: debug`,
+			err:  fmt.Errorf("failed to parse config file {file}: invalid character 'l' looking for beginning of value"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "secret-init.json")
+			err := os.WriteFile(file, []byte(ttp.file), 0o600)
+			assert.Nil(t, err, "Failed to write config file")
+
+			os.Setenv(ConfigFileEnv, file)
+			for envKey, envVal := range ttp.env {
+				os.Setenv(envKey, envVal)
+			}
+			defer os.Clearenv()
+
+			config, err := LoadConfig()
+			if ttp.err != nil {
+				assert.EqualError(t, err, strings.ReplaceAll(ttp.err.Error(), "{file}", file), "Unexpected error message")
+				return
+			}
+			assert.Nil(t, err, "Unexpected error")
+
+			ttp.wantConfig.ConfigFile = file
+			assert.Equal(t, ttp.wantConfig, config, "Unexpected config")
+
+			for envKey, envVal := range ttp.wantFileEnv {
+				assert.Equal(t, envVal, os.Getenv(envKey), "Unexpected value of %s", envKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// New creates the HTTP client of a REST-based provider.
+// The CA certificates of the bundles (PEM files, empty paths are skipped) are trusted on top of the system ones,
+// since private endpoints are commonly served with a certificate of an internal CA.
+func New(timeout time.Duration, caBundles ...string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	certPool, err := loadCertPool(caBundles)
+	if err != nil {
+		return nil, err
+	}
+
+	if certPool != nil {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    certPool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// loadCertPool returns nil without bundles, so the system roots are used as usual.
+func loadCertPool(caBundles []string) (*x509.CertPool, error) {
+	var certPool *x509.CertPool
+	for _, caBundle := range caBundles {
+		if caBundle == "" {
+			continue
+		}
+
+		cert, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate %s: %w", caBundle, err)
+		}
+
+		if certPool == nil {
+			certPool, err = x509.SystemCertPool()
+			if err != nil {
+				certPool = x509.NewCertPool()
+			}
+		}
+
+		if !certPool.AppendCertsFromPEM(cert) {
+			return nil, fmt.Errorf("no valid certificate found in %s", caBundle)
+		}
+	}
+
+	return certPool, nil
+}
@@ -0,0 +1,101 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	client, err := New(time.Second, "", caBundle)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, client.Timeout, "Unexpected timeout")
+
+	wantCertPool, err := x509.SystemCertPool()
+	require.NoError(t, err)
+	wantCertPool.AddCert(server.Certificate())
+
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok, "Unexpected transport")
+	require.NotNil(t, transport.TLSClientConfig, "TLS config must be set with a bundle")
+	assert.True(t, wantCertPool.Equal(transport.TLSClientConfig.RootCAs), "Root CAs must contain the bundle")
+
+	response, err := client.Get(server.URL)
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusNoContent, response.StatusCode, "Unexpected status")
+
+	// Without a bundle the system roots are used, which don't trust the server
+	client, err = New(time.Second)
+	require.NoError(t, err)
+	transport, ok = client.Transport.(*http.Transport)
+	require.True(t, ok, "Unexpected transport")
+	if transport.TLSClientConfig != nil {
+		assert.Nil(t, transport.TLSClientConfig.RootCAs, "Root CAs must be left alone without a bundle")
+	}
+
+	_, err = client.Get(server.URL)
+	assert.Error(t, err, "Server must not be trusted without the bundle")
+}
+
+func TestNewInvalidBundle(t *testing.T) {
+	dir := t.TempDir()
+	invalidBundle := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, os.WriteFile(invalidBundle, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name     string
+		caBundle string
+		err      error
+	}{
+		{
+			name:     "Missing bundle",
+			caBundle: filepath.Join(dir, "missing.pem"),
+			err:      fmt.Errorf("failed to read certificate %s/missing.pem: open %s/missing.pem: no such file or directory", dir, dir),
+		},
+		{
+			name:     "Bundle without certificates",
+			caBundle: invalidBundle,
+			err:      fmt.Errorf("no valid certificate found in %s", invalidBundle),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			_, err := New(time.Second, ttp.caBundle)
+			assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mask
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Replacement is written in place of every secret value
+const Replacement = "***"
+
+// Values shorter than this (e.g. a port or "true") would mask unrelated output all over the place
+const minValueLength = 4
+
+// Writer replaces the secret values in the stream written to it.
+// A value may be split across writes, so output that could be the beginning of a value is held back
+// until the next write tells whether it is one, or until Flush.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	values  [][]byte
+	pending []byte
+}
+
+// NewWriter masks the values in everything written to w, values shorter than 4 bytes are left alone.
+func NewWriter(w io.Writer, values []string) *Writer {
+	var masked [][]byte
+	for _, value := range values {
+		if len(value) >= minValueLength && !slices.ContainsFunc(masked, func(v []byte) bool { return string(v) == value }) {
+			masked = append(masked, []byte(value))
+		}
+	}
+
+	// The longest value wins when values overlap, e.g. a password and a URL containing it
+	slices.SortFunc(masked, func(a, b []byte) int {
+		return len(b) - len(a)
+	})
+
+	return &Writer{w: w, values: masked}
+}
+
+// Write masks the values in p, it reports len(p) as written on success,
+// even if part of it is held back for the next write.
+func (m *Writer) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buf := append(m.pending, p...)
+
+	var out bytes.Buffer
+	i := 0
+	for i < len(buf) {
+		if n := m.matchAt(buf[i:]); n > 0 {
+			out.WriteString(Replacement)
+			i += n
+
+			continue
+		}
+
+		if m.isPrefix(buf[i:]) {
+			break
+		}
+
+		out.WriteByte(buf[i])
+		i++
+	}
+	m.pending = append([]byte(nil), buf[i:]...)
+
+	_, err := m.w.Write(out.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the output held back, once nothing else is going to be written.
+func (m *Writer) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.pending) == 0 {
+		return nil
+	}
+
+	_, err := m.w.Write(m.pending)
+	m.pending = nil
+
+	return err
+}
+
+// matchAt returns the length of the value buf starts with, or zero.
+func (m *Writer) matchAt(buf []byte) int {
+	for _, value := range m.values {
+		if bytes.HasPrefix(buf, value) {
+			return len(value)
+		}
+	}
+
+	return 0
+}
+
+// isPrefix reports whether buf is the beginning of a value, which the next write may complete.
+func (m *Writer) isPrefix(buf []byte) bool {
+	for _, value := range m.values {
+		if len(buf) < len(value) && bytes.HasPrefix(value, buf) {
+			return true
+		}
+	}
+
+	return false
+}
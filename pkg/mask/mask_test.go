@@ -0,0 +1,92 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mask
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		writes []string
+		want   string
+	}{
+		{
+			name:   "Value within a write",
+			values: []string{"s3cr3t"},
+			writes: []string{"password=s3cr3t\n"},
+			want:   "password=***\n",
+		},
+		{
+			name:   "Value spanning two writes",
+			values: []string{"s3cr3t"},
+			writes: []string{"panic: invalid password s3c", "r3t\ngoroutine 1 [running]\n"},
+			want:   "panic: invalid password ***\ngoroutine 1 [running]\n",
+		},
+		{
+			name:   "Value spanning many writes",
+			values: []string{"s3cr3t"},
+			writes: []string{"s", "3", "c", "r", "3", "t", "!"},
+			want:   "***!",
+		},
+		{
+			name:   "Held back output turns out not to be a value",
+			values: []string{"s3cr3t"},
+			writes: []string{"s3cr", "eam\n"},
+			want:   "s3cream\n",
+		},
+		{
+			name:   "Output ending with the beginning of a value is flushed",
+			values: []string{"s3cr3t"},
+			writes: []string{"done s3c"},
+			want:   "done s3c",
+		},
+		{
+			name:   "Longest overlapping value wins",
+			values: []string{"p@ss", "postgres://root:p@ss@db"},
+			writes: []string{"connecting to postgres://root:p@ss@db with p@ss"},
+			want:   "connecting to *** with ***",
+		},
+		{
+			name:   "Short values are left alone",
+			values: []string{"1", "true", ""},
+			writes: []string{"retries=1 enabled=true"},
+			want:   "retries=1 enabled=***",
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewWriter(&out, ttp.values)
+
+			for _, write := range ttp.writes {
+				n, err := w.Write([]byte(write))
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, len(write), n, "Unexpected write length")
+			}
+
+			err := w.Flush()
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.want, out.String(), "Unexpected output")
+		})
+	}
+}
@@ -17,57 +17,177 @@ package aws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/secretsmanager"
-	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/spf13/cast"
 
 	"github.com/bank-vaults/secret-init/pkg/common"
 	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/retry"
+	"github.com/bank-vaults/secret-init/pkg/utils"
 )
 
 const (
 	ProviderType         = "aws"
 	referenceSelectorSM  = "arn:aws:secretsmanager:"
 	referenceSelectorSSM = "arn:aws:ssm:"
+	referenceSelectorS3  = "s3:"
+
+	// ssmBatchSize is the most parameters GetParameters reads at once
+	ssmBatchSize = 10
+
+	// stageOption selects a secrets manager version by staging label, e.g. AWSPREVIOUS during a rotation
+	stageOption  = "stage"
+	defaultStage = "AWSCURRENT"
 )
 
+// secretsManagerClient is the subset of the Secrets Manager client used by the provider.
+type secretsManagerClient interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// ssmClient is the subset of the SSM client used by the provider.
+type ssmClient interface {
+	GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+}
+
+// s3Client is the subset of the S3 client used by the provider.
+type s3Client interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
 type Provider struct {
-	sm  *secretsmanager.SecretsManager
-	ssm *ssm.SSM
+	sm          secretsManagerClient
+	ssm         ssmClient
+	s3          s3Client
+	retryPolicy retry.Policy
+	// maxSecretBytes limits the size of the objects read from S3, zero means unlimited
+	maxSecretBytes int64
+	// ignoreMissingSecrets skips missing secrets, parameters and objects instead of failing the load
+	ignoreMissingSecrets bool
 }
 
-func NewProvider(_ context.Context, _ *common.Config) (provider.Provider, error) {
-	config, err := LoadConfig()
+// ssmReference is a parameter to be read in a batch, name includes the version selector if any.
+type ssmReference struct {
+	key       string
+	name      string
+	reference string
+}
+
+func NewProvider(ctx context.Context, appConfig *common.Config) (provider.Provider, error) {
+	config, err := LoadConfig(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create vault config: %w", err)
+		return nil, fmt.Errorf("failed to create aws config: %w", err)
+	}
+
+	if appConfig.RequireAuth {
+		err = config.CheckAuth(ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &Provider{
-		sm:  secretsmanager.New(config.session),
-		ssm: ssm.New(config.session),
+		sm:  secretsmanager.NewFromConfig(config.awsConfig),
+		ssm: ssm.NewFromConfig(config.awsConfig),
+		s3:  s3.NewFromConfig(config.awsConfig),
+		retryPolicy: retry.Policy{
+			MaxAttempts: appConfig.RetryMaxAttempts,
+			BaseDelay:   appConfig.RetryBaseDelay,
+		},
+		maxSecretBytes:       int64(appConfig.MaxSecretBytes),
+		ignoreMissingSecrets: appConfig.IgnoreMissingSecrets,
 	}, nil
 }
 
+// GetProviderName returns the type of the AWS provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
 func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
 	var secrets []provider.Secret
+	var ssmReferences []ssmReference
+
+	// Explicitly referenced keys take precedence over expanded ones
+	referencedKeys := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		key, _, _ := strings.Cut(path, "=")
+		referencedKeys[key] = true
+	}
 
 	for _, path := range paths {
+		// Stop between the requests once the startup timeout has passed
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		split := strings.SplitN(path, "=", 2)
 		originalKey, secretID := split[0], split[1]
+		reference := secretID
+
+		// valid s3 object examples:
+		// s3:{BUCKET}/{KEY}, e.g. s3:my-secrets/service-account.json
+		if strings.HasPrefix(secretID, referenceSelectorS3) {
+			value, err := p.getObject(ctx, strings.TrimPrefix(secretID, referenceSelectorS3))
+			if err != nil {
+				if provider.SkipMissing(p.ignoreMissingSecrets, originalKey, err) {
+					continue
+				}
+
+				return nil, fmt.Errorf("failed to get object for %s: %w", originalKey, err)
+			}
+
+			secrets = append(secrets, provider.Secret{
+				Key:       originalKey,
+				Value:     string(value),
+				Provider:  ProviderType,
+				Reference: reference,
+			})
+
+			continue
+		}
+
+		secretID, options, err := utils.ParseReferenceOptions(secretID)
+		if err != nil {
+			return nil, err
+		}
 
 		// valid secretsmanager secret examples:
 		// arn:aws:secretsmanager:region:account-id:secret:secret-name
 		// secretsmanager:secret-name
 		if strings.Contains(secretID, "secretsmanager:") {
-			secret, err := p.sm.GetSecretValueWithContext(
-				ctx,
-				&secretsmanager.GetSecretValueInput{
-					SecretId: aws.String(secretID),
-				})
+			input, err := secretValueInput(secretID, options)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reference for %s: %w", originalKey, err)
+			}
+
+			var secret *secretsmanager.GetSecretValueOutput
+			err = retry.Do(ctx, p.retryPolicy, func() error {
+				var err error
+				secret, err = p.sm.GetSecretValue(ctx, input)
+
+				return markRetryable(err)
+			})
 			if err != nil {
+				err = markNotFound(err)
+				if provider.SkipMissing(p.ignoreMissingSecrets, originalKey, err) {
+					continue
+				}
+
 				return nil, fmt.Errorf("failed to get secret from AWS secrets manager: %w", err)
 			}
 
@@ -76,14 +196,33 @@ func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.
 				return nil, fmt.Errorf("failed to extract secret value from AWS secrets manager: %w", err)
 			}
 
+			if cast.ToBool(options.Get("expand")) {
+				for _, expanded := range expandSecretValue(originalKey, secretBytes) {
+					if expanded.Key != originalKey && referencedKeys[expanded.Key] {
+						slog.Warn("expanded key is referenced explicitly, skipping it", slog.String("key", expanded.Key))
+						continue
+					}
+
+					expanded.CreatedAt = aws.ToTime(secret.CreatedDate)
+					expanded.Provider = ProviderType
+					expanded.Reference = reference
+					secrets = append(secrets, expanded)
+				}
+
+				continue
+			}
+
 			secretValue, err := parseSecretValueFromSM(secretBytes)
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse secret value from AWS secrets manager: %w", err)
 			}
 
 			secrets = append(secrets, provider.Secret{
-				Key:   originalKey,
-				Value: string(secretValue),
+				Key:       originalKey,
+				Value:     string(secretValue),
+				CreatedAt: aws.ToTime(secret.CreatedDate),
+				Provider:  ProviderType,
+				Reference: reference,
 			})
 		}
 
@@ -91,23 +230,33 @@ func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.
 		// arn:aws:ssm:region:account-id:parameter/path/to/parameter-name
 		// arn:aws:ssm:us-west-2:123456789012:parameter/my-parameter
 		if strings.Contains(secretID, "ssm:") {
-			parameteredSecret, err := p.ssm.GetParameterWithContext(
-				ctx,
-				&ssm.GetParameterInput{
-					Name:           aws.String(secretID),
-					WithDecryption: aws.Bool(true),
-				})
-			if err != nil {
-				return nil, fmt.Errorf("failed to get secret from AWS SSM: %w", err)
+			if options.Has(stageOption) {
+				return nil, fmt.Errorf("invalid reference for %s: stage is only supported by secrets manager", originalKey)
 			}
 
-			secrets = append(secrets, provider.Secret{
-				Key:   originalKey,
-				Value: aws.StringValue(parameteredSecret.Parameter.Value),
-			})
+			// Parameters are pinned to a version number with a name:version suffix
+			name := secretID
+			if options.Has(utils.VersionOption) {
+				version, err := utils.ParseVersion(options.Get(utils.VersionOption))
+				if err != nil {
+					return nil, fmt.Errorf("invalid reference for %s: %w", originalKey, err)
+				}
+
+				if version != "" {
+					name += ":" + version
+				}
+			}
+
+			ssmReferences = append(ssmReferences, ssmReference{key: originalKey, name: name, reference: reference})
 		}
 	}
 
+	parameters, err := p.getParameters(ctx, ssmReferences)
+	if err != nil {
+		return nil, err
+	}
+	secrets = append(secrets, parameters...)
+
 	return secrets, nil
 }
 
@@ -115,7 +264,230 @@ func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.
 // arn:aws:secretsmanager:us-west-2:123456789012:secret:my-secret
 // arn:aws:ssm:us-west-2:123456789012:parameter/my-parameter
 func Valid(envValue string) bool {
-	return strings.HasPrefix(envValue, referenceSelectorSM) || strings.HasPrefix(envValue, referenceSelectorSSM)
+	return strings.HasPrefix(envValue, referenceSelectorSM) ||
+		strings.HasPrefix(envValue, referenceSelectorSSM) ||
+		strings.HasPrefix(envValue, referenceSelectorS3)
+}
+
+// getParameters reads the parameters in batches, SSM throttles reading them one by one already at a few dozen.
+// Every change of a parameter creates a new version, so the last modification is the creation of the version.
+func (p *Provider) getParameters(ctx context.Context, references []ssmReference) ([]provider.Secret, error) {
+	names := make([]string, 0, len(references))
+	for _, reference := range references {
+		if !slices.Contains(names, reference.name) {
+			names = append(names, reference.name)
+		}
+	}
+
+	// Parameters are returned under their name and a separate selector, even if they were requested by ARN
+	parameters := make(map[string]ssmtypes.Parameter, len(names))
+	invalidNames := make(map[string]bool)
+	for batch := range slices.Chunk(names, ssmBatchSize) {
+		// Stop between the requests once the startup timeout has passed
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var output *ssm.GetParametersOutput
+		err := retry.Do(ctx, p.retryPolicy, func() error {
+			var err error
+			output, err = p.ssm.GetParameters(
+				ctx,
+				&ssm.GetParametersInput{
+					Names:          batch,
+					WithDecryption: aws.Bool(true),
+				})
+
+			return markRetryable(err)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secrets from AWS SSM: %w", err)
+		}
+
+		for _, parameter := range output.Parameters {
+			selector := aws.ToString(parameter.Selector)
+			parameters[aws.ToString(parameter.Name)+selector] = parameter
+			parameters[aws.ToString(parameter.ARN)+selector] = parameter
+		}
+
+		for _, name := range output.InvalidParameters {
+			invalidNames[name] = true
+		}
+	}
+
+	secrets := make([]provider.Secret, 0, len(references))
+	for _, reference := range references {
+		parameter, ok := parameters[reference.name]
+		if !ok {
+			err := fmt.Errorf("parameter %s is missing from the response", reference.name)
+			if invalidNames[reference.name] {
+				err = provider.NotFound(fmt.Errorf("parameter %s not found", reference.name))
+			}
+
+			if provider.SkipMissing(p.ignoreMissingSecrets, reference.key, err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get secret for %s from AWS SSM: %w", reference.key, err)
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:       reference.key,
+			Value:     aws.ToString(parameter.Value),
+			CreatedAt: aws.ToTime(parameter.LastModifiedDate),
+			Provider:  ProviderType,
+			Reference: reference.reference,
+		})
+	}
+
+	return secrets, nil
+}
+
+// getObject reads the body of an S3 object, referenced as {bucket}/{key}.
+func (p *Provider) getObject(ctx context.Context, object string) ([]byte, error) {
+	bucket, key, ok := strings.Cut(object, "/")
+	if !ok || bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid reference, expected s3:<bucket>/<key>")
+	}
+
+	var output *s3.GetObjectOutput
+	err := retry.Do(ctx, p.retryPolicy, func() error {
+		var err error
+		output, err = p.s3.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+
+		return markRetryable(err)
+	})
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+		var noSuchBucket *s3types.NoSuchBucket
+		if errors.As(err, &noSuchKey) || errors.As(err, &noSuchBucket) {
+			return nil, provider.NotFound(fmt.Errorf("object s3://%s/%s not found: %w", bucket, key, err))
+		}
+
+		return nil, fmt.Errorf("failed to get object s3://%s/%s: %w", bucket, key, err)
+	}
+	defer output.Body.Close()
+
+	value, err := utils.ReadLimited(output.Body, p.maxSecretBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object s3://%s/%s: %w", bucket, key, err)
+	}
+
+	return value, nil
+}
+
+// AWS reports throttling with a 400 status code,
+// so it needs to be flagged explicitly to be retried.
+func markRetryable(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if _, ok := awsretry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]; ok {
+			return retry.Retryable(err)
+		}
+	}
+
+	return err
+}
+
+// markNotFound flags the errors of missing secrets, so they can be skipped.
+// Missing parameters are reported in the response of the batch instead.
+func markNotFound(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ResourceNotFoundException":
+			return provider.NotFound(err)
+		}
+	}
+
+	return err
+}
+
+// expandSecretValue unpacks each top-level key of a JSON object into a separate secret,
+// named as the original key and the uppercased JSON key joined by an underscore.
+// E.g. MYAPP_DB={"user":"root"} becomes MYAPP_DB_USER=root.
+// String values are used as is, any other value is kept as raw JSON.
+// Values that are not JSON objects are returned as a single secret under the original key.
+func expandSecretValue(originalKey string, secretBytes []byte) []provider.Secret {
+	var secretValue map[string]json.RawMessage
+	if err := json.Unmarshal(secretBytes, &secretValue); err != nil {
+		slog.Warn("secret is not a JSON object, it can't be expanded", slog.String("key", originalKey))
+
+		return []provider.Secret{{Key: originalKey, Value: string(secretBytes)}}
+	}
+
+	secrets := make([]provider.Secret, 0, len(secretValue))
+	for key, rawValue := range secretValue {
+		value := string(rawValue)
+
+		var stringValue string
+		if err := json.Unmarshal(rawValue, &stringValue); err == nil {
+			value = stringValue
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:   originalKey + "_" + sanitizeKey(key),
+			Value: value,
+		})
+	}
+
+	return secrets
+}
+
+// sanitizeKey turns a JSON key into a valid environment variable name.
+func sanitizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, strings.ToUpper(key))
+}
+
+// secretValueInput builds the request of a secrets manager secret from the options of its reference.
+// A version is selected either by id (?version=<id>, pinned:<id> works as well) or by staging label (?stage=AWSPREVIOUS),
+// the current version is requested otherwise.
+func secretValueInput(secretID string, options url.Values) (*secretsmanager.GetSecretValueInput, error) {
+	if options.Has(stageOption) && options.Has(utils.VersionOption) {
+		return nil, errors.New("stage and version can't be combined")
+	}
+
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	}
+
+	if options.Has(utils.VersionOption) {
+		value := options.Get(utils.VersionOption)
+		if value == "" {
+			return nil, errors.New("missing version id")
+		}
+
+		// Secrets manager version ids are accepted as is, besides the common version syntax
+		version, err := utils.ParseVersion(value)
+		if err != nil {
+			version = value
+		}
+
+		if version != "" {
+			input.VersionId = aws.String(version)
+			return input, nil
+		}
+	}
+
+	stage := defaultStage
+	if options.Has(stageOption) {
+		stage = options.Get(stageOption)
+		if stage == "" {
+			return nil, errors.New("missing stage")
+		}
+	}
+	input.VersionStage = aws.String(stage)
+
+	return input, nil
 }
 
 // AWS Secrets Manager can store secrets in two formats:
@@ -127,7 +499,7 @@ func Valid(envValue string) bool {
 func extractSecretValueFromSM(secret *secretsmanager.GetSecretValueOutput) ([]byte, error) {
 	// Secret available as string
 	if secret.SecretString != nil {
-		return []byte(aws.StringValue(secret.SecretString)), nil
+		return []byte(aws.ToString(secret.SecretString)), nil
 	}
 
 	// Secret available as binary
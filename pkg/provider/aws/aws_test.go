@@ -0,0 +1,487 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/retry"
+)
+
+type fakeSecretsManagerClient struct {
+	secrets map[string]string
+	errs    []error
+	calls   int
+}
+
+func (c *fakeSecretsManagerClient) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	c.calls++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+
+		return nil, err
+	}
+
+	// Versions are served under {secret id}@{version id or staging label}, the current one under the secret id
+	secretID := aws.ToString(params.SecretId)
+	if params.VersionId != nil {
+		secretID += "@" + aws.ToString(params.VersionId)
+	} else if stage := aws.ToString(params.VersionStage); stage != "" && stage != "AWSCURRENT" {
+		secretID += "@" + stage
+	}
+
+	secret, ok := c.secrets[secretID]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "ResourceNotFoundException", Message: "secret not found"}
+	}
+
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(secret)}, nil
+}
+
+// fakeSSMClient serves parameters by ARN, versions under {ARN}:{version}.
+// Like SSM, it returns them under their name and selector, missing ones are reported as invalid.
+type fakeSSMClient struct {
+	parameters map[string]string
+	batches    [][]string
+}
+
+func (c *fakeSSMClient) GetParameters(_ context.Context, params *ssm.GetParametersInput, _ ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	c.batches = append(c.batches, params.Names)
+	if !aws.ToBool(params.WithDecryption) {
+		return nil, &smithy.GenericAPIError{Code: "ValidationException", Message: "parameters must be decrypted"}
+	}
+
+	output := &ssm.GetParametersOutput{}
+	for _, name := range params.Names {
+		value, ok := c.parameters[name]
+		if !ok {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+			continue
+		}
+
+		prefix, path, _ := strings.Cut(name, ":parameter")
+		path, version, versioned := strings.Cut(path, ":")
+
+		parameter := ssmtypes.Parameter{
+			ARN:   aws.String(prefix + ":parameter" + path),
+			Name:  aws.String(path),
+			Value: aws.String(value),
+		}
+		if versioned {
+			parameter.Selector = aws.String(":" + version)
+		}
+		output.Parameters = append(output.Parameters, parameter)
+	}
+
+	return output, nil
+}
+
+// fakeS3Client serves objects under {bucket}/{key}
+type fakeS3Client struct {
+	objects map[string]string
+}
+
+func (c *fakeS3Client) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	object, ok := c.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, &s3types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(object))}, nil
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("arn:aws:secretsmanager:eu-north-1:123456789:secret:test/mysql"))
+	assert.True(t, Valid("arn:aws:ssm:eu-north-1:123456789:parameter/test/mysql"))
+	assert.True(t, Valid("s3:my-secrets/service-account.json"))
+	assert.False(t, Valid("gcs:my-secrets/service-account.json"))
+}
+
+func TestLoadSecrets(t *testing.T) {
+	const (
+		smARN  = "arn:aws:secretsmanager:eu-north-1:123456789:secret:test/mysql"
+		ssmARN = "arn:aws:ssm:eu-north-1:123456789:parameter/test/mysql"
+	)
+
+	tests := []struct {
+		name        string
+		sm          *fakeSecretsManagerClient
+		ssm         *fakeSSMClient
+		paths       []string
+		wantSecrets []provider.Secret
+		wantCalls   int
+		err         error
+	}{
+		{
+			name: "Load secrets from secrets manager and SSM",
+			sm:   &fakeSecretsManagerClient{secrets: map[string]string{smARN: "s3cr3t"}},
+			ssm:  &fakeSSMClient{parameters: map[string]string{ssmARN: "p4r4m"}},
+			paths: []string{
+				"MYSQL_PASSWORD=" + smARN,
+				"MYSQL_PARAMETER=" + ssmARN,
+			},
+			wantSecrets: []provider.Secret{
+				{Key: "MYSQL_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: smARN},
+				{Key: "MYSQL_PARAMETER", Value: "p4r4m", Provider: ProviderType, Reference: ssmARN},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:  "Expand JSON secret",
+			sm:    &fakeSecretsManagerClient{secrets: map[string]string{smARN: `{"user":"root","password":"s3cr3t"}`}},
+			ssm:   &fakeSSMClient{},
+			paths: []string{"MYSQL=" + smARN + "?expand=true"},
+			wantSecrets: []provider.Secret{
+				{Key: "MYSQL_USER", Value: "root", Provider: ProviderType, Reference: smARN + "?expand=true"},
+				{Key: "MYSQL_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: smARN + "?expand=true"},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "Load pinned versions from secrets manager and SSM",
+			sm:   &fakeSecretsManagerClient{secrets: map[string]string{smARN: "s3cr3t", smARN + "@v1": "old-s3cr3t"}},
+			ssm:  &fakeSSMClient{parameters: map[string]string{ssmARN: "p4r4m", ssmARN + ":2": "old-p4r4m"}},
+			paths: []string{
+				"MYSQL_PASSWORD=" + smARN + "?version=pinned:v1",
+				"MYSQL_PARAMETER=" + ssmARN + "?version=pinned:2",
+				"MYSQL_LATEST_PASSWORD=" + smARN + "?version=latest",
+			},
+			wantSecrets: []provider.Secret{
+				{Key: "MYSQL_PASSWORD", Value: "old-s3cr3t", Provider: ProviderType, Reference: smARN + "?version=pinned:v1"},
+				{Key: "MYSQL_PARAMETER", Value: "old-p4r4m", Provider: ProviderType, Reference: ssmARN + "?version=pinned:2"},
+				{Key: "MYSQL_LATEST_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: smARN + "?version=latest"},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "Load versions by id and staging label from secrets manager",
+			sm: &fakeSecretsManagerClient{secrets: map[string]string{
+				smARN:                  "s3cr3t",
+				smARN + "@v1":          "old-s3cr3t",
+				smARN + "@AWSPREVIOUS": "previous-s3cr3t",
+			}},
+			ssm: &fakeSSMClient{},
+			paths: []string{
+				"MYSQL_PASSWORD=" + smARN + "?version=v1",
+				"MYSQL_PREVIOUS_PASSWORD=" + smARN + "?stage=AWSPREVIOUS",
+			},
+			wantSecrets: []provider.Secret{
+				{Key: "MYSQL_PASSWORD", Value: "old-s3cr3t", Provider: ProviderType, Reference: smARN + "?version=v1"},
+				{Key: "MYSQL_PREVIOUS_PASSWORD", Value: "previous-s3cr3t", Provider: ProviderType, Reference: smARN + "?stage=AWSPREVIOUS"},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:  "Invalid version option",
+			sm:    &fakeSecretsManagerClient{},
+			ssm:   &fakeSSMClient{},
+			paths: []string{"MYSQL_PARAMETER=" + ssmARN + "?version=v1"},
+			err:   fmt.Errorf("invalid reference for MYSQL_PARAMETER: invalid version \"v1\", expected latest or pinned:<id>"),
+		},
+		{
+			name:  "Stage option with SSM",
+			sm:    &fakeSecretsManagerClient{},
+			ssm:   &fakeSSMClient{},
+			paths: []string{"MYSQL_PARAMETER=" + ssmARN + "?stage=AWSPREVIOUS"},
+			err:   fmt.Errorf("invalid reference for MYSQL_PARAMETER: stage is only supported by secrets manager"),
+		},
+		{
+			name: "Throttled request is retried",
+			sm: &fakeSecretsManagerClient{
+				secrets: map[string]string{smARN: "s3cr3t"},
+				errs:    []error{&smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}},
+			},
+			ssm:   &fakeSSMClient{},
+			paths: []string{"MYSQL_PASSWORD=" + smARN},
+			wantSecrets: []provider.Secret{
+				{Key: "MYSQL_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: smARN},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:      "Missing secret is not retried",
+			sm:        &fakeSecretsManagerClient{},
+			ssm:       &fakeSSMClient{},
+			paths:     []string{"MYSQL_PASSWORD=" + smARN},
+			wantCalls: 1,
+			err:       fmt.Errorf("failed to get secret from AWS secrets manager: api error ResourceNotFoundException: secret not found"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			p := &Provider{
+				sm:          ttp.sm,
+				ssm:         ttp.ssm,
+				retryPolicy: retry.Policy{MaxAttempts: 2},
+			}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.ElementsMatch(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+			}
+			assert.Equal(t, ttp.wantCalls, ttp.sm.calls, "Unexpected number of secrets manager calls")
+		})
+	}
+}
+
+func TestLoadSecretsS3(t *testing.T) {
+	client := &fakeS3Client{objects: map[string]string{
+		"my-secrets/service-account.json": `{"type":"service_account"}`,
+	}}
+
+	tests := []struct {
+		name           string
+		maxSecretBytes int64
+		paths          []string
+		wantSecrets    []provider.Secret
+		err            error
+	}{
+		{
+			name:  "Load object",
+			paths: []string{"SERVICE_ACCOUNT=s3:my-secrets/service-account.json"},
+			wantSecrets: []provider.Secret{
+				{Key: "SERVICE_ACCOUNT", Value: `{"type":"service_account"}`, Provider: ProviderType, Reference: "s3:my-secrets/service-account.json"},
+			},
+		},
+		{
+			name:           "Fail on object over the size limit",
+			maxSecretBytes: 10,
+			paths:          []string{"SERVICE_ACCOUNT=s3:my-secrets/service-account.json"},
+			err:            fmt.Errorf("failed to get object for SERVICE_ACCOUNT: failed to read object s3://my-secrets/service-account.json: value exceeds the limit of 10 bytes set by SECRET_INIT_MAX_SECRET_BYTES"),
+		},
+		{
+			name:  "Fail on missing object",
+			paths: []string{"SERVICE_ACCOUNT=s3:my-secrets/missing.json"},
+			err:   fmt.Errorf("failed to get object for SERVICE_ACCOUNT: object s3://my-secrets/missing.json not found: NoSuchKey: The specified key does not exist."),
+		},
+		{
+			name:  "Fail on missing key",
+			paths: []string{"SERVICE_ACCOUNT=s3:my-secrets"},
+			err:   fmt.Errorf("failed to get object for SERVICE_ACCOUNT: invalid reference, expected s3:<bucket>/<key>"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			p := &Provider{s3: client, maxSecretBytes: ttp.maxSecretBytes}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestLoadSecretsIgnoreMissing(t *testing.T) {
+	const (
+		smARN  = "arn:aws:secretsmanager:eu-north-1:123456789:secret:test/mysql"
+		ssmARN = "arn:aws:ssm:eu-north-1:123456789:parameter/test/mysql"
+	)
+
+	p := &Provider{
+		sm:                   &fakeSecretsManagerClient{secrets: map[string]string{smARN: "s3cr3t"}},
+		ssm:                  &fakeSSMClient{parameters: map[string]string{ssmARN: "p4r4m"}},
+		s3:                   &fakeS3Client{objects: map[string]string{"my-secrets/service-account.json": "{}"}},
+		ignoreMissingSecrets: true,
+	}
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{
+		"MYSQL_PASSWORD=" + smARN,
+		"MISSING_PASSWORD=" + smARN + "-missing",
+		"MYSQL_PARAMETER=" + ssmARN,
+		"MISSING_PARAMETER=" + ssmARN + "-missing",
+		"SERVICE_ACCOUNT=s3:my-secrets/service-account.json",
+		"MISSING_SERVICE_ACCOUNT=s3:my-secrets/missing.json",
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.ElementsMatch(t, []provider.Secret{
+		{Key: "MYSQL_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: smARN},
+		{Key: "MYSQL_PARAMETER", Value: "p4r4m", Provider: ProviderType, Reference: ssmARN},
+		{Key: "SERVICE_ACCOUNT", Value: "{}", Provider: ProviderType, Reference: "s3:my-secrets/service-account.json"},
+	}, secrets, "Missing secrets should be skipped")
+
+	// Other errors still fail the load
+	p.sm = &fakeSecretsManagerClient{errs: []error{&smithy.GenericAPIError{Code: "AccessDeniedException", Message: "access denied"}}}
+	_, err = p.LoadSecrets(context.Background(), []string{"MYSQL_PASSWORD=" + smARN})
+	assert.EqualError(t, err, "failed to get secret from AWS secrets manager: api error AccessDeniedException: access denied", "Unexpected error message")
+}
+
+func TestLoadSecretsSSMBatches(t *testing.T) {
+	const ssmARN = "arn:aws:ssm:eu-north-1:123456789:parameter/test/"
+
+	client := &fakeSSMClient{parameters: make(map[string]string)}
+	paths := make([]string, 0, 26)
+	wantSecrets := make([]provider.Secret, 0, 26)
+	for i := range 25 {
+		arn := fmt.Sprintf("%sparam-%d", ssmARN, i)
+		client.parameters[arn] = fmt.Sprintf("value-%d", i)
+		paths = append(paths, fmt.Sprintf("PARAM_%d=%s", i, arn))
+		wantSecrets = append(wantSecrets, provider.Secret{Key: fmt.Sprintf("PARAM_%d", i), Value: fmt.Sprintf("value-%d", i), Provider: ProviderType, Reference: arn})
+	}
+
+	// A parameter referenced twice is only requested once
+	client.parameters[ssmARN+"param-0:2"] = "old-value-0"
+	paths = append(paths, "OLD_PARAM_0="+ssmARN+"param-0?version=pinned:2", "PARAM_0_AGAIN="+ssmARN+"param-0")
+	wantSecrets = append(wantSecrets,
+		provider.Secret{Key: "OLD_PARAM_0", Value: "old-value-0", Provider: ProviderType, Reference: ssmARN + "param-0?version=pinned:2"},
+		provider.Secret{Key: "PARAM_0_AGAIN", Value: "value-0", Provider: ProviderType, Reference: ssmARN + "param-0"},
+	)
+
+	p := &Provider{ssm: client}
+
+	secrets, err := p.LoadSecrets(context.Background(), paths)
+	assert.NoError(t, err, "Unexpected error")
+	assert.ElementsMatch(t, wantSecrets, secrets, "Unexpected secrets")
+
+	assert.Len(t, client.batches, 3, "Unexpected number of GetParameters calls")
+	for i, wantSize := range []int{10, 10, 6} {
+		assert.Len(t, client.batches[i], wantSize, "Unexpected size of batch %d", i)
+	}
+
+	// Missing parameters are reported by name
+	_, err = p.LoadSecrets(context.Background(), []string{"PARAM_0=" + ssmARN + "param-0", "MISSING=" + ssmARN + "missing"})
+	assert.EqualError(t, err, "failed to get secret for MISSING from AWS SSM: parameter "+ssmARN+"missing not found", "Unexpected error message")
+}
+
+func TestExpandSecretValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		secretBytes []byte
+		wantSecrets []provider.Secret
+	}{
+		{
+			name:        "Flat JSON",
+			secretBytes: []byte(`{"user":"root","password":"s3cr3t","port":5432,"tls-enabled":true}`),
+			wantSecrets: []provider.Secret{
+				{Key: "MYAPP_DB_USER", Value: "root"},
+				{Key: "MYAPP_DB_PASSWORD", Value: "s3cr3t"},
+				{Key: "MYAPP_DB_PORT", Value: "5432"},
+				{Key: "MYAPP_DB_TLS_ENABLED", Value: "true"},
+			},
+		},
+		{
+			name:        "Nested JSON",
+			secretBytes: []byte(`{"user":"root","options":{"sslmode":"require"}}`),
+			wantSecrets: []provider.Secret{
+				{Key: "MYAPP_DB_USER", Value: "root"},
+				{Key: "MYAPP_DB_OPTIONS", Value: `{"sslmode":"require"}`},
+			},
+		},
+		{
+			name:        "Non-JSON value",
+			secretBytes: []byte("s3cr3t"),
+			wantSecrets: []provider.Secret{
+				{Key: "MYAPP_DB", Value: "s3cr3t"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			secrets := expandSecretValue("MYAPP_DB", ttp.secretBytes)
+
+			assert.ElementsMatch(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestSecretValueInput(t *testing.T) {
+	const secretID = "arn:aws:secretsmanager:eu-north-1:123456789:secret:test/mysql"
+
+	tests := []struct {
+		name      string
+		options   url.Values
+		wantInput *secretsmanager.GetSecretValueInput
+		err       error
+	}{
+		{
+			name:      "Current version by default",
+			options:   url.Values{},
+			wantInput: &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID), VersionStage: aws.String("AWSCURRENT")},
+		},
+		{
+			name:      "Previous version by staging label",
+			options:   url.Values{"stage": {"AWSPREVIOUS"}},
+			wantInput: &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID), VersionStage: aws.String("AWSPREVIOUS")},
+		},
+		{
+			name:      "Version by id",
+			options:   url.Values{"version": {"a1b2c3"}},
+			wantInput: &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID), VersionId: aws.String("a1b2c3")},
+		},
+		{
+			name:      "Pinned version by id",
+			options:   url.Values{"version": {"pinned:a1b2c3"}},
+			wantInput: &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID), VersionId: aws.String("a1b2c3")},
+		},
+		{
+			name:      "Latest version",
+			options:   url.Values{"version": {"latest"}},
+			wantInput: &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID), VersionStage: aws.String("AWSCURRENT")},
+		},
+		{
+			name:    "Stage and version combined",
+			options: url.Values{"stage": {"AWSPREVIOUS"}, "version": {"a1b2c3"}},
+			err:     fmt.Errorf("stage and version can't be combined"),
+		},
+		{
+			name:    "Missing stage",
+			options: url.Values{"stage": {""}},
+			err:     fmt.Errorf("missing stage"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			input, err := secretValueInput(secretID, ttp.options)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantInput, input, "Unexpected input")
+		})
+	}
+}
@@ -15,11 +15,16 @@
 package aws
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/spf13/cast"
 )
 
@@ -27,35 +32,69 @@ const (
 	LoadFromSharedConfigEnv = "AWS_LOAD_FROM_SHARED_CONFIG"
 	DefaultRegionEnv        = "AWS_DEFAULT_REGION"
 	RegionEnv               = "AWS_REGION"
+
+	imdsRegionTimeout = 2 * time.Second
 )
 
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = []string{LoadFromSharedConfigEnv, DefaultRegionEnv, RegionEnv}
+
 type Config struct {
-	session *session.Session
+	awsConfig aws.Config
 }
 
-func LoadConfig() (*Config, error) {
-	// Loading session data from shared config is disabled by default and needs to be
+func LoadConfig(ctx context.Context) (*Config, error) {
+	var options []func(*config.LoadOptions) error
+
+	// Loading data from shared config is disabled by default and needs to be
 	// explicitly enabled via AWS_LOAD_FROM_SHARED_CONFIG
-	options := session.Options{
-		SharedConfigState: session.SharedConfigDisable,
+	if !cast.ToBool(os.Getenv(LoadFromSharedConfigEnv)) {
+		options = append(options,
+			config.WithSharedConfigFiles([]string{}),
+			config.WithSharedCredentialsFiles([]string{}),
+		)
 	}
 
-	// Override session options from env configs
-	if cast.ToBool(os.Getenv(LoadFromSharedConfigEnv)) {
-		options.SharedConfigState = session.SharedConfigEnable
+	if region := getRegionEnv(); region != nil {
+		options = append(options, config.WithRegion(*region))
 	}
 
-	if region := getRegionEnv(); region != nil {
-		options.Config = aws.Config{Region: region}
+	awsConfig, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	// Fall back to the instance metadata when neither the environment
+	// nor the shared config provide a region (e.g. on EC2 or EKS nodes)
+	if awsConfig.Region == "" {
+		region, err := getRegionFromIMDS(ctx, "")
+		if err != nil {
+			slog.Warn(fmt.Errorf("failed to get region from instance metadata: %w", err).Error())
+		} else {
+			awsConfig.Region = region
+		}
+	}
+
+	return &Config{awsConfig: awsConfig}, nil
+}
+
+// CheckAuth verifies that the credential chain resolves to credentials,
+// anonymous credentials are rejected as well.
+func (c *Config) CheckAuth(ctx context.Context) error {
+	if c.awsConfig.Credentials == nil {
+		return fmt.Errorf("aws: no credentials configured")
 	}
 
-	// Create session
-	sess, err := session.NewSessionWithOptions(options)
+	credentials, err := c.awsConfig.Credentials.Retrieve(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+		return fmt.Errorf("aws: no credentials configured: %w", err)
+	}
+
+	if credentials.AccessKeyID == "" {
+		return fmt.Errorf("aws: no credentials configured")
 	}
 
-	return &Config{session: sess}, nil
+	return nil
 }
 
 func getRegionEnv() *string {
@@ -73,3 +112,24 @@ func getRegionEnv() *string {
 	// determine the region from the shared config or environment variables.
 	return nil
 }
+
+// getRegionFromIMDS queries the EC2 instance metadata service for the region of the instance.
+// The lookup is bound to a short timeout, since IMDS is unreachable outside of AWS.
+// An empty endpoint uses the default IMDS endpoint.
+func getRegionFromIMDS(ctx context.Context, endpoint string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, imdsRegionTimeout)
+	defer cancel()
+
+	client := imds.New(imds.Options{
+		Endpoint:   endpoint,
+		HTTPClient: &http.Client{Timeout: imdsRegionTimeout},
+		Retryer:    aws.NopRetryer{},
+	})
+
+	output, err := client.GetRegion(ctx, &imds.GetRegionInput{})
+	if err != nil {
+		return "", err
+	}
+
+	return output.Region, nil
+}
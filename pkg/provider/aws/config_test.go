@@ -0,0 +1,122 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRegionFromIMDS(t *testing.T) {
+	tests := []struct {
+		name       string
+		document   string
+		status     int
+		wantRegion string
+		wantErr    bool
+	}{
+		{
+			name:       "Region found in instance identity document",
+			document:   `{"region":"eu-north-1","availabilityZone":"eu-north-1a"}`,
+			status:     http.StatusOK,
+			wantRegion: "eu-north-1",
+		},
+		{
+			name:    "Instance metadata unavailable",
+			status:  http.StatusNotFound,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/latest/api/token":
+					_, _ = w.Write([]byte("token"))
+				case "/latest/dynamic/instance-identity/document":
+					w.WriteHeader(ttp.status)
+					_, _ = w.Write([]byte(ttp.document))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			region, err := getRegionFromIMDS(context.Background(), server.URL)
+			if ttp.wantErr {
+				assert.Error(t, err, "Expected error")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantRegion, region, "Unexpected region")
+		})
+	}
+}
+
+func TestConfig_CheckAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Static credentials",
+			env: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "access-key",
+				"AWS_SECRET_ACCESS_KEY": "secret-key",
+			},
+		},
+		{
+			name:    "No credentials",
+			env:     map[string]string{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			// The instance metadata is not queried for credentials outside of AWS
+			os.Clearenv()
+			os.Setenv(RegionEnv, "eu-north-1")
+			os.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+			for envKey, envVal := range ttp.env {
+				os.Setenv(envKey, envVal)
+			}
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			config, err := LoadConfig(context.Background())
+			assert.NoError(t, err, "Unexpected error")
+
+			err = config.CheckAuth(context.Background())
+			if ttp.wantErr {
+				assert.ErrorContains(t, err, "aws: no credentials configured", "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+		})
+	}
+}
@@ -16,14 +16,20 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
 
 	"github.com/bank-vaults/secret-init/pkg/common"
 	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/retry"
+	"github.com/bank-vaults/secret-init/pkg/utils"
 )
 
 const (
@@ -31,66 +37,242 @@ const (
 	referenceSelector = "azure:keyvault:"
 )
 
+// secretClient is the part of the Key Vault client used by the provider.
+type secretClient interface {
+	GetSecret(ctx context.Context, name string, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error)
+}
+
 type Provider struct {
-	client *azsecrets.Client
+	defaultVaultURL string
+	newClient       func(vaultURL string) (secretClient, error)
+	// clients caches a client per vault, references may name their own vault
+	clients     map[string]secretClient
+	retryPolicy retry.Policy
+	// ignoreMissingSecrets skips missing secrets instead of failing the load
+	ignoreMissingSecrets bool
 }
 
-func NewProvider(_ context.Context, _ *common.Config) (provider.Provider, error) {
+func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider, error) {
 	config, err := LoadConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create vault config: %w", err)
 	}
 
-	creds, err := azidentity.NewDefaultAzureCredential(nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create default azure credentials: %v", err)
-	}
-
-	client, err := azsecrets.NewClient(config.keyvaultURL, creds, nil)
+	creds, err := newCredential(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create new keyvault client: %v", err)
+		return nil, fmt.Errorf("failed to create azure credentials: %w", err)
 	}
 
 	return &Provider{
-		client: client,
+		defaultVaultURL: config.keyvaultURL,
+		newClient: func(vaultURL string) (secretClient, error) {
+			return azsecrets.NewClient(vaultURL, creds, nil)
+		},
+		clients: make(map[string]secretClient),
+		retryPolicy: retry.Policy{
+			MaxAttempts: appConfig.RetryMaxAttempts,
+			BaseDelay:   appConfig.RetryBaseDelay,
+		},
+		ignoreMissingSecrets: appConfig.IgnoreMissingSecrets,
 	}, nil
 }
 
+// GetProviderName returns the type of the Azure Key Vault provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
 func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
+	references, err := p.parseReferences(paths)
+	if err != nil {
+		return nil, err
+	}
+
 	var secrets []provider.Secret
 
-	for _, path := range paths {
-		split := strings.SplitN(path, "=", 2)
-		originalKey, secretID := split[0], split[1]
-
-		// valid Azure Key Vault secret examples:
-		// azure:keyvault:{SECRET_NAME}
-		// azure:keyvault:{SECRET_NAME}/{VERSION}
-		version := ""
-		secretID = strings.TrimPrefix(secretID, "azure:keyvault:")
-		split = strings.Split(secretID, "/")
-		secretID = split[0]
-		if len(split) == 2 {
-			version = split[1]
+	for i, path := range paths {
+		// Stop between the requests once the startup timeout has passed
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		originalKey, secretRef, _ := strings.Cut(path, "=")
+		reference := references[i]
+
+		client, err := p.clientFor(reference.vaultURL)
+		if err != nil {
+			return nil, err
 		}
 
-		secret, err := p.client.GetSecret(ctx, secretID, version, nil)
+		var secret azsecrets.GetSecretResponse
+		err = retry.Do(ctx, p.retryPolicy, func() error {
+			var err error
+			secret, err = client.GetSecret(ctx, reference.secretID, reference.version, nil)
+
+			return markRetryable(err)
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get secret %s: %v", path, err)
+			err = markNotFound(err)
+			if provider.SkipMissing(p.ignoreMissingSecrets, originalKey, err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get secret %s: %w", path, err)
+		}
+
+		var createdAt time.Time
+		if secret.Attributes != nil && secret.Attributes.Created != nil {
+			createdAt = *secret.Attributes.Created
 		}
 
 		secrets = append(secrets, provider.Secret{
-			Key:   originalKey,
-			Value: *secret.Value,
+			Key:       originalKey,
+			Value:     *secret.Value,
+			CreatedAt: createdAt,
+			Provider:  ProviderType,
+			Reference: secretRef,
 		})
 	}
 
 	return secrets, nil
 }
 
+// secretReference is a parsed reference, an empty vaultURL selects the default vault
+type secretReference struct {
+	vaultURL string
+	secretID string
+	version  string
+}
+
+// parseReferences parses the references up front, so a missing default vault fails the load before any secret is fetched.
+func (p *Provider) parseReferences(paths []string) ([]secretReference, error) {
+	references := make([]secretReference, 0, len(paths))
+	var missingVault []string
+	for _, path := range paths {
+		originalKey, secretRef, _ := strings.Cut(path, "=")
+
+		vaultURL, secretID, version, err := parseReference(strings.TrimPrefix(secretRef, referenceSelector))
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for %s: %w", originalKey, err)
+		}
+
+		if vaultURL == "" && p.defaultVaultURL == "" {
+			missingVault = append(missingVault, originalKey)
+		}
+
+		references = append(references, secretReference{vaultURL: vaultURL, secretID: secretID, version: version})
+	}
+
+	if len(missingVault) > 0 {
+		return nil, fmt.Errorf("missing azure key vault URL environment variable %s, required by %s", azureKeyVaultURLEnv, strings.Join(missingVault, ", "))
+	}
+
+	return references, nil
+}
+
+// parseReference splits a reference into its vault, secret name and version.
+// The version is either the last segment of the path or set by the version option,
+// e.g. {SECRET_NAME}?version=pinned:{VERSION}.
+func parseReference(reference string) (string, string, string, error) {
+	reference, pinnedVersion, versioned, err := utils.SplitVersion(reference)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	vaultURL, secretID, version, err := parseSecretPath(reference)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if versioned {
+		if version != "" {
+			return "", "", "", fmt.Errorf("version is set both in the reference and by the %s option", utils.VersionOption)
+		}
+
+		// An empty version reads the latest one
+		version = pinnedVersion
+	}
+
+	return vaultURL, secretID, version, nil
+}
+
+// parseSecretPath splits the path of a reference into its vault, secret name and version.
+// The vault is empty for references using the default vault.
+// Valid Azure Key Vault secret examples:
+// {SECRET_NAME}
+// {SECRET_NAME}/{VERSION}
+// https://{VAULT_NAME}.vault.azure.net/secrets/{SECRET_NAME}
+// https://{VAULT_NAME}.vault.azure.net/secrets/{SECRET_NAME}/{VERSION}
+func parseSecretPath(reference string) (string, string, string, error) {
+	if !strings.HasPrefix(reference, "https://") {
+		secretID, version, _ := strings.Cut(reference, "/")
+
+		return "", secretID, version, nil
+	}
+
+	secretURL, err := url.Parse(reference)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid secret URL: %w", err)
+	}
+
+	parts := strings.Split(strings.Trim(secretURL.Path, "/"), "/")
+	if secretURL.Host == "" || parts[0] != "secrets" || len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		return "", "", "", fmt.Errorf("invalid secret URL %s: expected https://{vault}/secrets/{name}[/{version}]", reference)
+	}
+
+	version := ""
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+
+	return "https://" + secretURL.Host, parts[1], version, nil
+}
+
+// clientFor returns the client of the vault, creating it on first use.
+// An empty vault URL selects the vault configured by AZURE_KEY_VAULT_URL.
+func (p *Provider) clientFor(vaultURL string) (secretClient, error) {
+	if vaultURL == "" {
+		vaultURL = p.defaultVaultURL
+	}
+
+	if client, ok := p.clients[vaultURL]; ok {
+		return client, nil
+	}
+
+	client, err := p.newClient(vaultURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new keyvault client: %v", err)
+	}
+
+	p.clients[vaultURL] = client
+
+	return client, nil
+}
+
 // Example Azure Key Vault secret examples:
 // azure:keyvault:{SECRET_NAME}
 // azure:keyvault:{SECRET_NAME}/{VERSION}
+// azure:keyvault:https://{VAULT_NAME}.vault.azure.net/secrets/{SECRET_NAME}/{VERSION}
 func Valid(envValue string) bool {
 	return strings.HasPrefix(envValue, referenceSelector)
 }
+
+// markRetryable flags responses with a transient status code, so they can be retried.
+func markRetryable(err error) error {
+	var responseErr *azcore.ResponseError
+	if errors.As(err, &responseErr) && retry.IsRetryableStatus(responseErr.StatusCode) {
+		return retry.Retryable(err)
+	}
+
+	return err
+}
+
+// markNotFound flags the errors of missing secrets and versions, so they can be skipped.
+func markNotFound(err error) error {
+	var responseErr *azcore.ResponseError
+	if errors.As(err, &responseErr) && responseErr.StatusCode == http.StatusNotFound {
+		return provider.NotFound(err)
+	}
+
+	return err
+}
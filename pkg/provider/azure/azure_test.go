@@ -0,0 +1,194 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// fakeSecretClient serves the secrets of a single vault by name and version.
+type fakeSecretClient struct {
+	secrets map[string]string
+}
+
+func (c *fakeSecretClient) GetSecret(_ context.Context, name string, version string, _ *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error) {
+	value, ok := c.secrets[name+"/"+version]
+	if !ok {
+		return azsecrets.GetSecretResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: "SecretNotFound"}
+	}
+
+	return azsecrets.GetSecretResponse{Secret: azsecrets.Secret{Value: &value}}, nil
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name         string
+		reference    string
+		wantVaultURL string
+		wantSecretID string
+		wantVersion  string
+		err          error
+	}{
+		{
+			name:         "Short form",
+			reference:    "mysql",
+			wantSecretID: "mysql",
+		},
+		{
+			name:         "Short form with version",
+			reference:    "mysql/v1",
+			wantSecretID: "mysql",
+			wantVersion:  "v1",
+		},
+		{
+			name:         "Full vault URL",
+			reference:    "https://other.vault.azure.net/secrets/mysql",
+			wantVaultURL: "https://other.vault.azure.net",
+			wantSecretID: "mysql",
+		},
+		{
+			name:         "Full vault URL with version",
+			reference:    "https://other.vault.azure.net/secrets/mysql/v1",
+			wantVaultURL: "https://other.vault.azure.net",
+			wantSecretID: "mysql",
+			wantVersion:  "v1",
+		},
+		{
+			name:         "Pinned version option",
+			reference:    "mysql?version=pinned:v1",
+			wantSecretID: "mysql",
+			wantVersion:  "v1",
+		},
+		{
+			name:         "Pinned version option with full vault URL",
+			reference:    "https://other.vault.azure.net/secrets/mysql?version=pinned:v1",
+			wantVaultURL: "https://other.vault.azure.net",
+			wantSecretID: "mysql",
+			wantVersion:  "v1",
+		},
+		{
+			name:         "Latest version option",
+			reference:    "mysql?version=latest",
+			wantSecretID: "mysql",
+		},
+		{
+			name:      "Version set twice",
+			reference: "mysql/v1?version=pinned:v2",
+			err:       fmt.Errorf("version is set both in the reference and by the version option"),
+		},
+		{
+			name:      "Invalid vault URL",
+			reference: "https://other.vault.azure.net/keys/mysql",
+			err:       fmt.Errorf("invalid secret URL https://other.vault.azure.net/keys/mysql: expected https://{vault}/secrets/{name}[/{version}]"),
+		},
+		{
+			name:      "Unparsable vault URL",
+			reference: "https://other vault/secrets/mysql",
+			err:       fmt.Errorf("invalid secret URL: parse \"https://other vault/secrets/mysql\": invalid character \" \" in host name"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			vaultURL, secretID, version, err := parseReference(ttp.reference)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantVaultURL, vaultURL, "Unexpected vault URL")
+			assert.Equal(t, ttp.wantSecretID, secretID, "Unexpected secret ID")
+			assert.Equal(t, ttp.wantVersion, version, "Unexpected version")
+		})
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	vaults := map[string]*fakeSecretClient{
+		"https://default.vault.azure.net": {secrets: map[string]string{"mysql/": "default-password"}},
+		"https://other.vault.azure.net":   {secrets: map[string]string{"mysql/v1": "other-password"}},
+	}
+
+	var createdClients []string
+	p := &Provider{
+		defaultVaultURL: "https://default.vault.azure.net",
+		newClient: func(vaultURL string) (secretClient, error) {
+			createdClients = append(createdClients, vaultURL)
+			return vaults[vaultURL], nil
+		},
+		clients: make(map[string]secretClient),
+	}
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{
+		"DEFAULT_PASSWORD=azure:keyvault:mysql",
+		"OTHER_PASSWORD=azure:keyvault:https://other.vault.azure.net/secrets/mysql/v1",
+		"OTHER_PASSWORD_AGAIN=azure:keyvault:https://other.vault.azure.net/secrets/mysql/v1",
+	})
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{
+		{Key: "DEFAULT_PASSWORD", Value: "default-password", Provider: ProviderType, Reference: "azure:keyvault:mysql"},
+		{Key: "OTHER_PASSWORD", Value: "other-password", Provider: ProviderType, Reference: "azure:keyvault:https://other.vault.azure.net/secrets/mysql/v1"},
+		{Key: "OTHER_PASSWORD_AGAIN", Value: "other-password", Provider: ProviderType, Reference: "azure:keyvault:https://other.vault.azure.net/secrets/mysql/v1"},
+	}, secrets, "Unexpected secrets")
+	assert.Equal(t, []string{"https://default.vault.azure.net", "https://other.vault.azure.net"}, createdClients, "Clients should be created once per vault")
+
+	// Short form references need the default vault, nothing is fetched without it
+	p.defaultVaultURL = ""
+	createdClients = nil
+	_, err = p.LoadSecrets(context.Background(), []string{
+		"NEW_PASSWORD=azure:keyvault:mysql",
+		"OTHER_PASSWORD=azure:keyvault:https://other.vault.azure.net/secrets/mysql/v1",
+		"OLD_PASSWORD=azure:keyvault:mysql/v1",
+	})
+	assert.EqualError(t, err, "missing azure key vault URL environment variable AZURE_KEY_VAULT_URL, required by NEW_PASSWORD, OLD_PASSWORD", "Unexpected error message")
+	assert.Empty(t, createdClients, "No client should be created without the default vault")
+}
+
+func TestLoadSecretsIgnoreMissing(t *testing.T) {
+	p := &Provider{
+		defaultVaultURL: "https://default.vault.azure.net",
+		newClient: func(string) (secretClient, error) {
+			return &fakeSecretClient{secrets: map[string]string{"mysql/": "default-password"}}, nil
+		},
+		clients:              make(map[string]secretClient),
+		ignoreMissingSecrets: true,
+	}
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{
+		"DEFAULT_PASSWORD=azure:keyvault:mysql",
+		"MISSING_PASSWORD=azure:keyvault:missing",
+		"MISSING_VERSION_PASSWORD=azure:keyvault:mysql/v2",
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{
+		{Key: "DEFAULT_PASSWORD", Value: "default-password", Provider: ProviderType, Reference: "azure:keyvault:mysql"},
+	}, secrets, "Missing secrets should be skipped")
+
+	// Missing secrets fail the load unless they are ignored
+	p.ignoreMissingSecrets = false
+	_, err = p.LoadSecrets(context.Background(), []string{"MISSING_PASSWORD=azure:keyvault:missing"})
+	assert.ErrorIs(t, err, provider.ErrSecretNotFound, "Unexpected error")
+}
@@ -16,20 +16,87 @@ package azure
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+const (
+	azureKeyVaultURLEnv = "AZURE_KEY_VAULT_URL"
+
+	// The identity settings are the ones of the Azure SDK, the workload identity webhook sets them as well
+	azureClientIDEnv           = "AZURE_CLIENT_ID"
+	azureTenantIDEnv           = "AZURE_TENANT_ID"
+	azureFederatedTokenFileEnv = "AZURE_FEDERATED_TOKEN_FILE"
+	azureClientSecretEnv       = "AZURE_CLIENT_SECRET"
+	azureClientCertificateEnv  = "AZURE_CLIENT_CERTIFICATE_PATH"
 )
 
-const azureKeyVaultURLEnv = "AZURE_KEY_VAULT_URL"
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = []string{
+	azureKeyVaultURLEnv,
+	azureClientIDEnv,
+	azureTenantIDEnv,
+	azureFederatedTokenFileEnv,
+	azureClientSecretEnv,
+	azureClientCertificateEnv,
+}
 
 type Config struct {
+	// keyvaultURL is the default vault of references without their own, it is optional
+	// when all references name their vault
 	keyvaultURL string
+	// clientID selects the user-assigned managed identity (or the app of the workload identity),
+	// without it the default identity of the node is used, which is ambiguous with multiple identities
+	clientID string
+	tenantID string
+	// federatedTokenFile is the token of the workload identity, the managed identity isn't used then
+	federatedTokenFile string
+	// servicePrincipal is set when client credentials are configured, the default credential picks them up
+	servicePrincipal bool
 }
 
+// LoadConfig loads the config of the provider from the environment, an invalid vault URL is rejected.
+// A missing vault URL is not, references naming their vault (e.g. azure:keyvault:https://{vault}/secrets/{name})
+// don't need one, so the provider rejects the references relying on the default vault before fetching anything instead.
 func LoadConfig() (*Config, error) {
-	azureKeyVaultURL, ok := os.LookupEnv(azureKeyVaultURLEnv)
-	if !ok {
-		return nil, fmt.Errorf("missing azure key vault URL environment variable %s", azureKeyVaultURLEnv)
+	keyvaultURL := strings.TrimSuffix(os.Getenv(azureKeyVaultURLEnv), "/")
+	if keyvaultURL != "" {
+		parsedURL, err := url.Parse(keyvaultURL)
+		if err != nil || parsedURL.Scheme != "https" || parsedURL.Host == "" || parsedURL.Path != "" {
+			return nil, fmt.Errorf("invalid %s %s: expected https://{vault}", azureKeyVaultURLEnv, keyvaultURL)
+		}
 	}
 
-	return &Config{keyvaultURL: azureKeyVaultURL}, nil
+	return &Config{
+		keyvaultURL:        keyvaultURL,
+		clientID:           os.Getenv(azureClientIDEnv),
+		tenantID:           os.Getenv(azureTenantIDEnv),
+		federatedTokenFile: os.Getenv(azureFederatedTokenFileEnv),
+		servicePrincipal:   os.Getenv(azureClientSecretEnv) != "" || os.Getenv(azureClientCertificateEnv) != "",
+	}, nil
+}
+
+// newCredential builds the credential of the configured identity: the workload identity,
+// the managed identity with the client ID or the default credential chain otherwise.
+func newCredential(config *Config) (azcore.TokenCredential, error) {
+	switch {
+	case config.servicePrincipal:
+		return azidentity.NewDefaultAzureCredential(nil)
+	case config.federatedTokenFile != "":
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientID:      config.clientID,
+			TenantID:      config.tenantID,
+			TokenFilePath: config.federatedTokenFile,
+		})
+	case config.clientID != "":
+		return azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+			ID: azidentity.ClientID(config.clientID),
+		})
+	default:
+		return azidentity.NewDefaultAzureCredential(nil)
+	}
 }
@@ -0,0 +1,142 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		wantConfig     *Config
+		wantCredential azcore.TokenCredential
+		err            error
+	}{
+		{
+			name: "Default identity",
+			env: map[string]string{
+				azureKeyVaultURLEnv: "https://myvault.vault.azure.net/",
+			},
+			wantConfig: &Config{
+				keyvaultURL: "https://myvault.vault.azure.net",
+			},
+			wantCredential: &azidentity.DefaultAzureCredential{},
+		},
+		{
+			name: "Managed identity selected by client ID",
+			env: map[string]string{
+				azureKeyVaultURLEnv: "https://myvault.vault.azure.net",
+				azureClientIDEnv:    "00000000-0000-0000-0000-000000000001",
+			},
+			wantConfig: &Config{
+				keyvaultURL: "https://myvault.vault.azure.net",
+				clientID:    "00000000-0000-0000-0000-000000000001",
+			},
+			wantCredential: &azidentity.ManagedIdentityCredential{},
+		},
+		{
+			name: "Workload identity",
+			env: map[string]string{
+				azureClientIDEnv:           "00000000-0000-0000-0000-000000000001",
+				azureTenantIDEnv:           "00000000-0000-0000-0000-000000000002",
+				azureFederatedTokenFileEnv: "/var/run/secrets/azure/tokens/azure-identity-token",
+			},
+			wantConfig: &Config{
+				clientID:           "00000000-0000-0000-0000-000000000001",
+				tenantID:           "00000000-0000-0000-0000-000000000002",
+				federatedTokenFile: "/var/run/secrets/azure/tokens/azure-identity-token",
+			},
+			wantCredential: &azidentity.WorkloadIdentityCredential{},
+		},
+		{
+			name: "Service principal",
+			env: map[string]string{
+				azureClientIDEnv:     "00000000-0000-0000-0000-000000000001",
+				azureTenantIDEnv:     "00000000-0000-0000-0000-000000000002",
+				azureClientSecretEnv: "s3cr3t",
+			},
+			wantConfig: &Config{
+				clientID:         "00000000-0000-0000-0000-000000000001",
+				tenantID:         "00000000-0000-0000-0000-000000000002",
+				servicePrincipal: true,
+			},
+			wantCredential: &azidentity.DefaultAzureCredential{},
+		},
+		{
+			name: "Missing vault URL",
+			env: map[string]string{
+				azureClientIDEnv: "00000000-0000-0000-0000-000000000001",
+			},
+			wantConfig: &Config{
+				clientID: "00000000-0000-0000-0000-000000000001",
+			},
+			wantCredential: &azidentity.ManagedIdentityCredential{},
+		},
+		{
+			name: "Invalid configuration - plain http vault URL",
+			env: map[string]string{
+				azureKeyVaultURLEnv: "http://myvault.vault.azure.net",
+			},
+			err: fmt.Errorf("invalid AZURE_KEY_VAULT_URL http://myvault.vault.azure.net: expected https://{vault}"),
+		},
+		{
+			name: "Invalid configuration - vault URL with a path",
+			env: map[string]string{
+				azureKeyVaultURLEnv: "https://myvault.vault.azure.net/secrets/db-password",
+			},
+			err: fmt.Errorf("invalid AZURE_KEY_VAULT_URL https://myvault.vault.azure.net/secrets/db-password: expected https://{vault}"),
+		},
+		{
+			name: "Invalid configuration - vault name instead of URL",
+			env: map[string]string{
+				azureKeyVaultURLEnv: "myvault",
+			},
+			err: fmt.Errorf("invalid AZURE_KEY_VAULT_URL myvault: expected https://{vault}"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			for envKey, envVal := range ttp.env {
+				os.Setenv(envKey, envVal)
+			}
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			config, err := LoadConfig()
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantConfig, config, "Unexpected config")
+
+			credential, err := newCredential(config)
+			assert.Nil(t, err, "Unexpected error")
+			assert.IsType(t, ttp.wantCredential, credential, "Unexpected credential")
+		})
+	}
+}
@@ -16,18 +16,21 @@ package bao
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"os/signal"
+	"maps"
 	"regexp"
 	"strings"
 
 	injector "github.com/bank-vaults/vault-sdk/injector/bao"
 	bao "github.com/bank-vaults/vault-sdk/vault"
+	baoapi "github.com/hashicorp/vault/api"
 
 	"github.com/bank-vaults/secret-init/pkg/common"
 	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/provider/renewer"
+	"github.com/bank-vaults/secret-init/pkg/retry"
 	"github.com/bank-vaults/secret-init/pkg/utils"
 )
 
@@ -36,6 +39,9 @@ const (
 	referenceSelector = `(bao:)(.*)#(.*)`
 )
 
+// The env store swaps bao and vault providers (see its OpenBao workaround), both must satisfy the same interface
+var _ provider.Provider = (*Provider)(nil)
+
 type Provider struct {
 	isLogin        bool
 	client         *bao.Client
@@ -43,6 +49,11 @@ type Provider struct {
 	secretRenewer  injector.SecretRenewer
 	fromPath       string
 	revokeToken    bool
+	retryPolicy    retry.Policy
+	// stopRefresh stops the token refresher running in daemon mode
+	stopRefresh context.CancelFunc
+	// revoked is set by the first load revoking the token, the provider is not reused after it
+	revoked bool
 }
 
 type sanitized struct {
@@ -67,12 +78,19 @@ func (s *sanitized) append(key string, value string) {
 	}
 }
 
-func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider, error) {
+func NewProvider(ctx context.Context, appConfig *common.Config) (provider.Provider, error) {
 	config, err := LoadConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create vault config: %w", err)
 	}
 
+	if appConfig.RequireAuth {
+		err = config.CheckAuth()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	clientOptions := []bao.ClientOption{bao.ClientLogger(clientLogger{slog.Default()})}
 	if config.TokenFile != "" {
 		clientOptions = append(clientOptions, bao.ClientToken(config.Token))
@@ -101,13 +119,29 @@ func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider
 	var secretRenewer injector.SecretRenewer
 
 	if appConfig.Daemon {
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs)
-
-		secretRenewer = daemonSecretRenewer{client: client, sigs: sigs}
+		secretRenewer = renewer.Daemon{
+			Client:      client,
+			Signal:      provider.SignalFunc(ctx),
+			StopSignal:  appConfig.StopSignal,
+			StopTimeout: appConfig.RenewalKillTimeout,
+			RenewSignal: appConfig.RenewSignal,
+		}
 		slog.Info("Daemon mode enabled. Will renew secrets in the background.")
 	}
 
+	// The refresher is stopped along with the client once the provider is closed
+	refreshCtx, stopRefresh := context.WithCancel(provider.Lifetime(ctx))
+
+	// Long running processes would outlive the token read from the file at startup
+	if appConfig.Daemon && config.TokenFile != "" {
+		refresher := tokenRefresher{
+			client:    client.RawClient(),
+			tokenFile: config.TokenFile,
+			threshold: tokenRefreshThreshold,
+		}
+		go refresher.Run(refreshCtx, tokenRefreshInterval)
+	}
+
 	return &Provider{
 		isLogin:        config.IsLogin,
 		client:         client,
@@ -115,9 +149,19 @@ func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider
 		secretRenewer:  secretRenewer,
 		fromPath:       config.FromPath,
 		revokeToken:    config.RevokeToken,
+		retryPolicy: retry.Policy{
+			MaxAttempts: appConfig.RetryMaxAttempts,
+			BaseDelay:   appConfig.RetryBaseDelay,
+		},
+		stopRefresh: stopRefresh,
 	}, nil
 }
 
+// GetProviderName returns the type of the OpenBao provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
 // LoadSecret's path formatting: <key>=<path>
 // This formatting is necessary because the injector expects a map of key=value pairs.
 // It also returns a map of key:value pairs, where the key is the environment variable name
@@ -125,8 +169,25 @@ func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider
 // E.g. paths: MYSQL_PASSWORD=secret/data/mysql/password
 // returns: []provider.Secret{provider.Secret{Path: "MYSQL_PASSWORD", Value: "password"}}
 func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
+	baoEnviron := parsePathsToMap(paths)
+	references := maps.Clone(baoEnviron)
+	for key, reference := range baoEnviron {
+		reference, err := translateVersion(reference)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for %s: %w", key, err)
+		}
+
+		baoEnviron[key] = reference
+	}
+
 	sanitized := sanitized{login: p.isLogin}
-	secretInjector := injector.NewSecretInjector(p.injectorConfig, p.client, p.secretRenewer, slog.Default())
+	// Leases are renewed until the load acquiring them has been replaced
+	secretRenewer := p.secretRenewer
+	if daemon, ok := secretRenewer.(renewer.Daemon); ok {
+		secretRenewer = daemon.ForLoad(ctx)
+	}
+
+	secretInjector := injector.NewSecretInjector(p.injectorConfig, p.client, secretRenewer, slog.Default())
 	inject := func(key, value string) {
 		// Check for key duplication
 		if utils.IsKeyDuplicated(&sanitized.secrets, key) {
@@ -137,15 +198,35 @@ func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.
 		sanitized.append(key, value)
 	}
 
-	err := secretInjector.InjectSecretsFromBao(parsePathsToMap(paths), inject)
+	// The reads are injected at once (e.g. transit references are decrypted in batches) and retried as a batch,
+	// the injector caches the secrets it read, so a retry doesn't read them again nor lease new secrets.
+	// Writes are never retried, a failed one may have been applied by Bao already.
+	reads, writes := splitWrites(baoEnviron)
+
+	err := p.injectWithRetry(ctx, &sanitized, false, func() error {
+		return secretInjector.InjectSecretsFromBao(reads, inject)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to inject secrets from bao: %w", err)
 	}
 
-	if p.fromPath != "" {
-		err = secretInjector.InjectSecretsFromBaoPath(p.fromPath, inject)
+	if len(writes) > 0 {
+		err = p.injectWithRetry(ctx, &sanitized, true, func() error {
+			return secretInjector.InjectSecretsFromBao(writes, inject)
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to inject secrets from bao path: %w", err)
+			return nil, fmt.Errorf("failed to inject secrets from bao: %w", err)
+		}
+	}
+
+	if p.fromPath != "" {
+		for _, path := range strings.Split(p.fromPath, ",") {
+			err := p.injectWithRetry(ctx, &sanitized, false, func() error {
+				return secretInjector.InjectSecretsFromBaoPath(path, inject)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to inject secrets from bao path: %w", err)
+			}
 		}
 	}
 
@@ -157,12 +238,96 @@ func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.
 			slog.Warn("failed to revoke token")
 		}
 
-		p.client.Close()
+		_ = p.Close()
+		p.revoked = true
+	}
+
+	// Secrets injected from BAO_FROM_PATH have no reference of their own
+	for i, secret := range sanitized.secrets {
+		sanitized.secrets[i].Provider = ProviderType
+		sanitized.secrets[i].Reference = references[secret.Key]
 	}
 
 	return sanitized.secrets, nil
 }
 
+// injectWithRetry runs an injection, retrying transient failures unless it writes to Bao.
+// Secrets injected by a failed attempt are discarded before retrying.
+func (p *Provider) injectWithRetry(ctx context.Context, sanitized *sanitized, write bool, inject func() error) error {
+	policy := p.retryPolicy
+	if write {
+		policy.MaxAttempts = 1
+	}
+
+	injected := len(sanitized.secrets)
+
+	return retry.Do(ctx, policy, func() error {
+		sanitized.secrets = sanitized.secrets[:injected]
+
+		return markRetryable(inject())
+	})
+}
+
+// splitWrites splits the references into the ones reading from Bao and the ones writing to it,
+// e.g. >>bao:pki/issue/app#certificate.
+func splitWrites(environ map[string]string) (map[string]string, map[string]string) {
+	reads := make(map[string]string, len(environ))
+	writes := make(map[string]string)
+	for key, reference := range environ {
+		if strings.HasPrefix(reference, ">>bao:") {
+			writes[key] = reference
+		} else {
+			reads[key] = reference
+		}
+	}
+
+	return reads, writes
+}
+
+// Close stops the token renewal of the client and the token refresher.
+// It is safe to call again after a load revoked the token, which closes the provider already.
+func (p *Provider) Close() error {
+	if p.stopRefresh != nil {
+		p.stopRefresh()
+	}
+
+	p.client.Close()
+
+	return nil
+}
+
+// Revoked reports whether a load revoked the token of the provider.
+func (p *Provider) Revoked() bool {
+	return p.revoked
+}
+
+// translateVersion turns the version option into the version segment of a KV v2 reference,
+// e.g. bao:secret/data/account#password?version=pinned:3 becomes bao:secret/data/account#password#3.
+func translateVersion(reference string) (string, error) {
+	if !strings.HasPrefix(reference, "bao:") {
+		return reference, nil
+	}
+
+	reference, version, ok, err := utils.SplitVersion(reference)
+	if err != nil || !ok {
+		return reference, err
+	}
+
+	path, options, hasOptions := strings.Cut(reference, "?")
+	if strings.Count(path, "#") > 1 {
+		return "", fmt.Errorf("version is set both in the reference and by the %s option", utils.VersionOption)
+	}
+
+	if version != "" {
+		path += "#" + version
+	}
+	if hasOptions {
+		path += "?" + options
+	}
+
+	return path, nil
+}
+
 // If the path contains some string formatted as "bao:{STR}#{STR}"
 // it is most probably a vault path
 func Valid(envValue string) bool {
@@ -180,3 +345,13 @@ func parsePathsToMap(paths []string) map[string]string {
 
 	return baoEnviron
 }
+
+// markRetryable flags responses with a transient status code, so they can be retried.
+func markRetryable(err error) error {
+	var responseErr *baoapi.ResponseError
+	if errors.As(err, &responseErr) && retry.IsRetryableStatus(responseErr.StatusCode) {
+		return retry.Retryable(err)
+	}
+
+	return err
+}
@@ -0,0 +1,95 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bao
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	bao "github.com/bank-vaults/vault-sdk/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProviderName(t *testing.T) {
+	assert.Equal(t, "bao", (&Provider{}).GetProviderName(), "Unexpected provider name")
+}
+
+func TestClose(t *testing.T) {
+	client, err := bao.NewClientWithOptions(bao.ClientToken("token"))
+	require.NoError(t, err)
+
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	p := &Provider{client: client, stopRefresh: stopRefresh}
+
+	assert.NoError(t, p.Close())
+	assert.ErrorIs(t, refreshCtx.Err(), context.Canceled, "The token refresher should be stopped")
+	assert.NoError(t, p.Close(), "Closing again should do no harm")
+}
+
+func TestTranslateVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		reference     string
+		wantReference string
+		err           error
+	}{
+		{
+			name:          "Reference without version option",
+			reference:     "bao:secret/data/account#password#2",
+			wantReference: "bao:secret/data/account#password#2",
+		},
+		{
+			name:          "Latest version",
+			reference:     "bao:secret/data/account#password?version=latest",
+			wantReference: "bao:secret/data/account#password",
+		},
+		{
+			name:          "Pinned version",
+			reference:     "bao:secret/data/account#password?version=pinned:3",
+			wantReference: "bao:secret/data/account#password#3",
+		},
+		{
+			name:          "Pinned version keeps other options",
+			reference:     "bao:secret/data/account#password?version=pinned:3&token_file=/creds/token",
+			wantReference: "bao:secret/data/account#password#3?token_file=%2Fcreds%2Ftoken",
+		},
+		{
+			name:      "Version set twice",
+			reference: "bao:secret/data/account#password#2?version=pinned:3",
+			err:       fmt.Errorf("version is set both in the reference and by the version option"),
+		},
+		{
+			name:      "Invalid version option",
+			reference: "bao:secret/data/account#password?version=3",
+			err:       fmt.Errorf("invalid version \"3\", expected latest or pinned:<id>"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			reference, err := translateVersion(ttp.reference)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantReference, reference, "Unexpected reference")
+		})
+	}
+}
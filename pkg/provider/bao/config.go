@@ -16,7 +16,9 @@ package bao
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cast"
@@ -113,6 +115,9 @@ var sanitizeEnvmap = map[string]envType{
 	FromPathEnv:             {login: false},
 }
 
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = append(slices.Sorted(maps.Keys(sanitizeEnvmap)), tokenFileEnv)
+
 func LoadConfig() (*Config, error) {
 	var (
 		role, authPath, authMethod      string
@@ -186,3 +191,21 @@ func LoadConfig() (*Config, error) {
 		RevokeToken:          cast.ToBool(os.Getenv(revokeTokenEnv)),
 	}, nil
 }
+
+// CheckAuth verifies that either a token or a role to log in with is configured,
+// so a misconfigured provider doesn't end up reading secrets without a token.
+func (c *Config) CheckAuth() error {
+	if c.TokenFile != "" {
+		if strings.TrimSpace(c.Token) == "" {
+			return fmt.Errorf("bao: token file %s is empty", c.TokenFile)
+		}
+
+		return nil
+	}
+
+	if c.Role == "" || c.AuthPath == "" || c.AuthMethod == "" {
+		return fmt.Errorf("bao: no token or role/path configured")
+	}
+
+	return nil
+}
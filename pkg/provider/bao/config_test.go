@@ -128,6 +128,46 @@ func TestConfig(t *testing.T) {
 	}
 }
 
+func TestConfig_CheckAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		err    error
+	}{
+		{
+			name:   "Token from token file",
+			config: &Config{Token: "root", TokenFile: "/openbao/.bao-token"},
+		},
+		{
+			name:   "Role and path",
+			config: &Config{Role: "test-app-role", AuthPath: "auth/approle/test/login", AuthMethod: "test-approle"},
+		},
+		{
+			name:   "Empty token file",
+			config: &Config{Token: "\n", TokenFile: "/openbao/.bao-token"},
+			err:    fmt.Errorf("bao: token file /openbao/.bao-token is empty"),
+		},
+		{
+			name:   "No credentials",
+			config: &Config{Role: "", AuthPath: "", AuthMethod: "test-approle"},
+			err:    fmt.Errorf("bao: no token or role/path configured"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			err := ttp.config.CheckAuth()
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+		})
+	}
+}
+
 func newTokenFile(t *testing.T) string {
 	tokenFile, err := os.CreateTemp("", "bao-token")
 	assert.Nil(t, err, "Failed to create a temporary token file")
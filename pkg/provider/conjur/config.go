@@ -0,0 +1,105 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conjur
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// Kubernetes mounts the service account token of the pod here,
+	// it is used for JWT authentication when no other token is configured
+	defaultJWTTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	ApplianceURLEnv    = "CONJUR_APPLIANCE_URL"
+	AccountEnv         = "CONJUR_ACCOUNT"
+	AuthnLoginEnv      = "CONJUR_AUTHN_LOGIN"
+	AuthnAPIKeyEnv     = "CONJUR_AUTHN_API_KEY"
+	AuthnJWTServiceEnv = "CONJUR_AUTHN_JWT_SERVICE_ID"
+	AuthnJWTTokenEnv   = "CONJUR_AUTHN_JWT_TOKEN_PATH"
+	CertFileEnv        = "CONJUR_CERT_FILE"
+)
+
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = []string{
+	ApplianceURLEnv,
+	AccountEnv,
+	AuthnLoginEnv,
+	AuthnAPIKeyEnv,
+	AuthnJWTServiceEnv,
+	AuthnJWTTokenEnv,
+	CertFileEnv,
+}
+
+type Config struct {
+	ApplianceURL string `json:"appliance_url"`
+	Account      string `json:"account"`
+	// Login and APIKey are used for API key authentication
+	Login  string `json:"login"`
+	APIKey string `json:"api_key"`
+	// JWTServiceID and JWTTokenPath are used for JWT authentication (e.g. with Kubernetes service account tokens)
+	JWTServiceID string `json:"jwt_service_id"`
+	JWTTokenPath string `json:"jwt_token_path"`
+	CertFile     string `json:"cert_file"`
+}
+
+func LoadConfig() (*Config, error) {
+	applianceURL, ok := os.LookupEnv(ApplianceURLEnv)
+	if !ok {
+		return nil, fmt.Errorf("incomplete configuration: %s missing", ApplianceURLEnv)
+	}
+
+	account, ok := os.LookupEnv(AccountEnv)
+	if !ok {
+		return nil, fmt.Errorf("incomplete configuration: %s missing", AccountEnv)
+	}
+
+	config := &Config{
+		ApplianceURL: strings.TrimSuffix(applianceURL, "/"),
+		Account:      account,
+		CertFile:     os.Getenv(CertFileEnv),
+	}
+
+	// API key authentication takes precedence, JWT authentication is used otherwise
+	if apiKey, ok := os.LookupEnv(AuthnAPIKeyEnv); ok {
+		login, ok := os.LookupEnv(AuthnLoginEnv)
+		if !ok {
+			return nil, fmt.Errorf("incomplete authentication configuration: %s missing", AuthnLoginEnv)
+		}
+
+		config.Login = login
+		config.APIKey = apiKey
+
+		return config, nil
+	}
+
+	serviceID, ok := os.LookupEnv(AuthnJWTServiceEnv)
+	if !ok {
+		return nil, fmt.Errorf("incomplete authentication configuration: either %s or %s must be set", AuthnAPIKeyEnv, AuthnJWTServiceEnv)
+	}
+
+	config.JWTServiceID = serviceID
+	config.JWTTokenPath = defaultJWTTokenPath
+	if tokenPath, ok := os.LookupEnv(AuthnJWTTokenEnv); ok {
+		config.JWTTokenPath = tokenPath
+	}
+
+	// Optional, some JWT authenticators identify the host by a claim of the token instead
+	config.Login = os.Getenv(AuthnLoginEnv)
+
+	return config, nil
+}
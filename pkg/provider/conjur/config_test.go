@@ -0,0 +1,133 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conjur
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantConfig *Config
+		err        error
+	}{
+		{
+			name: "Valid configuration with API key",
+			env: map[string]string{
+				ApplianceURLEnv: "https://conjur.example.com/",
+				AccountEnv:      "myorg",
+				AuthnLoginEnv:   "host/secret-init",
+				AuthnAPIKeyEnv:  "api-key",
+				CertFileEnv:     "/etc/conjur/ca.pem",
+			},
+			wantConfig: &Config{
+				ApplianceURL: "https://conjur.example.com",
+				Account:      "myorg",
+				Login:        "host/secret-init",
+				APIKey:       "api-key",
+				CertFile:     "/etc/conjur/ca.pem",
+			},
+		},
+		{
+			name: "Valid configuration with JWT using the service account token",
+			env: map[string]string{
+				ApplianceURLEnv:    "https://conjur.example.com",
+				AccountEnv:         "myorg",
+				AuthnJWTServiceEnv: "k8s-cluster",
+			},
+			wantConfig: &Config{
+				ApplianceURL: "https://conjur.example.com",
+				Account:      "myorg",
+				JWTServiceID: "k8s-cluster",
+				JWTTokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+			},
+		},
+		{
+			name: "Valid configuration with JWT using a custom token",
+			env: map[string]string{
+				ApplianceURLEnv:    "https://conjur.example.com",
+				AccountEnv:         "myorg",
+				AuthnLoginEnv:      "host/secret-init",
+				AuthnJWTServiceEnv: "k8s-cluster",
+				AuthnJWTTokenEnv:   "/var/run/secrets/tokens/conjur",
+			},
+			wantConfig: &Config{
+				ApplianceURL: "https://conjur.example.com",
+				Account:      "myorg",
+				Login:        "host/secret-init",
+				JWTServiceID: "k8s-cluster",
+				JWTTokenPath: "/var/run/secrets/tokens/conjur",
+			},
+		},
+		{
+			name: "Invalid configuration - missing appliance URL",
+			env: map[string]string{
+				AccountEnv: "myorg",
+			},
+			err: fmt.Errorf("incomplete configuration: CONJUR_APPLIANCE_URL missing"),
+		},
+		{
+			name: "Invalid configuration - missing account",
+			env: map[string]string{
+				ApplianceURLEnv: "https://conjur.example.com",
+			},
+			err: fmt.Errorf("incomplete configuration: CONJUR_ACCOUNT missing"),
+		},
+		{
+			name: "Invalid configuration - API key without login",
+			env: map[string]string{
+				ApplianceURLEnv: "https://conjur.example.com",
+				AccountEnv:      "myorg",
+				AuthnAPIKeyEnv:  "api-key",
+			},
+			err: fmt.Errorf("incomplete authentication configuration: CONJUR_AUTHN_LOGIN missing"),
+		},
+		{
+			name: "Invalid configuration - missing credentials",
+			env: map[string]string{
+				ApplianceURLEnv: "https://conjur.example.com",
+				AccountEnv:      "myorg",
+			},
+			err: fmt.Errorf("incomplete authentication configuration: either CONJUR_AUTHN_API_KEY or CONJUR_AUTHN_JWT_SERVICE_ID must be set"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			for envKey, envVal := range ttp.env {
+				os.Setenv(envKey, envVal)
+			}
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			config, err := LoadConfig()
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantConfig, config, "Unexpected config")
+		})
+	}
+}
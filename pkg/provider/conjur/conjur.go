@@ -0,0 +1,229 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conjur
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/httpclient"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/retry"
+)
+
+const (
+	ProviderType      = "conjur"
+	referenceSelector = "conjur:"
+
+	// Upper bound for a single API request, so an unreachable appliance can't hang the startup
+	requestTimeout = 10 * time.Second
+)
+
+type Provider struct {
+	client      *http.Client
+	config      *Config
+	retryPolicy retry.Policy
+}
+
+func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conjur config: %w", err)
+	}
+
+	// Self-hosted appliances are commonly served with a certificate of a private CA
+	client, err := httpclient.New(requestTimeout, config.CertFile, appConfig.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conjur client: %w", err)
+	}
+
+	return &Provider{
+		client: client,
+		config: config,
+		retryPolicy: retry.Policy{
+			MaxAttempts: appConfig.RetryMaxAttempts,
+			BaseDelay:   appConfig.RetryBaseDelay,
+		},
+	}, nil
+}
+
+// GetProviderName returns the type of the Conjur provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
+func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
+	var token string
+	err := retry.Do(ctx, p.retryPolicy, func() error {
+		var err error
+		token, err = p.authenticate(ctx)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate to conjur: %w", err)
+	}
+
+	var secrets []provider.Secret
+	for _, path := range paths {
+		split := strings.SplitN(path, "=", 2)
+		originalKey, secretRef := split[0], split[1]
+
+		// valid conjur variable examples:
+		// conjur:{VARIABLE_ID}, e.g. conjur:prod/db/password
+		variableID := strings.TrimPrefix(secretRef, referenceSelector)
+		if variableID == "" {
+			return nil, fmt.Errorf("invalid reference for %s: missing variable id", originalKey)
+		}
+
+		var value string
+		err := retry.Do(ctx, p.retryPolicy, func() error {
+			var err error
+			value, err = p.getVariable(ctx, token, variableID)
+
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get variable from conjur: %w", err)
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:       originalKey,
+			Value:     value,
+			Provider:  ProviderType,
+			Reference: secretRef,
+		})
+	}
+
+	return secrets, nil
+}
+
+// Example Conjur prefix:
+// conjur:prod/db/password
+func Valid(envValue string) bool {
+	return strings.HasPrefix(envValue, referenceSelector)
+}
+
+// authenticate exchanges the configured credentials for a short-lived access token,
+// returned base64 encoded, as expected by the Authorization header.
+// Ref: https://docs.cyberark.com/conjur-open-source/latest/en/content/developer/conjur_api/authenticate_api.htm
+func (p *Provider) authenticate(ctx context.Context) (string, error) {
+	var authnURL string
+	var body io.Reader
+	var contentType string
+
+	if p.config.APIKey != "" {
+		authnURL = fmt.Sprintf("%s/authn/%s/%s/authenticate",
+			p.config.ApplianceURL, url.PathEscape(p.config.Account), url.PathEscape(p.config.Login))
+		body = strings.NewReader(p.config.APIKey)
+		contentType = "text/plain"
+	} else {
+		jwt, err := os.ReadFile(p.config.JWTTokenPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read JWT token %s: %w", p.config.JWTTokenPath, err)
+		}
+
+		authnURL = fmt.Sprintf("%s/authn-jwt/%s/%s",
+			p.config.ApplianceURL, url.PathEscape(p.config.JWTServiceID), url.PathEscape(p.config.Account))
+		if p.config.Login != "" {
+			authnURL += "/" + url.PathEscape(p.config.Login)
+		}
+		authnURL += "/authenticate"
+
+		body = strings.NewReader(url.Values{"jwt": {strings.TrimSpace(string(jwt))}}.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authnURL, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create authentication request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept-Encoding", "base64")
+
+	token, err := p.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	return string(token), nil
+}
+
+// getVariable retrieves the value of a single variable.
+// Ref: https://docs.cyberark.com/conjur-open-source/latest/en/content/developer/conjur_api/retrieve_secret_api.htm
+func (p *Provider) getVariable(ctx context.Context, token string, variableID string) (string, error) {
+	variableURL := fmt.Sprintf("%s/secrets/%s/variable/%s",
+		p.config.ApplianceURL, url.PathEscape(p.config.Account), url.PathEscape(variableID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, variableURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create variable request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Token token=%q", token))
+
+	value, err := p.do(req)
+	if err != nil {
+		return "", fmt.Errorf("variable %s: %w", variableID, err)
+	}
+
+	return string(value), nil
+}
+
+func (p *Provider) do(req *http.Request) ([]byte, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusUnauthorized:
+		return nil, &responseError{status: resp.StatusCode, message: "unauthorized, check the credentials"}
+	case http.StatusForbidden:
+		return nil, &responseError{status: resp.StatusCode, message: "permission denied"}
+	case http.StatusNotFound:
+		return nil, &responseError{status: resp.StatusCode, message: "not found"}
+	default:
+		return nil, &responseError{status: resp.StatusCode, message: fmt.Sprintf("unexpected status code %d", resp.StatusCode)}
+	}
+}
+
+// responseError carries the status code of a failed request, so transient failures can be retried.
+type responseError struct {
+	status  int
+	message string
+}
+
+func (e *responseError) Error() string {
+	return e.message
+}
+
+func (e *responseError) StatusCode() int {
+	return e.status
+}
@@ -0,0 +1,126 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conjur
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		want      bool
+	}{
+		{name: "Valid reference", reference: "conjur:prod/db/password", want: true},
+		{name: "Other provider reference", reference: "vault:secret/data/db#password"},
+		{name: "Plain value", reference: "conjur"},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			assert.Equal(t, ttp.want, Valid(ttp.reference), "Unexpected validation result")
+		})
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	// Fake appliance accepting a single API key
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/authn/myorg/host%2Fsecret-init/authenticate":
+			apiKey, _ := io.ReadAll(r.Body)
+			if string(apiKey) != "api-key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			_, _ = w.Write([]byte("dG9rZW4="))
+		case "/secrets/myorg/variable/prod%2Fdb%2Fpassword":
+			if r.Header.Get("Authorization") != `Token token="dG9rZW4="` {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			_, _ = w.Write([]byte("s3cr3t"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		apiKey      string
+		paths       []string
+		wantSecrets []provider.Secret
+		err         error
+	}{
+		{
+			name:   "Load secrets successfully",
+			apiKey: "api-key",
+			paths:  []string{"DB_PASSWORD=conjur:prod/db/password"},
+			wantSecrets: []provider.Secret{
+				{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "conjur:prod/db/password"},
+			},
+		},
+		{
+			name:   "Fail to authenticate",
+			apiKey: "wrong-key",
+			paths:  []string{"DB_PASSWORD=conjur:prod/db/password"},
+			err:    fmt.Errorf("failed to authenticate to conjur: unauthorized, check the credentials"),
+		},
+		{
+			name:   "Fail to get missing variable",
+			apiKey: "api-key",
+			paths:  []string{"DB_USER=conjur:prod/db/user"},
+			err:    fmt.Errorf("failed to get variable from conjur: variable prod/db/user: not found"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			p := &Provider{
+				client: server.Client(),
+				config: &Config{
+					ApplianceURL: server.URL,
+					Account:      "myorg",
+					Login:        "host/secret-init",
+					APIKey:       ttp.apiKey,
+				},
+			}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doppler
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	defaultAPIHost = "https://api.doppler.com"
+
+	// Service tokens are scoped to a single project and config
+	serviceTokenPrefix = "dp.st."
+
+	TokenEnv   = "DOPPLER_TOKEN"
+	ProjectEnv = "DOPPLER_PROJECT"
+	ConfigEnv  = "DOPPLER_CONFIG"
+	APIHostEnv = "DOPPLER_API_HOST"
+)
+
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = []string{TokenEnv, ProjectEnv, ConfigEnv, APIHostEnv}
+
+type Config struct {
+	Token   string `json:"token"`
+	Project string `json:"project"`
+	// Config is the default Doppler config of references without their own
+	Config  string `json:"config"`
+	APIHost string `json:"api_host"`
+}
+
+func LoadConfig() (*Config, error) {
+	token, ok := os.LookupEnv(TokenEnv)
+	if !ok {
+		return nil, fmt.Errorf("incomplete configuration: %s missing", TokenEnv)
+	}
+
+	config := &Config{
+		Token:   token,
+		Project: os.Getenv(ProjectEnv),
+		Config:  os.Getenv(ConfigEnv),
+		APIHost: defaultAPIHost,
+	}
+
+	if apiHost, ok := os.LookupEnv(APIHostEnv); ok {
+		config.APIHost = strings.TrimSuffix(apiHost, "/")
+	}
+
+	// Other tokens (personal, service account) can access several projects, so it has to be picked
+	if !config.isServiceToken() && config.Project == "" {
+		return nil, fmt.Errorf("incomplete configuration: %s is required unless a service token is used", ProjectEnv)
+	}
+
+	return config, nil
+}
+
+func (c *Config) isServiceToken() bool {
+	return strings.HasPrefix(c.Token, serviceTokenPrefix)
+}
@@ -0,0 +1,93 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doppler
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantConfig *Config
+		err        error
+	}{
+		{
+			name: "Valid configuration with a service token",
+			env: map[string]string{
+				TokenEnv: "dp.st.prd.token",
+			},
+			wantConfig: &Config{
+				Token:   "dp.st.prd.token",
+				APIHost: "https://api.doppler.com",
+			},
+		},
+		{
+			name: "Valid configuration with a personal token",
+			env: map[string]string{
+				TokenEnv:   "dp.pt.token",
+				ProjectEnv: "backend",
+				ConfigEnv:  "prd",
+				APIHostEnv: "https://doppler.example.com/",
+			},
+			wantConfig: &Config{
+				Token:   "dp.pt.token",
+				Project: "backend",
+				Config:  "prd",
+				APIHost: "https://doppler.example.com",
+			},
+		},
+		{
+			name: "Invalid configuration - missing token",
+			env: map[string]string{
+				ProjectEnv: "backend",
+			},
+			err: fmt.Errorf("incomplete configuration: DOPPLER_TOKEN missing"),
+		},
+		{
+			name: "Invalid configuration - missing project",
+			env: map[string]string{
+				TokenEnv: "dp.pt.token",
+			},
+			err: fmt.Errorf("incomplete configuration: DOPPLER_PROJECT is required unless a service token is used"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			for envKey, envVal := range ttp.env {
+				os.Setenv(envKey, envVal)
+			}
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			config, err := LoadConfig()
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantConfig, config, "Unexpected config")
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doppler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/httpclient"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/retry"
+)
+
+const (
+	ProviderType      = "doppler"
+	referenceSelector = "doppler:"
+
+	// Upper bound for a single API request, so an unreachable API can't hang the startup
+	requestTimeout = 10 * time.Second
+)
+
+type Provider struct {
+	client      *http.Client
+	config      *Config
+	retryPolicy retry.Policy
+}
+
+func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create doppler config: %w", err)
+	}
+
+	client, err := httpclient.New(requestTimeout, appConfig.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create doppler client: %w", err)
+	}
+
+	return &Provider{
+		client: client,
+		config: config,
+		retryPolicy: retry.Policy{
+			MaxAttempts: appConfig.RetryMaxAttempts,
+			BaseDelay:   appConfig.RetryBaseDelay,
+		},
+	}, nil
+}
+
+// GetProviderName returns the type of the Doppler provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
+func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
+	var secrets []provider.Secret
+	for _, path := range paths {
+		split := strings.SplitN(path, "=", 2)
+		originalKey, secretRef := split[0], split[1]
+
+		// valid doppler secret examples:
+		// doppler:{CONFIG}/{SECRET_NAME}, e.g. doppler:prd/DB_PASSWORD
+		// doppler:{SECRET_NAME}, read from DOPPLER_CONFIG or the config of the service token
+		config, name, ok := strings.Cut(strings.TrimPrefix(secretRef, referenceSelector), "/")
+		if !ok {
+			config, name = p.config.Config, config
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("invalid reference for %s: missing secret name", originalKey)
+		}
+		if config == "" && !p.config.isServiceToken() {
+			return nil, fmt.Errorf("invalid reference for %s: missing config, set %s or use doppler:{config}/{secret}", originalKey, ConfigEnv)
+		}
+
+		var value string
+		err := retry.Do(ctx, p.retryPolicy, func() error {
+			var err error
+			value, err = p.getSecret(ctx, config, name)
+
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secret from doppler: %w", err)
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:       originalKey,
+			Value:     value,
+			Provider:  ProviderType,
+			Reference: secretRef,
+		})
+	}
+
+	return secrets, nil
+}
+
+// Example Doppler prefix:
+// doppler:prd/DB_PASSWORD
+func Valid(envValue string) bool {
+	return strings.HasPrefix(envValue, referenceSelector)
+}
+
+// getSecret retrieves the computed value of a single secret, with references to other secrets resolved.
+// Project and config are left to the service token if not set.
+// Ref: https://docs.doppler.com/reference/secrets-get
+func (p *Provider) getSecret(ctx context.Context, config string, name string) (string, error) {
+	query := url.Values{"name": {name}}
+	if p.config.Project != "" {
+		query.Set("project", p.config.Project)
+	}
+	if config != "" {
+		query.Set("config", config)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.APIHost+"/v3/configs/config/secret?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create secret request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.Token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", &responseError{status: resp.StatusCode, message: "unauthorized, check the token"}
+	case http.StatusForbidden:
+		return "", &responseError{status: resp.StatusCode, message: fmt.Sprintf("secret %s: permission denied", name)}
+	case http.StatusNotFound:
+		return "", &responseError{status: resp.StatusCode, message: fmt.Sprintf("secret %s: not found", name)}
+	default:
+		return "", &responseError{status: resp.StatusCode, message: fmt.Sprintf("secret %s: unexpected status code %d", name, resp.StatusCode)}
+	}
+
+	var secret struct {
+		Value struct {
+			Computed *string `json:"computed"`
+		} `json:"value"`
+	}
+	err = json.Unmarshal(body, &secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal secret %s: %w", name, err)
+	}
+
+	if secret.Value.Computed == nil {
+		return "", fmt.Errorf("secret %s has no value", name)
+	}
+
+	return *secret.Value.Computed, nil
+}
+
+// responseError carries the status code of a failed request, so transient failures can be retried.
+type responseError struct {
+	status  int
+	message string
+}
+
+func (e *responseError) Error() string {
+	return e.message
+}
+
+func (e *responseError) StatusCode() int {
+	return e.status
+}
@@ -0,0 +1,140 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doppler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		want      bool
+	}{
+		{name: "Valid reference with config", reference: "doppler:prd/DB_PASSWORD", want: true},
+		{name: "Valid reference without config", reference: "doppler:DB_PASSWORD", want: true},
+		{name: "Other provider reference", reference: "vault:secret/data/db#password"},
+		{name: "Plain value", reference: "doppler"},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			assert.Equal(t, ttp.want, Valid(ttp.reference), "Unexpected validation result")
+		})
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	// Fake API accepting a single token, holding a single secret of the prd config
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer dp.pt.token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		if r.URL.Path != "/v3/configs/config/secret" || query.Get("project") != "backend" ||
+			query.Get("config") != "prd" || query.Get("name") != "DB_PASSWORD" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"name":  "DB_PASSWORD",
+			"value": map[string]any{"raw": "${DB_ROOT}", "computed": "s3cr3t"},
+		})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		token       string
+		config      string
+		paths       []string
+		wantSecrets []provider.Secret
+		err         error
+	}{
+		{
+			name:  "Load secret with config in the reference",
+			token: "dp.pt.token",
+			paths: []string{"DB_PASSWORD=doppler:prd/DB_PASSWORD"},
+			wantSecrets: []provider.Secret{
+				{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "doppler:prd/DB_PASSWORD"},
+			},
+		},
+		{
+			name:   "Load secret with the default config",
+			token:  "dp.pt.token",
+			config: "prd",
+			paths:  []string{"DB_PASSWORD=doppler:DB_PASSWORD"},
+			wantSecrets: []provider.Secret{
+				{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "doppler:DB_PASSWORD"},
+			},
+		},
+		{
+			name:  "Fail with an invalid token",
+			token: "dp.pt.invalid",
+			paths: []string{"DB_PASSWORD=doppler:prd/DB_PASSWORD"},
+			err:   fmt.Errorf("failed to get secret from doppler: unauthorized, check the token"),
+		},
+		{
+			name:  "Fail to get missing secret",
+			token: "dp.pt.token",
+			paths: []string{"DB_USER=doppler:prd/DB_USER"},
+			err:   fmt.Errorf("failed to get secret from doppler: secret DB_USER: not found"),
+		},
+		{
+			name:  "Fail without config",
+			token: "dp.pt.token",
+			paths: []string{"DB_PASSWORD=doppler:DB_PASSWORD"},
+			err:   fmt.Errorf("invalid reference for DB_PASSWORD: missing config, set DOPPLER_CONFIG or use doppler:{config}/{secret}"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			p := &Provider{
+				client: server.Client(),
+				config: &Config{
+					Token:   ttp.token,
+					Project: "backend",
+					Config:  ttp.config,
+					APIHost: server.URL,
+				},
+			}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
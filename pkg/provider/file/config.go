@@ -17,16 +17,25 @@ package file
 import (
 	"log/slog"
 	"os"
+
+	"github.com/spf13/cast"
 )
 
 const (
 	defaultMountPath = "/"
 
 	MountPathEnv = "FILE_MOUNT_PATH"
+	// TrimSpaceEnv trims leading and trailing whitespace (e.g. the trailing newline) of all loaded secrets,
+	// references can override it with the trim option
+	TrimSpaceEnv = "FILE_TRIM_SPACE"
 )
 
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = []string{MountPathEnv, TrimSpaceEnv}
+
 type Config struct {
 	MountPath string `json:"mount_path"`
+	TrimSpace bool   `json:"trim_space"`
 }
 
 func LoadConfig() *Config {
@@ -36,5 +45,8 @@ func LoadConfig() *Config {
 		mountPath = defaultMountPath
 	}
 
-	return &Config{MountPath: mountPath}
+	return &Config{
+		MountPath: mountPath,
+		TrimSpace: cast.ToBool(os.Getenv(TrimSpaceEnv)),
+	}
 }
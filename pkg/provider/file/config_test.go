@@ -26,6 +26,7 @@ func TestConfig(t *testing.T) {
 		name          string
 		env           map[string]string
 		wantMountPath string
+		wantTrimSpace bool
 	}{
 		{
 			name:          "Default mount path",
@@ -39,6 +40,14 @@ func TestConfig(t *testing.T) {
 			},
 			wantMountPath: "/test/secrets",
 		},
+		{
+			name: "Trim space",
+			env: map[string]string{
+				TrimSpaceEnv: "true",
+			},
+			wantMountPath: "/",
+			wantTrimSpace: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -54,6 +63,7 @@ func TestConfig(t *testing.T) {
 			config := LoadConfig()
 
 			assert.Equal(t, ttp.wantMountPath, config.MountPath, "Unexpected mount path")
+			assert.Equal(t, ttp.wantTrimSpace, config.TrimSpace, "Unexpected trim space")
 		})
 	}
 }
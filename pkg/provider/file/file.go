@@ -18,23 +18,59 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
 
 	"github.com/bank-vaults/secret-init/pkg/common"
 	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/utils"
 )
 
 const (
 	ProviderType      = "file"
 	referenceSelector = "file:"
+
+	// dirOption selects how a reference to a directory is read, e.g. file:secrets/db?dir=files
+	dirOption = "dir"
+	// dirError fails on directories, like any other unreadable file
+	dirError = "error"
+	// dirConcat loads the contents of all files of the directory as a single secret
+	dirConcat = "concat"
+	// dirFiles loads each file of the directory as a separate secret, named after the key and the file
+	dirFiles = "files"
+
+	// trimOption trims leading and trailing whitespace of the secret, e.g. file:secrets/token?trim=true
+	trimOption = "trim"
+
+	// refreshOption re-reads a file periodically in daemon mode, e.g. file:tokens/vault?refresh=60s,
+	// for files rotated without a reliable change notification (e.g. projected service account tokens)
+	refreshOption = "refresh"
 )
 
+// refreshedFile is a file polled for a new content instead of being watched.
+type refreshedFile struct {
+	path     string
+	interval time.Duration
+	content  string
+}
+
 type Provider struct {
-	fs fs.FS
+	fs        fs.FS
+	mountPath string
+	// daemon enables watching the loaded files for changes
+	daemon bool
+	// trimSpace is the default of the trim option
+	trimSpace bool
 }
 
-func NewProvider(_ context.Context, _ *common.Config) (provider.Provider, error) {
+func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider, error) {
 	config := LoadConfig()
 
 	// Check whether the path exists
@@ -47,26 +83,133 @@ func NewProvider(_ context.Context, _ *common.Config) (provider.Provider, error)
 		return nil, fmt.Errorf("provided path is not a directory")
 	}
 
-	return &Provider{fs: os.DirFS(config.MountPath)}, nil
+	return &Provider{
+		fs:        os.DirFS(config.MountPath),
+		mountPath: config.MountPath,
+		daemon:    appConfig.Daemon,
+		trimSpace: config.TrimSpace,
+	}, nil
 }
 
-func (p *Provider) LoadSecrets(_ context.Context, paths []string) ([]provider.Secret, error) {
+// GetProviderName returns the type of the file provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
+func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
 	var secrets []provider.Secret
+	var files []string
+	var refreshedFiles []refreshedFile
 
 	for _, path := range paths {
 		split := strings.SplitN(path, "=", 2)
 		originalKey, valuePath := split[0], split[1]
+		reference := valuePath
 		valuePath = strings.TrimPrefix(valuePath, "file:")
 
-		secretValue, err := p.getSecretFromFile(valuePath)
+		valuePath, options, err := utils.ParseReferenceOptions(valuePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get secret from file: %w", err)
+			return nil, err
 		}
 
-		secrets = append(secrets, provider.Secret{
-			Key:   originalKey,
-			Value: secretValue,
-		})
+		dirMode := dirError
+		if options.Has(dirOption) {
+			dirMode = options.Get(dirOption)
+		}
+
+		format := formatRaw
+		if options.Has(formatOption) {
+			format = options.Get(formatOption)
+		}
+
+		trim := p.trimSpace
+		if options.Has(trimOption) {
+			trim, err = strconv.ParseBool(options.Get(trimOption))
+			if err != nil {
+				return nil, fmt.Errorf("invalid reference for %s: invalid %s option %q", originalKey, trimOption, options.Get(trimOption))
+			}
+		}
+
+		var refresh time.Duration
+		if options.Has(refreshOption) {
+			refresh, err = time.ParseDuration(options.Get(refreshOption))
+			if err != nil || refresh <= 0 {
+				return nil, fmt.Errorf("invalid reference for %s: invalid %s option %q", originalKey, refreshOption, options.Get(refreshOption))
+			}
+		}
+
+		// Directories are read file by file, the format only applies to a single file
+		if dirMode != dirError && format != formatRaw {
+			return nil, fmt.Errorf("invalid reference for %s: %s and %s can't be combined", originalKey, dirOption, formatOption)
+		}
+
+		if dirMode != dirError && refresh > 0 {
+			return nil, fmt.Errorf("invalid reference for %s: %s and %s can't be combined", originalKey, dirOption, refreshOption)
+		}
+
+		var loadedSecrets []provider.Secret
+		var loadedFiles []string
+		switch dirMode {
+		case dirError:
+			secretValue, err := p.getSecretFromFile(valuePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get secret from file: %w", err)
+			}
+
+			loadedSecrets, err = parseSecrets(originalKey, secretValue, format)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse secrets of %s: %w", originalKey, err)
+			}
+
+			if refresh > 0 {
+				refreshedFiles = append(refreshedFiles, refreshedFile{path: valuePath, interval: refresh, content: secretValue})
+			} else {
+				loadedFiles = []string{valuePath}
+			}
+		case dirConcat, dirFiles:
+			loadedSecrets, loadedFiles, err = p.getSecretsFromDir(originalKey, valuePath, dirMode)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get secret from directory: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid reference for %s: unknown %s mode %q", originalKey, dirOption, dirMode)
+		}
+
+		// Binary content must not be trimmed, so trimming is opt-in
+		if trim {
+			for i := range loadedSecrets {
+				loadedSecrets[i].Value = strings.TrimSpace(loadedSecrets[i].Value)
+			}
+		}
+
+		provider.SetOrigin(loadedSecrets, ProviderType, reference)
+		secrets = append(secrets, loadedSecrets...)
+		for _, file := range loadedFiles {
+			files = append(files, filepath.Join(p.mountPath, strings.TrimLeft(file, "/")))
+		}
+	}
+
+	// In daemon mode the process is restarted once a loaded file changes,
+	// files with a refresh interval are polled instead of watched
+	// The watchers outlive the load, which may be cancelled once it is done
+	lifetime := provider.Lifetime(ctx)
+	if p.daemon {
+		for _, file := range refreshedFiles {
+			go p.refresh(lifetime, file)
+		}
+	}
+
+	if p.daemon && len(files) > 0 {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file watcher: %w", err)
+		}
+
+		err = p.watch(lifetime, watcher, files)
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
 	}
 
 	return secrets, nil
@@ -76,6 +219,89 @@ func Valid(envValue string) bool {
 	return strings.HasPrefix(envValue, referenceSelector)
 }
 
+// watch requests a restart when any of the files changes, then stops watching.
+// The directories of the files are watched instead of the files themselves,
+// since mounted secrets (e.g. Kubernetes secret volumes) are updated by swapping symlinks.
+// The watcher is closed when the context is done.
+func (p *Provider) watch(ctx context.Context, watcher *fsnotify.Watcher, files []string) error {
+	watchedFiles := make(map[string]bool, len(files))
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if !watchedFiles[dir] {
+			err := watcher.Add(dir)
+			if err != nil {
+				return fmt.Errorf("failed to watch directory %s: %w", dir, err)
+			}
+		}
+
+		watchedFiles[dir] = true
+		watchedFiles[file] = true
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+
+				// Kubernetes swaps the ..data symlink of the volume on every update
+				if watchedFiles[event.Name] || filepath.Base(event.Name) == "..data" {
+					slog.Info("secret file changed, requesting restart", slog.String("file", event.Name))
+					provider.RequestRestart(ctx)
+
+					return
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				slog.Warn(fmt.Errorf("file watcher error: %w", err).Error())
+			}
+		}
+	}()
+
+	return nil
+}
+
+// refresh re-reads the file at its interval and requests a restart once its content changed, then stops.
+// Read errors are only logged, the file may be missing for a moment while it is rotated.
+// It stops when the context is done.
+func (p *Provider) refresh(ctx context.Context, file refreshedFile) {
+	ticker := time.NewTicker(file.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			content, err := p.getSecretFromFile(file.path)
+			if err != nil {
+				slog.Warn(fmt.Errorf("failed to refresh secret file: %w", err).Error(), slog.String("file", file.path))
+				continue
+			}
+
+			if content != file.content {
+				slog.Info("secret file refreshed, requesting restart", slog.String("file", file.path))
+				provider.RequestRestart(ctx)
+
+				return
+			}
+		}
+	}
+}
+
 func (p *Provider) getSecretFromFile(valuePath string) (string, error) {
 	valuePath = strings.TrimLeft(valuePath, "/")
 	content, err := fs.ReadFile(p.fs, valuePath)
@@ -85,3 +311,67 @@ func (p *Provider) getSecretFromFile(valuePath string) (string, error) {
 
 	return string(content), nil
 }
+
+// getSecretsFromDir reads the regular files of a directory, in the order of their names.
+// Hidden entries are skipped, e.g. the ..data symlink and the timestamped directories of Kubernetes volumes.
+// Subdirectories are not descended into.
+func (p *Provider) getSecretsFromDir(originalKey string, valuePath string, dirMode string) ([]provider.Secret, []string, error) {
+	valuePath = strings.TrimLeft(valuePath, "/")
+	entries, err := fs.ReadDir(p.fs, valuePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var secrets []provider.Secret
+	var files []string
+	var concatenated strings.Builder
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		file := path.Join(valuePath, entry.Name())
+
+		// Mounted secrets are symlinks, so the type of the target is checked
+		info, err := fs.Stat(p.fs, file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to access file: %w", err)
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		content, err := fs.ReadFile(p.fs, file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		files = append(files, file)
+		if dirMode == dirConcat {
+			concatenated.Write(content)
+			continue
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:   originalKey + "_" + sanitizeKey(entry.Name()),
+			Value: string(content),
+		})
+	}
+
+	if dirMode == dirConcat {
+		secrets = []provider.Secret{{Key: originalKey, Value: concatenated.String()}}
+	}
+
+	return secrets, files, nil
+}
+
+// sanitizeKey turns a file name into a valid environment variable name, e.g. tls.crt becomes TLS_CRT.
+func sanitizeKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, strings.ToUpper(name))
+}
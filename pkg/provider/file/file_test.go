@@ -17,10 +17,14 @@ package file
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/bank-vaults/secret-init/pkg/provider"
 )
@@ -40,9 +44,9 @@ func TestLoadSecrets(t *testing.T) {
 				"AWS_ACCESS_KEY_ID=file:test/secrets/awsid.txt",
 			},
 			wantSecrets: []provider.Secret{
-				{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t"},
-				{Key: "AWS_SECRET_ACCESS_KEY", Value: "s3cr3t"},
-				{Key: "AWS_ACCESS_KEY_ID", Value: "secretId"},
+				{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t", Provider: ProviderType, Reference: "file:test/secrets/sqlpass.txt"},
+				{Key: "AWS_SECRET_ACCESS_KEY", Value: "s3cr3t", Provider: ProviderType, Reference: "file:test/secrets/awsaccess.txt"},
+				{Key: "AWS_ACCESS_KEY_ID", Value: "secretId", Provider: ProviderType, Reference: "file:test/secrets/awsid.txt"},
 			},
 		},
 		{
@@ -75,3 +79,293 @@ func TestLoadSecrets(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadSecretsWatch(t *testing.T) {
+	mountPath := t.TempDir()
+	secretFile := filepath.Join(mountPath, "sqlpass.txt")
+	require.NoError(t, os.WriteFile(secretFile, []byte("3xtr3ms3cr3t"), 0o600))
+
+	restarts := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(provider.WithRestart(context.Background(), func() {
+		restarts <- struct{}{}
+	}))
+	defer cancel()
+
+	p := Provider{fs: os.DirFS(mountPath), mountPath: mountPath, daemon: true}
+	_, err := p.LoadSecrets(ctx, []string{"MYSQL_PASSWORD=file:sqlpass.txt"})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(secretFile, []byte("n3ws3cr3t"), 0o600))
+
+	select {
+	case <-restarts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Restart was not requested after the file changed")
+	}
+}
+
+func TestLoadSecretsRefresh(t *testing.T) {
+	mountPath := t.TempDir()
+	tokenFile := filepath.Join(mountPath, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token-1"), 0o600))
+
+	restarts := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(provider.WithRestart(context.Background(), func() {
+		restarts <- struct{}{}
+	}))
+	defer cancel()
+
+	p := Provider{fs: os.DirFS(mountPath), mountPath: mountPath, daemon: true}
+	paths := []string{"SA_TOKEN=file:token?refresh=10ms"}
+	secrets, err := p.LoadSecrets(ctx, paths)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", secrets[0].Value, "Unexpected token")
+
+	// The kubelet rotates the token
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token-2"), 0o600))
+
+	select {
+	case <-restarts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Restart was not requested after the token was rotated")
+	}
+
+	secrets, err = p.LoadSecrets(ctx, paths)
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", secrets[0].Value, "Unexpected refreshed token")
+}
+
+func TestLoadSecretsInvalidRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		err  error
+	}{
+		{
+			name: "Invalid interval",
+			path: "SA_TOKEN=file:tokens/vault?refresh=soon",
+			err:  fmt.Errorf("invalid reference for SA_TOKEN: invalid refresh option \"soon\""),
+		},
+		{
+			name: "Refreshed directory",
+			path: "SA_TOKEN=file:tokens?dir=files&refresh=60s",
+			err:  fmt.Errorf("invalid reference for SA_TOKEN: dir and refresh can't be combined"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			p := Provider{fs: fstest.MapFS{"tokens/vault": {Data: []byte("token")}}}
+			_, err := p.LoadSecrets(context.Background(), []string{ttp.path})
+			assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+		})
+	}
+}
+
+func TestLoadSecretsFromDirectory(t *testing.T) {
+	tests := []struct {
+		name        string
+		paths       []string
+		err         error
+		wantSecrets []provider.Secret
+	}{
+		{
+			name:  "Fail on directory by default",
+			paths: []string{"DB=file:test/secrets/db"},
+			err:   fmt.Errorf("failed to get secret from file: failed to read file: read test/secrets/db: invalid argument"),
+		},
+		{
+			name:  "Fail on directory explicitly",
+			paths: []string{"DB=file:test/secrets/db?dir=error"},
+			err:   fmt.Errorf("failed to get secret from file: failed to read file: read test/secrets/db: invalid argument"),
+		},
+		{
+			name:  "Concatenate files of directory",
+			paths: []string{"DB=file:test/secrets/db?dir=concat"},
+			wantSecrets: []provider.Secret{
+				{Key: "DB", Value: "s3cr3troot", Provider: ProviderType, Reference: "file:test/secrets/db?dir=concat"},
+			},
+		},
+		{
+			name:  "Load each file of directory",
+			paths: []string{"DB=file:test/secrets/db?dir=files"},
+			wantSecrets: []provider.Secret{
+				{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "file:test/secrets/db?dir=files"},
+				{Key: "DB_USER_NAME", Value: "root", Provider: ProviderType, Reference: "file:test/secrets/db?dir=files"},
+			},
+		},
+		{
+			name:  "Fail on unknown mode",
+			paths: []string{"DB=file:test/secrets/db?dir=recursive"},
+			err:   fmt.Errorf(`invalid reference for DB: unknown dir mode "recursive"`),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			fs := fstest.MapFS{
+				"test/secrets/db/password":          {Data: []byte("s3cr3t")},
+				"test/secrets/db/user.name":         {Data: []byte("root")},
+				"test/secrets/db/.hidden":           {Data: []byte("hidden")},
+				"test/secrets/db/nested/ignored":    {Data: []byte("nested")},
+				"test/secrets/db/..data/password":   {Data: []byte("s3cr3t")},
+				"test/secrets/other/not-referenced": {Data: []byte("other")},
+			}
+			provider := Provider{fs: fs}
+			secrets, err := provider.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestLoadSecretsTrimmed(t *testing.T) {
+	fs := fstest.MapFS{
+		"test/secrets/token.txt":    {Data: []byte("t0k3n\n")},
+		"test/secrets/password.txt": {Data: []byte("  s3cr3t \r\n")},
+	}
+
+	tests := []struct {
+		name        string
+		trimSpace   bool
+		paths       []string
+		wantSecrets []provider.Secret
+		err         error
+	}{
+		{
+			name:  "Keep content by default",
+			paths: []string{"TOKEN=file:test/secrets/token.txt"},
+			wantSecrets: []provider.Secret{
+				{Key: "TOKEN", Value: "t0k3n\n", Provider: ProviderType, Reference: "file:test/secrets/token.txt"},
+			},
+		},
+		{
+			name:      "Trim trailing newline",
+			trimSpace: true,
+			paths:     []string{"TOKEN=file:test/secrets/token.txt"},
+			wantSecrets: []provider.Secret{
+				{Key: "TOKEN", Value: "t0k3n", Provider: ProviderType, Reference: "file:test/secrets/token.txt"},
+			},
+		},
+		{
+			name:  "Trim leading spaces per reference",
+			paths: []string{"PASSWORD=file:test/secrets/password.txt?trim=true"},
+			wantSecrets: []provider.Secret{
+				{Key: "PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "file:test/secrets/password.txt?trim=true"},
+			},
+		},
+		{
+			name:      "Disable trimming per reference",
+			trimSpace: true,
+			paths:     []string{"PASSWORD=file:test/secrets/password.txt?trim=false"},
+			wantSecrets: []provider.Secret{
+				{Key: "PASSWORD", Value: "  s3cr3t \r\n", Provider: ProviderType, Reference: "file:test/secrets/password.txt?trim=false"},
+			},
+		},
+		{
+			name:  "Fail on invalid trim option",
+			paths: []string{"TOKEN=file:test/secrets/token.txt?trim=maybe"},
+			err:   fmt.Errorf(`invalid reference for TOKEN: invalid trim option "maybe"`),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			p := Provider{fs: fs, trimSpace: ttp.trimSpace}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestLoadSecretsWithFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		paths       []string
+		err         error
+		wantSecrets []provider.Secret
+	}{
+		{
+			name:  "Load raw file by default",
+			paths: []string{"DB=file:test/secrets/db.env"},
+			wantSecrets: []provider.Secret{
+				{Key: "DB", Value: "# database\nexport USER=root\nPASSWORD=\"s3cr3t\\n\"\nHOST='db:5432'\n", Provider: ProviderType, Reference: "file:test/secrets/db.env"},
+			},
+		},
+		{
+			name:  "Load dotenv file",
+			paths: []string{"DB=file:test/secrets/db.env?format=dotenv"},
+			wantSecrets: []provider.Secret{
+				{Key: "DB_HOST", Value: "db:5432", Provider: ProviderType, Reference: "file:test/secrets/db.env?format=dotenv"},
+				{Key: "DB_PASSWORD", Value: "s3cr3t\n", Provider: ProviderType, Reference: "file:test/secrets/db.env?format=dotenv"},
+				{Key: "DB_USER", Value: "root", Provider: ProviderType, Reference: "file:test/secrets/db.env?format=dotenv"},
+			},
+		},
+		{
+			name:  "Load YAML file",
+			paths: []string{"DB=file:test/secrets/db.yaml?format=yaml"},
+			wantSecrets: []provider.Secret{
+				{Key: "DB_HOSTS", Value: `["db-0","db-1"]`, Provider: ProviderType, Reference: "file:test/secrets/db.yaml?format=yaml"},
+				{Key: "DB_PORT", Value: "5432", Provider: ProviderType, Reference: "file:test/secrets/db.yaml?format=yaml"},
+				{Key: "DB_USER_NAME", Value: "root", Provider: ProviderType, Reference: "file:test/secrets/db.yaml?format=yaml"},
+				{Key: "DB_USER_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "file:test/secrets/db.yaml?format=yaml"},
+			},
+		},
+		{
+			name:  "Fail on malformed dotenv file",
+			paths: []string{"DB=file:test/secrets/malformed.env?format=dotenv"},
+			err:   fmt.Errorf("failed to parse secrets of DB: invalid dotenv line 2: expected KEY=VALUE"),
+		},
+		{
+			name:  "Fail on malformed YAML file",
+			paths: []string{"DB=file:test/secrets/malformed.yaml?format=yaml"},
+			err:   fmt.Errorf("failed to parse secrets of DB: invalid yaml: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!seq into map[string]interface {}"),
+		},
+		{
+			name:  "Fail on unknown format",
+			paths: []string{"DB=file:test/secrets/db.env?format=toml"},
+			err:   fmt.Errorf(`failed to parse secrets of DB: unknown format "toml"`),
+		},
+		{
+			name:  "Fail on format of directory",
+			paths: []string{"DB=file:test/secrets?dir=files&format=dotenv"},
+			err:   fmt.Errorf("invalid reference for DB: dir and format can't be combined"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			fs := fstest.MapFS{
+				"test/secrets/db.env":         {Data: []byte("# database\nexport USER=root\nPASSWORD=\"s3cr3t\\n\"\nHOST='db:5432'\n")},
+				"test/secrets/db.yaml":        {Data: []byte("user:\n  name: root\n  password: s3cr3t\nport: 5432\nhosts: [db-0, db-1]\n")},
+				"test/secrets/malformed.env":  {Data: []byte("USER=root\nPASSWORD\n")},
+				"test/secrets/malformed.yaml": {Data: []byte("- root\n- s3cr3t\n")},
+			}
+			provider := Provider{fs: fs}
+			secrets, err := provider.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const (
+	// formatOption selects how the content of a file is parsed, e.g. file:secrets/db.env?format=dotenv
+	formatOption = "format"
+	// formatRaw loads the content of the file as the value of a single secret
+	formatRaw = "raw"
+	// formatDotenv loads each KEY=VALUE line of the file as a separate secret
+	formatDotenv = "dotenv"
+	// formatYAML loads each value of a YAML map as a separate secret, nested keys are joined with "_"
+	formatYAML = "yaml"
+)
+
+var dotenvKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// parseSecrets parses the content of a file in the given format.
+// The keys of the parsed values are prefixed with the key of the reference,
+// e.g. USER in a file referenced by DB becomes DB_USER.
+func parseSecrets(originalKey string, content string, format string) ([]provider.Secret, error) {
+	var values map[string]string
+	var err error
+	switch format {
+	case formatRaw:
+		return []provider.Secret{{Key: originalKey, Value: content}}, nil
+	case formatDotenv:
+		values, err = parseDotenv(content)
+	case formatYAML:
+		values, err = parseYAML(content)
+	default:
+		return nil, fmt.Errorf("unknown %s %q", formatOption, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]provider.Secret, 0, len(values))
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		secrets = append(secrets, provider.Secret{
+			Key:   originalKey + "_" + sanitizeKey(key),
+			Value: values[key],
+		})
+	}
+
+	return secrets, nil
+}
+
+// parseDotenv parses KEY=VALUE lines, skipping empty lines and comments.
+// Lines may start with "export", values may be single quoted (taken literally)
+// or double quoted (with \n, \" and \\ escapes).
+func parseDotenv(content string) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || !dotenvKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid dotenv line %d: expected KEY=VALUE", i+1)
+		}
+
+		value, err := unquoteDotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid dotenv line %d: %w", i+1, err)
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+func unquoteDotenvValue(value string) (string, error) {
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		return value, nil
+	}
+
+	quote := value[0]
+	if len(value) < 2 || value[len(value)-1] != quote {
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+
+	value = value[1 : len(value)-1]
+	if quote == '\'' {
+		return value, nil
+	}
+
+	return strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value), nil
+}
+
+// parseYAML flattens a YAML map, nested maps are joined with "_", e.g. db.user becomes db_user.
+// Lists are kept as JSON, the same way as the nested values of expanded AWS secrets.
+func parseYAML(content string) (map[string]string, error) {
+	var document map[string]any
+	err := yaml.Unmarshal([]byte(content), &document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid yaml: %w", err)
+	}
+
+	values := make(map[string]string)
+	err = flattenYAML("", document, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func flattenYAML(prefix string, document map[string]any, values map[string]string) error {
+	for key, value := range document {
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch value := value.(type) {
+		case map[string]any:
+			err := flattenYAML(key, value, values)
+			if err != nil {
+				return err
+			}
+		case []any:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("failed to encode yaml list %s: %w", key, err)
+			}
+
+			values[key] = string(encoded)
+		case nil:
+			values[key] = ""
+		case string:
+			values[key] = value
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+
+	return nil
+}
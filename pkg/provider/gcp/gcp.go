@@ -16,28 +16,52 @@ package gcp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/crc32"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/bank-vaults/secret-init/pkg/common"
 	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/retry"
+	"github.com/bank-vaults/secret-init/pkg/utils"
 )
 
 const (
 	ProviderType      = "gcp"
 	referenceSelector = "gcp:secretmanager:"
 	versionRegex      = `.*/versions/(latest|\d+)$`
+
+	// ProjectEnv is the project of the secrets referenced by their name only
+	ProjectEnv = "GOOGLE_CLOUD_PROJECT"
 )
 
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = []string{ProjectEnv}
+
+// secretClient is the part of the Secret Manager client used by the provider.
+type secretClient interface {
+	AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error)
+	Close() error
+}
+
 type Provider struct {
-	client *secretmanager.Client
+	client      secretClient
+	retryPolicy retry.Policy
+	// ignoreMissingSecrets skips missing secrets instead of failing the load
+	ignoreMissingSecrets bool
 }
 
-func NewProvider(ctx context.Context, _ *common.Config) (provider.Provider, error) {
+func NewProvider(ctx context.Context, appConfig *common.Config) (provider.Provider, error) {
 	// This will automatically use the Application Default Credentials (ADC) strategy for authentication.
 	// If the GOOGLE_APPLICATION_CREDENTIALS environment variable is set,
 	// the client will use the service account key JSON file that the variable points to.
@@ -49,41 +73,106 @@ func NewProvider(ctx context.Context, _ *common.Config) (provider.Provider, erro
 		return nil, fmt.Errorf("failed to create secret manager client: %v", err)
 	}
 
-	return &Provider{client: client}, nil
+	return &Provider{
+		client: client,
+		retryPolicy: retry.Policy{
+			MaxAttempts: appConfig.RetryMaxAttempts,
+			BaseDelay:   appConfig.RetryBaseDelay,
+		},
+		ignoreMissingSecrets: appConfig.IgnoreMissingSecrets,
+	}, nil
 }
 
-func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
-	defer p.client.Close()
+// GetProviderName returns the type of the GCP Secret Manager provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
+// Close closes the client, it is kept open between loads.
+func (p *Provider) Close() error {
+	return p.client.Close()
+}
 
+func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
 	var secrets []provider.Secret
 
 	for _, path := range paths {
+		// Stop between the requests once the startup timeout has passed
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		split := strings.SplitN(path, "=", 2)
 		originalKey, secretID := split[0], split[1]
+		reference := secretID
 
 		// valid google cloud secret manager secret examples:
 		// gcp:secretmanager:projects/{PROJECT_ID}/secrets/{SECRET_NAME}
 		// gcp:secretmanager:projects/{PROJECT_ID}/secrets/{SECRET_NAME}/versions/{VERSION|latest}
+		// gcp:secretmanager:{SECRET_NAME}, in the project set by GOOGLE_CLOUD_PROJECT
+		// gcp:secretmanager:{SECRET_NAME}?version={latest|pinned:VERSION}
+		// gcp:secretmanager:{SECRET_NAME}#{FIELD}, a field of a JSON payload
 		secretID = strings.TrimPrefix(secretID, "gcp:secretmanager:")
 
+		secretID, field, hasField := splitField(secretID)
+		if hasField && field == "" {
+			return nil, fmt.Errorf("invalid reference for %s: missing field after #", originalKey)
+		}
+
+		secretID, err := translateVersion(secretID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for %s: %w", originalKey, err)
+		}
+
+		secretID, err = expandSecretName(secretID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for %s: %w", originalKey, err)
+		}
+
 		// Check if the path has version specified
-		secretID, err := handleVersion(secretID)
+		secretID, err = handleVersion(secretID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to handle secret ID version: %v", err)
 		}
 
-		secret, err := p.client.AccessSecretVersion(
-			ctx,
-			&secretmanagerpb.AccessSecretVersionRequest{
-				Name: secretID,
-			})
+		var secret *secretmanagerpb.AccessSecretVersionResponse
+		err = retry.Do(ctx, p.retryPolicy, func() error {
+			var err error
+			secret, err = p.client.AccessSecretVersion(
+				ctx,
+				&secretmanagerpb.AccessSecretVersionRequest{
+					Name: secretID,
+				})
+
+			return markRetryable(err)
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to access secret version from Google Cloud secret manager: %v", err)
+			err = markNotFound(err)
+			if provider.SkipMissing(p.ignoreMissingSecrets, originalKey, err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to access secret version from Google Cloud secret manager: %w", err)
+		}
+
+		err = verifyChecksum(secret.GetPayload())
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify secret %s: %w", secretID, err)
+		}
+
+		value := string(secret.Payload.GetData())
+		if hasField {
+			value, err = extractField(secret.Payload.GetData(), field)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get field of secret %s for %s: %w", secretID, originalKey, err)
+			}
 		}
 
 		secrets = append(secrets, provider.Secret{
-			Key:   originalKey,
-			Value: string(secret.Payload.GetData()),
+			Key:       originalKey,
+			Value:     value,
+			Provider:  ProviderType,
+			Reference: reference,
 		})
 	}
 
@@ -93,11 +182,106 @@ func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.
 // Example GCP prefixes:
 // gcp:secretmanager:projects/{PROJECT_ID}/secrets/{SECRET_NAME}
 // gcp:secretmanager:projects/{PROJECT_ID}/secrets/{SECRET_NAME}/versions/{VERSION|latest}
+// gcp:secretmanager:{SECRET_NAME}
 func Valid(envValue string) bool {
 	return strings.HasPrefix(envValue, referenceSelector)
 }
 
+// verifyChecksum guards against truncated or corrupted responses
+// by comparing the payload with the CRC32C checksum returned by Secret Manager.
+func verifyChecksum(payload *secretmanagerpb.SecretPayload) error {
+	// Older secret versions may not have a checksum, there is nothing to verify then
+	if payload.DataCrc32C == nil {
+		return nil
+	}
+
+	checksum := int64(crc32.Checksum(payload.GetData(), crc32.MakeTable(crc32.Castagnoli)))
+	if checksum != payload.GetDataCrc32C() {
+		return fmt.Errorf("data integrity check failed: checksum mismatch")
+	}
+
+	return nil
+}
+
+// splitField cuts the field from a reference, the options are kept,
+// e.g. {SECRET_NAME}#password?version=latest becomes {SECRET_NAME}?version=latest and password.
+func splitField(secretID string) (string, string, bool) {
+	name, rest, ok := strings.Cut(secretID, "#")
+	if !ok {
+		return secretID, "", false
+	}
+
+	field, options, hasOptions := strings.Cut(rest, "?")
+	if hasOptions {
+		name += "?" + options
+	}
+
+	return name, field, true
+}
+
+// extractField returns a top-level field of a JSON object payload.
+// String values are returned as is, any other value as raw JSON.
+func extractField(payload []byte, field string) (string, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return "", fmt.Errorf("payload is not a JSON object")
+	}
+
+	rawValue, ok := object[field]
+	if !ok {
+		return "", fmt.Errorf("field %s is missing", field)
+	}
+
+	var value string
+	if err := json.Unmarshal(rawValue, &value); err == nil {
+		return value, nil
+	}
+
+	return string(rawValue), nil
+}
+
+// expandSecretName expands a secret referenced by its name only (optionally with a version)
+// to the full resource name, using the project set by GOOGLE_CLOUD_PROJECT.
+func expandSecretName(secretID string) (string, error) {
+	if strings.HasPrefix(secretID, "projects/") {
+		return secretID, nil
+	}
+
+	project := os.Getenv(ProjectEnv)
+	if project == "" {
+		return "", fmt.Errorf("secret %s is not a full resource name and %s is not set", secretID, ProjectEnv)
+	}
+
+	return fmt.Sprintf("projects/%s/secrets/%s", project, secretID), nil
+}
+
+// translateVersion turns the version option into the version segment of the secret,
+// e.g. {SECRET_NAME}?version=pinned:3 becomes {SECRET_NAME}/versions/3.
+func translateVersion(secretID string) (string, error) {
+	secretID, version, ok, err := utils.SplitVersion(secretID)
+	if err != nil || !ok {
+		return secretID, err
+	}
+
+	if strings.Contains(secretID, "/versions/") {
+		return "", fmt.Errorf("version is set both in the reference and by the %s option", utils.VersionOption)
+	}
+
+	if version == "" {
+		version = "latest"
+	} else if number, err := strconv.Atoi(version); err != nil || number < 1 {
+		return "", fmt.Errorf("version %q must be a positive integer", version)
+	}
+
+	return secretID + "/versions/" + version, nil
+}
+
 func handleVersion(secretID string) (string, error) {
+	// The version keyword is matched case-insensitively, e.g. LATEST or Latest
+	if name, version, ok := strings.Cut(secretID, "/versions/"); ok && strings.EqualFold(version, "latest") {
+		secretID = name + "/versions/latest"
+	}
+
 	// If the version is correctly specified, return the secretID as is
 	match, err := regexp.MatchString(versionRegex, secretID)
 	if err != nil {
@@ -130,3 +314,22 @@ func handleVersion(secretID string) (string, error) {
 		return "", fmt.Errorf("invalid secret ID format: %s", secretID)
 	}
 }
+
+// Google Cloud APIs report transient failures through gRPC status codes.
+func markRetryable(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return retry.Retryable(err)
+	default:
+		return err
+	}
+}
+
+// markNotFound flags the errors of missing secrets and versions, so they can be skipped.
+func markNotFound(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return provider.NotFound(err)
+	}
+
+	return err
+}
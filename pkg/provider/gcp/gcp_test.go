@@ -0,0 +1,344 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// fakeSecretClient serves secret versions by their full resource name.
+type fakeSecretClient struct {
+	secrets map[string]string
+}
+
+func (c *fakeSecretClient) AccessSecretVersion(_ context.Context, req *secretmanagerpb.AccessSecretVersionRequest, _ ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	secret, ok := c.secrets[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret %s not found", req.GetName())
+	}
+
+	return &secretmanagerpb.AccessSecretVersionResponse{Payload: &secretmanagerpb.SecretPayload{Data: []byte(secret)}}, nil
+}
+
+func (c *fakeSecretClient) Close() error {
+	return nil
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("s3cr3t")
+	checksum := int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	wrongChecksum := checksum + 1
+
+	tests := []struct {
+		name    string
+		payload *secretmanagerpb.SecretPayload
+		err     error
+	}{
+		{
+			name:    "Matching checksum",
+			payload: &secretmanagerpb.SecretPayload{Data: data, DataCrc32C: &checksum},
+		},
+		{
+			name:    "Missing checksum",
+			payload: &secretmanagerpb.SecretPayload{Data: data},
+		},
+		{
+			name:    "Checksum mismatch",
+			payload: &secretmanagerpb.SecretPayload{Data: data, DataCrc32C: &wrongChecksum},
+			err:     fmt.Errorf("data integrity check failed: checksum mismatch"),
+		},
+		{
+			name:    "Truncated payload",
+			payload: &secretmanagerpb.SecretPayload{Data: data[:3], DataCrc32C: &checksum},
+			err:     fmt.Errorf("data integrity check failed: checksum mismatch"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			err := verifyChecksum(ttp.payload)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		secretID     string
+		wantSecretID string
+		err          error
+	}{
+		{
+			name:         "Latest version",
+			secretID:     "projects/test/secrets/mysql/versions/latest",
+			wantSecretID: "projects/test/secrets/mysql/versions/latest",
+		},
+		{
+			name:         "Uppercase latest version",
+			secretID:     "projects/test/secrets/mysql/versions/LATEST",
+			wantSecretID: "projects/test/secrets/mysql/versions/latest",
+		},
+		{
+			name:         "Mixed-case latest version",
+			secretID:     "projects/test/secrets/mysql/versions/Latest",
+			wantSecretID: "projects/test/secrets/mysql/versions/latest",
+		},
+		{
+			name:         "Numbered version",
+			secretID:     "projects/test/secrets/mysql/versions/3",
+			wantSecretID: "projects/test/secrets/mysql/versions/3",
+		},
+		{
+			name:         "Missing version",
+			secretID:     "projects/test/secrets/mysql",
+			wantSecretID: "projects/test/secrets/mysql/versions/latest",
+		},
+		{
+			name:     "Invalid secret ID",
+			secretID: "projects/test",
+			err:      fmt.Errorf("invalid secret ID format: projects/test"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			secretID, err := handleVersion(ttp.secretID)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, ttp.wantSecretID, secretID, "Unexpected secret ID")
+			}
+		})
+	}
+}
+
+func TestTranslateVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		secretID     string
+		wantSecretID string
+		err          error
+	}{
+		{
+			name:         "Reference without version option",
+			secretID:     "projects/test/secrets/mysql/versions/2",
+			wantSecretID: "projects/test/secrets/mysql/versions/2",
+		},
+		{
+			name:         "Latest version",
+			secretID:     "projects/test/secrets/mysql?version=latest",
+			wantSecretID: "projects/test/secrets/mysql/versions/latest",
+		},
+		{
+			name:         "Pinned version",
+			secretID:     "projects/test/secrets/mysql?version=pinned:3",
+			wantSecretID: "projects/test/secrets/mysql/versions/3",
+		},
+		{
+			name:         "Pinned version of a secret referenced by name",
+			secretID:     "mysql?version=pinned:3",
+			wantSecretID: "mysql/versions/3",
+		},
+		{
+			name:     "Version set twice",
+			secretID: "projects/test/secrets/mysql/versions/2?version=pinned:3",
+			err:      fmt.Errorf("version is set both in the reference and by the version option"),
+		},
+		{
+			name:     "Non-numeric pinned version",
+			secretID: "projects/test/secrets/mysql?version=pinned:abc",
+			err:      fmt.Errorf("version \"abc\" must be a positive integer"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			secretID, err := translateVersion(ttp.secretID)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, ttp.wantSecretID, secretID, "Unexpected secret ID")
+			}
+		})
+	}
+}
+
+func TestExpandSecretName(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		secretID     string
+		wantSecretID string
+		err          error
+	}{
+		{
+			name:         "Full resource name",
+			secretID:     "projects/test/secrets/mysql/versions/3",
+			wantSecretID: "projects/test/secrets/mysql/versions/3",
+		},
+		{
+			name:         "Short form with project",
+			env:          map[string]string{ProjectEnv: "test"},
+			secretID:     "mysql",
+			wantSecretID: "projects/test/secrets/mysql",
+		},
+		{
+			name:         "Short form with version",
+			env:          map[string]string{ProjectEnv: "test"},
+			secretID:     "mysql/versions/3",
+			wantSecretID: "projects/test/secrets/mysql/versions/3",
+		},
+		{
+			name:     "Short form without project",
+			secretID: "mysql",
+			err:      fmt.Errorf("secret mysql is not a full resource name and GOOGLE_CLOUD_PROJECT is not set"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			for envKey, envVal := range ttp.env {
+				os.Setenv(envKey, envVal)
+			}
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			secretID, err := expandSecretName(ttp.secretID)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecretID, secretID, "Unexpected secret ID")
+		})
+	}
+}
+
+func TestLoadSecretsIgnoreMissing(t *testing.T) {
+	const name = "projects/my-project/secrets/mysql/versions/latest"
+
+	p := &Provider{
+		client: &fakeSecretClient{secrets: map[string]string{name: "s3cr3t"}},
+	}
+
+	paths := []string{
+		"MYSQL_PASSWORD=gcp:secretmanager:projects/my-project/secrets/mysql",
+		"MISSING_PASSWORD=gcp:secretmanager:projects/my-project/secrets/missing",
+	}
+
+	_, err := p.LoadSecrets(context.Background(), paths)
+	assert.EqualError(t, err, "failed to access secret version from Google Cloud secret manager: rpc error: code = NotFound desc = secret projects/my-project/secrets/missing/versions/latest not found", "Unexpected error message")
+
+	p.ignoreMissingSecrets = true
+	secrets, err := p.LoadSecrets(context.Background(), paths)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{
+		{Key: "MYSQL_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "gcp:secretmanager:projects/my-project/secrets/mysql"},
+	}, secrets, "Missing secrets should be skipped")
+}
+
+func TestLoadSecretsField(t *testing.T) {
+	client := &fakeSecretClient{secrets: map[string]string{
+		"projects/my-project/secrets/db/versions/latest":    `{"user":"root","password":"s3cr3t","port":5432}`,
+		"projects/my-project/secrets/db/versions/2":         `{"user":"root","password":"old-s3cr3t"}`,
+		"projects/my-project/secrets/plain/versions/latest": "s3cr3t",
+	}}
+
+	tests := []struct {
+		name      string
+		reference string
+		wantValue string
+		err       error
+	}{
+		{
+			name:      "String field of a flat JSON payload",
+			reference: "gcp:secretmanager:projects/my-project/secrets/db#password",
+			wantValue: "s3cr3t",
+		},
+		{
+			name:      "Non-string field is returned as JSON",
+			reference: "gcp:secretmanager:projects/my-project/secrets/db#port",
+			wantValue: "5432",
+		},
+		{
+			name:      "Field of a pinned version",
+			reference: "gcp:secretmanager:projects/my-project/secrets/db#password?version=pinned:2",
+			wantValue: "old-s3cr3t",
+		},
+		{
+			name:      "Whole payload without a field",
+			reference: "gcp:secretmanager:projects/my-project/secrets/plain",
+			wantValue: "s3cr3t",
+		},
+		{
+			name:      "Missing field",
+			reference: "gcp:secretmanager:projects/my-project/secrets/db#host",
+			err:       fmt.Errorf("failed to get field of secret projects/my-project/secrets/db/versions/latest for MYSQL: field host is missing"),
+		},
+		{
+			name:      "Non-JSON payload",
+			reference: "gcp:secretmanager:projects/my-project/secrets/plain#password",
+			err:       fmt.Errorf("failed to get field of secret projects/my-project/secrets/plain/versions/latest for MYSQL: payload is not a JSON object"),
+		},
+		{
+			name:      "Empty field",
+			reference: "gcp:secretmanager:projects/my-project/secrets/db#",
+			err:       fmt.Errorf("invalid reference for MYSQL: missing field after #"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			p := &Provider{client: client}
+
+			secrets, err := p.LoadSecrets(context.Background(), []string{"MYSQL=" + ttp.reference})
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, []provider.Secret{
+				{Key: "MYSQL", Value: ttp.wantValue, Provider: ProviderType, Reference: ttp.reference},
+			}, secrets, "Unexpected secrets")
+		})
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/utils"
+)
+
+const (
+	ProviderType      = "gcs"
+	referenceSelector = "gcs:"
+)
+
+// EnvVars lists the environment variables the provider is configured with, it has none of its own:
+// the client authenticates with the Application Default Credentials, the same way as the GCP provider.
+var EnvVars []string
+
+var errObjectNotExist = errors.New("object not found")
+
+// objectStore opens objects for reading, it is the subset of the storage client used by the provider.
+type objectStore interface {
+	NewReader(ctx context.Context, bucket string, object string) (io.ReadCloser, error)
+}
+
+type storageClient struct {
+	client *storage.Client
+}
+
+func (c storageClient) NewReader(ctx context.Context, bucket string, object string) (io.ReadCloser, error) {
+	reader, err := c.client.Bucket(bucket).Object(object).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+		return nil, fmt.Errorf("%w: %w", errObjectNotExist, err)
+	}
+
+	return reader, err
+}
+
+func (c storageClient) Close() error {
+	return c.client.Close()
+}
+
+type Provider struct {
+	store          objectStore
+	maxSecretBytes int64
+}
+
+func NewProvider(ctx context.Context, appConfig *common.Config) (provider.Provider, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+
+	return &Provider{
+		store:          storageClient{client: client},
+		maxSecretBytes: int64(appConfig.MaxSecretBytes),
+	}, nil
+}
+
+// GetProviderName returns the type of the Google Cloud Storage provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
+// Close closes the storage client, it is kept open between loads.
+func (p *Provider) Close() error {
+	if closer, ok := p.store.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
+	var secrets []provider.Secret
+	for _, path := range paths {
+		// Stop between the requests once the startup timeout has passed
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		split := strings.SplitN(path, "=", 2)
+		originalKey, secretRef := split[0], split[1]
+
+		// valid google cloud storage object examples:
+		// gcs:{BUCKET}/{OBJECT}, e.g. gcs:my-secrets/service-account.json
+		bucket, object, ok := strings.Cut(strings.TrimPrefix(secretRef, referenceSelector), "/")
+		if !ok || bucket == "" || object == "" {
+			return nil, fmt.Errorf("invalid reference for %s: expected gcs:<bucket>/<object>", originalKey)
+		}
+
+		value, err := p.readObject(ctx, bucket, object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get object gs://%s/%s for %s: %w", bucket, object, originalKey, err)
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:       originalKey,
+			Value:     string(value),
+			Provider:  ProviderType,
+			Reference: secretRef,
+		})
+	}
+
+	return secrets, nil
+}
+
+func (p *Provider) readObject(ctx context.Context, bucket string, object string) ([]byte, error) {
+	reader, err := p.store.NewReader(ctx, bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return utils.ReadLimited(reader, p.maxSecretBytes)
+}
+
+// Example Google Cloud Storage prefix:
+// gcs:my-secrets/service-account.json
+func Valid(envValue string) bool {
+	return strings.HasPrefix(envValue, referenceSelector)
+}
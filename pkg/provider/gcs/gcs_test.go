@@ -0,0 +1,128 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// fakeObjectStore serves objects under {bucket}/{object}
+type fakeObjectStore struct {
+	objects map[string]string
+}
+
+func (s *fakeObjectStore) NewReader(ctx context.Context, bucket string, object string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	content, ok := s.objects[bucket+"/"+object]
+	if !ok {
+		return nil, fmt.Errorf("%w: %w", errObjectNotExist, storage.ErrObjectNotExist)
+	}
+
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("gcs:my-secrets/service-account.json"))
+	assert.False(t, Valid("gcp:secretmanager:projects/123456789012/secrets/db-password"))
+	assert.False(t, Valid("s3:my-secrets/service-account.json"))
+}
+
+func TestLoadSecrets(t *testing.T) {
+	store := &fakeObjectStore{objects: map[string]string{
+		"my-secrets/service-account.json": `{"type":"service_account"}`,
+		"my-secrets/tls/tls.crt":          "-----BEGIN CERTIFICATE-----",
+	}}
+
+	tests := []struct {
+		name           string
+		maxSecretBytes int64
+		paths          []string
+		wantSecrets    []provider.Secret
+		err            error
+	}{
+		{
+			name: "Load objects",
+			paths: []string{
+				"SERVICE_ACCOUNT=gcs:my-secrets/service-account.json",
+				"TLS_CRT=gcs:my-secrets/tls/tls.crt",
+			},
+			wantSecrets: []provider.Secret{
+				{Key: "SERVICE_ACCOUNT", Value: `{"type":"service_account"}`, Provider: ProviderType, Reference: "gcs:my-secrets/service-account.json"},
+				{Key: "TLS_CRT", Value: "-----BEGIN CERTIFICATE-----", Provider: ProviderType, Reference: "gcs:my-secrets/tls/tls.crt"},
+			},
+		},
+		{
+			name:           "Load object within the size limit",
+			maxSecretBytes: 27,
+			paths:          []string{"TLS_CRT=gcs:my-secrets/tls/tls.crt"},
+			wantSecrets: []provider.Secret{
+				{Key: "TLS_CRT", Value: "-----BEGIN CERTIFICATE-----", Provider: ProviderType, Reference: "gcs:my-secrets/tls/tls.crt"},
+			},
+		},
+		{
+			name:           "Fail on object over the size limit",
+			maxSecretBytes: 10,
+			paths:          []string{"TLS_CRT=gcs:my-secrets/tls/tls.crt"},
+			err:            fmt.Errorf("failed to get object gs://my-secrets/tls/tls.crt for TLS_CRT: value exceeds the limit of 10 bytes set by SECRET_INIT_MAX_SECRET_BYTES"),
+		},
+		{
+			name:  "Fail on missing object",
+			paths: []string{"TLS_KEY=gcs:my-secrets/tls/tls.key"},
+			err:   fmt.Errorf("failed to get object gs://my-secrets/tls/tls.key for TLS_KEY: object not found: storage: object doesn't exist"),
+		},
+		{
+			name:  "Fail on missing object name",
+			paths: []string{"TLS_KEY=gcs:my-secrets"},
+			err:   fmt.Errorf("invalid reference for TLS_KEY: expected gcs:<bucket>/<object>"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			p := &Provider{store: store, maxSecretBytes: ttp.maxSecretBytes}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestLoadSecretsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &Provider{store: &fakeObjectStore{}}
+	_, err := p.LoadSecrets(ctx, []string{"TLS_CRT=gcs:my-secrets/tls/tls.crt"})
+	assert.ErrorIs(t, err, context.Canceled, "Loading must stop once the context is done")
+}
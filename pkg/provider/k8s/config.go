@@ -0,0 +1,63 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// Upper bound for a single API request, so an unreachable API server can't hang the startup
+	defaultRequestTimeout = 10 * time.Second
+
+	KubeconfigEnv = "KUBECONFIG"
+)
+
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = []string{KubeconfigEnv}
+
+type Config struct {
+	restConfig *rest.Config
+}
+
+func LoadConfig() (*Config, error) {
+	// Prefer the in-cluster config, mounted into every pod by Kubernetes
+	restConfig, err := rest.InClusterConfig()
+	if errors.Is(err, rest.ErrNotInCluster) {
+		kubeconfig, ok := os.LookupEnv(KubeconfigEnv)
+		if !ok {
+			return nil, fmt.Errorf("not running in a cluster and %s is not set", KubeconfigEnv)
+		}
+
+		restConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig %s: %w", kubeconfig, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+	}
+
+	if restConfig.Timeout == 0 {
+		restConfig.Timeout = defaultRequestTimeout
+	}
+
+	return &Config{restConfig: restConfig}, nil
+}
@@ -0,0 +1,131 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const (
+	ProviderType      = "k8s"
+	referenceSelector = `^k8s:[^/]+/[^#]+#.+$`
+)
+
+type Provider struct {
+	client kubernetes.Interface
+}
+
+func NewProvider(_ context.Context, _ *common.Config) (provider.Provider, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	return &Provider{client: client}, nil
+}
+
+// GetProviderName returns the type of the Kubernetes provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
+func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
+	var secrets []provider.Secret
+
+	// Secrets holding multiple referenced keys are only fetched once
+	k8sSecrets := make(map[string]*corev1.Secret)
+
+	for _, path := range paths {
+		split := strings.SplitN(path, "=", 2)
+		originalKey, secretRef := split[0], split[1]
+
+		// valid kubernetes secret examples:
+		// k8s:{NAMESPACE}/{SECRET_NAME}#{KEY}
+		namespace, name, key, err := parseReference(secretRef)
+		if err != nil {
+			return nil, err
+		}
+
+		k8sSecret, ok := k8sSecrets[namespace+"/"+name]
+		if !ok {
+			k8sSecret, err = p.client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				switch {
+				case apierrors.IsForbidden(err):
+					return nil, fmt.Errorf("access to secret %s/%s is forbidden, check the RBAC permissions: %w", namespace, name, err)
+				case apierrors.IsNotFound(err):
+					return nil, fmt.Errorf("secret %s/%s not found: %w", namespace, name, err)
+				default:
+					return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
+				}
+			}
+
+			k8sSecrets[namespace+"/"+name] = k8sSecret
+		}
+
+		// The client already decodes the base64 encoded data
+		value, ok := k8sSecret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in secret %s/%s", key, namespace, name)
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:       originalKey,
+			Value:     string(value),
+			Provider:  ProviderType,
+			Reference: secretRef,
+		})
+	}
+
+	return secrets, nil
+}
+
+// Example Kubernetes secret reference:
+// k8s:{NAMESPACE}/{SECRET_NAME}#{KEY}
+func Valid(envValue string) bool {
+	return regexp.MustCompile(referenceSelector).MatchString(envValue)
+}
+
+func parseReference(secretRef string) (string, string, string, error) {
+	secretRef = strings.TrimPrefix(secretRef, "k8s:")
+
+	secretPath, key, ok := strings.Cut(secretRef, "#")
+	if !ok || key == "" {
+		return "", "", "", fmt.Errorf("secret key not defined in reference: %s", secretRef)
+	}
+
+	namespace, name, ok := strings.Cut(secretPath, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", "", fmt.Errorf("invalid secret reference, expected namespace/name: %s", secretRef)
+	}
+
+	return namespace, name, key, nil
+}
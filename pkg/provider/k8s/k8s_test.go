@@ -0,0 +1,104 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("k8s:default/mysql#password"))
+	assert.False(t, Valid("k8s:default/mysql"))
+	assert.False(t, Valid("k8s:mysql#password"))
+	assert.False(t, Valid("vault:secret/data/mysql#password"))
+}
+
+func TestLoadSecrets(t *testing.T) {
+	tests := []struct {
+		name        string
+		paths       []string
+		forbidden   bool
+		err         error
+		wantSecrets []provider.Secret
+	}{
+		{
+			name: "Load secrets successfully",
+			paths: []string{
+				"MYSQL_USERNAME=k8s:default/mysql#username",
+				"MYSQL_PASSWORD=k8s:default/mysql#password",
+			},
+			wantSecrets: []provider.Secret{
+				{Key: "MYSQL_USERNAME", Value: "root", Provider: ProviderType, Reference: "k8s:default/mysql#username"},
+				{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t", Provider: ProviderType, Reference: "k8s:default/mysql#password"},
+			},
+		},
+		{
+			name:  "Fail to load missing key",
+			paths: []string{"MYSQL_PORT=k8s:default/mysql#port"},
+			err:   fmt.Errorf("key port not found in secret default/mysql"),
+		},
+		{
+			name:  "Fail to load missing secret",
+			paths: []string{"MYSQL_PASSWORD=k8s:default/postgres#password"},
+			err:   fmt.Errorf("secret default/postgres not found: secrets \"postgres\" not found"),
+		},
+		{
+			name:      "Fail to load forbidden secret",
+			paths:     []string{"MYSQL_PASSWORD=k8s:default/mysql#password"},
+			forbidden: true,
+			err:       fmt.Errorf("access to secret default/mysql is forbidden, check the RBAC permissions: secrets \"mysql\" is forbidden: denied"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+				Data: map[string][]byte{
+					"username": []byte("root"),
+					"password": []byte("3xtr3ms3cr3t"),
+				},
+			})
+			if ttp.forbidden {
+				client.PrependReactor("get", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "mysql", fmt.Errorf("denied"))
+				})
+			}
+
+			provider := Provider{client: client}
+			secrets, err := provider.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.ElementsMatch(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+			}
+		})
+	}
+}
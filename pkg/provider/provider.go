@@ -16,6 +16,11 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
 
 	"github.com/bank-vaults/secret-init/pkg/common"
 )
@@ -24,16 +29,163 @@ type Factory struct {
 	ProviderType string
 	Validator    func(envValue string) bool
 	Create       func(ctx context.Context, cfg *common.Config) (Provider, error)
+	// EnvVars lists the environment variables the provider is configured with
+	EnvVars []string
 }
 
 // Provider is an interface for securely loading secrets based on environment variables.
 type Provider interface {
+	// GetProviderName returns the type of the provider, as registered in its factory
+	GetProviderName() string
 	// LoadSecrets loads secrets from the provider based on the given paths
 	LoadSecrets(ctx context.Context, paths []string) ([]Secret, error)
 }
 
+// Closer is implemented by providers holding resources (e.g. a client connection),
+// they are released once the provider is no longer used, not after every load.
+type Closer interface {
+	Close() error
+}
+
+// Revoker is implemented by providers which may revoke their token while loading secrets (e.g. VAULT_REVOKE_TOKEN),
+// a provider whose token is revoked can't be reused and is created again by the next load.
+type Revoker interface {
+	Revoked() bool
+}
+
+// ErrSecretNotFound is matched by the errors of secrets missing from the backend,
+// see NotFound and SkipMissing.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// notFoundError marks an error of the backend as a missing secret, without changing its message.
+type notFoundError struct {
+	err error
+}
+
+func (e *notFoundError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notFoundError) Unwrap() []error {
+	return []error{ErrSecretNotFound, e.err}
+}
+
+// NotFound marks the error as a missing secret, so it matches ErrSecretNotFound.
+func NotFound(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &notFoundError{err: err}
+}
+
+// SkipMissing reports whether the secret of the key is to be skipped,
+// because it is missing and missing secrets are ignored (SECRET_INIT_IGNORE_MISSING_SECRETS).
+func SkipMissing(ignoreMissing bool, key string, err error) bool {
+	if !ignoreMissing || !errors.Is(err, ErrSecretNotFound) {
+		return false
+	}
+
+	slog.Warn(fmt.Errorf("secret is missing, skipping it: %w", err).Error(), slog.String("key", key))
+
+	return true
+}
+
 // Secret holds Provider-specific secret data.
 type Secret struct {
 	Key   string
 	Value string
+	// TTL is the remaining lifetime reported by the backend, zero if unknown
+	TTL time.Duration
+	// CreatedAt is when the loaded version of the secret was created, zero if unknown
+	CreatedAt time.Time
+	// Binary secrets are only delivered as files, never as env vars
+	Binary bool
+	// FilePath is where the secret is delivered as a file, the env var is set to the path then
+	FilePath string
+	// Provider and Reference record where the secret was loaded from, for diagnostics only
+	Provider  string
+	Reference string
+}
+
+// SetOrigin records the provider and the reference the secrets were loaded from.
+func SetOrigin(secrets []Secret, providerName string, reference string) {
+	for i := range secrets {
+		secrets[i].Provider = providerName
+		secrets[i].Reference = reference
+	}
+}
+
+type restartKey struct{}
+
+// WithRestart returns a context through which providers can request
+// a restart of the entrypoint process, e.g. when their secrets have changed.
+func WithRestart(ctx context.Context, restart func()) context.Context {
+	return context.WithValue(ctx, restartKey{}, restart)
+}
+
+// RequestRestart asks for a restart of the entrypoint process with reloaded secrets.
+// It does nothing if the context doesn't support restarts.
+func RequestRestart(ctx context.Context) {
+	if restart, ok := ctx.Value(restartKey{}).(func()); ok {
+		restart()
+	}
+}
+
+type signalKey struct{}
+
+// WithSignal returns a context through which providers can signal the entrypoint process,
+// e.g. when the leases of its secrets are renewed.
+func WithSignal(ctx context.Context, signal func(sig os.Signal)) context.Context {
+	return context.WithValue(ctx, signalKey{}, signal)
+}
+
+// SignalFunc returns the function signaling the entrypoint process, which is the one running at the time of the call.
+// It does nothing if the context doesn't support signals.
+func SignalFunc(ctx context.Context) func(sig os.Signal) {
+	if signal, ok := ctx.Value(signalKey{}).(func(sig os.Signal)); ok {
+		return signal
+	}
+
+	return func(os.Signal) {}
+}
+
+type lifetimeKey struct{}
+
+// WithLifetime returns a context for a single load, through which providers get the context
+// their goroutines outliving the load run with (e.g. file watchers and token refreshers).
+// The load itself may be cancelled before, e.g. by fail fast once another provider failed.
+func WithLifetime(ctx context.Context, lifetime context.Context) context.Context {
+	return context.WithValue(ctx, lifetimeKey{}, lifetime)
+}
+
+// Lifetime returns the context the goroutines of a provider outliving the load have to run with.
+// It is the context itself if it has no lifetime of its own.
+func Lifetime(ctx context.Context) context.Context {
+	if lifetime, ok := ctx.Value(lifetimeKey{}).(context.Context); ok {
+		return lifetime
+	}
+
+	return ctx
+}
+
+type cleanupKey struct{}
+
+// WithCleanup returns a context through which providers can register cleanups
+// to run once the entrypoint process has exited, e.g. revoking credentials the process still uses.
+func WithCleanup(ctx context.Context, register func(cleanup func())) context.Context {
+	return context.WithValue(ctx, cleanupKey{}, register)
+}
+
+// RegisterCleanup defers the cleanup until the entrypoint process has exited.
+// It reports false if the context doesn't support cleanups, the caller should clean up right away then.
+func RegisterCleanup(ctx context.Context, cleanup func()) bool {
+	register, ok := ctx.Value(cleanupKey{}).(func(cleanup func()))
+	if !ok {
+		return false
+	}
+
+	register(cleanup)
+
+	return true
 }
@@ -0,0 +1,121 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package renewer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/bank-vaults/vault-sdk/vault"
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// Daemon renews the leases of the secrets in daemon mode, it is shared by the vault and bao providers.
+// The entrypoint process is told about renewals with the renew signal and stopped once a lease can't be renewed.
+type Daemon struct {
+	Client *vault.Client
+	// Signal sends a signal to the entrypoint process, see provider.SignalFunc
+	Signal func(os.Signal)
+
+	StopSignal  syscall.Signal
+	StopTimeout time.Duration
+	// RenewSignal is sent on every renewal, so the process can reconnect, zero sends nothing
+	RenewSignal syscall.Signal
+	// Done stops the watchers of the leases once it is closed, see ForLoad. A nil channel never stops them.
+	Done <-chan struct{}
+}
+
+// ForLoad returns the renewer of the leases acquired by a single load. Their watchers are stopped
+// along with the cleanups of the load, i.e. once the process using its secrets has been replaced or has exited,
+// so an expiring lease of a replaced load never stops the process started after it.
+func (r Daemon) ForLoad(ctx context.Context) Daemon {
+	done := make(chan struct{})
+	if provider.RegisterCleanup(ctx, func() { close(done) }) {
+		r.Done = done
+	}
+
+	return r
+}
+
+func (r Daemon) Renew(path string, secret *vaultapi.Secret) error {
+	watcherInput := vaultapi.LifetimeWatcherInput{Secret: secret}
+	watcher, err := r.Client.RawClient().NewLifetimeWatcher(&watcherInput)
+	if err != nil {
+		return fmt.Errorf("failed to create lifetime watcher: %w", err)
+	}
+
+	go watcher.Start()
+
+	go func() {
+		defer watcher.Stop()
+		for {
+			select {
+			case renewOutput := <-watcher.RenewCh():
+				r.renewed(path, renewOutput.Secret)
+			case <-r.Done:
+				return
+			case doneError := <-watcher.DoneCh():
+				if !secret.Renewable {
+					leaseDuration := time.Duration(secret.LeaseDuration) * time.Second
+					select {
+					case <-r.Done:
+						return
+					case <-time.After(leaseDuration):
+					}
+
+					slog.Info("secret lease has expired", slog.String("path", path), slog.Duration("lease-duration", leaseDuration))
+				}
+
+				slog.Info("secret renewal has stopped, sending stop signal to process", slog.String("path", path), slog.String("signal", r.StopSignal.String()), slog.Any("done-error", doneError))
+
+				r.stopProcess()
+
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// renewed tells the process about the renewal with the renew signal, if any.
+func (r Daemon) renewed(path string, secret *vaultapi.Secret) {
+	slog.Info("secret renewed", slog.String("path", path), slog.Duration("lease-duration", time.Duration(secret.LeaseDuration)*time.Second))
+
+	if r.RenewSignal != 0 {
+		slog.Info("sending renew signal to process", slog.String("path", path), slog.String("signal", r.RenewSignal.String()))
+		r.Signal(r.RenewSignal)
+	}
+}
+
+// stopProcess sends the stop signal, then kills the process once the timeout has passed.
+// The process isn't killed if the load is replaced in the meantime, the signal would reach the new process.
+func (r Daemon) stopProcess() {
+	r.Signal(r.StopSignal)
+
+	select {
+	case <-r.Done:
+		slog.Info("secrets reloaded before the stop timeout, not killing the process")
+	case timeout := <-time.After(r.StopTimeout):
+		slog.Info("killing process due to stop timeout", slog.Time("timeout", timeout))
+		r.Signal(syscall.SIGKILL)
+	}
+}
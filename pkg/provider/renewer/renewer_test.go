@@ -0,0 +1,89 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package renewer
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// recordSignals returns a signal function recording the sent signals
+func recordSignals() (func(os.Signal), chan os.Signal) {
+	sigs := make(chan os.Signal, 2)
+
+	return func(sig os.Signal) { sigs <- sig }, sigs
+}
+
+func TestDaemon_StopProcess(t *testing.T) {
+	signal, sigs := recordSignals()
+	renewer := Daemon{
+		Signal:      signal,
+		StopSignal:  syscall.SIGTERM,
+		StopTimeout: 50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	renewer.stopProcess()
+
+	assert.Equal(t, syscall.SIGTERM, <-sigs, "Unexpected stop signal")
+	assert.Equal(t, syscall.SIGKILL, <-sigs, "Unexpected kill signal")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "Process killed before the timeout")
+}
+
+func TestDaemon_StopProcessReplacedLoad(t *testing.T) {
+	var cleanups []func()
+	ctx := provider.WithCleanup(context.Background(), func(cleanup func()) { cleanups = append(cleanups, cleanup) })
+
+	signal, sigs := recordSignals()
+	renewer := Daemon{
+		Signal:      signal,
+		StopSignal:  syscall.SIGTERM,
+		StopTimeout: time.Hour,
+	}.ForLoad(ctx)
+	assert.Len(t, cleanups, 1, "The renewer should be stopped along with the load")
+
+	stopped := make(chan struct{})
+	go func() {
+		renewer.stopProcess()
+		close(stopped)
+	}()
+	assert.Equal(t, syscall.SIGTERM, <-sigs, "Unexpected stop signal")
+
+	// Replacing the load must not kill the process started after it
+	cleanups[0]()
+	<-stopped
+	assert.Empty(t, sigs, "The process of the new load should not be killed")
+}
+
+func TestDaemon_Renewed(t *testing.T) {
+	secret := &vaultapi.Secret{LeaseDuration: 3600}
+
+	signal, sigs := recordSignals()
+	renewer := Daemon{Signal: signal}
+	renewer.renewed("database/creds/app", secret)
+	assert.Empty(t, sigs, "No signal should be sent without a renew signal")
+
+	renewer.RenewSignal = syscall.SIGUSR1
+	renewer.renewed("database/creds/app", secret)
+	assert.Equal(t, syscall.SIGUSR1, <-sigs, "Unexpected renew signal")
+}
@@ -0,0 +1,196 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sops
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const (
+	ProviderType      = "sops"
+	referenceSelector = "sops:"
+
+	BinaryEnv = "SOPS_BINARY"
+
+	defaultBinary = "sops"
+)
+
+// EnvVars lists the environment variables the provider is configured with,
+// the keys are picked up by sops itself, e.g. from SOPS_AGE_KEY_FILE or the cloud KMS credentials.
+var EnvVars = []string{BinaryEnv}
+
+// decryptFunc returns the decrypted content of a SOPS file as JSON.
+type decryptFunc func(ctx context.Context, path string) ([]byte, error)
+
+type Provider struct {
+	decrypt decryptFunc
+	// Decrypted files by path, so every file is decrypted (and its data key unwrapped) once per run
+	trees map[string]map[string]any
+}
+
+func NewProvider(_ context.Context, _ *common.Config) (provider.Provider, error) {
+	binary := cmp.Or(os.Getenv(BinaryEnv), defaultBinary)
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, fmt.Errorf("failed to find sops binary %s: %w", binary, err)
+	}
+
+	return &Provider{
+		decrypt: commandDecrypter(binary),
+		trees:   make(map[string]map[string]any),
+	}, nil
+}
+
+func commandDecrypter(binary string) decryptFunc {
+	return func(ctx context.Context, path string) ([]byte, error) {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, binary, "--decrypt", "--output-type", "json", path)
+		cmd.Stderr = &stderr
+
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		return output, nil
+	}
+}
+
+// GetProviderName returns the type of the SOPS provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
+func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
+	var secrets []provider.Secret
+	for _, path := range paths {
+		split := strings.SplitN(path, "=", 2)
+		originalKey, secretRef := split[0], split[1]
+
+		// valid sops references:
+		// sops:{ABSOLUTE_FILE_PATH}#{KEY_PATH}, e.g. sops:/secrets/app.enc.yaml#db.password
+		file, keyPath, ok := strings.Cut(strings.TrimPrefix(secretRef, referenceSelector), "#")
+		if !ok || !filepath.IsAbs(file) || keyPath == "" {
+			return nil, fmt.Errorf("invalid reference for %s: expected sops:<absolute file path>#<key path>", originalKey)
+		}
+
+		tree, err := p.loadTree(ctx, filepath.Clean(file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s for %s: %w", file, originalKey, err)
+		}
+
+		value, err := lookup(tree, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s from %s for %s: %w", keyPath, file, originalKey, err)
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:       originalKey,
+			Value:     value,
+			Provider:  ProviderType,
+			Reference: secretRef,
+		})
+	}
+
+	return secrets, nil
+}
+
+func (p *Provider) loadTree(ctx context.Context, file string) (map[string]any, error) {
+	if tree, ok := p.trees[file]; ok {
+		return tree, nil
+	}
+
+	content, err := p.decrypt(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	// Keep numbers as written, e.g. a port of 5432 must not become 5432.0
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted content: %w", err)
+	}
+
+	p.trees[file] = tree
+
+	return tree, nil
+}
+
+// lookup walks the dotted key path, e.g. db.hosts.0, through maps and lists of the decrypted tree.
+func lookup(tree map[string]any, keyPath string) (string, error) {
+	var node any = tree
+	for _, key := range strings.Split(keyPath, ".") {
+		switch value := node.(type) {
+		case map[string]any:
+			child, ok := value[key]
+			if !ok {
+				return "", fmt.Errorf("key %s not found", key)
+			}
+			node = child
+
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(value) {
+				return "", fmt.Errorf("index %s out of range", key)
+			}
+			node = value[index]
+
+		default:
+			return "", fmt.Errorf("key %s not found", key)
+		}
+	}
+
+	switch value := node.(type) {
+	case string:
+		return value, nil
+
+	case json.Number:
+		return value.String(), nil
+
+	case bool:
+		return strconv.FormatBool(value), nil
+
+	case nil:
+		return "", nil
+
+	default:
+		// Maps and lists are loaded as JSON
+		content, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal value: %w", err)
+		}
+
+		return string(content), nil
+	}
+}
+
+// Example SOPS prefix:
+// sops:/secrets/app.enc.yaml#db.password
+func Valid(envValue string) bool {
+	return strings.HasPrefix(envValue, referenceSelector)
+}
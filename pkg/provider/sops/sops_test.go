@@ -0,0 +1,122 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sops
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// fakeDecrypter serves the decrypted content of files and counts the decryptions per file
+type fakeDecrypter struct {
+	files map[string]string
+	calls map[string]int
+}
+
+func (d *fakeDecrypter) decrypt(_ context.Context, path string) ([]byte, error) {
+	d.calls[path]++
+
+	content, ok := d.files[path]
+	if !ok {
+		return nil, fmt.Errorf("open %s: no such file or directory", path)
+	}
+
+	return []byte(content), nil
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("sops:/secrets/app.enc.yaml#db.password"))
+	assert.False(t, Valid("file:/secrets/app.enc.yaml"))
+}
+
+func TestLoadSecrets(t *testing.T) {
+	decrypted := `{"db":{"password":"s3cr3t","port":5432,"tls":true,"hosts":["db-0","db-1"]},"api":{"token":"t0k3n"}}`
+
+	tests := []struct {
+		name        string
+		paths       []string
+		wantSecrets []provider.Secret
+		err         error
+	}{
+		{
+			name: "Load values by key path",
+			paths: []string{
+				"DB_PASSWORD=sops:/secrets/app.enc.yaml#db.password",
+				"DB_PORT=sops:/secrets/app.enc.yaml#db.port",
+				"DB_TLS=sops:/secrets/app.enc.yaml#db.tls",
+				"DB_HOST=sops:/secrets/app.enc.yaml#db.hosts.1",
+				"DB_HOSTS=sops:/secrets/app.enc.yaml#db.hosts",
+			},
+			wantSecrets: []provider.Secret{
+				{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "sops:/secrets/app.enc.yaml#db.password"},
+				{Key: "DB_PORT", Value: "5432", Provider: ProviderType, Reference: "sops:/secrets/app.enc.yaml#db.port"},
+				{Key: "DB_TLS", Value: "true", Provider: ProviderType, Reference: "sops:/secrets/app.enc.yaml#db.tls"},
+				{Key: "DB_HOST", Value: "db-1", Provider: ProviderType, Reference: "sops:/secrets/app.enc.yaml#db.hosts.1"},
+				{Key: "DB_HOSTS", Value: `["db-0","db-1"]`, Provider: ProviderType, Reference: "sops:/secrets/app.enc.yaml#db.hosts"},
+			},
+		},
+		{
+			name:  "Fail on missing key",
+			paths: []string{"DB_USER=sops:/secrets/app.enc.yaml#db.user"},
+			err:   fmt.Errorf("failed to get db.user from /secrets/app.enc.yaml for DB_USER: key user not found"),
+		},
+		{
+			name:  "Fail on index out of range",
+			paths: []string{"DB_HOST=sops:/secrets/app.enc.yaml#db.hosts.2"},
+			err:   fmt.Errorf("failed to get db.hosts.2 from /secrets/app.enc.yaml for DB_HOST: index 2 out of range"),
+		},
+		{
+			name:  "Fail on missing file",
+			paths: []string{"DB_PASSWORD=sops:/secrets/missing.enc.yaml#db.password"},
+			err:   fmt.Errorf("failed to decrypt /secrets/missing.enc.yaml for DB_PASSWORD: open /secrets/missing.enc.yaml: no such file or directory"),
+		},
+		{
+			name:  "Fail on missing key path",
+			paths: []string{"DB_PASSWORD=sops:/secrets/app.enc.yaml"},
+			err:   fmt.Errorf("invalid reference for DB_PASSWORD: expected sops:<absolute file path>#<key path>"),
+		},
+		{
+			name:  "Fail on relative file path",
+			paths: []string{"DB_PASSWORD=sops:secrets/app.enc.yaml#db.password"},
+			err:   fmt.Errorf("invalid reference for DB_PASSWORD: expected sops:<absolute file path>#<key path>"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			decrypter := &fakeDecrypter{
+				files: map[string]string{"/secrets/app.enc.yaml": decrypted},
+				calls: make(map[string]int),
+			}
+			p := Provider{decrypt: decrypter.decrypt, trees: make(map[string]map[string]any)}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+			assert.Equal(t, 1, decrypter.calls["/secrets/app.enc.yaml"], "File should be decrypted once")
+		})
+	}
+}
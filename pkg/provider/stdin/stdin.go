@@ -0,0 +1,111 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stdin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const (
+	ProviderType      = "stdin"
+	referenceSelector = "stdin:"
+)
+
+// Provider serves secrets already resolved by the caller, piped to stdin as a JSON object.
+type Provider struct {
+	secrets map[string]string
+}
+
+// NewProvider reads a JSON object of KEY: value pairs from the reader until EOF,
+// so the reader can't be handed to the entrypoint process afterwards.
+func NewProvider(r io.Reader) (*Provider, error) {
+	var secrets map[string]string
+	err := json.NewDecoder(r).Decode(&secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secrets: %w", err)
+	}
+
+	for key := range secrets {
+		if key == "" || strings.Contains(key, "=") {
+			return nil, fmt.Errorf("invalid secret key %q", key)
+		}
+	}
+
+	return &Provider{secrets: secrets}, nil
+}
+
+// GetProviderName returns the type of the stdin provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
+func (p *Provider) LoadSecrets(_ context.Context, paths []string) ([]provider.Secret, error) {
+	var secrets []provider.Secret
+	for _, path := range paths {
+		split := strings.SplitN(path, "=", 2)
+		originalKey, secretRef := split[0], split[1]
+
+		// valid stdin references:
+		// stdin:{KEY}, e.g. stdin:DB_PASSWORD
+		key := strings.TrimPrefix(secretRef, referenceSelector)
+		value, ok := p.secrets[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid reference for %s: key %s was not read from stdin", originalKey, key)
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:       originalKey,
+			Value:     value,
+			Provider:  ProviderType,
+			Reference: secretRef,
+		})
+	}
+
+	return secrets, nil
+}
+
+// References returns a reference for every secret read from stdin, named after its key.
+func (p *Provider) References() map[string]string {
+	references := make(map[string]string, len(p.secrets))
+	for key := range p.secrets {
+		references[key] = referenceSelector + key
+	}
+
+	return references
+}
+
+// Factory returns a factory handing out the provider, the secrets are only read from stdin once.
+func (p *Provider) Factory() provider.Factory {
+	return provider.Factory{
+		ProviderType: ProviderType,
+		Validator:    Valid,
+		Create: func(_ context.Context, _ *common.Config) (provider.Provider, error) {
+			return p, nil
+		},
+	}
+}
+
+// Example stdin reference:
+// stdin:DB_PASSWORD
+func Valid(envValue string) bool {
+	return strings.HasPrefix(envValue, referenceSelector)
+}
@@ -0,0 +1,118 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stdin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		want      bool
+	}{
+		{name: "Valid reference", reference: "stdin:DB_PASSWORD", want: true},
+		{name: "Other provider reference", reference: "vault:secret/data/db#password"},
+		{name: "Plain value", reference: "stdin"},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			assert.Equal(t, ttp.want, Valid(ttp.reference), "Unexpected validation result")
+		})
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	go func() {
+		_, _ = w.WriteString(`{"DB_USER":"root","DB_PASSWORD":"s3cr3t"}`)
+		w.Close()
+	}()
+
+	p, err := NewProvider(r)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"DB_USER":     "stdin:DB_USER",
+		"DB_PASSWORD": "stdin:DB_PASSWORD",
+	}, p.References(), "Unexpected references")
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{"DB_USER=stdin:DB_USER", "DB_PASSWORD=stdin:DB_PASSWORD"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []provider.Secret{
+		{Key: "DB_USER", Value: "root", Provider: ProviderType, Reference: "stdin:DB_USER"},
+		{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "stdin:DB_PASSWORD"},
+	}, secrets, "Unexpected secrets")
+
+	_, err = p.LoadSecrets(context.Background(), []string{"DB_HOST=stdin:DB_HOST"})
+	assert.EqualError(t, err, "invalid reference for DB_HOST: key DB_HOST was not read from stdin", "Unexpected error message")
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{
+			name:  "Valid JSON object",
+			input: `{"DB_PASSWORD":"s3cr3t"}`,
+		},
+		{
+			name:  "Non-string value",
+			input: `{"DB_PORT":5432}`,
+			err:   fmt.Errorf("failed to decode secrets: json: cannot unmarshal number into Go struct field .DB_PORT of type string"),
+		},
+		{
+			name:  "Invalid key",
+			input: `{"DB=PASSWORD":"s3cr3t"}`,
+			err:   fmt.Errorf("invalid secret key \"DB=PASSWORD\""),
+		},
+		{
+			name:  "Empty input",
+			input: "",
+			err:   fmt.Errorf("failed to decode secrets: EOF"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			_, err := NewProvider(strings.NewReader(ttp.input))
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+		})
+	}
+}
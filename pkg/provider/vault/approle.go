@@ -0,0 +1,124 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const defaultAppRolePath = "approle"
+
+// readAppRoleCredentials reads the role id and the secret id from their files.
+func readAppRoleCredentials(roleIDFile string, secretIDFile string) (string, string, error) {
+	roleID, err := os.ReadFile(roleIDFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read role id file %s: %w", roleIDFile, err)
+	}
+
+	secretID, err := os.ReadFile(secretIDFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read secret id file %s: %w", secretIDFile, err)
+	}
+
+	return strings.TrimSpace(string(roleID)), strings.TrimSpace(string(secretID)), nil
+}
+
+// appRoleLogin logs in with the role id and the secret id to the AppRole auth method mounted at the path,
+// returning the issued Vault token. An empty address is taken from VAULT_ADDR.
+func appRoleLogin(ctx context.Context, config *Config, addr string) (string, error) {
+	client, err := newLoginClient(addr)
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.Trim(cmp.Or(config.AuthPath, defaultAppRolePath), "/")
+	secret, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", path), map[string]any{
+		"role_id":   config.RoleID,
+		"secret_id": config.SecretID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to log in with AppRole: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", errors.New("failed to log in with AppRole: no token issued")
+	}
+
+	return secret.Auth.ClientToken, nil
+}
+
+// appRoleRefresher logs in with AppRole again once the current token is near expiry.
+// AppRole tokens are not renewed by the client, unlike the ones it logged in with itself.
+// The credentials are read again, so a rotated secret id is picked up.
+type appRoleRefresher struct {
+	client    *vaultapi.Client
+	config    Config
+	addr      string
+	threshold time.Duration
+}
+
+// Run checks the token periodically until the context is done.
+func (r appRoleRefresher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.refresh(ctx)
+			if err != nil {
+				slog.Warn(fmt.Errorf("failed to refresh token: %w", err).Error(), slog.String("role-id-file", r.config.RoleIDFile))
+			}
+		}
+	}
+}
+
+// refresh logs in again if the current token expires within the threshold.
+func (r appRoleRefresher) refresh(ctx context.Context) error {
+	// A failed lookup usually means the token has already expired
+	current, err := r.client.Auth().Token().LookupSelfWithContext(ctx)
+	if err == nil {
+		ttl, err := current.TokenTTL()
+		if err == nil && ttl > r.threshold {
+			return nil
+		}
+	}
+
+	config := r.config
+	config.RoleID, config.SecretID, err = readAppRoleCredentials(config.RoleIDFile, config.SecretIDFile)
+	if err != nil {
+		return err
+	}
+
+	token, err := appRoleLogin(ctx, &config, r.addr)
+	if err != nil {
+		return err
+	}
+
+	r.client.SetToken(token)
+	slog.Info("logged in with AppRole again", slog.String("role-id-file", config.RoleIDFile))
+
+	return nil
+}
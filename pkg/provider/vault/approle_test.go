@@ -0,0 +1,106 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppRoleLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/approle/login" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{"invalid secret id"}})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"auth": map[string]any{"client_token": "approle-token"},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+
+	tests := []struct {
+		name      string
+		config    *Config
+		wantToken string
+		err       error
+	}{
+		{
+			name:      "Log in with the default path",
+			config:    &Config{RoleID: "role", SecretID: "secret"},
+			wantToken: "approle-token",
+		},
+		{
+			name:      "Log in with a custom path",
+			config:    &Config{AuthPath: "/approle/", RoleID: "role", SecretID: "secret"},
+			wantToken: "approle-token",
+		},
+		{
+			name:   "Invalid secret id",
+			config: &Config{RoleID: "role", SecretID: "other"},
+			err:    fmt.Errorf("failed to log in with AppRole: Error making API request.\n\nURL: PUT %s/v1/auth/approle/login\nCode: 400. Errors:\n\n* invalid secret id", server.URL),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			token, err := appRoleLogin(context.Background(), ttp.config, "")
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantToken, token, "Unexpected token")
+		})
+	}
+}
+
+func TestReadAppRoleCredentials(t *testing.T) {
+	dir := t.TempDir()
+	roleIDFile := filepath.Join(dir, "role-id")
+	secretIDFile := filepath.Join(dir, "secret-id")
+	require.NoError(t, os.WriteFile(roleIDFile, []byte("role\n"), 0o600))
+	require.NoError(t, os.WriteFile(secretIDFile, []byte(" secret\n"), 0o600))
+
+	roleID, secretID, err := readAppRoleCredentials(roleIDFile, secretIDFile)
+	require.NoError(t, err)
+	assert.Equal(t, "role", roleID, "Unexpected role id")
+	assert.Equal(t, "secret", secretID, "Unexpected secret id")
+
+	_, _, err = readAppRoleCredentials(roleIDFile, filepath.Join(dir, "missing"))
+	assert.EqualError(t, err, fmt.Sprintf("failed to read secret id file %s/missing: open %s/missing: no such file or directory", dir, dir), "Unexpected error message")
+}
@@ -15,8 +15,11 @@
 package vault
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cast"
@@ -58,7 +61,20 @@ const (
 	passthroughEnv          = "VAULT_PASSTHROUGH"
 	logLevelEnv             = "VAULT_LOG_LEVEL"
 	revokeTokenEnv          = "VAULT_REVOKE_TOKEN"
+	exposeTokenTTLEnv       = "VAULT_EXPOSE_TOKEN_TTL"
 	FromPathEnv             = "VAULT_FROM_PATH"
+
+	// spiffeSocketEnv logs in to the JWT auth method at VAULT_PATH with a JWT SVID from the SPIFFE Workload API
+	spiffeSocketEnv   = "VAULT_SPIFFE_SOCKET"
+	spiffeAudienceEnv = "VAULT_SPIFFE_AUDIENCE"
+
+	// roleIDFileEnv and secretIDFileEnv log in to the AppRole auth method at VAULT_PATH (approle by default)
+	// with the credentials read from the files, e.g. mounted from a Kubernetes secret
+	roleIDFileEnv   = "VAULT_ROLE_ID_FILE"
+	secretIDFileEnv = "VAULT_SECRET_ID_FILE"
+
+	// tokenTTLEnv carries the remaining TTL of the passed through login token in seconds
+	tokenTTLEnv = "VAULT_TOKEN_TTL"
 )
 
 type Config struct {
@@ -74,6 +90,15 @@ type Config struct {
 	IgnoreMissingSecrets bool   `json:"ignore_missing_secrets"`
 	FromPath             string `json:"from_path"`
 	RevokeToken          bool   `json:"revoke_token"`
+	ExposeTokenTTL       bool   `json:"expose_token_ttl"`
+	SpiffeSocket         string `json:"spiffe_socket"`
+	SpiffeAudience       string `json:"spiffe_audience"`
+	RoleIDFile           string `json:"role_id_file"`
+	SecretIDFile         string `json:"secret_id_file"`
+	RoleID               string `json:"role_id"`
+	SecretID             string `json:"secret_id"`
+	// Addrs are the addresses to fail over between, only set when VAULT_ADDR lists more than one
+	Addrs []string `json:"addrs"`
 }
 
 type envType struct {
@@ -110,13 +135,23 @@ var sanitizeEnvmap = map[string]envType{
 	passthroughEnv:          {login: false},
 	logLevelEnv:             {login: false},
 	revokeTokenEnv:          {login: false},
+	exposeTokenTTLEnv:       {login: false},
+	spiffeSocketEnv:         {login: false},
+	spiffeAudienceEnv:       {login: false},
+	roleIDFileEnv:           {login: false},
+	secretIDFileEnv:         {login: false},
 	FromPathEnv:             {login: false},
 }
 
+// EnvVars lists the environment variables the provider is configured with.
+var EnvVars = append(slices.Sorted(maps.Keys(sanitizeEnvmap)), tokenFileEnv)
+
 func LoadConfig() (*Config, error) {
 	var (
 		role, authPath, authMethod      string
 		hasRole, hasPath, hasAuthMethod bool
+		roleIDFile, secretIDFile        string
+		roleID, secretID                string
 	)
 
 	// The login procedure takes the token from a file (if using Vault Agent)
@@ -132,6 +167,24 @@ func LoadConfig() (*Config, error) {
 			return nil, fmt.Errorf("failed to read token file %s: %w", tokenFile, err)
 		}
 		vaultToken = string(tokenFileContent)
+	} else if roleIDFile, ok = os.LookupEnv(roleIDFileEnv); ok {
+		if isLogin {
+			_ = os.Unsetenv(tokenEnv)
+		}
+
+		// will use AppRole authentication with the credentials from the files
+		secretIDFile, ok = os.LookupEnv(secretIDFileEnv)
+		if !ok {
+			return nil, fmt.Errorf("incomplete authentication configuration: %s missing", secretIDFileEnv)
+		}
+
+		var err error
+		roleID, secretID, err = readAppRoleCredentials(roleIDFile, secretIDFile)
+		if err != nil {
+			return nil, err
+		}
+
+		authPath = cmp.Or(os.Getenv(pathEnv), defaultAppRolePath)
 	} else {
 		if isLogin {
 			_ = os.Unsetenv(tokenEnv)
@@ -146,8 +199,9 @@ func LoadConfig() (*Config, error) {
 		if !hasPath {
 			return nil, fmt.Errorf("incomplete authentication configuration: %s missing", pathEnv)
 		}
+		// The SVID is always exchanged with the JWT auth method
 		authMethod, hasAuthMethod = os.LookupEnv(authMethodEnv)
-		if !hasAuthMethod {
+		if !hasAuthMethod && os.Getenv(spiffeSocketEnv) == "" {
 			return nil, fmt.Errorf("incomplete authentication configuration: %s missing", authMethodEnv)
 		}
 	}
@@ -178,5 +232,39 @@ func LoadConfig() (*Config, error) {
 		IgnoreMissingSecrets: cast.ToBool(os.Getenv(ignoreMissingSecretsEnv)), // Used both for reading secrets and transit encryption
 		FromPath:             os.Getenv(FromPathEnv),
 		RevokeToken:          cast.ToBool(os.Getenv(revokeTokenEnv)),
+		ExposeTokenTTL:       cast.ToBool(os.Getenv(exposeTokenTTLEnv)),
+		SpiffeSocket:         os.Getenv(spiffeSocketEnv),
+		SpiffeAudience:       os.Getenv(spiffeAudienceEnv),
+		Addrs:                splitAddrs(os.Getenv(addrEnv)),
+		RoleIDFile:           roleIDFile,
+		SecretIDFile:         secretIDFile,
+		RoleID:               roleID,
+		SecretID:             secretID,
 	}, nil
 }
+
+// CheckAuth verifies that either a token or a role to log in with is configured,
+// so a misconfigured provider doesn't end up reading secrets without a token.
+func (c *Config) CheckAuth() error {
+	if c.TokenFile != "" {
+		if strings.TrimSpace(c.Token) == "" {
+			return fmt.Errorf("vault: token file %s is empty", c.TokenFile)
+		}
+
+		return nil
+	}
+
+	if c.RoleIDFile != "" {
+		if c.RoleID == "" || c.SecretID == "" {
+			return fmt.Errorf("vault: role id file %s or secret id file %s is empty", c.RoleIDFile, c.SecretIDFile)
+		}
+
+		return nil
+	}
+
+	if c.Role == "" || c.AuthPath == "" || (c.AuthMethod == "" && c.SpiffeSocket == "") {
+		return fmt.Errorf("vault: no token or role/path configured")
+	}
+
+	return nil
+}
@@ -25,6 +25,10 @@ import (
 func TestConfig(t *testing.T) {
 	tokenFile := newTokenFile(t)
 	defer os.Remove(tokenFile)
+	roleIDFile := newTokenFile(t)
+	defer os.Remove(roleIDFile)
+	secretIDFile := newTokenFile(t)
+	defer os.Remove(secretIDFile)
 
 	tests := []struct {
 		name       string
@@ -73,6 +77,33 @@ func TestConfig(t *testing.T) {
 				AuthMethod: "test-approle",
 			},
 		},
+		{
+			name: "Valid login configuration with SPIFFE",
+			env: map[string]string{
+				roleEnv:         "test-app-role",
+				pathEnv:         "jwt",
+				spiffeSocketEnv: "unix:///run/spire/sockets/agent.sock",
+			},
+			wantConfig: &Config{
+				Role:         "test-app-role",
+				AuthPath:     "jwt",
+				SpiffeSocket: "unix:///run/spire/sockets/agent.sock",
+			},
+		},
+		{
+			name: "Valid login configuration with AppRole",
+			env: map[string]string{
+				roleIDFileEnv:   roleIDFile,
+				secretIDFileEnv: secretIDFile,
+			},
+			wantConfig: &Config{
+				AuthPath:     "approle",
+				RoleIDFile:   roleIDFile,
+				SecretIDFile: secretIDFile,
+				RoleID:       "root",
+				SecretID:     "root",
+			},
+		},
 		{
 			name: "Invalid login configuration using tokenfile - missing token file",
 			env: map[string]string{
@@ -80,6 +111,21 @@ func TestConfig(t *testing.T) {
 			},
 			err: fmt.Errorf("failed to read token file %s/invalid: open %s/invalid: not a directory", tokenFile, tokenFile),
 		},
+		{
+			name: "Invalid login configuration using AppRole - missing role id file",
+			env: map[string]string{
+				roleIDFileEnv:   roleIDFile + "/invalid",
+				secretIDFileEnv: secretIDFile,
+			},
+			err: fmt.Errorf("failed to read role id file %s/invalid: open %s/invalid: not a directory", roleIDFile, roleIDFile),
+		},
+		{
+			name: "Invalid login configuration using AppRole - missing secret id file",
+			env: map[string]string{
+				roleIDFileEnv: roleIDFile,
+			},
+			err: fmt.Errorf("incomplete authentication configuration: VAULT_SECRET_ID_FILE missing"),
+		},
 		{
 			name: "Invalid login configuration using role/path - missing role",
 			env: map[string]string{
@@ -128,6 +174,55 @@ func TestConfig(t *testing.T) {
 	}
 }
 
+func TestConfig_CheckAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		err    error
+	}{
+		{
+			name:   "Token from token file",
+			config: &Config{Token: "root", TokenFile: "/vault/.vault-token"},
+		},
+		{
+			name:   "Role and path",
+			config: &Config{Role: "test-app-role", AuthPath: "auth/approle/test/login", AuthMethod: "test-approle"},
+		},
+		{
+			name:   "AppRole credentials",
+			config: &Config{RoleIDFile: "/vault/role-id", SecretIDFile: "/vault/secret-id", RoleID: "role", SecretID: "secret"},
+		},
+		{
+			name:   "Empty secret id file",
+			config: &Config{RoleIDFile: "/vault/role-id", SecretIDFile: "/vault/secret-id", RoleID: "role"},
+			err:    fmt.Errorf("vault: role id file /vault/role-id or secret id file /vault/secret-id is empty"),
+		},
+		{
+			name:   "Empty token file",
+			config: &Config{Token: "\n", TokenFile: "/vault/.vault-token"},
+			err:    fmt.Errorf("vault: token file /vault/.vault-token is empty"),
+		},
+		{
+			name:   "No credentials",
+			config: &Config{Role: "", AuthPath: "", AuthMethod: "test-approle"},
+			err:    fmt.Errorf("vault: no token or role/path configured"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			err := ttp.config.CheckAuth()
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+		})
+	}
+}
+
 func newTokenFile(t *testing.T) string {
 	tokenFile, err := os.CreateTemp("", "vault-token")
 	assert.Nil(t, err, "Failed to create a temporary token file")
@@ -0,0 +1,94 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/bank-vaults/vault-sdk/vault"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// splitAddrs returns the addresses listed in VAULT_ADDR (comma-separated) to fail over between.
+// A single address is left to the client, as before.
+func splitAddrs(value string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	if len(addrs) < 2 {
+		return nil
+	}
+
+	return addrs
+}
+
+// connect creates the client of the provider, returning the address it connected to.
+// With several addresses listed in VAULT_ADDR they are tried in order, until a client authenticates.
+func connect(ctx context.Context, config *Config, baseClientOptions []vault.ClientOption) (*vault.Client, string, error) {
+	if len(config.Addrs) == 0 {
+		client, err := newClient(ctx, config, "", baseClientOptions)
+
+		return client, "", err
+	}
+
+	var errs error
+	for _, addr := range config.Addrs {
+		client, err := newClient(ctx, config, addr, append(slices.Clone(baseClientOptions), vault.ClientURL(addr)))
+		if err == nil {
+			// Creating a client with a token doesn't reach Vault, looking the token up does
+			_, err = client.RawClient().Auth().Token().LookupSelfWithContext(ctx)
+			if err == nil {
+				slog.Info("connected to vault", slog.String("addr", addr))
+
+				return client, addr, nil
+			}
+
+			client.Close()
+			err = fmt.Errorf("failed to look up token: %w", err)
+		}
+
+		slog.Warn(fmt.Errorf("failed to connect to vault, trying the next address: %w", err).Error(), slog.String("addr", addr))
+		errs = errors.Join(errs, fmt.Errorf("%s: %w", addr, err))
+	}
+
+	return nil, "", fmt.Errorf("failed to connect to any vault address: %w", errs)
+}
+
+// newLoginClient creates a client for the login requests, an empty address is taken from VAULT_ADDR.
+func newLoginClient(addr string) (*vaultapi.Client, error) {
+	client, err := vaultapi.NewClient(vaultapi.DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client: %w", err)
+	}
+
+	if addr != "" {
+		err = client.SetAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set vault address %s: %w", addr, err)
+		}
+	}
+
+	return client, nil
+}
@@ -0,0 +1,107 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestSplitAddrs(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantAddrs []string
+	}{
+		{
+			name:  "Single address",
+			value: "https://vault.eu:8200",
+		},
+		{
+			name:      "Several addresses",
+			value:     "https://vault.eu:8200, https://vault.us:8200,",
+			wantAddrs: []string{"https://vault.eu:8200", "https://vault.us:8200"},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			assert.Equal(t, ttp.wantAddrs, splitAddrs(ttp.value), "Unexpected addresses")
+		})
+	}
+}
+
+func TestNewProviderFailover(t *testing.T) {
+	// The primary is down, nothing listens on its address anymore
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{"ttl": 3600},
+			})
+		case "/v1/secret/data/app":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{
+					"data":     map[string]any{"key": "secondary-secret"},
+					"metadata": map[string]any{"version": 1},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer secondary.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("root"), 0o600))
+
+	t.Setenv("VAULT_ADDR", primary.URL+","+secondary.URL)
+	t.Setenv("VAULT_TOKEN_FILE", tokenFile)
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+
+	p, err := NewProvider(context.Background(), &common.Config{})
+	require.NoError(t, err)
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{"APP_KEY=vault:secret/data/app#key"})
+	require.NoError(t, err)
+	assert.Equal(t, []provider.Secret{
+		{Key: "APP_KEY", Value: "secondary-secret", Provider: ProviderType, Reference: "vault:secret/data/app#key"},
+	}, secrets, "Unexpected secrets")
+
+	// Without a reachable address the provider can't be created
+	t.Setenv("VAULT_ADDR", primary.URL+","+primary.URL)
+	_, err = NewProvider(context.Background(), &common.Config{})
+	assert.ErrorContains(t, err, "failed to connect to any vault address", "Unexpected error message")
+}
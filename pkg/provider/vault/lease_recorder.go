@@ -0,0 +1,63 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	injector "github.com/bank-vaults/vault-sdk/injector/vault"
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// leaseRecorder wraps a secret renewer to record the lease duration of each read path.
+// The injector only hands leased secrets to the renewer, so this is the only place where they are visible.
+type leaseRecorder struct {
+	next   injector.SecretRenewer
+	mu     sync.Mutex
+	leases map[string]time.Duration
+}
+
+func newLeaseRecorder(next injector.SecretRenewer) *leaseRecorder {
+	return &leaseRecorder{
+		next:   next,
+		leases: make(map[string]time.Duration),
+	}
+}
+
+func (r *leaseRecorder) Renew(path string, secret *vaultapi.Secret) error {
+	r.mu.Lock()
+	r.leases[path] = time.Duration(secret.LeaseDuration) * time.Second
+	r.mu.Unlock()
+
+	return r.next.Renew(path, secret)
+}
+
+// leaseFor returns the lease duration of the path referenced by a reference,
+// e.g. vault:database/creds/app#password, or zero if it is unknown.
+func (r *leaseRecorder) leaseFor(reference string) time.Duration {
+	reference = strings.TrimPrefix(reference, ">>")
+	if !strings.HasPrefix(reference, "vault:") {
+		return 0
+	}
+
+	path, _, _ := strings.Cut(strings.TrimPrefix(reference, "vault:"), "#")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.leases[path]
+}
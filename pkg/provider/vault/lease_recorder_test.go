@@ -0,0 +1,46 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRenewer struct {
+	paths []string
+}
+
+func (r *fakeRenewer) Renew(path string, _ *vaultapi.Secret) error {
+	r.paths = append(r.paths, path)
+	return nil
+}
+
+func TestLeaseRecorder(t *testing.T) {
+	next := &fakeRenewer{}
+	recorder := newLeaseRecorder(next)
+
+	err := recorder.Renew("database/creds/app", &vaultapi.Secret{LeaseDuration: 30})
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []string{"database/creds/app"}, next.paths, "Renewal was not delegated")
+
+	assert.Equal(t, 30*time.Second, recorder.leaseFor("vault:database/creds/app#password"))
+	assert.Equal(t, 30*time.Second, recorder.leaseFor(">>vault:database/creds/app#password"))
+	assert.Equal(t, time.Duration(0), recorder.leaseFor("vault:secret/data/app#password"))
+	assert.Equal(t, time.Duration(0), recorder.leaseFor("scheme://${vault:database/creds/app#password}"))
+}
@@ -0,0 +1,171 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// fetchJWTSVIDMethod is the Workload API method issuing JWT SVIDs
+	// Ref: https://github.com/spiffe/spiffe/blob/main/standards/SPIFFE_Workload_API.md
+	fetchJWTSVIDMethod = "/SpiffeWorkloadAPI/FetchJWTSVID"
+
+	// The Workload API rejects requests without this header, as a protection against SSRF
+	workloadAPIHeader = "workload.spiffe.io"
+
+	defaultSpiffeAudience = "vault"
+)
+
+// spiffeLogin logs in with the JWT SVID of the workload to the JWT auth method mounted at the path,
+// returning the issued Vault token. An empty address is taken from VAULT_ADDR.
+func spiffeLogin(ctx context.Context, config *Config, addr string) (string, error) {
+	svid, err := fetchJWTSVID(ctx, config.SpiffeSocket, cmp.Or(config.SpiffeAudience, defaultSpiffeAudience))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch JWT SVID: %w", err)
+	}
+
+	client, err := newLoginClient(addr)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", strings.Trim(config.AuthPath, "/")), map[string]any{
+		"role": config.Role,
+		"jwt":  svid,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to log in with JWT SVID: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", errors.New("failed to log in with JWT SVID: no token issued")
+	}
+
+	return secret.Auth.ClientToken, nil
+}
+
+// fetchJWTSVID requests a JWT SVID for the audience from the Workload API listening on the socket.
+// The messages are encoded by hand, they only have a few fields:
+//
+//	JWTSVIDRequest  { repeated string audience = 1; string spiffe_id = 2; }
+//	JWTSVIDResponse { repeated JWTSVID svids = 1; }
+//	JWTSVID         { string spiffe_id = 1; string svid = 2; ... }
+func fetchJWTSVID(ctx context.Context, socket string, audience string) (string, error) {
+	if !strings.HasPrefix(socket, "unix:") {
+		socket = "unix://" + socket
+	}
+
+	conn, err := grpc.NewClient(socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to workload API: %w", err)
+	}
+	defer conn.Close()
+
+	request := protowire.AppendTag(nil, 1, protowire.BytesType)
+	request = protowire.AppendString(request, audience)
+
+	var response []byte
+	ctx = metadata.AppendToOutgoingContext(ctx, workloadAPIHeader, "true")
+	err = conn.Invoke(ctx, fetchJWTSVIDMethod, &request, &response, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return "", err
+	}
+
+	// The first SVID is the default identity of the workload
+	svids, err := protoStrings(response, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(svids) == 0 {
+		return "", errors.New("no JWT SVID returned")
+	}
+
+	svid, err := protoStrings([]byte(svids[0]), 2)
+	if err != nil {
+		return "", err
+	}
+	if len(svid) == 0 || svid[0] == "" {
+		return "", errors.New("JWT SVID has no token")
+	}
+
+	return svid[0], nil
+}
+
+// protoStrings returns the values of a length-delimited field of a protobuf message, skipping the other fields.
+func protoStrings(message []byte, field protowire.Number) ([]string, error) {
+	var values []string
+	for len(message) > 0 {
+		number, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid workload API response: %w", protowire.ParseError(n))
+		}
+		message = message[n:]
+
+		if number == field && typ == protowire.BytesType {
+			value, n := protowire.ConsumeBytes(message)
+			if n < 0 {
+				return nil, fmt.Errorf("invalid workload API response: %w", protowire.ParseError(n))
+			}
+			message = message[n:]
+			values = append(values, string(value))
+
+			continue
+		}
+
+		n = protowire.ConsumeFieldValue(number, typ, message)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid workload API response: %w", protowire.ParseError(n))
+		}
+		message = message[n:]
+	}
+
+	return values, nil
+}
+
+// rawCodec passes already encoded protobuf messages through gRPC.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	message, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+
+	return *message, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	message, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*message = append((*message)[:0], data...)
+
+	return nil
+}
+
+// Name keeps the content type of protobuf, which the Workload API expects.
+func (rawCodec) Name() string {
+	return "proto"
+}
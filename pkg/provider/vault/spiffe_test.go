@@ -0,0 +1,155 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// serveWorkloadAPI serves a fake SPIFFE Workload API on a unix socket, issuing the JWT SVID for the vault audience.
+func serveWorkloadAPI(t *testing.T, svid string) string {
+	// Unix socket paths are limited in length, the test temp dir may be too deep
+	dir, err := os.MkdirTemp("", "spiffe")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			if method != fetchJWTSVIDMethod {
+				return status.Errorf(codes.Unimplemented, "unknown method %s", method)
+			}
+
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			if len(md.Get(workloadAPIHeader)) == 0 {
+				return status.Error(codes.InvalidArgument, "security header missing from request")
+			}
+
+			var request []byte
+			if err := stream.RecvMsg(&request); err != nil {
+				return err
+			}
+
+			audiences, err := protoStrings(request, 1)
+			if err != nil || len(audiences) != 1 || audiences[0] != "vault" {
+				return status.Error(codes.PermissionDenied, "unexpected audience")
+			}
+
+			jwtSVID := protowire.AppendTag(nil, 1, protowire.BytesType)
+			jwtSVID = protowire.AppendString(jwtSVID, "spiffe://example.org/app")
+			jwtSVID = protowire.AppendTag(jwtSVID, 2, protowire.BytesType)
+			jwtSVID = protowire.AppendString(jwtSVID, svid)
+
+			response := protowire.AppendTag(nil, 1, protowire.BytesType)
+			response = protowire.AppendBytes(response, jwtSVID)
+
+			return stream.SendMsg(&response)
+		}),
+	)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	return socket
+}
+
+func TestSpiffeLogin(t *testing.T) {
+	socket := serveWorkloadAPI(t, "header.payload.signature")
+
+	// Fake JWT auth method issuing a token for the SVID of the workload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/jwt/login" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role"] != "app" || body["jwt"] != "header.payload.signature" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{"invalid jwt"}})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"auth": map[string]any{"client_token": "spiffe-token"},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+
+	tests := []struct {
+		name      string
+		config    *Config
+		wantToken string
+		err       error
+	}{
+		{
+			name:      "Log in with the JWT SVID",
+			config:    &Config{Role: "app", AuthPath: "jwt", SpiffeSocket: socket},
+			wantToken: "spiffe-token",
+		},
+		{
+			name:      "Log in with a unix socket address",
+			config:    &Config{Role: "app", AuthPath: "/jwt/", SpiffeSocket: "unix://" + socket},
+			wantToken: "spiffe-token",
+		},
+		{
+			name:   "Role rejecting the SVID",
+			config: &Config{Role: "other", AuthPath: "jwt", SpiffeSocket: socket},
+			err:    fmt.Errorf("failed to log in with JWT SVID: Error making API request.\n\nURL: PUT %s/v1/auth/jwt/login\nCode: 400. Errors:\n\n* invalid jwt", server.URL),
+		},
+		{
+			name:   "SVID for another audience",
+			config: &Config{Role: "app", AuthPath: "jwt", SpiffeSocket: socket, SpiffeAudience: "other"},
+			err:    fmt.Errorf("failed to fetch JWT SVID: rpc error: code = PermissionDenied desc = unexpected audience"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			token, err := spiffeLogin(context.Background(), ttp.config, "")
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantToken, token, "Unexpected token")
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+const (
+	tokenRefreshInterval  = time.Minute
+	tokenRefreshThreshold = 5 * time.Minute
+)
+
+// tokenRefresher logs in with the token file again once the current token is near expiry.
+// The file is rewritten periodically (e.g. by the Vault Agent), while the token was only read at startup.
+type tokenRefresher struct {
+	client    *vaultapi.Client
+	tokenFile string
+	threshold time.Duration
+}
+
+// Run checks the token periodically until the context is done.
+func (r tokenRefresher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.refresh(ctx)
+			if err != nil {
+				slog.Warn(fmt.Errorf("failed to refresh token: %w", err).Error(), slog.String("file", r.tokenFile))
+			}
+		}
+	}
+}
+
+// refresh switches to the token in the file if the current one expires within the threshold.
+func (r tokenRefresher) refresh(ctx context.Context) error {
+	// A failed lookup usually means the token has already expired, the file is read in that case as well
+	current, err := r.client.Auth().Token().LookupSelfWithContext(ctx)
+	if err == nil {
+		ttl, err := current.TokenTTL()
+		if err == nil && ttl > r.threshold {
+			return nil
+		}
+	}
+
+	content, err := os.ReadFile(r.tokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read token file %s: %w", r.tokenFile, err)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" || token == strings.TrimSpace(r.client.Token()) {
+		slog.Debug("token is near expiry, but the token file has not been rewritten yet", slog.String("file", r.tokenFile))
+		return nil
+	}
+
+	// The new token is only kept if it is accepted by Vault
+	previous := r.client.Token()
+	r.client.SetToken(token)
+
+	_, err = r.client.Auth().Token().LookupSelfWithContext(ctx)
+	if err != nil {
+		r.client.SetToken(previous)
+		return fmt.Errorf("failed to log in with the rewritten token file: %w", err)
+	}
+
+	slog.Info("logged in with the rewritten token file", slog.String("file", r.tokenFile))
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRefresher_Refresh(t *testing.T) {
+	// Fake token lookup, the initial token is about to expire
+	tokenTTLs := map[string]int{"token-old": 60, "token-new": 3600}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ttl, ok := tokenTTLs[r.Header.Get("X-Vault-Token")]
+		if r.URL.Path != "/v1/auth/token/lookup-self" || !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"id": r.Header.Get("X-Vault-Token"), "ttl": ttl},
+		})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		fileToken string
+		threshold time.Duration
+		wantToken string
+		err       error
+	}{
+		{
+			name:      "Log in with the rewritten token file",
+			fileToken: "token-new\n",
+			threshold: 5 * time.Minute,
+			wantToken: "token-new",
+		},
+		{
+			name:      "Keep the token while it is not near expiry",
+			fileToken: "token-new",
+			threshold: 30 * time.Second,
+			wantToken: "token-old",
+		},
+		{
+			name:      "Keep the token while the token file is not rewritten",
+			fileToken: "token-old",
+			threshold: 5 * time.Minute,
+			wantToken: "token-old",
+		},
+		{
+			name:      "Keep the token if the rewritten one is rejected",
+			fileToken: "token-invalid",
+			threshold: 5 * time.Minute,
+			wantToken: "token-old",
+			err:       fmt.Errorf("failed to log in with the rewritten token file: Error making API request.\n\nURL: GET %s/v1/auth/token/lookup-self\nCode: 403. Raw Message:\n\n", server.URL),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			tokenFile := filepath.Join(t.TempDir(), "token")
+			err := os.WriteFile(tokenFile, []byte(ttp.fileToken), 0o600)
+			require.NoError(t, err)
+
+			config := vaultapi.DefaultConfig()
+			config.Address = server.URL
+			client, err := vaultapi.NewClient(config)
+			require.NoError(t, err)
+			client.SetToken("token-old")
+
+			refresher := tokenRefresher{
+				client:    client,
+				tokenFile: tokenFile,
+				threshold: ttp.threshold,
+			}
+
+			err = refresher.refresh(context.Background())
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.Nil(t, err, "Unexpected error")
+			}
+
+			assert.Equal(t, ttp.wantToken, client.Token(), "Unexpected token")
+		})
+	}
+}
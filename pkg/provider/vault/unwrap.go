@@ -0,0 +1,123 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// unwrapSelector marks a reference to a response-wrapped secret, whose wrapping token is read from a file,
+// e.g. vault:unwrap:/vault/wrapping-token#password
+const unwrapSelector = "vault:unwrap:"
+
+// unwrapSecrets unwraps the secrets of the unwrap references and removes those references from the environ,
+// since the injector doesn't know about them.
+// Wrapping tokens are single-use, so unwrapping is never retried.
+func (p *Provider) unwrapSecrets(ctx context.Context, vaultEnviron map[string]string) ([]provider.Secret, error) {
+	var secrets []provider.Secret
+	for _, key := range slices.Sorted(maps.Keys(vaultEnviron)) {
+		reference := vaultEnviron[key]
+		if !strings.HasPrefix(reference, unwrapSelector) {
+			continue
+		}
+		delete(vaultEnviron, key)
+
+		tokenFile, field, err := parseUnwrapReference(reference)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for %s: %w", key, err)
+		}
+
+		value, err := p.unwrap(ctx, tokenFile, field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap secret for %s: %w", key, err)
+		}
+
+		secrets = append(secrets, provider.Secret{
+			Key:       key,
+			Value:     value,
+			Provider:  ProviderType,
+			Reference: reference,
+		})
+	}
+
+	return secrets, nil
+}
+
+// parseUnwrapReference returns the wrapping token file and the selected field of an unwrap reference.
+func parseUnwrapReference(reference string) (string, string, error) {
+	tokenFile, field, ok := strings.Cut(strings.TrimPrefix(reference, unwrapSelector), "#")
+	if !ok || tokenFile == "" || field == "" {
+		return "", "", errors.New("expected vault:unwrap:<wrapping token file>#<field>")
+	}
+
+	return tokenFile, field, nil
+}
+
+// unwrap exchanges the wrapping token for the wrapped secret and returns its field.
+// Ref: https://developer.hashicorp.com/vault/api-docs/system/wrapping-unwrap
+func (p *Provider) unwrap(ctx context.Context, tokenFile string, field string) (string, error) {
+	wrappingToken, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read wrapping token: %w", err)
+	}
+
+	secret, err := p.client.RawClient().Logical().UnwrapWithContext(ctx, strings.TrimSpace(string(wrappingToken)))
+	if err != nil {
+		var responseErr *vaultapi.ResponseError
+		if errors.As(err, &responseErr) && responseErr.StatusCode == http.StatusBadRequest {
+			return "", fmt.Errorf("wrapping token is invalid, expired or already unwrapped (it can only be unwrapped once): %w", err)
+		}
+
+		return "", err
+	}
+	if secret == nil || secret.Data == nil {
+		return "", errors.New("no secret is wrapped by the token")
+	}
+
+	// Wrapped KV v2 reads keep their fields under data, next to the metadata
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]any); ok && data["metadata"] != nil {
+		data = inner
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %s not found in the wrapped secret", field)
+	}
+
+	if value, ok := value.(string); ok {
+		return value, nil
+	}
+
+	// Non-string values are injected as JSON, the same as the whole secret (#*)
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode field %s: %w", field, err)
+	}
+
+	return string(jsonValue), nil
+}
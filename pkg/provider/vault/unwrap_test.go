@@ -0,0 +1,159 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bank-vaults/vault-sdk/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestParseUnwrapReference(t *testing.T) {
+	tests := []struct {
+		name          string
+		reference     string
+		wantTokenFile string
+		wantField     string
+		err           error
+	}{
+		{
+			name:          "Valid reference",
+			reference:     "vault:unwrap:/vault/wrapping-token#password",
+			wantTokenFile: "/vault/wrapping-token",
+			wantField:     "password",
+		},
+		{
+			name:      "Missing field",
+			reference: "vault:unwrap:/vault/wrapping-token",
+			err:       fmt.Errorf("expected vault:unwrap:<wrapping token file>#<field>"),
+		},
+		{
+			name:      "Missing token file",
+			reference: "vault:unwrap:#password",
+			err:       fmt.Errorf("expected vault:unwrap:<wrapping token file>#<field>"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			tokenFile, field, err := parseUnwrapReference(ttp.reference)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantTokenFile, tokenFile, "Unexpected token file")
+			assert.Equal(t, ttp.wantField, field, "Unexpected field")
+		})
+	}
+}
+
+func TestLoadSecretsUnwrap(t *testing.T) {
+	// Fake Vault unwrapping a wrapped KV v2 read exactly once
+	unwrapped := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/wrapping/unwrap" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var body struct {
+			Token string `json:"token"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		if body.Token != "wrapping-token" || unwrapped[body.Token] {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{"wrapping token is not valid or does not exist"}})
+			return
+		}
+		unwrapped[body.Token] = true
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"password": "s3cr3t"},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+
+	tokenFile := filepath.Join(t.TempDir(), "wrapping-token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("wrapping-token\n"), 0o600))
+
+	tests := []struct {
+		name        string
+		paths       []string
+		wantSecrets []provider.Secret
+		err         error
+	}{
+		{
+			name: "Unwrap secret fields",
+			paths: []string{
+				"DB_PASSWORD=vault:unwrap:" + tokenFile + "#password",
+			},
+			wantSecrets: []provider.Secret{
+				{Key: "DB_PASSWORD", Value: "s3cr3t", Provider: ProviderType, Reference: "vault:unwrap:" + tokenFile + "#password"},
+			},
+		},
+		{
+			name:  "Unwrap an already unwrapped secret",
+			paths: []string{"DB_PASSWORD=vault:unwrap:" + tokenFile + "#password"},
+			err:   fmt.Errorf("failed to unwrap secret for DB_PASSWORD: wrapping token is invalid, expired or already unwrapped (it can only be unwrapped once): Error making API request.\n\nURL: PUT %s/v1/sys/wrapping/unwrap\nCode: 400. Errors:\n\n* wrapping token is not valid or does not exist", server.URL),
+		},
+		{
+			name:  "Missing wrapping token",
+			paths: []string{"DB_PASSWORD=vault:unwrap:/not/existing#password"},
+			err:   fmt.Errorf("failed to unwrap secret for DB_PASSWORD: failed to read wrapping token: open /not/existing: no such file or directory"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			client, err := vault.NewClientWithOptions(vault.ClientToken("token"))
+			require.NoError(t, err)
+
+			p := &Provider{
+				client:       client,
+				tokenClients: make(map[string]*vault.Client),
+			}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.ElementsMatch(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
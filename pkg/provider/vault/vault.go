@@ -16,33 +16,68 @@ package vault
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net/http"
 	"os"
-	"os/signal"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	injector "github.com/bank-vaults/vault-sdk/injector/vault"
 	"github.com/bank-vaults/vault-sdk/vault"
+	vaultapi "github.com/hashicorp/vault/api"
 
 	"github.com/bank-vaults/secret-init/pkg/common"
 	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/provider/renewer"
+	"github.com/bank-vaults/secret-init/pkg/retry"
 	"github.com/bank-vaults/secret-init/pkg/utils"
 )
 
 const (
 	ProviderType      = "vault"
 	referenceSelector = `(vault:)(.*)#(.*)`
+
+	// tokenFileOption selects the token a reference is read with,
+	// e.g. vault:secret/data/app#key?token_file=/creds/tenant-a
+	tokenFileOption = "token_file"
+
+	// namespacePrefix selects the namespace a reference is read from, instead of VAULT_NAMESPACE,
+	// e.g. vault:ns=team-a:secret/data/app#key
+	namespacePrefix = "vault:ns="
+
+	// wholeSecretKey selects all fields of a secret, e.g. vault:secret/data/app#*
+	wholeSecretKey = "*"
+	// wholeSecretTemplate renders the secret data as compact JSON with the injector's template support
+	wholeSecretTemplate = "${ toJson . }"
 )
 
+// Fails to compile when the provider drifts from the interface shared with bao
+var _ provider.Provider = (*Provider)(nil)
+
 type Provider struct {
-	isLogin        bool
-	client         *vault.Client
+	isLogin bool
+	client  *vault.Client
+	// baseClientOptions are shared by the clients created for references with their own token
+	baseClientOptions []vault.ClientOption
+	// tokenClients caches the clients created for references with their own token, by token file
+	tokenClients   map[string]*vault.Client
 	injectorConfig injector.Config
 	secretRenewer  injector.SecretRenewer
 	fromPath       string
 	revokeToken    bool
+	exposeTokenTTL bool
+	retryPolicy    retry.Policy
+	// namespaceClients caches the clients of references with their own namespace, by token file and namespace
+	namespaceClients map[clientKey]*vault.Client
+	// stopRefresh stops the token refreshers running in daemon mode
+	stopRefresh context.CancelFunc
+	// revoked is set by the first load revoking the token, the provider is not reused after it
+	revoked bool
 }
 
 type sanitized struct {
@@ -67,27 +102,28 @@ func (s *sanitized) append(key string, value string) {
 	}
 }
 
-func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider, error) {
+func NewProvider(ctx context.Context, appConfig *common.Config) (provider.Provider, error) {
 	config, err := LoadConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create vault config: %w", err)
 	}
 
-	clientOptions := []vault.ClientOption{vault.ClientLogger(clientLogger{slog.Default()})}
-	if config.TokenFile != "" {
-		clientOptions = append(clientOptions, vault.ClientToken(config.Token))
-	} else {
-		// use role/path based authentication
-		clientOptions = append(clientOptions,
-			vault.ClientRole(config.Role),
-			vault.ClientAuthPath(config.AuthPath),
-			vault.ClientAuthMethod(config.AuthMethod),
-		)
+	if appConfig.RequireAuth {
+		err = config.CheckAuth()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	client, err := vault.NewClientWithOptions(clientOptions...)
+	baseClientOptions := []vault.ClientOption{vault.ClientLogger(clientLogger{slog.Default()})}
+	client, addr, err := connect(ctx, config, baseClientOptions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create vault client: %w", err)
+		return nil, err
+	}
+
+	// The clients of references with their own token connect to the same address
+	if addr != "" {
+		baseClientOptions = append(baseClientOptions, vault.ClientURL(addr))
 	}
 
 	injectorConfig := injector.Config{
@@ -101,23 +137,100 @@ func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider
 	var secretRenewer injector.SecretRenewer
 
 	if appConfig.Daemon {
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs)
-
-		secretRenewer = daemonSecretRenewer{client: client, sigs: sigs}
+		secretRenewer = renewer.Daemon{
+			Client:      client,
+			Signal:      provider.SignalFunc(ctx),
+			StopSignal:  appConfig.StopSignal,
+			StopTimeout: appConfig.RenewalKillTimeout,
+			RenewSignal: appConfig.RenewSignal,
+		}
 		slog.Info("Daemon mode enabled. Will renew secrets in the background.")
 	}
 
+	// The refreshers are stopped along with the clients once the provider is closed
+	refreshCtx, stopRefresh := context.WithCancel(provider.Lifetime(ctx))
+
+	// Long running processes would outlive the token read from the file at startup
+	if appConfig.Daemon && config.TokenFile != "" {
+		refresher := tokenRefresher{
+			client:    client.RawClient(),
+			tokenFile: config.TokenFile,
+			threshold: tokenRefreshThreshold,
+		}
+		go refresher.Run(refreshCtx, tokenRefreshInterval)
+	}
+
+	// The AppRole token is neither renewed nor rewritten to a file, it is replaced by logging in again
+	if appConfig.Daemon && config.RoleIDFile != "" {
+		refresher := appRoleRefresher{
+			client:    client.RawClient(),
+			config:    *config,
+			addr:      addr,
+			threshold: tokenRefreshThreshold,
+		}
+		go refresher.Run(refreshCtx, tokenRefreshInterval)
+	}
+
 	return &Provider{
-		isLogin:        config.IsLogin,
-		client:         client,
-		injectorConfig: injectorConfig,
-		secretRenewer:  secretRenewer,
-		fromPath:       config.FromPath,
-		revokeToken:    config.RevokeToken,
+		isLogin:           config.IsLogin,
+		client:            client,
+		baseClientOptions: baseClientOptions,
+		tokenClients:      make(map[string]*vault.Client),
+		injectorConfig:    injectorConfig,
+		secretRenewer:     secretRenewer,
+		fromPath:          config.FromPath,
+		revokeToken:       config.RevokeToken,
+		exposeTokenTTL:    config.ExposeTokenTTL,
+		retryPolicy: retry.Policy{
+			MaxAttempts: appConfig.RetryMaxAttempts,
+			BaseDelay:   appConfig.RetryBaseDelay,
+		},
+		stopRefresh: stopRefresh,
 	}, nil
 }
 
+// newClient creates a client authenticated with the configured method.
+// An empty address is taken from VAULT_ADDR, the client options have to set it otherwise.
+func newClient(ctx context.Context, config *Config, addr string, baseClientOptions []vault.ClientOption) (*vault.Client, error) {
+	clientOptions := slices.Clone(baseClientOptions)
+	if config.TokenFile != "" {
+		clientOptions = append(clientOptions, vault.ClientToken(config.Token))
+	} else if config.RoleIDFile != "" {
+		token, err := appRoleLogin(ctx, config, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		clientOptions = append(clientOptions, vault.ClientToken(token))
+	} else if config.SpiffeSocket != "" {
+		token, err := spiffeLogin(ctx, config, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		clientOptions = append(clientOptions, vault.ClientToken(token))
+	} else {
+		// use role/path based authentication
+		clientOptions = append(clientOptions,
+			vault.ClientRole(config.Role),
+			vault.ClientAuthPath(config.AuthPath),
+			vault.ClientAuthMethod(config.AuthMethod),
+		)
+	}
+
+	client, err := vault.NewClientWithOptions(clientOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client: %w", err)
+	}
+
+	return client, nil
+}
+
+// GetProviderName returns the type of the Vault provider.
+func (p *Provider) GetProviderName() string {
+	return ProviderType
+}
+
 // LoadSecret's path formatting: <key>=<path>
 // This formatting is necessary because the injector expects a map of key=value pairs.
 // It also returns a map of key:value pairs, where the key is the environment variable name
@@ -125,8 +238,62 @@ func NewProvider(_ context.Context, appConfig *common.Config) (provider.Provider
 // E.g. paths: MYSQL_PASSWORD=secret/data/mysql/password
 // returns: []provider.Secret{provider.Secret{Path: "MYSQL_PASSWORD", Value: "password"}}
 func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.Secret, error) {
+	vaultEnviron := parsePathsToMap(paths)
+	references := maps.Clone(vaultEnviron)
+
+	// Has to happen before the token may be revoked
+	unwrappedSecrets, err := p.unwrapSecrets(ctx, vaultEnviron)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, reference := range vaultEnviron {
+		reference, err := translateVersion(reference)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for %s: %w", key, err)
+		}
+
+		vaultEnviron[key] = reference
+	}
+
+	environsByClient, err := splitByClient(vaultEnviron)
+	if err != nil {
+		return nil, err
+	}
+
 	sanitized := sanitized{login: p.isLogin}
-	secretInjector := injector.NewSecretInjector(p.injectorConfig, p.client, p.secretRenewer, slog.Default())
+	secretInjectors := make(map[clientKey]*injector.SecretInjector, len(environsByClient))
+	// Leases are only handed to the renewer, which is only set in daemon mode
+	leaseRecorders := make(map[clientKey]*leaseRecorder, len(environsByClient))
+	// Leases are renewed until the load acquiring them has been replaced
+	loadRenewer := p.secretRenewer
+	if daemon, ok := loadRenewer.(renewer.Daemon); ok {
+		loadRenewer = daemon.ForLoad(ctx)
+	}
+	for key, environ := range environsByClient {
+		for envKey, reference := range environ {
+			if err := validateVersion(reference); err != nil {
+				return nil, fmt.Errorf("invalid reference for %s: %w", envKey, err)
+			}
+
+			environ[envKey] = selectWholeSecret(reference)
+		}
+
+		client, err := p.clientFor(key)
+		if err != nil {
+			return nil, err
+		}
+
+		var secretRenewer injector.SecretRenewer
+		if p.secretRenewer != nil {
+			leaseRecorders[key] = newLeaseRecorder(renewerFor(loadRenewer, client))
+			secretRenewer = leaseRecorders[key]
+		}
+
+		secretInjector := injector.NewSecretInjector(p.injectorConfig, client, secretRenewer, slog.Default())
+		secretInjectors[key] = &secretInjector
+	}
+
 	inject := func(key, value string) {
 		// Check for key duplication
 		if utils.IsKeyDuplicated(&sanitized.secrets, key) {
@@ -137,30 +304,173 @@ func (p *Provider) LoadSecrets(ctx context.Context, paths []string) ([]provider.
 		sanitized.append(key, value)
 	}
 
-	err := secretInjector.InjectSecretsFromVault(parsePathsToMap(paths), inject)
-	if err != nil {
-		return nil, fmt.Errorf("failed to inject secrets from vault: %w", err)
+	// The reads are injected at once (e.g. transit references are decrypted in batches) and retried as a batch,
+	// the injector caches the secrets it read, so a retry doesn't read them again nor lease new secrets.
+	// Writes are never retried, a failed one may have been applied by Vault already.
+	for key, environ := range environsByClient {
+		reads, writes := splitWrites(environ)
+
+		err := p.injectWithRetry(ctx, &sanitized, false, func() error {
+			return checkPermissionDenied(secretInjectors[key].InjectSecretsFromVault(reads, inject))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to inject secrets from vault: %w", err)
+		}
+
+		if len(writes) > 0 {
+			err = p.injectWithRetry(ctx, &sanitized, true, func() error {
+				return checkPermissionDenied(secretInjectors[key].InjectSecretsFromVault(writes, inject))
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to inject secrets from vault: %w", err)
+			}
+		}
 	}
 
 	if p.fromPath != "" {
-		err = secretInjector.InjectSecretsFromVaultPath(p.fromPath, inject)
+		for _, path := range strings.Split(p.fromPath, ",") {
+			err := p.injectWithRetry(ctx, &sanitized, false, func() error {
+				return checkPermissionDenied(secretInjectors[clientKey{}].InjectSecretsFromVaultPath(path, inject))
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to inject secrets from vault path: %w", err)
+			}
+		}
+	}
+
+	// The lookup has to happen before the token is revoked
+	tokenPassedThrough := slices.ContainsFunc(sanitized.secrets, func(secret provider.Secret) bool {
+		return secret.Key == tokenEnv
+	})
+	if p.isLogin && p.exposeTokenTTL && tokenPassedThrough {
+		tokenTTL, err := p.lookupTokenTTL(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to inject secrets from vault path: %w", err)
+			return nil, err
 		}
+
+		sanitized.secrets = append(sanitized.secrets, tokenTTL)
 	}
 
 	if p.revokeToken {
-		// ref: https://www.vaultproject.io/api/auth/token/index.html#revoke-a-token-self
-		err := p.client.RawClient().Auth().Token().RevokeSelfWithContext(ctx, p.client.RawClient().Token())
-		if err != nil {
-			// Do not exit on error, token revoking can be denied by policy
-			slog.Warn("failed to revoke token")
+		p.revoked = true
+
+		// A token still used by the process can only be revoked once the process has exited
+		revokeCtx := context.WithoutCancel(ctx)
+		deferred := (p.isLogin || tokenPassedThrough) && provider.RegisterCleanup(ctx, func() { p.revokeSelf(revokeCtx) })
+		if !deferred {
+			p.revokeSelf(ctx)
+		}
+	}
+
+	for key, leases := range leaseRecorders {
+		for i, secret := range sanitized.secrets {
+			if reference, ok := environsByClient[key][secret.Key]; ok {
+				sanitized.secrets[i].TTL = leases.leaseFor(reference)
+			}
 		}
+	}
+
+	// Secrets injected from VAULT_FROM_PATH have no reference of their own
+	for i, secret := range sanitized.secrets {
+		sanitized.secrets[i].Provider = ProviderType
+		sanitized.secrets[i].Reference = references[secret.Key]
+	}
+
+	return append(sanitized.secrets, unwrappedSecrets...), nil
+}
 
-		p.client.Close()
+// injectWithRetry runs an injection, retrying transient failures unless it writes to Vault.
+// Secrets injected by a failed attempt are discarded before retrying.
+func (p *Provider) injectWithRetry(ctx context.Context, sanitized *sanitized, write bool, inject func() error) error {
+	policy := p.retryPolicy
+	if write {
+		policy.MaxAttempts = 1
 	}
 
-	return sanitized.secrets, nil
+	injected := len(sanitized.secrets)
+
+	return retry.Do(ctx, policy, func() error {
+		sanitized.secrets = sanitized.secrets[:injected]
+
+		return markRetryable(inject())
+	})
+}
+
+// splitWrites splits the references into the ones reading from Vault and the ones writing to it,
+// e.g. >>vault:pki/issue/app#certificate.
+func splitWrites(environ map[string]string) (map[string]string, map[string]string) {
+	reads := make(map[string]string, len(environ))
+	writes := make(map[string]string)
+	for key, reference := range environ {
+		if strings.HasPrefix(reference, ">>vault:") {
+			writes[key] = reference
+		} else {
+			reads[key] = reference
+		}
+	}
+
+	return reads, writes
+}
+
+// Revoked reports whether a load revoked the token of the provider, or deferred its revocation until the process exits.
+func (p *Provider) Revoked() bool {
+	return p.revoked
+}
+
+// Close stops the token renewal of the clients and the token refreshers.
+func (p *Provider) Close() error {
+	if p.stopRefresh != nil {
+		p.stopRefresh()
+	}
+
+	p.client.Close()
+	for _, client := range p.tokenClients {
+		client.Close()
+	}
+	for _, client := range p.namespaceClients {
+		client.Close()
+	}
+
+	return nil
+}
+
+// revokeSelf revokes the tokens of the provider, including the ones of references with their own token, and closes it.
+func (p *Provider) revokeSelf(ctx context.Context) {
+	revokeToken(ctx, p.client)
+	for _, tokenFile := range slices.Sorted(maps.Keys(p.tokenClients)) {
+		revokeToken(ctx, p.tokenClients[tokenFile])
+	}
+
+	_ = p.Close()
+}
+
+// revokeToken revokes the token of the client.
+func revokeToken(ctx context.Context, client *vault.Client) {
+	// ref: https://www.vaultproject.io/api/auth/token/index.html#revoke-a-token-self
+	err := client.RawClient().Auth().Token().RevokeSelfWithContext(ctx, client.RawClient().Token())
+	if err != nil {
+		// Do not exit on error, token revoking can be denied by policy
+		slog.Warn("failed to revoke token")
+	}
+}
+
+// lookupTokenTTL returns the remaining TTL of the passed through login token in seconds.
+func (p *Provider) lookupTokenTTL(ctx context.Context) (provider.Secret, error) {
+	token, err := p.client.RawClient().Auth().Token().LookupSelfWithContext(ctx)
+	if err != nil {
+		return provider.Secret{}, fmt.Errorf("failed to look up vault token: %w", err)
+	}
+
+	ttl, err := token.TokenTTL()
+	if err != nil {
+		return provider.Secret{}, fmt.Errorf("failed to get vault token TTL: %w", err)
+	}
+
+	return provider.Secret{
+		Key:   tokenTTLEnv,
+		Value: strconv.Itoa(int(ttl.Seconds())),
+		TTL:   ttl,
+	}, nil
 }
 
 // If the path contains some string formatted as "vault:{STR}#{STR}"
@@ -169,6 +479,196 @@ func Valid(envValue string) bool {
 	return regexp.MustCompile(referenceSelector).MatchString(envValue)
 }
 
+// validateVersion checks the optional KV v2 version segment of a reference.
+// E.g. vault:secret/data/account#password#3 pins the secret to version 3,
+// while vault:secret/data/account#password keeps reading the latest version.
+// Write references (>>vault:) and inline references are left to the injector,
+// since their third segment carries data instead of a version.
+func validateVersion(reference string) error {
+	if !strings.HasPrefix(reference, "vault:") {
+		return nil
+	}
+
+	split := strings.SplitN(reference, "#", 3)
+	if len(split) < 3 {
+		return nil
+	}
+
+	version, err := strconv.Atoi(split[2])
+	if err != nil || version < 1 {
+		return fmt.Errorf("version %q must be a positive integer", split[2])
+	}
+
+	return nil
+}
+
+// translateVersion turns the version option into the version segment of a KV v2 reference,
+// e.g. vault:secret/data/account#password?version=pinned:3 becomes vault:secret/data/account#password#3.
+func translateVersion(reference string) (string, error) {
+	if !strings.HasPrefix(reference, "vault:") {
+		return reference, nil
+	}
+
+	reference, version, ok, err := utils.SplitVersion(reference)
+	if err != nil || !ok {
+		return reference, err
+	}
+
+	path, options, hasOptions := strings.Cut(reference, "?")
+	if strings.Count(path, "#") > 1 {
+		return "", fmt.Errorf("version is set both in the reference and by the %s option", utils.VersionOption)
+	}
+
+	if version != "" {
+		path += "#" + version
+	}
+	if hasOptions {
+		path += "?" + options
+	}
+
+	return path, nil
+}
+
+// selectWholeSecret turns references to all fields of a secret into a template rendering them as JSON.
+// The JSON is compact, with keys sorted and HTML characters escaped (e.g. < as \u003c), as encoding/json does.
+// E.g. vault:secret/data/app#* becomes {"enabled":true,"name":"app","port":5432}.
+func selectWholeSecret(reference string) string {
+	if !strings.HasPrefix(reference, "vault:") {
+		return reference
+	}
+
+	split := strings.SplitN(reference, "#", 3)
+	if len(split) < 2 || split[1] != wholeSecretKey {
+		return reference
+	}
+
+	split[1] = wholeSecretTemplate
+
+	return strings.Join(split, "#")
+}
+
+// clientKey identifies the client a reference is read with, the zero value is the default client.
+type clientKey struct {
+	tokenFile string
+	namespace string
+}
+
+// splitByClient groups the references by the token file and the namespace they need to be read with.
+// References without either are grouped under the zero key, read with the default client.
+func splitByClient(vaultEnviron map[string]string) (map[clientKey]map[string]string, error) {
+	// The default group is always present, since secrets from VAULT_FROM_PATH are read with the default client
+	environsByClient := map[clientKey]map[string]string{{}: {}}
+	for envKey, reference := range vaultEnviron {
+		var key clientKey
+
+		reference, namespace, err := splitNamespace(reference)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference for %s: %w", envKey, err)
+		}
+		key.namespace = namespace
+
+		// Only plain references may carry options, inline and write references are passed on as is
+		if strings.HasPrefix(reference, "vault:") && strings.Contains(reference, "?") {
+			strippedReference, options, err := utils.ParseReferenceOptions(reference)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reference for %s: %w", envKey, err)
+			}
+
+			if options.Has(tokenFileOption) {
+				key.tokenFile = options.Get(tokenFileOption)
+				reference = strippedReference
+			}
+		}
+
+		if environsByClient[key] == nil {
+			environsByClient[key] = make(map[string]string)
+		}
+		environsByClient[key][envKey] = reference
+	}
+
+	return environsByClient, nil
+}
+
+// splitNamespace strips the namespace from a reference, e.g. vault:ns=team-a:secret/data/app#key
+// returns vault:secret/data/app#key and team-a. The namespace is empty if the reference has none.
+func splitNamespace(reference string) (string, string, error) {
+	rest, ok := strings.CutPrefix(reference, namespacePrefix)
+	if !ok {
+		return reference, "", nil
+	}
+
+	namespace, path, ok := strings.Cut(rest, ":")
+	if !ok || namespace == "" || path == "" {
+		return "", "", fmt.Errorf("expected %s<namespace>:<path>#<key>", namespacePrefix)
+	}
+
+	return "vault:" + path, namespace, nil
+}
+
+// clientFor returns the client for the references read with the given token file and namespace.
+// Clients are cached, so each token is only read once.
+func (p *Provider) clientFor(key clientKey) (*vault.Client, error) {
+	client, err := p.tokenClientFor(key.tokenFile)
+	if err != nil || key.namespace == "" {
+		return client, err
+	}
+
+	if client, ok := p.namespaceClients[key]; ok {
+		return client, nil
+	}
+
+	// The namespace is set on a copy of the raw client, the client of the token keeps its own namespace
+	rawClient := client.RawClient().WithNamespace(key.namespace)
+	namespaceClient, err := vault.NewClientFromRawClient(rawClient, vault.ClientLogger(clientLogger{slog.Default()}), vault.ClientToken(rawClient.Token()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client for namespace %s: %w", key.namespace, err)
+	}
+
+	if p.namespaceClients == nil {
+		p.namespaceClients = make(map[clientKey]*vault.Client)
+	}
+	p.namespaceClients[key] = namespaceClient
+
+	return namespaceClient, nil
+}
+
+// tokenClientFor returns the client for the references read with the given token file.
+// Clients are cached by token file, so each token is only read once.
+func (p *Provider) tokenClientFor(tokenFile string) (*vault.Client, error) {
+	if tokenFile == "" {
+		return p.client, nil
+	}
+
+	if client, ok := p.tokenClients[tokenFile]; ok {
+		return client, nil
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file %s: %w", tokenFile, err)
+	}
+
+	clientOptions := append(slices.Clone(p.baseClientOptions), vault.ClientToken(strings.TrimSpace(string(token))))
+	client, err := vault.NewClientWithOptions(clientOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client for token file %s: %w", tokenFile, err)
+	}
+
+	p.tokenClients[tokenFile] = client
+
+	return client, nil
+}
+
+// renewerFor makes the daemon renewer renew leases with the client that acquired them.
+func renewerFor(secretRenewer injector.SecretRenewer, client *vault.Client) injector.SecretRenewer {
+	if daemon, ok := secretRenewer.(renewer.Daemon); ok {
+		daemon.Client = client
+		return daemon
+	}
+
+	return secretRenewer
+}
+
 func parsePathsToMap(paths []string) map[string]string {
 	vaultEnviron := make(map[string]string)
 
@@ -180,3 +680,25 @@ func parsePathsToMap(paths []string) map[string]string {
 
 	return vaultEnviron
 }
+
+// checkPermissionDenied calls out policy denials, which are never ignored as missing secrets.
+// VAULT_IGNORE_MISSING_SECRETS only skips paths Vault reports as absent (404),
+// a denied read (403) would otherwise silently drop a secret the application needs.
+func checkPermissionDenied(err error) error {
+	var responseErr *vaultapi.ResponseError
+	if errors.As(err, &responseErr) && responseErr.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("permission denied, check the policies of the token: %w", err)
+	}
+
+	return err
+}
+
+// markRetryable flags responses with a transient status code, so they can be retried.
+func markRetryable(err error) error {
+	var responseErr *vaultapi.ResponseError
+	if errors.As(err, &responseErr) && retry.IsRetryableStatus(responseErr.StatusCode) {
+		return retry.Retryable(err)
+	}
+
+	return err
+}
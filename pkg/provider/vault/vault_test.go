@@ -0,0 +1,629 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	injector "github.com/bank-vaults/vault-sdk/injector/vault"
+	"github.com/bank-vaults/vault-sdk/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/retry"
+)
+
+func TestValidateVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		err       error
+	}{
+		{
+			name:      "Reference without version",
+			reference: "vault:secret/data/account#password",
+		},
+		{
+			name:      "Reference with pinned version",
+			reference: "vault:secret/data/account#password#1",
+		},
+		{
+			name:      "Write reference with data segment",
+			reference: ">>vault:pki/root/generate/internal#certificate#{\"common_name\":\"test\"}",
+		},
+		{
+			name:      "Reference with non-numeric version",
+			reference: "vault:secret/data/account#password#abc",
+			err:       fmt.Errorf("version \"abc\" must be a positive integer"),
+		},
+		{
+			name:      "Reference with zero version",
+			reference: "vault:secret/data/account#password#0",
+			err:       fmt.Errorf("version \"0\" must be a positive integer"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			err := validateVersion(ttp.reference)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestTranslateVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		reference     string
+		wantReference string
+		err           error
+	}{
+		{
+			name:          "Reference without version option",
+			reference:     "vault:secret/data/account#password#2",
+			wantReference: "vault:secret/data/account#password#2",
+		},
+		{
+			name:          "Latest version",
+			reference:     "vault:secret/data/account#password?version=latest",
+			wantReference: "vault:secret/data/account#password",
+		},
+		{
+			name:          "Pinned version",
+			reference:     "vault:secret/data/account#password?version=pinned:3",
+			wantReference: "vault:secret/data/account#password#3",
+		},
+		{
+			name:          "Pinned version keeps other options",
+			reference:     "vault:secret/data/account#password?version=pinned:3&token_file=/creds/token",
+			wantReference: "vault:secret/data/account#password#3?token_file=%2Fcreds%2Ftoken",
+		},
+		{
+			name:      "Version set twice",
+			reference: "vault:secret/data/account#password#2?version=pinned:3",
+			err:       fmt.Errorf("version is set both in the reference and by the version option"),
+		},
+		{
+			name:      "Invalid version option",
+			reference: "vault:secret/data/account#password?version=3",
+			err:       fmt.Errorf("invalid version \"3\", expected latest or pinned:<id>"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			reference, err := translateVersion(ttp.reference)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantReference, reference, "Unexpected reference")
+		})
+	}
+}
+
+func TestLoadSecretsWithTokenFiles(t *testing.T) {
+	// Fake KV v2 engine that returns a different secret for every token
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/app" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"key": "secret-of-" + r.Header.Get("X-Vault-Token")},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+
+	tokenDir := t.TempDir()
+	tenantA := filepath.Join(tokenDir, "tenant-a")
+	require.NoError(t, os.WriteFile(tenantA, []byte("token-a\n"), 0o600))
+	tenantB := filepath.Join(tokenDir, "tenant-b")
+	require.NoError(t, os.WriteFile(tenantB, []byte("token-b\n"), 0o600))
+
+	client, err := vault.NewClientWithOptions(vault.ClientToken("token-default"))
+	require.NoError(t, err)
+
+	p := &Provider{
+		client:       client,
+		tokenClients: make(map[string]*vault.Client),
+	}
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{
+		"DEFAULT_KEY=vault:secret/data/app#key",
+		"TENANT_A_KEY=vault:secret/data/app#key?token_file=" + tenantA,
+		"TENANT_B_KEY=vault:secret/data/app#key?token_file=" + tenantB,
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []provider.Secret{
+		{Key: "DEFAULT_KEY", Value: "secret-of-token-default", Provider: ProviderType, Reference: "vault:secret/data/app#key"},
+		{Key: "TENANT_A_KEY", Value: "secret-of-token-a", Provider: ProviderType, Reference: "vault:secret/data/app#key?token_file=" + tenantA},
+		{Key: "TENANT_B_KEY", Value: "secret-of-token-b", Provider: ProviderType, Reference: "vault:secret/data/app#key?token_file=" + tenantB},
+	}, secrets, "Unexpected secrets")
+	assert.Len(t, p.tokenClients, 2, "Clients should be cached by token file")
+}
+
+func TestSplitNamespace(t *testing.T) {
+	tests := []struct {
+		name          string
+		reference     string
+		wantReference string
+		wantNamespace string
+		err           error
+	}{
+		{
+			name:          "Reference without namespace",
+			reference:     "vault:secret/data/app#key",
+			wantReference: "vault:secret/data/app#key",
+		},
+		{
+			name:          "Reference with namespace",
+			reference:     "vault:ns=team-a:secret/data/app#key",
+			wantReference: "vault:secret/data/app#key",
+			wantNamespace: "team-a",
+		},
+		{
+			name:          "Reference with nested namespace and options",
+			reference:     "vault:ns=org/team-a:secret/data/app#key?token_file=/creds/team-a",
+			wantReference: "vault:secret/data/app#key?token_file=/creds/team-a",
+			wantNamespace: "org/team-a",
+		},
+		{
+			name:      "Reference with empty namespace",
+			reference: "vault:ns=:secret/data/app#key",
+			err:       fmt.Errorf("expected vault:ns=<namespace>:<path>#<key>"),
+		},
+		{
+			name:      "Reference without path",
+			reference: "vault:ns=team-a",
+			err:       fmt.Errorf("expected vault:ns=<namespace>:<path>#<key>"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			reference, namespace, err := splitNamespace(ttp.reference)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, ttp.wantReference, reference, "Unexpected reference")
+				assert.Equal(t, ttp.wantNamespace, namespace, "Unexpected namespace")
+			}
+		})
+	}
+}
+
+func TestLoadSecretsWithNamespaces(t *testing.T) {
+	// Fake KV v2 engine that returns a different secret for every namespace
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/app" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"key": "secret-of-" + r.Header.Get("X-Vault-Namespace")},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_NAMESPACE", "default")
+
+	client, err := vault.NewClientWithOptions(vault.ClientToken("token-default"))
+	require.NoError(t, err)
+
+	p := &Provider{
+		client:       client,
+		tokenClients: make(map[string]*vault.Client),
+	}
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{
+		"DEFAULT_KEY=vault:secret/data/app#key",
+		"TEAM_A_KEY=vault:ns=team-a:secret/data/app#key",
+		"TEAM_B_KEY=vault:ns=team-b:secret/data/app#key",
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []provider.Secret{
+		{Key: "DEFAULT_KEY", Value: "secret-of-default", Provider: ProviderType, Reference: "vault:secret/data/app#key"},
+		{Key: "TEAM_A_KEY", Value: "secret-of-team-a", Provider: ProviderType, Reference: "vault:ns=team-a:secret/data/app#key"},
+		{Key: "TEAM_B_KEY", Value: "secret-of-team-b", Provider: ProviderType, Reference: "vault:ns=team-b:secret/data/app#key"},
+	}, secrets, "Unexpected secrets")
+	assert.Len(t, p.namespaceClients, 2, "Clients should be cached by namespace")
+}
+
+func TestLoadSecretsWithTokenTTL(t *testing.T) {
+	// Fake token lookup reporting the remaining TTL of the login token
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/token/lookup-self" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"id": r.Header.Get("X-Vault-Token"), "ttl": 3600},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+
+	tests := []struct {
+		name           string
+		exposeTokenTTL bool
+		wantSecrets    []provider.Secret
+	}{
+		{
+			name:           "Token TTL exposed",
+			exposeTokenTTL: true,
+			wantSecrets: []provider.Secret{
+				{Key: "VAULT_TOKEN", Value: "token-login", Provider: ProviderType, Reference: "vault:login"},
+				{Key: "VAULT_TOKEN_TTL", Value: "3600", TTL: time.Hour, Provider: ProviderType},
+			},
+		},
+		{
+			name:           "Token TTL not exposed",
+			exposeTokenTTL: false,
+			wantSecrets: []provider.Secret{
+				{Key: "VAULT_TOKEN", Value: "token-login", Provider: ProviderType, Reference: "vault:login"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			client, err := vault.NewClientWithOptions(vault.ClientToken("token-login"))
+			require.NoError(t, err)
+
+			p := &Provider{
+				isLogin:        true,
+				client:         client,
+				tokenClients:   make(map[string]*vault.Client),
+				exposeTokenTTL: ttp.exposeTokenTTL,
+			}
+
+			secrets, err := p.LoadSecrets(context.Background(), []string{"VAULT_TOKEN=vault:login"})
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, ttp.wantSecrets, secrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestLoadSecretsRevokeToken(t *testing.T) {
+	// Fake Vault counting the revocations of the token
+	var revoked int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/revoke-self":
+			revoked++
+			w.WriteHeader(http.StatusNoContent)
+		case "/v1/secret/data/app":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{
+					"data":     map[string]any{"key": "s3cr3t"},
+					"metadata": map[string]any{"version": 1},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+
+	tokenFile := filepath.Join(t.TempDir(), "tenant-a")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token-a\n"), 0o600))
+
+	tests := []struct {
+		name              string
+		isLogin           bool
+		paths             []string
+		supportCleanup    bool
+		wantRevokedOnLoad int
+		wantRevokedOnExit int
+	}{
+		{
+			name:              "Token revoked right after loading",
+			paths:             []string{"DB_PASSWORD=vault:secret/data/app#key"},
+			supportCleanup:    true,
+			wantRevokedOnLoad: 1,
+			wantRevokedOnExit: 1,
+		},
+		{
+			name:              "Tokens of references with their own token revoked along with the default one",
+			paths:             []string{"DB_PASSWORD=vault:secret/data/app#key", "TENANT_KEY=vault:secret/data/app#key?token_file=" + tokenFile},
+			supportCleanup:    true,
+			wantRevokedOnLoad: 2,
+			wantRevokedOnExit: 2,
+		},
+		{
+			name:              "Login token revoked after the process exited",
+			isLogin:           true,
+			paths:             []string{"VAULT_TOKEN=vault:login"},
+			supportCleanup:    true,
+			wantRevokedOnLoad: 0,
+			wantRevokedOnExit: 1,
+		},
+		{
+			name:              "Login token revoked right after loading without cleanup support",
+			isLogin:           true,
+			paths:             []string{"VAULT_TOKEN=vault:login"},
+			wantRevokedOnLoad: 1,
+			wantRevokedOnExit: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			revoked = 0
+
+			var cleanups []func()
+			ctx := context.Background()
+			if ttp.supportCleanup {
+				ctx = provider.WithCleanup(ctx, func(cleanup func()) {
+					cleanups = append(cleanups, cleanup)
+				})
+			}
+
+			client, err := vault.NewClientWithOptions(vault.ClientToken("token-login"))
+			require.NoError(t, err)
+
+			p := &Provider{
+				isLogin:      ttp.isLogin,
+				client:       client,
+				tokenClients: make(map[string]*vault.Client),
+				revokeToken:  true,
+			}
+
+			_, err = p.LoadSecrets(ctx, ttp.paths)
+			require.NoError(t, err)
+			assert.True(t, p.Revoked(), "Provider should not be reused after revoking its token")
+			assert.Equal(t, ttp.wantRevokedOnLoad, revoked, "Unexpected revocations after loading")
+
+			// Simulates the exit of the process
+			for _, cleanup := range cleanups {
+				cleanup()
+			}
+			assert.Equal(t, ttp.wantRevokedOnExit, revoked, "Unexpected revocations after the process exited")
+		})
+	}
+}
+
+func TestLoadSecretsWholeSecret(t *testing.T) {
+	// Fake KV v2 engine holding string, numeric and boolean values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/app" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"name": "app", "port": 5432, "enabled": true, "ratio": 0.5},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+
+	client, err := vault.NewClientWithOptions(vault.ClientToken("token-default"))
+	require.NoError(t, err)
+
+	p := &Provider{
+		client:       client,
+		tokenClients: make(map[string]*vault.Client),
+	}
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{
+		"APP_CONFIG=vault:secret/data/app#*",
+		"APP_CONFIG_V1=vault:secret/data/app#*#1",
+		"APP_PORT=vault:secret/data/app#port",
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []provider.Secret{
+		{Key: "APP_CONFIG", Value: `{"enabled":true,"name":"app","port":5432,"ratio":0.5}`, Provider: ProviderType, Reference: "vault:secret/data/app#*"},
+		{Key: "APP_CONFIG_V1", Value: `{"enabled":true,"name":"app","port":5432,"ratio":0.5}`, Provider: ProviderType, Reference: "vault:secret/data/app#*#1"},
+		{Key: "APP_PORT", Value: "5432", Provider: ProviderType, Reference: "vault:secret/data/app#port"},
+	}, secrets, "Unexpected secrets")
+}
+
+func TestLoadSecretsRetry(t *testing.T) {
+	// Fake KV v2 engine, failing the first read of the flaky secret and every write
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		count := requests[r.Method+" "+r.URL.Path]
+		mu.Unlock()
+
+		if r.Method != http.MethodGet || (r.URL.Path == "/v1/secret/data/flaky" && count == 1) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"key": "value-of-" + r.URL.Path},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+
+	client, err := vault.NewClientWithOptions(vault.ClientToken("token-default"))
+	require.NoError(t, err)
+
+	p := &Provider{
+		client:       client,
+		tokenClients: make(map[string]*vault.Client),
+		retryPolicy:  retry.Policy{MaxAttempts: 3},
+	}
+
+	secrets, err := p.LoadSecrets(context.Background(), []string{
+		"FLAKY_KEY=vault:secret/data/flaky#key",
+		"STABLE_KEY=vault:secret/data/stable#key",
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []provider.Secret{
+		{Key: "FLAKY_KEY", Value: "value-of-/v1/secret/data/flaky", Provider: ProviderType, Reference: "vault:secret/data/flaky#key"},
+		{Key: "STABLE_KEY", Value: "value-of-/v1/secret/data/stable", Provider: ProviderType, Reference: "vault:secret/data/stable#key"},
+	}, secrets, "Unexpected secrets")
+	assert.Equal(t, 2, requests["GET /v1/secret/data/flaky"], "The failed read should be retried")
+	assert.Equal(t, 1, requests["GET /v1/secret/data/stable"], "The secrets read before the failure should not be read again")
+
+	// Writes are never retried, Vault may have applied the failed one already
+	_, err = p.LoadSecrets(context.Background(), []string{"CERT=>>vault:pki/issue/app#certificate"})
+	assert.Error(t, err, "The failed write should fail the load")
+	assert.Equal(t, 1, requests["PUT /v1/pki/issue/app"], "The failed write should not be retried")
+}
+
+func TestSelectWholeSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		reference     string
+		wantReference string
+	}{
+		{
+			name:          "Whole secret",
+			reference:     "vault:secret/data/app#*",
+			wantReference: "vault:secret/data/app#${ toJson . }",
+		},
+		{
+			name:          "Whole secret with version",
+			reference:     "vault:secret/data/app#*#2",
+			wantReference: "vault:secret/data/app#${ toJson . }#2",
+		},
+		{
+			name:          "Single field",
+			reference:     "vault:secret/data/app#port",
+			wantReference: "vault:secret/data/app#port",
+		},
+		{
+			name:          "Write reference",
+			reference:     ">>vault:pki/issue/app#*#{}",
+			wantReference: ">>vault:pki/issue/app#*#{}",
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			assert.Equal(t, ttp.wantReference, selectWholeSecret(ttp.reference), "Unexpected reference")
+		})
+	}
+}
+
+func TestLoadSecretsIgnoreMissingDenied(t *testing.T) {
+	// Fake KV v2 engine denying one path, the other paths are absent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/secret/data/denied" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+
+	tests := []struct {
+		name  string
+		paths []string
+		err   bool
+	}{
+		{
+			name:  "Missing secret is ignored",
+			paths: []string{"MISSING=vault:secret/data/missing#key"},
+		},
+		{
+			name:  "Denied secret is not ignored",
+			paths: []string{"MISSING=vault:secret/data/missing#key", "DENIED=vault:secret/data/denied#key"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			client, err := vault.NewClientWithOptions(vault.ClientToken("token-default"))
+			require.NoError(t, err)
+
+			p := &Provider{
+				client:         client,
+				tokenClients:   make(map[string]*vault.Client),
+				injectorConfig: injector.Config{IgnoreMissingSecrets: true},
+			}
+
+			secrets, err := p.LoadSecrets(context.Background(), ttp.paths)
+			if ttp.err {
+				assert.ErrorContains(t, err, "failed to inject secrets from vault: permission denied, check the policies of the token: failed to read secret from path: secret/data/denied", "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Empty(t, secrets, "Unexpected secrets")
+		})
+	}
+}
+
+func TestGetProviderName(t *testing.T) {
+	assert.Equal(t, "vault", (&Provider{}).GetProviderName(), "Unexpected provider name")
+}
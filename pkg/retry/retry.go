@@ -0,0 +1,133 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxDelay caps the exponential backoff so a large attempt count can't stall startup indefinitely.
+const maxDelay = 30 * time.Second
+
+// Policy describes how many times and how often a failed operation is retried.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error,
+// runs out of attempts or the context is done.
+// The delay between attempts doubles after each failure, starting from BaseDelay.
+func Do(ctx context.Context, policy Policy, fn func() error) error {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn()
+		if err == nil || !IsRetryable(err) || attempt == attempts {
+			return err
+		}
+
+		delay := backoff(policy.BaseDelay, attempt)
+		slog.Warn("retrying after transient error",
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.String("error", err.Error()),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	return err
+}
+
+// Retryable marks err as transient, so Do will retry it regardless of its type.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &retryableError{err: err}
+}
+
+// IsRetryable reports whether err is a transient error worth retrying:
+// network timeouts, HTTP 429 and 503 responses, or errors explicitly marked with Retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var retryableErr *retryableError
+	if errors.As(err, &retryableErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// Used by the AWS SDK request failures
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		return IsRetryableStatus(statusErr.StatusCode())
+	}
+
+	return false
+}
+
+// IsRetryableStatus reports whether an HTTP status code signals a transient failure.
+func IsRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	delay := base << (attempt - 1)
+	if delay <= 0 || delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,115 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       Policy
+		failures     int
+		failWith     error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "Succeed after transient failures",
+			policy:       Policy{MaxAttempts: 4, BaseDelay: time.Millisecond},
+			failures:     3,
+			failWith:     statusError(503),
+			wantAttempts: 4,
+		},
+		{
+			name:         "Give up after max attempts",
+			policy:       Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			failures:     5,
+			failWith:     statusError(429),
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+		{
+			name:         "Do not retry non-retryable errors",
+			policy:       Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			failures:     2,
+			failWith:     statusError(403),
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "Retry explicitly marked errors",
+			policy:       Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			failures:     1,
+			failWith:     Retryable(fmt.Errorf("throttled")),
+			wantAttempts: 2,
+		},
+		{
+			name:         "Zero attempts still calls once",
+			policy:       Policy{},
+			failures:     1,
+			failWith:     statusError(503),
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			attempts := 0
+			err := Do(context.Background(), ttp.policy, func() error {
+				attempts++
+				if attempts <= ttp.failures {
+					return ttp.failWith
+				}
+
+				return nil
+			})
+
+			assert.Equal(t, ttp.wantAttempts, attempts, "Unexpected number of attempts")
+			if ttp.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDoContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := Do(ctx, Policy{MaxAttempts: 5, BaseDelay: time.Hour}, func() error {
+		attempts++
+		return statusError(503)
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts, "Unexpected number of attempts")
+}
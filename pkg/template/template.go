@@ -0,0 +1,131 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package template
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	tokenStart = "${"
+	tokenEnd   = "}"
+)
+
+// token is a ${reference} of a template, start and end index the whole token in the template
+type token struct {
+	reference  string
+	start, end int
+}
+
+// Template is a value with inline references, e.g. scheme://${vault:secret/data/db#user}:${aws:db-password}@db
+type Template struct {
+	value  string
+	tokens []token
+}
+
+// Parse finds the ${reference} tokens of a value.
+// Braces are balanced, so a token may contain templates of its own, e.g. ${vault:secret/data/db#${.password | urlquery}}.
+func Parse(value string) (*Template, error) {
+	var tokens []token
+	for offset := 0; ; {
+		start := strings.Index(value[offset:], tokenStart)
+		if start < 0 {
+			break
+		}
+		start += offset
+
+		end := closingBrace(value, start+len(tokenStart))
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated reference at offset %d", start)
+		}
+
+		reference := value[start+len(tokenStart) : end]
+		if reference == "" {
+			return nil, fmt.Errorf("empty reference at offset %d", start)
+		}
+
+		tokens = append(tokens, token{reference: reference, start: start, end: end + len(tokenEnd)})
+		offset = end + len(tokenEnd)
+	}
+
+	return &Template{value: value, tokens: tokens}, nil
+}
+
+// closingBrace returns the index of the brace closing the token starting before from, or -1.
+func closingBrace(value string, from int) int {
+	depth := 1
+	for i := from; i < len(value); i++ {
+		switch value[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// References returns the distinct references of the template, in the order of their first occurrence.
+func (t *Template) References() []string {
+	var references []string
+	for _, token := range t.tokens {
+		if !slices.Contains(references, token.reference) {
+			references = append(references, token.reference)
+		}
+	}
+
+	return references
+}
+
+// GroupByProvider groups the distinct references by the provider they belong to,
+// so each provider resolves all of its references in a single batch.
+func (t *Template) GroupByProvider(providerOf func(reference string) (string, bool)) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	for _, reference := range t.References() {
+		providerName, ok := providerOf(reference)
+		if !ok {
+			return nil, fmt.Errorf("reference %s is not supported", reference)
+		}
+
+		groups[providerName] = append(groups[providerName], reference)
+	}
+
+	return groups, nil
+}
+
+// Render rebuilds the value with the tokens replaced by the resolved values of their references.
+func (t *Template) Render(values map[string]string) (string, error) {
+	var rendered strings.Builder
+	last := 0
+	for _, token := range t.tokens {
+		value, ok := values[token.reference]
+		if !ok {
+			return "", fmt.Errorf("reference %s was not resolved", token.reference)
+		}
+
+		rendered.WriteString(t.value[last:token.start])
+		rendered.WriteString(value)
+		last = token.end
+	}
+	rendered.WriteString(t.value[last:])
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,114 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package template
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		value          string
+		wantReferences []string
+		err            error
+	}{
+		{
+			name:           "Value with references of several providers",
+			value:          "scheme://${vault:secret/data/db#user}:${arn:aws:secretsmanager:eu-west-1:123456789012:secret:db}@db",
+			wantReferences: []string{"vault:secret/data/db#user", "arn:aws:secretsmanager:eu-west-1:123456789012:secret:db"},
+		},
+		{
+			name:           "Repeated references are listed once",
+			value:          "${file:/secrets/user}/${file:/secrets/user}",
+			wantReferences: []string{"file:/secrets/user"},
+		},
+		{
+			name:           "Reference with a template of its own",
+			value:          "${vault:secret/data/db#${.password | urlquery}}@db",
+			wantReferences: []string{"vault:secret/data/db#${.password | urlquery}"},
+		},
+		{
+			name:  "Value without references",
+			value: "postgres://db:5432",
+		},
+		{
+			name:  "Unterminated reference",
+			value: "scheme://${vault:secret/data/db#user",
+			err:   fmt.Errorf("unterminated reference at offset 9"),
+		},
+		{
+			name:  "Empty reference",
+			value: "scheme://${}",
+			err:   fmt.Errorf("empty reference at offset 9"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			tmpl, err := Parse(ttp.value)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantReferences, tmpl.References(), "Unexpected references")
+		})
+	}
+}
+
+func TestGroupByProvider(t *testing.T) {
+	providerOf := func(reference string) (string, bool) {
+		providerName, _, ok := strings.Cut(reference, ":")
+		return providerName, ok && providerName != "unknown"
+	}
+
+	tmpl, err := Parse("${vault:secret/data/db#user}:${arn:aws:ssm:eu-west-1:123456789012:parameter/db}:${vault:secret/data/db#password}")
+	assert.NoError(t, err, "Unexpected error")
+
+	groups, err := tmpl.GroupByProvider(providerOf)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, map[string][]string{
+		"vault": {"vault:secret/data/db#user", "vault:secret/data/db#password"},
+		"arn":   {"arn:aws:ssm:eu-west-1:123456789012:parameter/db"},
+	}, groups, "Unexpected groups")
+
+	tmpl, err = Parse("${vault:secret/data/db#user}:${unknown:password}")
+	assert.NoError(t, err, "Unexpected error")
+
+	_, err = tmpl.GroupByProvider(providerOf)
+	assert.EqualError(t, err, "reference unknown:password is not supported", "Unexpected error message")
+}
+
+func TestRender(t *testing.T) {
+	tmpl, err := Parse("scheme://${vault:secret/data/db#user}:${arn:aws:secretsmanager:eu-west-1:123456789012:secret:db}@db/${vault:secret/data/db#user}")
+	assert.NoError(t, err, "Unexpected error")
+
+	value, err := tmpl.Render(map[string]string{
+		"vault:secret/data/db#user":                               "root",
+		"arn:aws:secretsmanager:eu-west-1:123456789012:secret:db": "p@ss",
+	})
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "scheme://root:p@ss@db/root", value, "Unexpected value")
+
+	_, err = tmpl.Render(map[string]string{"vault:secret/data/db#user": "root"})
+	assert.EqualError(t, err, "reference arn:aws:secretsmanager:eu-west-1:123456789012:secret:db was not resolved", "Unexpected error message")
+}
@@ -0,0 +1,90 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/utils"
+)
+
+const (
+	Base64    = "base64"
+	Base64URL = "base64url"
+	Hex       = "hex"
+
+	decodeOption = "decode"
+)
+
+// SplitDecode strips the decode option from a reference, so providers only see the reference itself.
+// E.g. vault:secret/data/tls#key?decode=base64 returns vault:secret/data/tls#key and base64.
+// Other options are kept, the encoding is empty if the reference has no decode option.
+func SplitDecode(reference string) (string, string, error) {
+	if !strings.Contains(reference, "?") {
+		return reference, "", nil
+	}
+
+	base, options, err := utils.ParseReferenceOptions(reference)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !options.Has(decodeOption) {
+		return reference, "", nil
+	}
+
+	encoding := options.Get(decodeOption)
+	switch encoding {
+	case Base64, Base64URL, Hex:
+	default:
+		return "", "", fmt.Errorf("unsupported decode option %q, expected one of %s, %s, %s", encoding, Base64, Base64URL, Hex)
+	}
+
+	options.Del(decodeOption)
+	if len(options) > 0 {
+		base += "?" + options.Encode()
+	}
+
+	return base, encoding, nil
+}
+
+// Decode decodes a value returned by a provider.
+// Invalid input fails instead of passing the value through as is.
+func Decode(value string, encoding string) (string, error) {
+	var decoded []byte
+	var err error
+
+	switch encoding {
+	case Base64:
+		// Line breaks of wrapped values (e.g. PEM bodies) are ignored
+		decoded, err = base64.StdEncoding.DecodeString(value)
+	case Base64URL:
+		// Padding is optional in the URL safe variant
+		decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	case Hex:
+		decoded, err = hex.DecodeString(strings.TrimSpace(value))
+	default:
+		return "", fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("invalid %s value: %w", encoding, err)
+	}
+
+	return string(decoded), nil
+}
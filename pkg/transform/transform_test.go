@@ -0,0 +1,136 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitDecode(t *testing.T) {
+	tests := []struct {
+		name          string
+		reference     string
+		wantReference string
+		wantEncoding  string
+		err           error
+	}{
+		{
+			name:          "Reference without options",
+			reference:     "vault:secret/data/tls#key",
+			wantReference: "vault:secret/data/tls#key",
+		},
+		{
+			name:          "Reference with decode option",
+			reference:     "vault:secret/data/tls#key?decode=base64",
+			wantReference: "vault:secret/data/tls#key",
+			wantEncoding:  Base64,
+		},
+		{
+			name:          "Other options are kept",
+			reference:     "arn:aws:secretsmanager:eu-west-1:123456789012:secret:db?expand=true&decode=hex",
+			wantReference: "arn:aws:secretsmanager:eu-west-1:123456789012:secret:db?expand=true",
+			wantEncoding:  Hex,
+		},
+		{
+			name:          "Reference with other options only",
+			reference:     "file:/secrets?dir=concat",
+			wantReference: "file:/secrets?dir=concat",
+		},
+		{
+			name:      "Unsupported encoding",
+			reference: "vault:secret/data/tls#key?decode=rot13",
+			err:       fmt.Errorf("unsupported decode option \"rot13\", expected one of base64, base64url, hex"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			reference, encoding, err := SplitDecode(ttp.reference)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantReference, reference, "Unexpected reference")
+			assert.Equal(t, ttp.wantEncoding, encoding, "Unexpected encoding")
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		encoding  string
+		wantValue string
+		err       error
+	}{
+		{
+			name:      "Base64",
+			value:     "czNjcjN0Pz4=",
+			encoding:  Base64,
+			wantValue: "s3cr3t?>",
+		},
+		{
+			name:      "Base64 with line breaks",
+			value:     "czNjcjN0\nPz4=\n",
+			encoding:  Base64,
+			wantValue: "s3cr3t?>",
+		},
+		{
+			name:      "Base64 URL without padding",
+			value:     "czNjcjN0Pz4",
+			encoding:  Base64URL,
+			wantValue: "s3cr3t?>",
+		},
+		{
+			name:      "Hex",
+			value:     "73336372337400\n",
+			encoding:  Hex,
+			wantValue: "s3cr3t\x00",
+		},
+		{
+			name:     "Invalid base64",
+			value:    "s3cr3t!",
+			encoding: Base64,
+			err:      fmt.Errorf("invalid base64 value: illegal base64 data at input byte 6"),
+		},
+		{
+			name:     "Invalid hex",
+			value:    "s3cr3t",
+			encoding: Hex,
+			err:      fmt.Errorf("invalid hex value: encoding/hex: invalid byte: U+0073 's'"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			value, err := Decode(ttp.value, ttp.encoding)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantValue, value, "Unexpected value")
+		})
+	}
+}
@@ -14,7 +14,15 @@
 
 package utils
 
-import "github.com/bank-vaults/secret-init/pkg/provider"
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
 
 // Checks whether a key is duplicated in the secrets slice
 func IsKeyDuplicated(secrets *[]provider.Secret, searchKey string) bool {
@@ -30,3 +38,103 @@ func IsKeyDuplicated(secrets *[]provider.Secret, searchKey string) bool {
 
 	return false
 }
+
+// ReadLimited reads r until EOF, failing as soon as more than limit bytes are read.
+// A limit of zero or less reads everything.
+func ReadLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("value exceeds the limit of %d bytes set by %s", limit, common.MaxSecretBytesEnv)
+	}
+
+	return content, nil
+}
+
+// ParseReferenceOptions splits the query style options from a secret reference.
+// E.g. arn:aws:secretsmanager:region:account-id:secret:db?expand=true
+// returns the reference without options and expand=true.
+func ParseReferenceOptions(reference string) (string, url.Values, error) {
+	reference, rawOptions, ok := strings.Cut(reference, "?")
+	if !ok {
+		return reference, url.Values{}, nil
+	}
+
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse reference options %q: %w", rawOptions, err)
+	}
+
+	return reference, options, nil
+}
+
+// VersionOption selects the version of a secret the same way with every provider:
+// ?version=latest reads the current version, ?version=pinned:<id> a specific one,
+// with the id in the native format of the provider (e.g. 3 for Vault or GCP, a version id for Azure or AWS).
+const VersionOption = "version"
+
+const pinnedVersionPrefix = "pinned:"
+
+// CutOption removes an option from a reference, keeping the other options.
+// It returns the value of the option and whether the option was set at all.
+func CutOption(reference string, option string) (string, string, bool, error) {
+	strippedReference, rawOptions, ok := strings.Cut(reference, "?")
+	if !ok {
+		return reference, "", false, nil
+	}
+
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to parse reference options %q: %w", rawOptions, err)
+	}
+
+	if !options.Has(option) {
+		return reference, "", false, nil
+	}
+
+	value := options.Get(option)
+	options.Del(option)
+	if len(options) > 0 {
+		strippedReference += "?" + options.Encode()
+	}
+
+	return strippedReference, value, true, nil
+}
+
+// SplitVersion removes the version option from a reference, keeping the other options.
+// It returns the id of the pinned version, which is empty for the latest version,
+// and whether the version option was set at all.
+func SplitVersion(reference string) (string, string, bool, error) {
+	strippedReference, value, ok, err := CutOption(reference, VersionOption)
+	if err != nil || !ok {
+		return strippedReference, "", false, err
+	}
+
+	version, err := ParseVersion(value)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	return strippedReference, version, true, nil
+}
+
+// ParseVersion parses the value of the version option, returning the id of the pinned version,
+// or an empty id for the latest version.
+func ParseVersion(value string) (string, error) {
+	if strings.EqualFold(value, "latest") {
+		return "", nil
+	}
+
+	if version, ok := strings.CutPrefix(value, pinnedVersionPrefix); ok && version != "" {
+		return version, nil
+	}
+
+	return "", fmt.Errorf("invalid version %q, expected latest or pinned:<id>", value)
+}
@@ -0,0 +1,119 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rule constrains an env var of the entrypoint process.
+// Messages never include the value, it is most likely a secret.
+type Rule struct {
+	// Required env vars have to be set, they may be empty unless NonEmpty is set as well
+	Required bool `json:"required"`
+	NonEmpty bool `json:"non_empty"`
+	// MinLength is counted in characters, zero means no minimum
+	MinLength int `json:"min_length"`
+	// Pattern has to match the value, it is not anchored implicitly
+	Pattern string `json:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+// Schema holds the rules by env var key.
+type Schema map[string]Rule
+
+// LoadSchema reads the rules from a JSON file, e.g. {"DB_PASSWORD": {"required": true, "min_length": 12}}.
+func LoadSchema(path string) (Schema, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	var schema Schema
+	err = json.Unmarshal(content, &schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema file %s: %w", path, err)
+	}
+
+	for key, rule := range schema {
+		if rule.Pattern == "" {
+			continue
+		}
+
+		rule.pattern, err = regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %s in schema file %s: %w", key, path, err)
+		}
+
+		schema[key] = rule
+	}
+
+	return schema, nil
+}
+
+// Require adds the keys as required and non-empty to the schema, keeping their other rules.
+func (s Schema) Require(keys ...string) Schema {
+	if s == nil {
+		s = make(Schema, len(keys))
+	}
+
+	for _, key := range keys {
+		rule := s[key]
+		rule.Required = true
+		rule.NonEmpty = true
+		s[key] = rule
+	}
+
+	return s
+}
+
+// Validate checks the environment (KEY=VALUE pairs) against the schema,
+// returning every violation at once, ordered by key.
+func (s Schema) Validate(environ []string) error {
+	values := make(map[string]string, len(environ))
+	for _, env := range environ {
+		key, value, _ := strings.Cut(env, "=")
+		values[key] = value
+	}
+
+	var errs error
+	for _, key := range slices.Sorted(maps.Keys(s)) {
+		rule := s[key]
+		value, ok := values[key]
+		switch {
+		case !ok:
+			if rule.Required {
+				errs = errors.Join(errs, fmt.Errorf("required env var %s is missing", key))
+			}
+		case value == "" && rule.NonEmpty:
+			errs = errors.Join(errs, fmt.Errorf("env var %s is empty", key))
+		case utf8.RuneCountInString(value) < rule.MinLength:
+			errs = errors.Join(errs, fmt.Errorf("env var %s is shorter than %d characters", key, rule.MinLength))
+		case rule.pattern != nil && !rule.pattern.MatchString(value):
+			errs = errors.Join(errs, fmt.Errorf("env var %s does not match the pattern %s", key, rule.Pattern))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,119 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := LoadSchema(newSchemaFile(t, `{
+		"DB_PASSWORD": {"min_length": 8},
+		"DB_PORT": {"pattern": "^[0-9]+$"},
+		"LOG_LEVEL": {"required": true}
+	}`))
+	require.NoError(t, err)
+	schema = schema.Require("DB_PASSWORD", "API_KEY")
+
+	tests := []struct {
+		name    string
+		environ []string
+		err     error
+	}{
+		{
+			name:    "Valid environment",
+			environ: []string{"DB_PASSWORD=3xtr3ms3cr3t", "DB_PORT=5432", "LOG_LEVEL=", "API_KEY=key"},
+		},
+		{
+			name:    "Optional keys may be missing",
+			environ: []string{"DB_PASSWORD=3xtr3ms3cr3t", "LOG_LEVEL=info", "API_KEY=key"},
+		},
+		{
+			name:    "Missing required keys",
+			environ: []string{"DB_PASSWORD=3xtr3ms3cr3t", "DB_PORT=5432"},
+			err:     fmt.Errorf("required env var API_KEY is missing\nrequired env var LOG_LEVEL is missing"),
+		},
+		{
+			name:    "Required key resolved empty",
+			environ: []string{"DB_PASSWORD=3xtr3ms3cr3t", "LOG_LEVEL=info", "API_KEY="},
+			err:     fmt.Errorf("env var API_KEY is empty"),
+		},
+		{
+			name:    "Value too short",
+			environ: []string{"DB_PASSWORD=s3cr3t", "LOG_LEVEL=info", "API_KEY=key"},
+			err:     fmt.Errorf("env var DB_PASSWORD is shorter than 8 characters"),
+		},
+		{
+			name:    "Value not matching the pattern",
+			environ: []string{"DB_PASSWORD=3xtr3ms3cr3t", "DB_PORT=vault:secret/data/db#port", "LOG_LEVEL=info", "API_KEY=key"},
+			err:     fmt.Errorf("env var DB_PORT does not match the pattern ^[0-9]+$"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			err := schema.Validate(ttp.environ)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+		})
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     error
+	}{
+		{
+			name:    "Invalid pattern",
+			content: `{"DB_PORT": {"pattern": "[0-9"}}`,
+			err:     fmt.Errorf("invalid pattern for DB_PORT in schema file %%s: error parsing regexp: missing closing ]: `[0-9`"),
+		},
+		{
+			name:    "Invalid rule",
+			content: `{"DB_PORT": {"min_length": "8"}}`,
+			err:     fmt.Errorf("failed to parse schema file %%s: json: cannot unmarshal string into Go struct field Schema.DB_PORT.min_length of type int"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			path := newSchemaFile(t, ttp.content)
+
+			_, err := LoadSchema(path)
+			assert.EqualError(t, err, fmt.Sprintf(ttp.err.Error(), path), "Unexpected error message")
+		})
+	}
+}
+
+func newSchemaFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
@@ -0,0 +1,59 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+)
+
+// printFlag resolves the secrets and prints them for a shell to eval, e.g. eval "$(secret-init --print)"
+const printFlag = "--print"
+
+// printSecrets resolves the secrets and writes them as shell export lines, no entrypoint process is run.
+// The secrets end up on stdout, so the output must only ever be consumed by eval.
+// Nothing is printed with secret resolution disabled, the references are left in the environment as they are.
+func printSecrets(ctx context.Context, w io.Writer, config *common.Config, envStore *EnvStore) error {
+	if resolutionDisabled(config) {
+		return nil
+	}
+
+	_, secretsEnv, err := loadSecrets(ctx, config, envStore)
+	if err != nil {
+		return err
+	}
+
+	for _, env := range secretsEnv {
+		key, value, _ := strings.Cut(env, "=")
+		_, err = fmt.Fprintf(w, "export %s=%s\n", key, shellQuote(value))
+		if err != nil {
+			return fmt.Errorf("failed to print secrets: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// shellQuote wraps a value in single quotes, within which a shell takes everything literally (newlines included).
+// A single quote can't be escaped inside them, so it closes the quotes, is escaped and reopens them:
+//
+//	it's -> 'it'\''s'
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,132 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{
+			name:  "Plain value",
+			value: "s3cr3t",
+			want:  `'s3cr3t'`,
+		},
+		{
+			name:  "Value with spaces",
+			value: "my secret value",
+			want:  `'my secret value'`,
+		},
+		{
+			name:  "Value with single quotes",
+			value: "it's 'quoted'",
+			want:  `'it'\''s '\''quoted'\'''`,
+		},
+		{
+			name:  "Value with newlines",
+			value: "-----BEGIN KEY-----\nabc\n-----END KEY-----\n",
+			want:  "'-----BEGIN KEY-----\nabc\n-----END KEY-----\n'",
+		},
+		{
+			name:  "Value with shell expansions",
+			value: "$HOME `id` $(id) \\n",
+			want:  "'$HOME `id` $(id) \\n'",
+		},
+		{
+			name:  "Empty value",
+			value: "",
+			want:  `''`,
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			assert.Equal(t, ttp.want, shellQuote(ttp.value), "Unexpected quoting")
+
+			// The shell must read back the value as it was
+			if _, err := exec.LookPath("sh"); err == nil {
+				output, err := exec.Command("sh", "-c", "printf %s "+shellQuote(ttp.value)).Output()
+				assert.NoError(t, err, "Unexpected error")
+				assert.Equal(t, ttp.value, string(output), "Unexpected value read by the shell")
+			}
+		})
+	}
+}
+
+func TestPrintSecrets(t *testing.T) {
+	secretFile := newSecretFile(t, "it's a secret")
+	t.Setenv("API_KEY", "file:"+secretFile)
+
+	var output bytes.Buffer
+	err := printSecrets(context.Background(), &output, &common.Config{}, NewEnvStore(&common.Config{}))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "export API_KEY='it'\\''s a secret'\n", output.String(), "Unexpected output")
+}
+
+func TestPrintSecretsDisabled(t *testing.T) {
+	secretFile := newSecretFile(t, "secret")
+	t.Setenv("API_KEY", "file:"+secretFile)
+
+	config := &common.Config{Disable: true}
+
+	var output bytes.Buffer
+	err := printSecrets(context.Background(), &output, config, NewEnvStore(config))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Empty(t, output.String(), "Unexpected output")
+}
+
+func TestPrintSecretsToFile(t *testing.T) {
+	secretFile := newSecretFile(t, "-----BEGIN CERTIFICATE-----")
+	targetPath := filepath.Join(t.TempDir(), "tls", "tls.crt")
+	t.Setenv("TLS_CRT", "file:"+secretFile+"?file="+targetPath)
+
+	config := &common.Config{}
+
+	var output bytes.Buffer
+	err := printSecrets(context.Background(), &output, config, NewEnvStore(config))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "export TLS_CRT='"+targetPath+"'\n", output.String(), "Unexpected output")
+
+	content, err := os.ReadFile(targetPath)
+	assert.NoError(t, err, "Secret file not written")
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----", string(content), "Unexpected secret file content")
+}
+
+func TestPrintSecretsRequired(t *testing.T) {
+	secretFile := newSecretFile(t, "secret")
+	t.Setenv("API_KEY", "file:"+secretFile)
+
+	config := &common.Config{Require: []string{"DB_PASSWORD"}}
+
+	var output bytes.Buffer
+	err := printSecrets(context.Background(), &output, config, NewEnvStore(config))
+	assert.Error(t, err, "Missing required env var not detected")
+	assert.Empty(t, output.String(), "Unexpected output")
+}
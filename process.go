@@ -0,0 +1,248 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+)
+
+// newCommand sets up the entrypoint process with the standard streams of secret-init.
+// The extra files are inherited as file descriptors 3, 4, ... in their order,
+// with CloseStdin the process reads from the null device instead of stdin.
+func newCommand(config *common.Config, binaryPath string, binaryArgs []string, env []string, stdin io.Reader, extraFiles []*os.File) *exec.Cmd {
+	cmd := exec.Command(binaryPath, binaryArgs...)
+	cmd.Env = env
+	cmd.Stdin = stdin
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	cmd.ExtraFiles = extraFiles
+
+	if config.CloseStdin {
+		cmd.Stdin = nil
+	}
+
+	return cmd
+}
+
+// inheritedFiles opens the file descriptors of secret-init passed on to the process.
+// They are checked up front, starting the process would fail with a less telling error.
+func inheritedFiles(fds []int) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(fds))
+	for _, fd := range fds {
+		_, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inherit file descriptor %d: %w", fd, err)
+		}
+
+		files = append(files, os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd)))
+	}
+
+	return files, nil
+}
+
+// forwardSignals passes every received signal to the process.
+// Receiving the stop signal starts a graceful stop:
+// the process gets stopTimeout to exit before it is killed.
+// With reload set, SIGHUP is not forwarded, it calls reload instead.
+func forwardSignals(cmd *exec.Cmd, sigs <-chan os.Signal, stopSignal syscall.Signal, stopTimeout time.Duration, reload func()) {
+	var stopOnce sync.Once
+	var killTimer *time.Timer
+
+	for sig := range sigs {
+		slog.Info("received signal", slog.String("signal", sig.String()))
+
+		if sig == syscall.SIGHUP && reload != nil {
+			slog.Info("reloading secrets on SIGHUP")
+			reload()
+
+			continue
+		}
+
+		// The process state is owned by cmd.Wait, an exited process is only told apart by the failed signal
+		err := cmd.Process.Signal(sig)
+		if errors.Is(err, os.ErrProcessDone) {
+			break
+		}
+		if err != nil {
+			slog.Warn(
+				fmt.Errorf("failed to signal process: %w", err).Error(),
+				slog.String("signal", sig.String()),
+			)
+		}
+
+		if sig == stopSignal {
+			stopOnce.Do(func() {
+				killTimer = time.AfterFunc(stopTimeout, func() {
+					slog.Info("killing process due to stop timeout", slog.Duration("timeout", stopTimeout))
+
+					// Fails harmlessly if the process has already exited
+					_ = cmd.Process.Kill()
+				})
+			})
+		}
+	}
+
+	// The signal channel is closed once the process has exited
+	if killTimer != nil {
+		killTimer.Stop()
+	}
+}
+
+// signalRelay catches the signals sent to secret-init while the process runs.
+type signalRelay struct {
+	sigs chan os.Signal
+	// done is closed once the forwarding has finished, it is nil without forwarding
+	done chan struct{}
+}
+
+// catchSignals starts catching the given signals, all of them if none is given.
+// They are dropped unless forwarded to the process.
+func catchSignals(signals ...os.Signal) *signalRelay {
+	relay := &signalRelay{sigs: make(chan os.Signal, 1)}
+	signal.Notify(relay.sigs, signals...)
+
+	return relay
+}
+
+// forward passes the caught signals to the started process with forwardSignals.
+func (r *signalRelay) forward(cmd *exec.Cmd, stopSignal syscall.Signal, stopTimeout time.Duration, reload func()) {
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		forwardSignals(cmd, r.sigs, stopSignal, stopTimeout, reload)
+	}()
+}
+
+// stop stops catching signals and waits for the forwarding to finish,
+// so neither the process is signaled nor a reload is requested once it has returned.
+func (r *signalRelay) stop() {
+	// No signal is delivered to the channel after Stop has returned, so it is safe to close
+	signal.Stop(r.sigs)
+	close(r.sigs)
+
+	if r.done != nil {
+		<-r.done
+	}
+}
+
+// processSignaler signals the running process on behalf of the providers, e.g. when leases are renewed.
+// Providers outlive the process in daemon mode, so the process is looked up when signaling.
+type processSignaler struct {
+	mu  sync.Mutex
+	cmd *exec.Cmd
+}
+
+// set makes the started process the one to signal.
+func (s *processSignaler) set(cmd *exec.Cmd) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cmd = cmd
+}
+
+func (s *processSignaler) signal(sig os.Signal) {
+	s.mu.Lock()
+	cmd := s.cmd
+	s.mu.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		slog.Warn("no process to signal", slog.String("signal", sig.String()))
+		return
+	}
+
+	err := cmd.Process.Signal(sig)
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		slog.Warn(fmt.Errorf("failed to signal process: %w", err).Error(), slog.String("signal", sig.String()))
+	}
+}
+
+// stopProcess sends the stop signal to the process, then kills it if it doesn't exit within the timeout.
+// It returns once the process has exited, as reported on the exited channel.
+func stopProcess(cmd *exec.Cmd, exited <-chan error, stopSignal syscall.Signal, stopTimeout time.Duration) {
+	err := cmd.Process.Signal(stopSignal)
+	if err != nil {
+		slog.Warn(fmt.Errorf("failed to signal process: %w", err).Error(), slog.String("signal", stopSignal.String()))
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(stopTimeout):
+		slog.Info("killing process due to stop timeout", slog.Duration("timeout", stopTimeout))
+
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// exitCode returns the exit code of a finished process.
+// Processes terminated by a signal follow the shell convention of 128 + signal number.
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return state.ExitCode()
+}
+
+// exitCleanups collects the cleanups registered by the providers, to run once the process has exited.
+type exitCleanups struct {
+	mu       sync.Mutex
+	cleanups []func()
+}
+
+func (c *exitCleanups) register(cleanup func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cleanups = append(c.cleanups, cleanup)
+}
+
+// run runs the registered cleanups in reverse order of registration and forgets them.
+func (c *exitCleanups) run() {
+	c.mu.Lock()
+	cleanups := c.cleanups
+	c.cleanups = nil
+	c.mu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
+// detach forgets the registered cleanups and returns them, so they are kept apart from the ones registered later
+// (e.g. by the load of a restart, while the old process is still running).
+func (c *exitCleanups) detach() *exitCleanups {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	detached := &exitCleanups{cleanups: c.cleanups}
+	c.cleanups = nil
+
+	return detached
+}
@@ -0,0 +1,289 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestForwardSignals(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       string
+		wantExitCode int
+	}{
+		{
+			name:         "Process exits gracefully on stop signal",
+			script:       `trap 'exit 3' TERM; while true; do sleep 0.1; done`,
+			wantExitCode: 3,
+		},
+		{
+			name:         "Process is killed after stop timeout",
+			script:       `trap '' TERM; while true; do sleep 0.1; done`,
+			wantExitCode: 128 + int(syscall.SIGKILL),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			cmd := exec.Command("/bin/sh", "-c", ttp.script)
+			require.NoError(t, cmd.Start())
+
+			sigs := make(chan os.Signal, 1)
+			go forwardSignals(cmd, sigs, syscall.SIGTERM, 500*time.Millisecond, nil)
+
+			// Give the shell time to install its trap
+			time.Sleep(200 * time.Millisecond)
+			sigs <- syscall.SIGTERM
+
+			_ = cmd.Wait()
+			close(sigs)
+
+			assert.Equal(t, ttp.wantExitCode, exitCode(cmd.ProcessState), "Unexpected exit code")
+		})
+	}
+}
+
+func TestForwardSignalsReloadOnSighup(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", `trap 'exit 3' TERM; trap 'exit 4' HUP; while true; do sleep 0.1; done`)
+	require.NoError(t, cmd.Start())
+
+	reloads := make(chan struct{}, 1)
+	sigs := make(chan os.Signal, 1)
+	go forwardSignals(cmd, sigs, syscall.SIGTERM, 500*time.Millisecond, func() {
+		reloads <- struct{}{}
+	})
+
+	// Give the shell time to install its traps
+	time.Sleep(200 * time.Millisecond)
+	sigs <- syscall.SIGHUP
+
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload was not requested on SIGHUP")
+	}
+
+	sigs <- syscall.SIGTERM
+
+	_ = cmd.Wait()
+	close(sigs)
+
+	assert.Equal(t, 3, exitCode(cmd.ProcessState), "SIGHUP should not be forwarded to the process")
+}
+
+func TestSignalRelayRapidExit(t *testing.T) {
+	// Keeps SIGHUP from terminating the test between two relays
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, syscall.SIGHUP)
+	defer signal.Stop(guard)
+
+	// Signals keep arriving while the processes exit and the relays are stopped
+	stopSending := make(chan struct{})
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for {
+			select {
+			case <-stopSending:
+				return
+			default:
+				_ = syscall.Kill(os.Getpid(), syscall.SIGHUP)
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	defer func() {
+		close(stopSending)
+		<-sent
+	}()
+
+	for i := 0; i < 20; i++ {
+		cmd := exec.Command("/bin/true")
+		relay := catchSignals(syscall.SIGHUP)
+		require.NoError(t, cmd.Start())
+
+		var reloads atomic.Int32
+		relay.forward(cmd, syscall.SIGTERM, time.Second, func() { reloads.Add(1) })
+
+		_ = cmd.Wait()
+		relay.stop()
+
+		// Nothing is reloaded once the relay is stopped
+		stoppedReloads := reloads.Load()
+		time.Sleep(5 * time.Millisecond)
+		assert.Equal(t, stoppedReloads, reloads.Load(), "Reload requested after the relay was stopped")
+		assert.Equal(t, 0, exitCode(cmd.ProcessState), "SIGHUP should not be forwarded to the process")
+	}
+}
+
+func TestStopProcess(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", `trap '' TERM; while true; do sleep 0.1; done`)
+	require.NoError(t, cmd.Start())
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	// Give the shell time to install its trap
+	time.Sleep(200 * time.Millisecond)
+	stopProcess(cmd, exited, syscall.SIGTERM, 200*time.Millisecond)
+
+	assert.Equal(t, 128+int(syscall.SIGKILL), exitCode(cmd.ProcessState), "Unexpected exit code")
+}
+
+func TestExitCleanups(t *testing.T) {
+	var order []int
+	cleanups := &exitCleanups{}
+	cleanups.register(func() { order = append(order, 1) })
+	cleanups.register(func() { order = append(order, 2) })
+
+	cleanups.run()
+	assert.Equal(t, []int{2, 1}, order, "Cleanups should run in reverse order of registration")
+
+	// Cleanups only run once, the next process registers its own
+	cleanups.run()
+	assert.Equal(t, []int{2, 1}, order, "Cleanups should not run twice")
+}
+
+func TestExitCleanupsDetach(t *testing.T) {
+	var ran []string
+	cleanups := &exitCleanups{}
+	cleanups.register(func() { ran = append(ran, "old") })
+
+	detached := cleanups.detach()
+	cleanups.register(func() { ran = append(ran, "new") })
+
+	detached.run()
+	assert.Equal(t, []string{"old"}, ran, "Only the detached cleanups should run")
+
+	cleanups.run()
+	assert.Equal(t, []string{"old", "new"}, ran, "Cleanups registered after detaching should be kept")
+}
+
+func TestNewCommand(t *testing.T) {
+	// Prints the inherited file descriptors above the standard streams, then the content of stdin
+	script := `for fd in 3 4 5 6; do if { true >&$fd; } 2>/dev/null; then echo "fd $fd"; fi; done; cat`
+
+	tests := []struct {
+		name       string
+		config     *common.Config
+		extraFiles int
+		want       string
+	}{
+		{
+			name:   "Standard streams only",
+			config: &common.Config{},
+			want:   "stdin",
+		},
+		{
+			name:       "Extra files",
+			config:     &common.Config{},
+			extraFiles: 2,
+			want:       "fd 3\nfd 4\nstdin",
+		},
+		{
+			name:   "Closed stdin",
+			config: &common.Config{CloseStdin: true},
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			// Raw descriptors, so they are only owned by the inherited files
+			var fds []int
+			for i := 0; i < ttp.extraFiles; i++ {
+				fd, err := unix.Open(os.DevNull, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+				require.NoError(t, err)
+
+				fds = append(fds, fd)
+			}
+
+			extraFiles, err := inheritedFiles(fds)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				for _, file := range extraFiles {
+					file.Close()
+				}
+			})
+
+			cmd := newCommand(ttp.config, "/bin/sh", []string{"-c", script}, nil, bytes.NewBufferString("stdin"), extraFiles)
+			var stdout bytes.Buffer
+			cmd.Stdout = &stdout
+			require.NoError(t, cmd.Run())
+
+			assert.Equal(t, ttp.want, stdout.String(), "Unexpected output of the process")
+		})
+	}
+}
+
+func TestInheritedFilesInvalid(t *testing.T) {
+	_, err := inheritedFiles([]int{1000})
+	assert.EqualError(t, err, "failed to inherit file descriptor 1000: bad file descriptor", "Unexpected error message")
+}
+
+func TestProcessSignaler(t *testing.T) {
+	signaler := &processSignaler{}
+
+	// Nothing is signaled before a process has started
+	signaler.signal(syscall.SIGUSR1)
+
+	// The process exits successfully only once it received the signal
+	cmd := exec.Command("/bin/sh", "-c", `trap 'exit 0' USR1; while true; do sleep 0.05; done`)
+	require.NoError(t, cmd.Start())
+	signaler.set(cmd)
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	// A renewer reaches the process through the context it was created with
+	signal := provider.SignalFunc(provider.WithSignal(context.Background(), signaler.signal))
+
+	// Give the shell time to install its trap
+	time.Sleep(200 * time.Millisecond)
+	signal(syscall.SIGUSR1)
+
+	select {
+	case err := <-exited:
+		assert.NoError(t, err, "Process should exit on the renew signal")
+	case <-time.After(2 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatal("Process did not receive the renew signal")
+	}
+
+	// An exited process is not signaled anymore
+	signaler.signal(syscall.SIGUSR1)
+}
@@ -0,0 +1,147 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"time"
+
+	"github.com/bank-vaults/secret-init/pkg/provider/bao"
+	"github.com/bank-vaults/secret-init/pkg/provider/file"
+	"github.com/bank-vaults/secret-init/pkg/provider/vault"
+)
+
+// selfReloadingTypes are left out of periodic reloads: vault and bao renew their leases
+// (dynamic secrets would differ on every load) and file watches the files,
+// composed and template values may depend on their references.
+var selfReloadingTypes = []string{vault.ProviderType, bao.ProviderType, file.ProviderType, composeProviderType, templateProviderType}
+
+// periodicReloader resolves the secrets periodically and requests a restart of the process once they changed.
+// The secrets resolved when it first starts are the baseline of the comparison,
+// it is kept when the reloader is stopped and started again.
+type periodicReloader struct {
+	interval time.Duration
+	load     func(ctx context.Context) (map[string]string, error)
+	restart  func()
+	// jitter returns the random part of a wait, it is within ±10% of the interval by default
+	jitter func(interval time.Duration) time.Duration
+	// baseline holds the values of the last load, nil until a load succeeded
+	baseline map[string]string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newPeriodicReloader(interval time.Duration, load func(ctx context.Context) (map[string]string, error), restart func()) *periodicReloader {
+	return &periodicReloader{
+		interval: interval,
+		load:     load,
+		restart:  restart,
+		jitter: func(interval time.Duration) time.Duration {
+			return rand.N(interval/5+1) - interval/10
+		},
+	}
+}
+
+// start runs the reloader until it is stopped or has requested a restart.
+func (r *periodicReloader) start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		r.run(ctx)
+	}()
+}
+
+// stop returns once a running load is done, so the secrets are never loaded concurrently.
+// It is safe to call on a nil reloader.
+func (r *periodicReloader) stop() {
+	if r == nil || r.cancel == nil {
+		return
+	}
+
+	r.cancel()
+	<-r.done
+}
+
+func (r *periodicReloader) run(ctx context.Context) {
+	if r.baseline == nil {
+		var err error
+		r.baseline, err = r.load(ctx)
+		if err != nil {
+			slog.Warn(fmt.Errorf("failed to reload secrets: %w", err).Error())
+		}
+	}
+
+	for {
+		timer := time.NewTimer(r.interval + r.jitter(r.interval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		values, err := r.load(ctx)
+		if err != nil {
+			slog.Warn(fmt.Errorf("failed to reload secrets: %w", err).Error())
+			continue
+		}
+
+		// Without a baseline, changes can't be told apart, the first successful load becomes the baseline then
+		if r.baseline != nil && !maps.Equal(r.baseline, values) {
+			slog.Info("secrets changed since the last load")
+			r.restart()
+
+			return
+		}
+
+		r.baseline = values
+	}
+}
+
+// reloadReferences returns the references reloaded periodically, nil if there are none.
+func reloadReferences(envStore *EnvStore) map[string][]string {
+	references := envStore.GetSecretReferences()
+	for _, providerType := range selfReloadingTypes {
+		delete(references, providerType)
+	}
+
+	if len(references) == 0 {
+		return nil
+	}
+
+	return references
+}
+
+// reloadValues loads the references reloaded periodically and returns the values by env var.
+func reloadValues(ctx context.Context, envStore *EnvStore) (map[string]string, error) {
+	secrets, err := envStore.LoadProviderSecrets(ctx, reloadReferences(envStore))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		values[secret.Key] = secret.Value
+	}
+
+	return values, nil
+}
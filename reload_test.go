@@ -0,0 +1,149 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// rotatingProvider serves v1 on the first load, then the value set in rotated
+type rotatingProvider struct {
+	loads   atomic.Int32
+	rotated string
+}
+
+func (p *rotatingProvider) GetProviderName() string {
+	return "rotating"
+}
+
+func (p *rotatingProvider) LoadSecrets(_ context.Context, paths []string) ([]provider.Secret, error) {
+	value := p.rotated
+	if p.loads.Add(1) == 1 {
+		value = "v1"
+	}
+
+	var secrets []provider.Secret
+	for _, path := range paths {
+		key, _, _ := strings.Cut(path, "=")
+		secrets = append(secrets, provider.Secret{Key: key, Value: value})
+	}
+
+	return secrets, nil
+}
+
+func newRotatingEnvStore(rotatingProvider *rotatingProvider) *EnvStore {
+	envStore := NewEnvStore(&common.Config{})
+	envStore.RegisterFactory(provider.Factory{
+		ProviderType: "rotating",
+		Validator:    func(envValue string) bool { return strings.HasPrefix(envValue, "rotating:") },
+		Create: func(_ context.Context, _ *common.Config) (provider.Provider, error) {
+			return rotatingProvider, nil
+		},
+	})
+	envStore.AddReferences(map[string]string{
+		"API_TOKEN":   "rotating:token",
+		"DB_PASSWORD": "vault:secret/data/db#password",
+	})
+
+	return envStore
+}
+
+func TestPeriodicReloaderRestartsOnChange(t *testing.T) {
+	rotatingProvider := &rotatingProvider{rotated: "v2"}
+	envStore := newRotatingEnvStore(rotatingProvider)
+
+	restarted := make(chan struct{})
+	reloader := newPeriodicReloader(10*time.Millisecond, func(ctx context.Context) (map[string]string, error) {
+		return reloadValues(ctx, envStore)
+	}, func() { close(restarted) })
+	reloader.jitter = func(time.Duration) time.Duration { return 0 }
+	reloader.start(context.Background())
+	defer reloader.stop()
+
+	select {
+	case <-restarted:
+	case <-time.After(time.Second):
+		t.Fatal("Restart was not requested after the secret changed")
+	}
+
+	assert.Equal(t, int32(2), rotatingProvider.loads.Load(), "Unexpected number of loads")
+}
+
+func TestPeriodicReloaderKeepsUnchanged(t *testing.T) {
+	rotatingProvider := &rotatingProvider{rotated: "v1"}
+	envStore := newRotatingEnvStore(rotatingProvider)
+
+	var restarts atomic.Int32
+	reloader := newPeriodicReloader(10*time.Millisecond, func(ctx context.Context) (map[string]string, error) {
+		return reloadValues(ctx, envStore)
+	}, func() { restarts.Add(1) })
+	reloader.jitter = func(time.Duration) time.Duration { return 0 }
+	reloader.start(context.Background())
+
+	time.Sleep(100 * time.Millisecond)
+	reloader.stop()
+
+	assert.Greater(t, rotatingProvider.loads.Load(), int32(2), "Secrets must be reloaded periodically")
+	assert.Zero(t, restarts.Load(), "Restart must not be requested for unchanged secrets")
+}
+
+func TestReloadReferences(t *testing.T) {
+	envStore := newRotatingEnvStore(&rotatingProvider{})
+
+	references := reloadReferences(envStore)
+	assert.Equal(t, []string{"API_TOKEN=rotating:token"}, references["rotating"], "Unexpected reloaded references")
+	assert.NotContains(t, references, "vault", "Vault references must not be reloaded")
+}
+
+func TestPeriodicReloaderJitter(t *testing.T) {
+	reloader := newPeriodicReloader(time.Minute, nil, nil)
+
+	for i := 0; i < 100; i++ {
+		jitter := reloader.jitter(time.Minute)
+		assert.LessOrEqual(t, jitter.Abs(), 6*time.Second, "Jitter must be within a tenth of the interval")
+	}
+}
+
+func TestPeriodicReloaderKeepsBaseline(t *testing.T) {
+	rotatingProvider := &rotatingProvider{rotated: "v2"}
+	envStore := newRotatingEnvStore(rotatingProvider)
+
+	var restarts atomic.Int32
+	reloader := newPeriodicReloader(time.Hour, func(ctx context.Context) (map[string]string, error) {
+		return reloadValues(ctx, envStore)
+	}, func() { restarts.Add(1) })
+	reloader.start(context.Background())
+	assert.Eventually(t, func() bool { return rotatingProvider.loads.Load() == 1 }, time.Second, time.Millisecond, "Baseline was not loaded")
+	reloader.stop()
+	assert.Equal(t, map[string]string{"API_TOKEN": "v1"}, reloader.baseline, "Unexpected baseline")
+
+	// A reloader started again compares against the secrets it loaded first
+	reloader.interval = 10 * time.Millisecond
+	reloader.jitter = func(time.Duration) time.Duration { return 0 }
+	reloader.start(context.Background())
+	assert.Eventually(t, func() bool { return restarts.Load() == 1 }, time.Second, 10*time.Millisecond, "Restart was not requested after the secret changed")
+	reloader.stop()
+
+	assert.Equal(t, int32(2), rotatingProvider.loads.Load(), "The baseline should not be loaded again")
+}
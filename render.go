@@ -0,0 +1,84 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// dotenvEscaper escapes the characters that can't be taken literally within double quotes,
+// a $ would be expanded as a variable (e.g. $VAR or ${VAR}) and a ` would run a command when the file is sourced otherwise
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`)
+
+// renderSecrets writes the secrets as a KEY=VALUE dotenv file readable only by the current user.
+// This keeps secrets out of the process environment, which is exposed via /proc/<pid>/environ.
+func renderSecrets(path string, secrets []provider.Secret) error {
+	return writeFileAtomically(path, renderDotenv(secrets))
+}
+
+// renderDotenv renders the secrets the same way they would be set as env vars, one KEY=VALUE line each.
+// Values a dotenv loader would not read back as they are (e.g. multiline, quoted, with a #, a $ or surrounding spaces)
+// are wrapped in double quotes, with backslashes, double quotes, dollar signs, backticks and line breaks escaped.
+func renderDotenv(secrets []provider.Secret) []byte {
+	var content strings.Builder
+	for _, secret := range secrets {
+		value, ok := envValue(secret)
+		if !ok {
+			continue
+		}
+
+		if strings.ContainsAny(value, "\n\r\"'#$\\`") || strings.TrimSpace(value) != value {
+			value = `"` + dotenvEscaper.Replace(value) + `"`
+		}
+
+		content.WriteString(secret.Key + "=" + value + "\n")
+	}
+
+	return []byte(content.String())
+}
+
+// writeFileAtomically writes a file readable only by the current user.
+// The content is written to a temporary file first and then renamed, so readers never see a partial file.
+func writeFileAtomically(path string, content []byte) error {
+	// CreateTemp creates the file with 0600 permissions
+	file, err := os.CreateTemp(filepath.Dir(path), ".secret-init-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return fmt.Errorf("failed to move file to %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestRenderSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.env")
+
+	err := renderSecrets(path, []provider.Secret{
+		{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t"},
+		{Key: "AWS_ACCESS_KEY_ID", Value: "secretId"},
+	})
+	assert.Nil(t, err, "Unexpected error")
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err, "Failed to read rendered file")
+	assert.Equal(t, "MYSQL_PASSWORD=3xtr3ms3cr3t\nAWS_ACCESS_KEY_ID=secretId\n", string(content), "Unexpected file content")
+
+	fileInfo, err := os.Stat(path)
+	assert.Nil(t, err, "Failed to stat rendered file")
+	assert.Equal(t, os.FileMode(0o600), fileInfo.Mode().Perm(), "Unexpected file permissions")
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err, "Failed to read directory")
+	assert.Len(t, entries, 1, "Temporary file was not cleaned up")
+}
+
+func TestRenderDotenv(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []provider.Secret
+		want    string
+	}{
+		{
+			name:    "Plain value",
+			secrets: []provider.Secret{{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t"}},
+			want:    "MYSQL_PASSWORD=3xtr3ms3cr3t\n",
+		},
+		{
+			name:    "Multiline value",
+			secrets: []provider.Secret{{Key: "TLS_KEY", Value: "-----BEGIN KEY-----\nabc\r\n-----END KEY-----"}},
+			want:    `TLS_KEY="-----BEGIN KEY-----\nabc\r\n-----END KEY-----"` + "\n",
+		},
+		{
+			name:    "Value with double quotes and backslash",
+			secrets: []provider.Secret{{Key: "JSON", Value: `{"path":"C:\tmp"}`}},
+			want:    `JSON="{\"path\":\"C:\\tmp\"}"` + "\n",
+		},
+		{
+			name:    "Value with leading and trailing spaces",
+			secrets: []provider.Secret{{Key: "PADDED", Value: " secret "}},
+			want:    "PADDED=\" secret \"\n",
+		},
+		{
+			name:    "Value with comment character",
+			secrets: []provider.Secret{{Key: "PASSWORD", Value: "pass#word"}},
+			want:    "PASSWORD=\"pass#word\"\n",
+		},
+		{
+			name:    "Value with dollar signs",
+			secrets: []provider.Secret{{Key: "PASSWORD", Value: "pa$word${HOME}"}},
+			want:    `PASSWORD="pa\$word\${HOME}"` + "\n",
+		},
+		{
+			name:    "Value with backticks",
+			secrets: []provider.Secret{{Key: "PASSWORD", Value: "pa`id`word"}},
+			want:    "PASSWORD=\"pa\\`id\\`word\"\n",
+		},
+		{
+			name: "File and binary secrets",
+			secrets: []provider.Secret{
+				{Key: "CERT", Value: "data", FilePath: "/tmp/secrets/cert"},
+				{Key: "BLOB", Value: "\x00\x01", Binary: true},
+			},
+			want: "CERT=/tmp/secrets/cert\n",
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			assert.Equal(t, ttp.want, string(renderDotenv(ttp.secrets)), "Unexpected rendered content")
+		})
+	}
+}
+
+func TestRenderDotenvSourced(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	// Sourcing the file must neither expand variables nor run commands
+	value := "pa$word ${HOME} `id` $(id) \\ \"quoted\" it's"
+	path := filepath.Join(t.TempDir(), "secrets.env")
+	err := renderSecrets(path, []provider.Secret{{Key: "PASSWORD", Value: value}})
+	assert.NoError(t, err, "Unexpected error")
+
+	output, err := exec.Command("sh", "-c", `. "$0" && printf %s "$PASSWORD"`, path).Output()
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, value, string(output), "Unexpected value read by the shell")
+}
@@ -0,0 +1,120 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+const (
+	referenceSucceeded = "succeeded"
+	// referenceSkipped marks references that were not injected without failing on their own,
+	// e.g. missing secrets ignored by the provider, or references of a load aborted by another provider
+	referenceSkipped = "skipped"
+	referenceFailed  = "failed"
+)
+
+type loadResult struct {
+	Succeeded  int               `json:"succeeded"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	References []referenceResult `json:"references"`
+}
+
+// referenceResult describes the outcome of a reference.
+// Neither values nor errors are recorded, since errors may quote secret data.
+type referenceResult struct {
+	Key      string `json:"key"`
+	Provider string `json:"provider"`
+	Status   string `json:"status"`
+}
+
+// newLoadResult matches the requested references with the loaded secrets and the providers that failed.
+func newLoadResult(secretReferences map[string][]string, secrets []provider.Secret, loadErr error) *loadResult {
+	loadedKeys := make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		loadedKeys[secret.Key] = true
+	}
+
+	failedProviders := make(map[string]bool)
+	collectFailedProviders(loadErr, failedProviders)
+
+	result := &loadResult{References: []referenceResult{}}
+	for providerName, paths := range secretReferences {
+		for _, path := range paths {
+			key, _, _ := strings.Cut(path, "=")
+
+			status := referenceSkipped
+			switch {
+			case failedProviders[providerName]:
+				status = referenceFailed
+			case loadErr == nil && loadedKeys[key]:
+				status = referenceSucceeded
+			}
+
+			switch status {
+			case referenceSucceeded:
+				result.Succeeded++
+			case referenceSkipped:
+				result.Skipped++
+			case referenceFailed:
+				result.Failed++
+			}
+
+			result.References = append(result.References, referenceResult{
+				Key:      key,
+				Provider: providerName,
+				Status:   status,
+			})
+		}
+	}
+
+	slices.SortFunc(result.References, func(a, b referenceResult) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return result
+}
+
+// collectFailedProviders walks the joined loading errors for the providers that failed.
+func collectFailedProviders(err error, failedProviders map[string]bool) {
+	var providerErr *providerError
+	if errors.As(err, &providerErr) {
+		failedProviders[providerErr.providerName] = true
+	}
+
+	if joinedErr, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joinedErr.Unwrap() {
+			collectFailedProviders(err, failedProviders)
+		}
+	}
+}
+
+// writeLoadResult writes the outcome of the secret loading as JSON,
+// so the entrypoint process or the orchestrator can react to skipped references.
+func writeLoadResult(path string, result *loadResult) error {
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal load result: %w", err)
+	}
+
+	return writeFileAtomically(path, append(content, '\n'))
+}
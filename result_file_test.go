@@ -0,0 +1,111 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestNewLoadResult(t *testing.T) {
+	secretReferences := map[string][]string{
+		"vault": {
+			"MYSQL_PASSWORD=vault:secret/data/test/mysql#MYSQL_PASSWORD",
+			"OPTIONAL_TOKEN=vault:secret/data/test/optional#TOKEN",
+		},
+		"file": {
+			"AWS_SECRET_ACCESS_KEY_ID=file:secret/data/test/aws",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		secrets    []provider.Secret
+		loadErr    error
+		wantResult *loadResult
+	}{
+		{
+			name: "Missing references are skipped",
+			secrets: []provider.Secret{
+				{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t"},
+				{Key: "AWS_SECRET_ACCESS_KEY_ID", Value: "secretId"},
+			},
+			wantResult: &loadResult{
+				Succeeded: 2,
+				Skipped:   1,
+				References: []referenceResult{
+					{Key: "AWS_SECRET_ACCESS_KEY_ID", Provider: "file", Status: referenceSucceeded},
+					{Key: "MYSQL_PASSWORD", Provider: "vault", Status: referenceSucceeded},
+					{Key: "OPTIONAL_TOKEN", Provider: "vault", Status: referenceSkipped},
+				},
+			},
+		},
+		{
+			name: "References of failed providers are failed",
+			loadErr: errors.Join(&providerError{
+				providerName: "vault",
+				err:          fmt.Errorf("failed to load secrets for provider vault: permission denied"),
+			}),
+			wantResult: &loadResult{
+				Skipped: 1,
+				Failed:  2,
+				References: []referenceResult{
+					{Key: "AWS_SECRET_ACCESS_KEY_ID", Provider: "file", Status: referenceSkipped},
+					{Key: "MYSQL_PASSWORD", Provider: "vault", Status: referenceFailed},
+					{Key: "OPTIONAL_TOKEN", Provider: "vault", Status: referenceFailed},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			result := newLoadResult(secretReferences, ttp.secrets, ttp.loadErr)
+
+			assert.Equal(t, ttp.wantResult, result, "Unexpected result")
+		})
+	}
+}
+
+func TestWriteLoadResult(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	result := newLoadResult(
+		map[string][]string{"vault": {"MYSQL_PASSWORD=vault:secret/data/test/mysql#MYSQL_PASSWORD"}},
+		[]provider.Secret{{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t"}},
+		nil,
+	)
+
+	err := writeLoadResult(path, result)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "3xtr3ms3cr3t", "Secret value leaked into the result file")
+
+	var writtenResult loadResult
+	err = json.Unmarshal(content, &writtenResult)
+	require.NoError(t, err)
+	assert.Equal(t, result, &writtenResult, "Unexpected result")
+}
@@ -0,0 +1,59 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// writeSecretFiles writes each secret with a target path to that path, and each other binary secret
+// to a file named after its key in the files directory.
+// Binary secrets can't be delivered any other way, so they fail the startup without a files directory.
+func writeSecretFiles(dir string, providerSecrets []provider.Secret) error {
+	for _, secret := range providerSecrets {
+		path := secret.FilePath
+		if path == "" {
+			if !secret.Binary {
+				continue
+			}
+
+			if dir == "" {
+				return fmt.Errorf("secret %s is binary and can only be written to a file, %s must be set", secret.Key, common.FilesDirEnv)
+			}
+
+			path = filepath.Join(dir, secret.Key)
+		}
+
+		err := os.MkdirAll(filepath.Dir(path), 0o700)
+		if err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+		}
+
+		err = writeFileAtomically(path, []byte(secret.Value))
+		if err != nil {
+			return err
+		}
+
+		slog.Info("secret written to file", slog.String("key", secret.Key), slog.String("path", path))
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestWriteSecretFiles(t *testing.T) {
+	providerSecrets := []provider.Secret{
+		{Key: "KEYSTORE", Value: "\x00\x01\x02\x03", Binary: true},
+		{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t"},
+	}
+
+	tests := []struct {
+		name      string
+		filesMode bool
+		err       error
+	}{
+		{
+			name:      "Write binary secrets to files",
+			filesMode: true,
+		},
+		{
+			name: "Fail on binary secret without files directory",
+			err:  fmt.Errorf("secret KEYSTORE is binary and can only be written to a file, SECRET_INIT_FILES_DIR must be set"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			var dir string
+			if ttp.filesMode {
+				dir = filepath.Join(t.TempDir(), "secrets")
+			}
+
+			err := writeSecretFiles(dir, providerSecrets)
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.Nil(t, err, "Unexpected error")
+
+			content, err := os.ReadFile(filepath.Join(dir, "KEYSTORE"))
+			require.NoError(t, err)
+			assert.Equal(t, "\x00\x01\x02\x03", string(content), "Unexpected file content")
+
+			fileInfo, err := os.Stat(filepath.Join(dir, "KEYSTORE"))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), fileInfo.Mode().Perm(), "Unexpected file permissions")
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1, "Only binary secrets must be written to files")
+		})
+	}
+}
+
+func TestWriteSecretFilesTargetPath(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "etc", "tls", "tls.crt")
+	keystorePath := filepath.Join(dir, "keystore.p12")
+
+	err := writeSecretFiles("", []provider.Secret{
+		{Key: "TLS_CRT", Value: "-----BEGIN CERTIFICATE-----", FilePath: certPath},
+		{Key: "KEYSTORE", Value: "\x00\x01\x02\x03", Binary: true, FilePath: keystorePath},
+		{Key: "MYSQL_PASSWORD", Value: "3xtr3ms3cr3t"},
+	})
+	require.NoError(t, err)
+
+	for path, want := range map[string]string{certPath: "-----BEGIN CERTIFICATE-----", keystorePath: "\x00\x01\x02\x03"} {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(content), "Unexpected file content")
+
+		fileInfo, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fileInfo.Mode().Perm(), "Unexpected file permissions")
+	}
+
+	dirInfo, err := os.Stat(filepath.Dir(certPath))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), dirInfo.Mode().Perm(), "Unexpected directory permissions")
+}
@@ -0,0 +1,88 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+// selftestCommand checks the provider registration of a build, without reaching out to any backend
+const selftestCommand = "selftest"
+
+// selftestReferences are canonical references of the compiled-in providers, by provider type
+var selftestReferences = map[string]string{
+	"file":    "file:/secrets/db/password",
+	"vault":   "vault:secret/data/db#password",
+	"bao":     "bao:secret/data/db#password",
+	"aws":     "arn:aws:secretsmanager:eu-west-1:123456789012:secret:db/password",
+	"gcp":     "gcp:secretmanager:projects/123456789012/secrets/db-password",
+	"gcs":     "gcs:secrets/db/password",
+	"azure":   "azure:keyvault:db-password",
+	"k8s":     "k8s:default/db#password",
+	"conjur":  "conjur:prod/db/password",
+	"doppler": "doppler:prd/DB_PASSWORD",
+	"sops":    "sops:/secrets/app.enc.yaml#db.password",
+}
+
+// runSelftest routes the canonical reference of each registered provider and reports OK or FAIL per provider.
+// A provider fails if it has no canonical reference, or if its reference is not routed to it alone.
+func runSelftest(w io.Writer, factories []provider.Factory) error {
+	var errs error
+	for _, factory := range factories {
+		err := selftestFactory(factory, factories)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("provider %s: %w", factory.ProviderType, err))
+			fmt.Fprintf(w, "FAIL %s: %s\n", factory.ProviderType, err)
+
+			continue
+		}
+
+		fmt.Fprintf(w, "OK   %s\n", factory.ProviderType)
+	}
+
+	return errs
+}
+
+func selftestFactory(factory provider.Factory, factories []provider.Factory) error {
+	reference, ok := selftestReferences[factory.ProviderType]
+	if !ok {
+		return errors.New("no canonical reference")
+	}
+
+	if !factory.Validator(reference) {
+		return fmt.Errorf("reference %s is not valid", reference)
+	}
+
+	// Routed the same way as references found in the environment
+	envStore := &EnvStore{
+		data:      map[string]string{"SELFTEST": reference},
+		appConfig: &common.Config{},
+		factories: factories,
+	}
+
+	routed := slices.Sorted(maps.Keys(envStore.GetSecretReferences()))
+	if !slices.Equal(routed, []string{factory.ProviderType}) {
+		return fmt.Errorf("reference %s is routed to %v", reference, routed)
+	}
+
+	return nil
+}
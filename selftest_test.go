@@ -0,0 +1,78 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+)
+
+func TestRunSelftest(t *testing.T) {
+	var buf bytes.Buffer
+	err := runSelftest(&buf, factories)
+	assert.NoError(t, err, "Registered providers should pass the selftest")
+
+	for _, factory := range factories {
+		assert.Contains(t, buf.String(), "OK   "+factory.ProviderType+"\n", "Missing report of provider %s", factory.ProviderType)
+	}
+}
+
+func TestRunSelftestFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		factories []provider.Factory
+		err       error
+	}{
+		{
+			name: "Provider without canonical reference",
+			factories: []provider.Factory{
+				{ProviderType: "unknown", Validator: func(string) bool { return true }},
+			},
+			err: fmt.Errorf("provider unknown: no canonical reference"),
+		},
+		{
+			name: "Provider rejecting its canonical reference",
+			factories: []provider.Factory{
+				{ProviderType: "file", Validator: func(string) bool { return false }},
+			},
+			err: fmt.Errorf("provider file: reference file:/secrets/db/password is not valid"),
+		},
+		{
+			name: "Reference routed to several providers",
+			factories: []provider.Factory{
+				{ProviderType: "file", Validator: func(value string) bool { return strings.HasPrefix(value, "file:") }},
+				{ProviderType: "doppler", Validator: func(string) bool { return true }},
+			},
+			err: fmt.Errorf("provider file: reference file:/secrets/db/password is routed to [doppler file]"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := runSelftest(&buf, ttp.factories)
+
+			assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+			assert.Contains(t, buf.String(), "FAIL ", "Missing failure report")
+		})
+	}
+}
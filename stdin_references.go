@@ -0,0 +1,54 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var envKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// readReferenceLines reads newline-delimited KEY=reference pairs, e.g. piped from a shell.
+// Empty lines and lines starting with # are skipped.
+// The reader is consumed until EOF, so it can't be handed to the entrypoint process afterwards.
+func readReferenceLines(r io.Reader) (map[string]string, error) {
+	references := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, reference, ok := strings.Cut(line, "=")
+		if !ok || !envKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid reference on line %d: expected KEY=reference", lineNumber)
+		}
+
+		references[key] = reference
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read references: %w", err)
+	}
+
+	return references, nil
+}
@@ -0,0 +1,101 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+)
+
+func TestReadReferenceLines(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		wantReferences map[string]string
+		err            error
+	}{
+		{
+			name:  "Valid references",
+			input: "DB_PASSWORD=vault:secret/data/db#password\n\n# comment\n  DB_USER=file:secret/db/user  \nDB_PORT=vault:secret/data/db#port|5432",
+			wantReferences: map[string]string{
+				"DB_PASSWORD": "vault:secret/data/db#password",
+				"DB_USER":     "file:secret/db/user",
+				"DB_PORT":     "vault:secret/data/db#port|5432",
+			},
+		},
+		{
+			name:           "Empty input",
+			input:          "",
+			wantReferences: map[string]string{},
+		},
+		{
+			name:  "Missing separator",
+			input: "DB_PASSWORD=vault:secret/data/db#password\nvault:secret/data/db#user",
+			err:   fmt.Errorf("invalid reference on line 2: expected KEY=reference"),
+		},
+		{
+			name:  "Invalid key",
+			input: "DB-PASSWORD=vault:secret/data/db#password",
+			err:   fmt.Errorf("invalid reference on line 1: expected KEY=reference"),
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			references, err := readReferenceLines(strings.NewReader(ttp.input))
+			if ttp.err != nil {
+				assert.EqualError(t, err, ttp.err.Error(), "Unexpected error message")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, ttp.wantReferences, references, "Unexpected references")
+		})
+	}
+}
+
+func TestEnvStore_AddReferencesFromPipe(t *testing.T) {
+	t.Setenv("DB_USER", "file:secret/db/user")
+	t.Cleanup(func() {
+		os.Clearenv()
+	})
+
+	reader, writer := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(writer, "DB_PASSWORD=vault:secret/data/db#password\n")
+		_, _ = io.WriteString(writer, "DB_USER=file:secret/db/admin\n")
+		writer.Close()
+	}()
+
+	references, err := readReferenceLines(reader)
+	require.NoError(t, err)
+
+	envStore := NewEnvStore(&common.Config{})
+	envStore.AddReferences(references)
+
+	assert.Equal(t, map[string][]string{
+		"vault": {"DB_PASSWORD=vault:secret/data/db#password"},
+		"file":  {"DB_USER=file:secret/db/admin"},
+	}, envStore.GetSecretReferences(), "Piped references must be merged, overriding the environment")
+}
@@ -0,0 +1,51 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// loadSummary counts the injected secrets for pipeline assertions, neither keys nor values are recorded.
+type loadSummary struct {
+	Providers  map[string]int `json:"providers"`
+	Total      int            `json:"total"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+func newLoadSummary(loadedCounts map[string]int, duration time.Duration) *loadSummary {
+	summary := &loadSummary{
+		Providers:  make(map[string]int, len(loadedCounts)),
+		DurationMs: duration.Milliseconds(),
+	}
+	for providerName, count := range loadedCounts {
+		summary.Providers[providerName] = count
+		summary.Total += count
+	}
+
+	return summary
+}
+
+// writeLoadSummary writes the summary of a successful secret loading as JSON.
+func writeLoadSummary(path string, summary *loadSummary) error {
+	content, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal load summary: %w", err)
+	}
+
+	return writeFileAtomically(path, append(content, '\n'))
+}
@@ -0,0 +1,116 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// syslogBufferSize is the number of messages kept while the log server is unreachable, the oldest are dropped first
+	syslogBufferSize = 256
+
+	syslogDialTimeout    = time.Second
+	syslogReconnectDelay = 5 * time.Second
+	// syslogWriteTimeout keeps a stalled server (e.g. a full TCP window) from blocking the logging of the process
+	syslogWriteTimeout = time.Second
+)
+
+// syslogWriter sends log messages to the log server, reconnecting when the connection fails.
+// Messages written while the server is unreachable (e.g. before it is up) are buffered and sent once
+// a connection is made. Writes never fail, logging must not get in the way of the process.
+type syslogWriter struct {
+	network string
+	addr    string
+	dial    func(network string, addr string, timeout time.Duration) (net.Conn, error)
+	now     func() time.Time
+	// writeTimeout is the deadline of each write, a write timing out drops the connection like a failed one
+	writeTimeout time.Duration
+
+	mu sync.Mutex
+	// conn is nil while disconnected, no dial is attempted before retryAt then
+	conn    net.Conn
+	retryAt time.Time
+	pending [][]byte
+}
+
+func newSyslogWriter(network string, addr string) *syslogWriter {
+	return &syslogWriter{
+		network: network,
+		addr:    addr,
+		dial:    net.DialTimeout,
+		now:     time.Now,
+
+		writeTimeout: syslogWriteTimeout,
+	}
+}
+
+// Write buffers the message and sends all the buffered ones if connected.
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	message := slices.Clone(p)
+	// Messages on a stream are delimited by newlines (RFC 6587 non-transparent framing)
+	if w.network != "udp" && (len(message) == 0 || message[len(message)-1] != '\n') {
+		message = append(message, '\n')
+	}
+
+	w.pending = append(w.pending, message)
+	if len(w.pending) > syslogBufferSize {
+		w.pending = w.pending[len(w.pending)-syslogBufferSize:]
+	}
+
+	w.flush()
+
+	return len(p), nil
+}
+
+// flush sends the buffered messages in order, the unsent ones stay buffered if the connection fails.
+func (w *syslogWriter) flush() {
+	if w.conn == nil {
+		if w.now().Before(w.retryAt) {
+			return
+		}
+
+		conn, err := w.dial(w.network, w.addr, syslogDialTimeout)
+		if err != nil {
+			w.retryAt = w.now().Add(syslogReconnectDelay)
+			return
+		}
+
+		w.conn = conn
+	}
+
+	for len(w.pending) > 0 {
+		// The deadline is set on the socket, so it follows the wall clock rather than w.now
+		err := w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+		if err == nil {
+			_, err = w.conn.Write(w.pending[0])
+		}
+		if err != nil {
+			w.conn.Close()
+			w.conn = nil
+			w.retryAt = w.now().Add(syslogReconnectDelay)
+
+			return
+		}
+
+		w.pending = w.pending[1:]
+	}
+}
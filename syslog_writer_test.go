@@ -0,0 +1,171 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSyslogConn records the messages written to it, it fails writes once broken
+type fakeSyslogConn struct {
+	net.Conn
+	messages []string
+	broken   bool
+	closed   bool
+	// deadlines records the write deadlines set before each write
+	deadlines int
+}
+
+func (c *fakeSyslogConn) SetWriteDeadline(_ time.Time) error {
+	c.deadlines++
+
+	return nil
+}
+
+func (c *fakeSyslogConn) Write(p []byte) (int, error) {
+	if c.broken {
+		return 0, errors.New("broken pipe")
+	}
+
+	c.messages = append(c.messages, string(p))
+
+	return len(p), nil
+}
+
+func (c *fakeSyslogConn) Close() error {
+	c.closed = true
+
+	return nil
+}
+
+// newFakeSyslogWriter serves the connections in order, a nil connection fails the dial
+func newFakeSyslogWriter(network string, conns ...*fakeSyslogConn) (*syslogWriter, *time.Time, *int) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dials := 0
+
+	writer := newSyslogWriter(network, "syslog:514")
+	writer.now = func() time.Time { return now }
+	writer.dial = func(_ string, _ string, _ time.Duration) (net.Conn, error) {
+		conn := conns[dials]
+		dials++
+		if conn == nil {
+			return nil, fmt.Errorf("dial tcp: connection refused")
+		}
+
+		return conn, nil
+	}
+
+	return writer, &now, &dials
+}
+
+func TestSyslogWriterReconnect(t *testing.T) {
+	conn := &fakeSyslogConn{}
+	writer, now, dials := newFakeSyslogWriter("udp", nil, conn)
+
+	// The server is unreachable at first, the messages are buffered
+	n, err := writer.Write([]byte("first"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n, "Unexpected written length")
+
+	_, err = writer.Write([]byte("second"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, *dials, "Dialing must wait for the reconnect delay")
+
+	// Once the server is up, the buffered messages are sent in order
+	*now = now.Add(syslogReconnectDelay)
+	_, err = writer.Write([]byte("third"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, conn.messages, "Unexpected messages")
+	assert.Equal(t, 3, conn.deadlines, "Every write must have a deadline")
+	assert.Equal(t, 2, *dials, "Unexpected number of dials")
+}
+
+func TestSyslogWriterBrokenConnection(t *testing.T) {
+	conn := &fakeSyslogConn{}
+	reconnected := &fakeSyslogConn{}
+	writer, now, _ := newFakeSyslogWriter("tcp", conn, reconnected)
+
+	_, err := writer.Write([]byte("first"))
+	require.NoError(t, err)
+
+	// A failed write drops the connection, the message is sent again over the next one
+	conn.broken = true
+	_, err = writer.Write([]byte("second"))
+	require.NoError(t, err)
+	assert.True(t, conn.closed, "Broken connection must be closed")
+
+	*now = now.Add(syslogReconnectDelay)
+	_, err = writer.Write([]byte("third\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"first\n"}, conn.messages, "Unexpected messages on the broken connection")
+	assert.Equal(t, []string{"second\n", "third\n"}, reconnected.messages, "Unexpected messages after reconnecting")
+}
+
+func TestSyslogWriterBufferLimit(t *testing.T) {
+	conn := &fakeSyslogConn{}
+	writer, now, _ := newFakeSyslogWriter("udp", nil, conn)
+
+	for i := range syslogBufferSize + 2 {
+		_, err := writer.Write([]byte(fmt.Sprintf("message %d", i)))
+		require.NoError(t, err)
+	}
+
+	*now = now.Add(syslogReconnectDelay)
+	_, err := writer.Write([]byte("last"))
+	require.NoError(t, err)
+
+	require.Len(t, conn.messages, syslogBufferSize, "Buffer must be capped")
+	assert.Equal(t, "message 3", conn.messages[0], "Oldest messages must be dropped first")
+	assert.Equal(t, "last", conn.messages[syslogBufferSize-1], "Unexpected last message")
+}
+
+func TestSyslogWriterWriteTimeout(t *testing.T) {
+	// Nothing reads from the server side of the pipe, so writes block like on a stalled server
+	client, server := net.Pipe()
+	defer server.Close()
+
+	reconnected := &fakeSyslogConn{}
+	writer, now, _ := newFakeSyslogWriter("tcp", reconnected)
+	writer.writeTimeout = 50 * time.Millisecond
+	writer.conn = client
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = writer.Write([]byte("first"))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write must not block on a stalled server")
+	}
+
+	// The timed out connection is dropped and the message is sent again over the next one
+	assert.Nil(t, writer.conn, "Timed out connection must be dropped")
+
+	*now = now.Add(syslogReconnectDelay)
+	_, err := writer.Write([]byte("second"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first\n", "second\n"}, reconnected.messages, "Unexpected messages after reconnecting")
+}
@@ -0,0 +1,171 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/provider/bao"
+	"github.com/bank-vaults/secret-init/pkg/provider/vault"
+	"github.com/bank-vaults/secret-init/pkg/template"
+)
+
+// templateProviderType is a meta-provider that renders values with inline references of several providers,
+// e.g. scheme://${vault:secret/data/db#user}:${arn:aws:secretsmanager:eu-west-1:123456789012:secret:db}@db
+const templateProviderType = "template"
+
+// The injectors of these providers render inline references themselves,
+// values referring to only one of them are handed over as they are.
+var inlineProviders = []string{vault.ProviderType, bao.ProviderType}
+
+// templateReference is a value rendered from the secrets of its inline references.
+type templateReference struct {
+	key      string
+	template *template.Template
+	// subKeys maps the inline references to the keys they are loaded under
+	subKeys map[string]string
+}
+
+// isTemplateValue reports whether the inline references of a value are resolved by secret-init itself,
+// that is when they belong to more than one provider, or to a provider without inline references of its own.
+// Inline references of unknown providers don't count, e.g. a shell-style ${HOME} is left alone.
+func (s *EnvStore) isTemplateValue(value string) bool {
+	if !strings.Contains(value, "${") {
+		return false
+	}
+
+	tmpl, err := template.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	var providerNames []string
+	for _, reference := range tmpl.References() {
+		providerName, ok := s.referenceProvider(reference)
+		if ok && !slices.Contains(providerNames, providerName) {
+			providerNames = append(providerNames, providerName)
+		}
+	}
+
+	switch len(providerNames) {
+	case 0:
+		return false
+	case 1:
+		return !slices.Contains(inlineProviders, providerNames[0])
+	default:
+		return true
+	}
+}
+
+// expandTemplateReferences parses the template values and hands their inline references over to
+// the providers they belong to, so each provider resolves them in one batch along with its other references.
+func (s *EnvStore) expandTemplateReferences(providerPaths map[string][]string) ([]templateReference, map[string][]string, error) {
+	templatePaths, ok := providerPaths[templateProviderType]
+	if !ok {
+		return nil, providerPaths, nil
+	}
+
+	expandedPaths := make(map[string][]string, len(providerPaths))
+	for providerName, paths := range providerPaths {
+		if providerName != templateProviderType {
+			expandedPaths[providerName] = append([]string(nil), paths...)
+		}
+	}
+
+	templateReferences := make([]templateReference, 0, len(templatePaths))
+	for _, path := range templatePaths {
+		key, value, _ := strings.Cut(path, "=")
+
+		tmpl, err := template.Parse(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to render %s: %w", key, err)
+		}
+
+		groups, err := tmpl.GroupByProvider(s.referenceProvider)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to render %s: %w", key, err)
+		}
+
+		// Env var names can't contain dots, so the keys of the inline references can't clash with real ones
+		subKeys := make(map[string]string)
+		for i, reference := range tmpl.References() {
+			subKeys[reference] = fmt.Sprintf("%s.%d", key, i)
+		}
+
+		for providerName, references := range groups {
+			for _, reference := range references {
+				expandedPaths[providerName] = append(expandedPaths[providerName], fmt.Sprintf("%s=%s", subKeys[reference], reference))
+			}
+		}
+
+		templateReferences = append(templateReferences, templateReference{
+			key:      key,
+			template: tmpl,
+			subKeys:  subKeys,
+		})
+	}
+
+	return templateReferences, expandedPaths, nil
+}
+
+// renderTemplateSecrets replaces the secrets of inline references with the values rendered from them.
+func renderTemplateSecrets(providerSecrets []provider.Secret, templateReferences []templateReference) ([]provider.Secret, error) {
+	if len(templateReferences) == 0 {
+		return providerSecrets, nil
+	}
+
+	secretValues := make(map[string]string, len(providerSecrets))
+	for _, secret := range providerSecrets {
+		secretValues[secret.Key] = secret.Value
+	}
+
+	subKeys := make(map[string]bool)
+	renderedSecrets := make([]provider.Secret, 0, len(templateReferences))
+	for _, templateRef := range templateReferences {
+		values := make(map[string]string, len(templateRef.subKeys))
+		for reference, subKey := range templateRef.subKeys {
+			value, ok := secretValues[subKey]
+			if !ok {
+				continue
+			}
+
+			values[reference] = value
+			subKeys[subKey] = true
+		}
+
+		value, err := templateRef.template.Render(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", templateRef.key, err)
+		}
+
+		renderedSecrets = append(renderedSecrets, provider.Secret{
+			Key:      templateRef.key,
+			Value:    value,
+			Provider: templateProviderType,
+		})
+	}
+
+	secrets := make([]provider.Secret, 0, len(providerSecrets))
+	for _, secret := range providerSecrets {
+		if !subKeys[secret.Key] {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	return append(secrets, renderedSecrets...), nil
+}
@@ -0,0 +1,89 @@
+// Copyright © 2024 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bank-vaults/secret-init/pkg/common"
+	"github.com/bank-vaults/secret-init/pkg/provider"
+	"github.com/bank-vaults/secret-init/pkg/provider/aws"
+	"github.com/bank-vaults/secret-init/pkg/provider/vault"
+)
+
+func TestIsTemplateValue(t *testing.T) {
+	envStore := NewEnvStore(&common.Config{})
+	assert.True(t, envStore.isTemplateValue("scheme://${vault:secret/data/db#user}:${arn:aws:secretsmanager:eu-west-1:123456789012:secret:db}@db"))
+	assert.True(t, envStore.isTemplateValue("${file:/secrets/user}:${file:/secrets/password}"))
+	assert.False(t, envStore.isTemplateValue("scheme://${vault:secret/data/db#user}:${vault:secret/data/db#password}@db"), "Vault renders its own inline references")
+	assert.False(t, envStore.isTemplateValue("${HOME}/.config"), "Unknown references are left alone")
+	assert.False(t, envStore.isTemplateValue("file:/secrets/user"))
+}
+
+func TestExpandTemplateReferences(t *testing.T) {
+	providerPaths := map[string][]string{
+		templateProviderType: {"DB_URL=scheme://${vault:secret/data/db#user}:${arn:aws:secretsmanager:eu-west-1:123456789012:secret:db}@db/${vault:secret/data/db#name}"},
+		vault.ProviderType:   {"DB_USER=vault:secret/data/db#user"},
+	}
+
+	envStore := NewEnvStore(&common.Config{})
+	templateReferences, expandedPaths, err := envStore.expandTemplateReferences(providerPaths)
+	assert.Nil(t, err, "Unexpected error")
+
+	assert.Equal(t, map[string][]string{
+		vault.ProviderType: {"DB_USER=vault:secret/data/db#user", "DB_URL.0=vault:secret/data/db#user", "DB_URL.2=vault:secret/data/db#name"},
+		aws.ProviderType:   {"DB_URL.1=arn:aws:secretsmanager:eu-west-1:123456789012:secret:db"},
+	}, expandedPaths, "Unexpected provider paths")
+	assert.Len(t, templateReferences, 1)
+
+	secrets, err := renderTemplateSecrets([]provider.Secret{
+		{Key: "DB_USER", Value: "root", Provider: vault.ProviderType},
+		{Key: "DB_URL.0", Value: "root", Provider: vault.ProviderType},
+		{Key: "DB_URL.2", Value: "app", Provider: vault.ProviderType},
+		{Key: "DB_URL.1", Value: "p@ss", Provider: aws.ProviderType},
+	}, templateReferences)
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{
+		{Key: "DB_USER", Value: "root", Provider: vault.ProviderType},
+		{Key: "DB_URL", Value: "scheme://root:p@ss@db/app", Provider: templateProviderType},
+	}, secrets, "Unexpected secrets")
+
+	_, _, err = envStore.expandTemplateReferences(map[string][]string{
+		templateProviderType: {"DB_URL=${file:/secrets/user}:${unknown:password}"},
+	})
+	assert.EqualError(t, err, "failed to render DB_URL: reference unknown:password is not supported", "Unexpected error message")
+}
+
+func TestEnvStore_LoadProviderSecretsTemplated(t *testing.T) {
+	userFile := newSecretFile(t, "root")
+	passwordFile := newSecretFile(t, "p@ss")
+
+	value := fmt.Sprintf("postgres://${file:%s}:${file:%s}@db:5432", userFile, passwordFile)
+	t.Setenv("DB_URL", value)
+
+	envStore := NewEnvStore(&common.Config{})
+	secretReferences := envStore.GetSecretReferences()
+	assert.Equal(t, map[string][]string{templateProviderType: {"DB_URL=" + value}}, secretReferences, "Unexpected references")
+
+	providerSecrets, err := envStore.LoadProviderSecrets(context.Background(), secretReferences)
+	assert.Nil(t, err, "Unexpected error")
+	assert.Equal(t, []provider.Secret{
+		{Key: "DB_URL", Value: "postgres://root:p@ss@db:5432", Provider: templateProviderType},
+	}, providerSecrets, "Unexpected secrets")
+}